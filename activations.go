@@ -0,0 +1,103 @@
+package reticulum
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/nathanleary/reticulum/volume"
+)
+
+// ForwardWithActivations runs net in inference mode and returns its output along with
+// the output of every layer, keyed by layer name. The activations are copies, so they
+// remain valid after later forward passes. It requires a network built by this
+// package.
+func ForwardWithActivations(net Network, vol *volume.Volume) (*volume.Volume, map[string]*volume.Volume) {
+	n, ok := net.(*network)
+	if !ok {
+		panic("activations require a network built by NewNetwork or NewGraph")
+	}
+	return n.forwardWithActivations(vol)
+}
+
+// ActivationsOf runs net in inference mode and returns copies of the outputs of the
+// named layers, e.g. to extract embeddings from a hidden layer. It requires a network
+// built by this package.
+func ActivationsOf(net Network, vol *volume.Volume, names ...string) (map[string]*volume.Volume, error) {
+	n, ok := net.(*network)
+	if !ok {
+		return nil, errors.New("activations require a network built by NewNetwork or NewGraph")
+	}
+	return n.activationsOf(vol, names...)
+}
+
+func (n *network) forwardWithActivations(vol *volume.Volume) (*volume.Volume, map[string]*volume.Volume) {
+	acts := make(map[string]*volume.Volume, len(n.layers))
+	out := n.forwardActivations(vol, func(i int, act *volume.Volume) {
+		acts[n.names[i]] = act.Clone()
+	})
+	return out, acts
+}
+
+func (n *network) activationsOf(vol *volume.Volume, names ...string) (acts map[string]*volume.Volume, err error) {
+	wanted := map[int]bool{}
+	for _, name := range names {
+		i := n.layerIndex(name)
+		if i < 0 {
+			return nil, fmt.Errorf("unknown layer %q", name)
+		}
+		wanted[i] = true
+	}
+	if vol == nil {
+		return nil, errors.New("volume cannot be nil")
+	} else if len(n.inputDims) != 1 {
+		return nil, fmt.Errorf("network has %d inputs, use ForwardMulti", len(n.inputDims))
+	} else if dim := vol.Dimensions(); dim != n.inputDims[0] {
+		return nil, fmt.Errorf("volume dimensions %v do not match the input %v", dim, n.inputDims[0])
+	}
+	defer recoverError(&err)
+
+	acts = make(map[string]*volume.Volume, len(wanted))
+	n.forwardActivations(vol, func(i int, act *volume.Volume) {
+		if wanted[i] {
+			acts[n.names[i]] = act.Clone()
+		}
+	})
+	return acts, nil
+}
+
+// forwardActivations runs an inference forward pass, calling visit with the output
+// of every layer.
+func (n *network) forwardActivations(vol *volume.Volume, visit func(i int, act *volume.Volume)) *volume.Volume {
+	if n.graph != nil {
+		out := n.Forward(vol, false)
+		for i, act := range n.graph.outputs {
+			visit(i, act)
+		}
+		return out
+	}
+
+	ctx, pass := n.startPass("forward")
+	defer endSpan(pass)
+
+	out := vol
+	for i, layer := range n.layers {
+		if i == len(n.layers)-1 {
+			n.lossInput = out
+		}
+		span := n.beginLayer(ctx, i, false)
+		out = layer.Forward(out, false)
+		n.endLayer(i, span)
+		visit(i, out)
+	}
+	return out
+}
+
+// layerIndex returns the index of the named layer, or -1.
+func (n *network) layerIndex(name string) int {
+	for i, layerName := range n.names {
+		if layerName == name {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,123 @@
+package reticulum
+
+import (
+	"errors"
+	"math"
+)
+
+// AnnealingOptions configures simulated annealing.
+type AnnealingOptions struct {
+	Iterations int
+
+	// InitialTemp and FinalTemp bound the temperature, which is cooled geometrically
+	// from the first to the second over the iterations.
+	InitialTemp float64
+	FinalTemp   float64
+
+	// StepSize is the standard deviation of the change applied to a weight per move.
+	StepSize float64
+
+	// Moves is the number of randomly chosen weights changed per iteration. Defaults to 1.
+	Moves int
+
+	HasSeed bool
+	Seed    int64
+}
+
+// AnnealingResults summarises a simulated annealing run.
+type AnnealingResults struct {
+	BestReward float64
+	Accepted   int
+	Iterations int
+}
+
+// Anneal searches the weights of net with simulated annealing, maximising reward.
+// Worse candidates are accepted with probability exp(delta / temperature), so the
+// search can escape local optima early on and settles as the temperature drops.
+// It needs no gradients, which suits tiny networks and non-differentiable objectives
+// such as accuracy. The best weights found are left in net.
+func Anneal(net Network, reward RewardFunc, opts AnnealingOptions) (AnnealingResults, error) {
+	if net == nil {
+		return AnnealingResults{}, errors.New("network cannot be nil")
+	} else if reward == nil {
+		return AnnealingResults{}, errors.New("reward function cannot be nil")
+	} else if opts.Iterations <= 0 {
+		return AnnealingResults{}, errors.New("iterations must be greater than 0")
+	} else if opts.InitialTemp <= 0 || opts.FinalTemp <= 0 || opts.FinalTemp > opts.InitialTemp {
+		return AnnealingResults{}, errors.New("temperatures must satisfy 0 < final <= initial")
+	} else if opts.StepSize <= 0 {
+		return AnnealingResults{}, errors.New("step size must be greater than 0")
+	}
+	moves := opts.Moves
+	if moves <= 0 {
+		moves = 1
+	}
+
+	params := trainable(net)
+	var size int
+	for _, p := range params {
+		size += len(p.Weights)
+	}
+	if size == 0 {
+		return AnnealingResults{}, errors.New("network has no parameters")
+	}
+
+	rng := newRand(opts.HasSeed, opts.Seed)
+
+	// weight returns a pointer to the i-th weight across all parameter sets
+	weight := func(i int) *float64 {
+		for _, p := range params {
+			if i < len(p.Weights) {
+				return &p.Weights[i]
+			}
+			i -= len(p.Weights)
+		}
+		panic("weight index out of range")
+	}
+	snapshot := func() [][]float64 {
+		s := make([][]float64, len(params))
+		for i, p := range params {
+			s[i] = append([]float64(nil), p.Weights...)
+		}
+		return s
+	}
+
+	current := reward(net)
+	res := AnnealingResults{BestReward: current, Iterations: opts.Iterations}
+	best := snapshot()
+
+	cooling := math.Pow(opts.FinalTemp/opts.InitialTemp, 1/float64(opts.Iterations))
+	temp := opts.InitialTemp
+	indices := make([]int, moves)
+	previous := make([]float64, moves)
+	for k := 0; k < opts.Iterations; k++ {
+		for m := range indices {
+			indices[m] = rng.Intn(size)
+			w := weight(indices[m])
+			previous[m] = *w
+			*w += opts.StepSize * rng.NormFloat64()
+		}
+
+		candidate := reward(net)
+		delta := candidate - current
+		if delta >= 0 || rng.Float64() < math.Exp(delta/temp) {
+			current = candidate
+			res.Accepted++
+			if current > res.BestReward {
+				res.BestReward = current
+				best = snapshot()
+			}
+		} else {
+			// undo in reverse, the same weight may have been moved twice
+			for m := len(indices) - 1; m >= 0; m-- {
+				*weight(indices[m]) = previous[m]
+			}
+		}
+		temp *= cooling
+	}
+
+	for i, p := range params {
+		copy(p.Weights, best[i])
+	}
+	return res, nil
+}
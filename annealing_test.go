@@ -0,0 +1,43 @@
+package reticulum
+
+import "testing"
+
+func TestAnneal(t *testing.T) {
+	data := separable(32)
+	reward := accuracyReward(data)
+	opts := AnnealingOptions{Iterations: 500, InitialTemp: 0.1, FinalTemp: 0.001, StepSize: 0.05, Moves: 2, HasSeed: true, Seed: 1}
+	for _, test := range []struct {
+		name    string
+		net     Network
+		reward  RewardFunc
+		opts    AnnealingOptions
+		wantErr bool
+	}{
+		{"seeded", privacyNetwork(t, 4, 3), reward, opts, false},
+		{"nil network", nil, reward, opts, true},
+		{"nil reward", privacyNetwork(t, 4, 3), nil, opts, true},
+		{"no iterations", privacyNetwork(t, 4, 3), reward, AnnealingOptions{InitialTemp: 1, FinalTemp: 1, StepSize: 1}, true},
+		{"rising temperature", privacyNetwork(t, 4, 3), reward, AnnealingOptions{Iterations: 1, InitialTemp: 1, FinalTemp: 2, StepSize: 1}, true},
+		{"no step size", privacyNetwork(t, 4, 3), reward, AnnealingOptions{Iterations: 1, InitialTemp: 1, FinalTemp: 1}, true},
+	} {
+		var before float64
+		if test.net != nil {
+			before = reward(test.net)
+		}
+		res, err := Anneal(test.net, test.reward, test.opts)
+		if test.wantErr {
+			if err == nil {
+				t.Errorf("%s: expected an error", test.name)
+			}
+			continue
+		} else if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if got := reward(test.net); got <= before || got != res.BestReward {
+			t.Errorf("%s: reward %v after annealing, best %v, was %v", test.name, got, res.BestReward, before)
+		}
+		if res.Iterations != test.opts.Iterations {
+			t.Errorf("%s: %d iterations, want %d", test.name, res.Iterations, test.opts.Iterations)
+		}
+	}
+}
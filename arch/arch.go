@@ -0,0 +1,302 @@
+// Package arch builds the layer definitions of well known network architectures, so
+// standard topologies need not be written out by hand. Every builder checks the shapes
+// of the whole architecture for the given input and returns the first mismatch as an
+// error.
+//
+// Purely sequential architectures like LeNet5, VGG16 and MobileNet can be passed to
+// either reticulum.NewNetwork or reticulum.NewGraph, the residual networks and U-Net
+// only to NewGraph:
+//
+//	defs, err := arch.ResNet18(volume.NewDimensions(64, 64, 3), 10)
+//	if err != nil {
+//		return err
+//	}
+//	net, err := reticulum.NewGraph(defs)
+package arch
+
+import (
+	"fmt"
+
+	"github.com/nathanleary/reticulum/layers"
+	"github.com/nathanleary/reticulum/volume"
+)
+
+// LeNet5 returns the LeNet-5 network for 32x32 grayscale images, with tanh activations
+// and max pooling for the subsampling layers.
+func LeNet5(classes int) ([]layers.LayerDef, error) {
+	b := newBuilder(volume.NewDimensions(32, 32, 1))
+	b.conv("c1", 6, 5, 1, 0, layers.Tanh)
+	b.pool("s2", 2, 2, 0)
+	b.conv("c3", 16, 5, 1, 0, layers.Tanh)
+	b.pool("s4", 2, 2, 0)
+	b.fc("c5", 120, layers.Tanh, 0)
+	b.fc("f6", 84, layers.Tanh, 0)
+	b.softmax("output", classes)
+	return b.result()
+}
+
+// vggConfigs lists the filters of every conv layer of each VGG stage, after which the
+// stage is pooled.
+var vggConfigs = map[string][][]int{
+	"vgg11": {{64}, {128}, {256, 256}, {512, 512}, {512, 512}},
+	"vgg16": {{64, 64}, {128, 128}, {256, 256, 256}, {512, 512, 512}, {512, 512, 512}},
+}
+
+// VGG11 returns the 11 layer VGG network (configuration A). The input must be at
+// least 32x32 to survive its five pooling stages.
+func VGG11(input volume.Dimensions, classes int) ([]layers.LayerDef, error) {
+	return vgg(vggConfigs["vgg11"], input, classes)
+}
+
+// VGG16 returns the 16 layer VGG network (configuration D). The input must be at
+// least 32x32 to survive its five pooling stages.
+func VGG16(input volume.Dimensions, classes int) ([]layers.LayerDef, error) {
+	return vgg(vggConfigs["vgg16"], input, classes)
+}
+
+func vgg(stages [][]int, input volume.Dimensions, classes int) ([]layers.LayerDef, error) {
+	b := newBuilder(input)
+	for s, filters := range stages {
+		for i, f := range filters {
+			b.conv(fmt.Sprintf("conv%d_%d", s+1, i+1), f, 3, 1, 1, layers.ReLU)
+		}
+		b.pool(fmt.Sprintf("pool%d", s+1), 2, 2, 0)
+	}
+	b.fc("fc6", 4096, layers.ReLU, 0.5)
+	b.fc("fc7", 4096, layers.ReLU, 0.5)
+	b.softmax("output", classes)
+	return b.result()
+}
+
+// ResNet18 returns the 18 layer residual network. Each block adds its input back to
+// the output of two 3x3 convolutions, through a strided 1x1 convolution where the
+// block downsamples. There is no batch normalization, and the final average pooling
+// is a global max pooling.
+func ResNet18(input volume.Dimensions, classes int) ([]layers.LayerDef, error) {
+	return resnet([]int{2, 2, 2, 2}, input, classes)
+}
+
+// ResNet34 returns the 34 layer residual network, built like ResNet18 with more
+// blocks per stage.
+func ResNet34(input volume.Dimensions, classes int) ([]layers.LayerDef, error) {
+	return resnet([]int{3, 4, 6, 3}, input, classes)
+}
+
+func resnet(blocks []int, input volume.Dimensions, classes int) ([]layers.LayerDef, error) {
+	b := newBuilder(input)
+	b.conv("conv1", 64, 7, 2, 3, layers.ReLU)
+	x := b.pool("maxpool", 3, 2, 1)
+
+	filters := 64
+	for s, n := range blocks {
+		for i := 0; i < n; i++ {
+			stride := 1
+			if s > 0 && i == 0 {
+				stride = 2
+			}
+			x = b.basicBlock(fmt.Sprintf("layer%d.%d", s+1, i), x, filters, stride)
+		}
+		filters *= 2
+	}
+
+	b.globalPool("pool")
+	b.softmax("output", classes)
+	return b.result()
+}
+
+// basicBlock adds a residual block reading from the layer named in and returns the
+// name of its output.
+func (b *builder) basicBlock(name, in string, filters, stride int) string {
+	b.conv(name+".conv1", filters, 3, stride, 1, layers.ReLU, in)
+	branch := b.conv(name+".conv2", filters, 3, 1, 1, "")
+
+	shortcut := in
+	if dim, ok := b.dims[in]; ok && (stride != 1 || dim.Z != filters) {
+		shortcut = b.conv(name+".downsample", filters, 1, stride, 0, "", in)
+	}
+	return b.add(layers.LayerDef{Type: layers.Add, Name: name + ".add", Activation: layers.ReLU}, branch, shortcut)
+}
+
+// mobileNetBlocks holds the pointwise filters and depthwise stride of every depthwise
+// separable block of MobileNet.
+var mobileNetBlocks = []struct{ filters, stride int }{
+	{64, 1}, {128, 2}, {128, 1}, {256, 2}, {256, 1}, {512, 2},
+	{512, 1}, {512, 1}, {512, 1}, {512, 1}, {512, 1},
+	{1024, 2}, {1024, 1},
+}
+
+// MobileNet returns the MobileNet (v1) network: a strided 3x3 convolution followed by
+// 13 depthwise separable blocks, each a 3x3 depthwise convolution filtering every
+// channel on its own and a 1x1 convolution mixing them. There is no batch
+// normalization, and the final average pooling is a global max pooling. The input
+// must be at least 32x32 to survive its five strided stages.
+func MobileNet(input volume.Dimensions, classes int) ([]layers.LayerDef, error) {
+	b := newBuilder(input)
+	b.conv("conv1", 32, 3, 2, 1, layers.ReLU)
+	for i, block := range mobileNetBlocks {
+		b.separable(fmt.Sprintf("block%d", i+1), block.filters, block.stride)
+	}
+	b.globalPool("pool")
+	b.softmax("output", classes)
+	return b.result()
+}
+
+// separable adds a depthwise separable block, a 3x3 depthwise convolution with the
+// given stride followed by a 1x1 convolution with the given number of filters.
+func (b *builder) separable(name string, filters, stride int) string {
+	conf := layers.NewDepthwiseLayerConfig(3, layers.WithStride(stride), layers.WithPadding(1))
+	b.add(layers.LayerDef{Type: layers.Depthwise, Name: name + ".depthwise", Activation: layers.ReLU, LayerConfig: conf})
+	return b.conv(name+".pointwise", filters, 1, 1, 0, layers.ReLU)
+}
+
+// UNet returns a U-Net for segmenting the input into the given number of classes.
+// Each of the depth encoder levels applies two 3x3 convolutions and halves the width
+// and height, starting from 64 filters and doubling them per level. The decoder
+// mirrors it, upsampling and concatenating the encoder output of the same size before
+// its convolutions. A final 1x1 convolution yields the logits of every class at every
+// pixel, followed by a pixel-wise softmax loss named "output", so the output holds the
+// class probabilities of every pixel and the network trains on samples with a label
+// map. The width and height of the input must be divisible by 2^depth.
+func UNet(input volume.Dimensions, classes, depth int) ([]layers.LayerDef, error) {
+	if depth <= 0 {
+		return nil, fmt.Errorf("depth must be greater than 0, got %d", depth)
+	} else if classes <= 0 {
+		return nil, fmt.Errorf("class count must be greater than 0, got %d", classes)
+	} else if scale := 1 << depth; input.X%scale != 0 || input.Y%scale != 0 {
+		return nil, fmt.Errorf("input %dx%d is not divisible by %d for a depth of %d", input.X, input.Y, scale, depth)
+	}
+
+	b := newBuilder(input)
+	skips := make([]string, depth)
+	filters := 64
+	for l := 0; l < depth; l++ {
+		b.conv(fmt.Sprintf("down%d.conv1", l+1), filters, 3, 1, 1, layers.ReLU)
+		skips[l] = b.conv(fmt.Sprintf("down%d.conv2", l+1), filters, 3, 1, 1, layers.ReLU)
+		b.pool(fmt.Sprintf("down%d.pool", l+1), 2, 2, 0)
+		filters *= 2
+	}
+
+	b.conv("bottom.conv1", filters, 3, 1, 1, layers.ReLU)
+	b.conv("bottom.conv2", filters, 3, 1, 1, layers.ReLU)
+
+	for l := depth - 1; l >= 0; l-- {
+		filters /= 2
+		b.add(layers.LayerDef{Type: layers.Upsample, Name: fmt.Sprintf("up%d.upsample", l+1), LayerConfig: layers.NewUpsampleLayerConfig(2)})
+		up := b.conv(fmt.Sprintf("up%d.conv0", l+1), filters, 3, 1, 1, layers.ReLU)
+		b.add(layers.LayerDef{Type: layers.Concat, Name: fmt.Sprintf("up%d.concat", l+1)}, skips[l], up)
+		b.conv(fmt.Sprintf("up%d.conv1", l+1), filters, 3, 1, 1, layers.ReLU)
+		b.conv(fmt.Sprintf("up%d.conv2", l+1), filters, 3, 1, 1, layers.ReLU)
+	}
+
+	b.conv("head", classes, 1, 1, 0, "")
+	b.add(layers.LayerDef{Type: layers.PixelSoftMax, Name: "output"})
+	return b.result()
+}
+
+// builder collects the definitions of an architecture, computing the dimensions of
+// every layer as it is added. The first error stops the build.
+type builder struct {
+	defs []layers.LayerDef
+	dims map[string]volume.Dimensions
+	last string
+	err  error
+}
+
+func newBuilder(input volume.Dimensions) *builder {
+	b := &builder{dims: map[string]volume.Dimensions{}}
+	if input.Size() == 0 {
+		b.err = fmt.Errorf("invalid input dimensions %dx%dx%d", input.X, input.Y, input.Z)
+		return b
+	}
+	b.add(layers.LayerDef{Type: layers.Input, Name: "input", Output: input})
+	return b
+}
+
+// add appends def reading from the named layers, the previous one by default, and
+// returns its name. Inputs are only recorded on def when it does not read from the
+// previous layer alone, so sequential architectures stay valid for NewNetwork.
+func (b *builder) add(def layers.LayerDef, inputs ...string) string {
+	if b.err != nil {
+		return ""
+	} else if _, ok := b.dims[def.Name]; ok {
+		b.err = fmt.Errorf("duplicate layer name: %s", def.Name)
+		return ""
+	}
+
+	if def.Type != layers.Input {
+		if len(inputs) == 0 {
+			inputs = []string{b.last}
+		}
+		if len(inputs) > 1 || inputs[0] != b.last {
+			def.Inputs = inputs
+		}
+		for _, in := range inputs {
+			def.InputDims = append(def.InputDims, b.dims[in])
+		}
+		def.Input = def.InputDims[0]
+
+		out, err := layers.InferOutput(def)
+		if err != nil {
+			b.err = fmt.Errorf("layer %s (%s): %w", def.Name, def.Type, err)
+			return ""
+		}
+		def.Output = out
+
+		// the graph fills in the dimensions of merge inputs itself
+		def.InputDims = nil
+	}
+
+	b.defs = append(b.defs, def)
+	b.dims[def.Name] = def.Output
+	b.last = def.Name
+	return def.Name
+}
+
+// conv adds a size x size convolution, applying act unless it is empty.
+func (b *builder) conv(name string, filters, size, stride, pad int, act layers.LayerType, inputs ...string) string {
+	conf := layers.NewConvLayerConfig(filters, layers.WithSx(size), layers.WithStride(stride), layers.WithPadding(pad))
+	return b.add(layers.LayerDef{Type: layers.Conv, Name: name, Activation: act, LayerConfig: conf}, inputs...)
+}
+
+// pool adds a size x size max pooling.
+func (b *builder) pool(name string, size, stride, pad int) string {
+	conf := layers.NewPoolLayerConfig(size, layers.WithStride(stride), layers.WithPadding(pad))
+	return b.add(layers.LayerDef{Type: layers.Pool, Name: name, LayerConfig: conf})
+}
+
+// globalPool adds a max pooling over the whole width and height of the previous layer.
+func (b *builder) globalPool(name string) string {
+	if b.err != nil {
+		return ""
+	}
+	dim := b.dims[b.last]
+	conf := layers.NewPoolLayerConfig(dim.X, layers.WithSy(dim.Y), layers.WithStride(1))
+	return b.add(layers.LayerDef{Type: layers.Pool, Name: name, LayerConfig: conf})
+}
+
+// fc adds a fully connected layer, followed by dropout with probability p if p > 0.
+func (b *builder) fc(name string, neurons int, act layers.LayerType, p float64) string {
+	def := layers.LayerDef{Type: layers.FullyConnected, Name: name, Activation: act, LayerConfig: layers.NewFullyConnectedLayerConfig(neurons)}
+	if p > 0 {
+		def.Dropout = &layers.DropoutLayerConfig{DropoutProbability: p}
+	}
+	return b.add(def)
+}
+
+// softmax adds the classifier.
+func (b *builder) softmax(name string, classes int) string {
+	if b.err == nil && classes <= 0 {
+		b.err = fmt.Errorf("class count must be greater than 0, got %d", classes)
+	}
+	if b.err != nil {
+		return ""
+	}
+	return b.add(layers.LayerDef{Type: layers.SoftMax, Name: name, LayerConfig: layers.NewSoftmaxLayerConfig(classes)})
+}
+
+func (b *builder) result() ([]layers.LayerDef, error) {
+	if b.err != nil {
+		return nil, b.err
+	}
+	return b.defs, nil
+}
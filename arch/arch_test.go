@@ -0,0 +1,85 @@
+package arch
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/nathanleary/reticulum"
+	"github.com/nathanleary/reticulum/layers"
+	"github.com/nathanleary/reticulum/volume"
+)
+
+func TestBuilders(t *testing.T) {
+	rgb := volume.NewDimensions(32, 32, 3)
+	for _, test := range []struct {
+		name    string
+		build   func() ([]layers.LayerDef, error)
+		output  volume.Dimensions
+		wantErr bool
+	}{
+		{"lenet5", func() ([]layers.LayerDef, error) { return LeNet5(10) }, volume.NewDimensions(1, 1, 10), false},
+		{"vgg11", func() ([]layers.LayerDef, error) { return VGG11(rgb, 10) }, volume.NewDimensions(1, 1, 10), false},
+		{"resnet18", func() ([]layers.LayerDef, error) { return ResNet18(rgb, 10) }, volume.NewDimensions(1, 1, 10), false},
+		{"mobilenet", func() ([]layers.LayerDef, error) { return MobileNet(rgb, 10) }, volume.NewDimensions(1, 1, 10), false},
+		{"unet", func() ([]layers.LayerDef, error) { return UNet(volume.NewDimensions(16, 16, 1), 3, 2) }, volume.NewDimensions(16, 16, 3), false},
+		{"vgg11 small input", func() ([]layers.LayerDef, error) { return VGG11(volume.NewDimensions(16, 16, 3), 10) }, volume.Dimensions{}, true},
+		{"mobilenet no classes", func() ([]layers.LayerDef, error) { return MobileNet(rgb, 0) }, volume.Dimensions{}, true},
+		{"unet indivisible", func() ([]layers.LayerDef, error) { return UNet(volume.NewDimensions(10, 10, 1), 3, 2) }, volume.Dimensions{}, true},
+	} {
+		defs, err := test.build()
+		if test.wantErr {
+			if err == nil {
+				t.Errorf("%s: expected an error", test.name)
+			}
+			continue
+		} else if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if got := defs[len(defs)-1].Output; got != test.output {
+			t.Errorf("%s: output %v, want %v", test.name, got, test.output)
+		}
+	}
+}
+
+func TestMobileNet_UsesSeparableBlocks(t *testing.T) {
+	defs, err := MobileNet(volume.NewDimensions(32, 32, 3), 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var depthwise int
+	for i, def := range defs {
+		if def.Type != layers.Depthwise {
+			continue
+		}
+		depthwise++
+		if next := defs[i+1]; next.Type != layers.Conv || next.Output.X != def.Output.X {
+			t.Fatalf("%s is followed by %s %v, want a pointwise conv", def.Name, next.Type, next.Output)
+		}
+	}
+	if depthwise != 13 {
+		t.Fatalf("%d depthwise layers, want 13", depthwise)
+	}
+
+	// the network runs, and its compiled predictor agrees with it
+	net, err := reticulum.NewNetwork(defs, reticulum.WithNetworkSeed(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := reticulum.Compile(net, reticulum.InferenceOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := rand.New(rand.NewSource(1))
+	vol := volume.NewVolume(volume.NewDimensions(32, 32, 3), volume.WithRand(r))
+	want := net.Forward(vol, false).Weights()
+	got, err := p.Forward(vol)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, w := range want {
+		if math.Abs(got.Weights()[i]-w) > 1e-9 {
+			t.Fatalf("predictor output %d = %v, want %v", i, got.Weights()[i], w)
+		}
+	}
+}
@@ -0,0 +1,347 @@
+// Package autoencoder builds and trains fully connected autoencoders, networks which
+// learn to reconstruct their input through a narrow latent layer. The latent codes
+// serve for dimensionality reduction, and the reconstruction error of an input for
+// anomaly detection, as inputs unlike the training data reconstruct poorly:
+//
+//	ae, err := autoencoder.New(volume.NewDimensions(28, 28, 1), []int{128}, 16,
+//		autoencoder.WithLoss(autoencoder.BCE), autoencoder.WithTiedWeights())
+//	if err != nil {
+//		return err
+//	}
+//	losses, err := ae.Fit(images, 20, 32)
+//	code := ae.Encode(images[0])
+package autoencoder
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+
+	"github.com/nathanleary/reticulum"
+	"github.com/nathanleary/reticulum/layers"
+	"github.com/nathanleary/reticulum/volume"
+)
+
+// Loss is the reconstruction loss of an autoencoder.
+type Loss string
+
+const (
+	// MSE is the halved squared error of a linear reconstruction, for real valued
+	// inputs.
+	MSE Loss = "mse"
+
+	// BCE is the binary cross-entropy of a sigmoid reconstruction, for inputs in
+	// [0, 1] such as normalized pixels.
+	BCE Loss = "bce"
+)
+
+// OptionFunc modifies the Options of an autoencoder.
+type OptionFunc func(*Options)
+
+// Options configures an autoencoder.
+type Options struct {
+	// Activation is applied to the hidden layers of the encoder and decoder. The
+	// latent layer is linear.
+	Activation layers.LayerType
+
+	Loss Loss
+
+	// TiedWeights keeps the weights of every decoder layer equal to the transpose of
+	// those of the mirrored encoder layer. Both layers still hold their own copy of
+	// the weights and their own optimizer state, so the parameter count is unchanged:
+	// the decoder starts from the transposed encoder weights, and after every update
+	// both are set to the mean of the two. This approximates a single shared weight
+	// matrix, whose step would sum the gradients of both layers rather than average
+	// their separate updates. The biases are not tied.
+	TiedWeights bool
+
+	// TrainerOptions configure the trainer used by Fit.
+	TrainerOptions []reticulum.OptionFunc
+
+	HasSeed bool
+	Seed    int64
+}
+
+// WithActivation sets the activation of the hidden layers.
+func WithActivation(t layers.LayerType) OptionFunc {
+	return func(opts *Options) {
+		opts.Activation = t
+	}
+}
+
+// WithLoss sets the reconstruction loss.
+func WithLoss(loss Loss) OptionFunc {
+	return func(opts *Options) {
+		opts.Loss = loss
+	}
+}
+
+// WithTiedWeights keeps the weights of the decoder equal to the transpose of those of
+// the encoder, see Options.TiedWeights.
+func WithTiedWeights() OptionFunc {
+	return func(opts *Options) {
+		opts.TiedWeights = true
+	}
+}
+
+// WithTrainerOptions replaces the options of the trainer used by Fit.
+func WithTrainerOptions(opts ...reticulum.OptionFunc) OptionFunc {
+	return func(o *Options) {
+		o.TrainerOptions = opts
+	}
+}
+
+// WithSeed makes the initial weights and the order of the training data reproducible.
+func WithSeed(seed int64) OptionFunc {
+	return func(opts *Options) {
+		opts.HasSeed = true
+		opts.Seed = seed
+	}
+}
+
+// Autoencoder is an encoder of fully connected layers down to a latent layer, and a
+// decoder mirroring it back up to the size of the input, trained as one network. An
+// Autoencoder is not safe for concurrent use.
+type Autoencoder struct {
+	net     reticulum.Network
+	trainer reticulum.Trainer
+	opts    *Options
+	rng     *rand.Rand
+	input   volume.Dimensions
+
+	// tied pairs the names of every encoder layer with its mirrored decoder layer
+	tied [][2]string
+}
+
+// New creates an autoencoder for inputs of the given dimensions, with encoder layers
+// of the hidden sizes, a latent layer of latent units, and decoder layers of the
+// hidden sizes in reverse. The encoder layers are named "encoder1" and so on, the
+// latent layer "latent" and the decoder layers "decoder1" onwards.
+func New(input volume.Dimensions, hidden []int, latent int, optFuncs ...OptionFunc) (*Autoencoder, error) {
+	opts := &Options{
+		Activation: layers.ReLU,
+		Loss:       MSE,
+		TrainerOptions: []reticulum.OptionFunc{
+			reticulum.WithMethod(reticulum.Adam),
+			reticulum.WithLearningRate(0.001),
+		},
+	}
+	for _, optFn := range optFuncs {
+		optFn(opts)
+	}
+	if input.Size() == 0 {
+		return nil, fmt.Errorf("invalid input dimensions %dx%dx%d", input.X, input.Y, input.Z)
+	} else if latent <= 0 {
+		return nil, fmt.Errorf("latent size must be greater than 0, got %d", latent)
+	} else if opts.Loss != MSE && opts.Loss != BCE {
+		return nil, fmt.Errorf("unknown loss: %q", opts.Loss)
+	}
+	for _, n := range hidden {
+		if n <= 0 {
+			return nil, fmt.Errorf("hidden sizes must be greater than 0, got %v", hidden)
+		}
+	}
+
+	fc := func(name string, neurons int, activation layers.LayerType) layers.LayerDef {
+		return layers.LayerDef{Type: layers.FullyConnected, Name: name, Activation: activation, LayerConfig: layers.NewFullyConnectedLayerConfig(neurons)}
+	}
+	defs := []layers.LayerDef{{Type: layers.Input, Name: "input", Output: input}}
+	var encoder, decoder []string
+	for i, n := range hidden {
+		defs = append(defs, fc(fmt.Sprintf("encoder%d", i+1), n, opts.Activation))
+		encoder = append(encoder, defs[len(defs)-1].Name)
+	}
+	defs = append(defs, fc("latent", latent, ""))
+	encoder = append(encoder, "latent")
+	for i := range hidden {
+		defs = append(defs, fc(fmt.Sprintf("decoder%d", i+1), hidden[len(hidden)-1-i], opts.Activation))
+		decoder = append(decoder, defs[len(defs)-1].Name)
+	}
+	defs = append(defs, layers.LayerDef{Type: layers.Regression, Name: "output", LayerConfig: layers.NewRegressionLayerConfig(input.Size())})
+
+	var netOpts []reticulum.NetworkOptionFunc
+	if opts.HasSeed {
+		netOpts = append(netOpts, reticulum.WithNetworkSeed(opts.Seed))
+	}
+	net, err := reticulum.NewNetwork(defs, netOpts...)
+	if err != nil {
+		return nil, err
+	}
+	// the regression layer adds the fc layer reconstructing the input
+	decoder = append(decoder, reticulum.LayerName(net, net.Size()-2))
+
+	seed := rand.Int63()
+	if opts.HasSeed {
+		seed = opts.Seed
+	}
+	a := &Autoencoder{net: net, opts: opts, rng: rand.New(rand.NewSource(seed)), input: input}
+	trainerOpts := opts.TrainerOptions
+	if opts.TiedWeights {
+		for i, name := range encoder {
+			a.tied = append(a.tied, [2]string{name, decoder[len(decoder)-1-i]})
+		}
+		a.tie(false)
+		trainerOpts = append(append([]reticulum.OptionFunc(nil), trainerOpts...), reticulum.WithCallbacks(&tieCallback{a: a}))
+	}
+	if a.trainer, err = reticulum.NewTrainerE(net, trainerOpts...); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// Network returns the network of the autoencoder, ending in the reconstruction.
+func (a *Autoencoder) Network() reticulum.Network {
+	return a.net
+}
+
+// Trainer returns the trainer used by Fit.
+func (a *Autoencoder) Trainer() reticulum.Trainer {
+	return a.trainer
+}
+
+// Encode returns the latent code of the volume.
+func (a *Autoencoder) Encode(vol *volume.Volume) *volume.Volume {
+	acts, err := reticulum.ActivationsOf(a.net, vol, "latent")
+	if err != nil {
+		panic(err)
+	}
+	return acts["latent"]
+}
+
+// Reconstruct returns the reconstruction of the volume, with its dimensions.
+func (a *Autoencoder) Reconstruct(vol *volume.Volume) *volume.Volume {
+	out := a.net.Forward(vol, false).Weights()
+	rec := volume.NewVolume(a.input, volume.WithZeros())
+	w := rec.Weights()
+	for i, v := range out {
+		w[i] = v
+		if a.opts.Loss == BCE {
+			w[i] = sigmoid(v)
+		}
+	}
+	return rec
+}
+
+// ReconstructionError returns the reconstruction loss of the volume, averaged over
+// its values, e.g. as an anomaly score.
+func (a *Autoencoder) ReconstructionError(vol *volume.Volume) float64 {
+	rec := a.Reconstruct(vol).Weights()
+	var loss float64
+	for i, y := range vol.Weights() {
+		loss += pointLoss(a.opts.Loss, rec[i], y)
+	}
+	return loss / float64(len(rec))
+}
+
+// Fit trains the autoencoder to reconstruct data for the given number of epochs, in
+// shuffled mini-batches of batchSize volumes, and returns the mean reconstruction
+// loss of every epoch, averaged over the values of the inputs. Training stops early
+// if a callback of the trainer calls Stop.
+func (a *Autoencoder) Fit(data []*volume.Volume, epochs, batchSize int) ([]float64, error) {
+	if len(data) == 0 {
+		return nil, errors.New("data cannot be empty")
+	} else if epochs <= 0 || batchSize <= 0 {
+		return nil, fmt.Errorf("epochs and batch size must be greater than 0, got %d and %d", epochs, batchSize)
+	}
+	for i, vol := range data {
+		if vol.Size() != a.input.Size() {
+			return nil, fmt.Errorf("volume %d has %d values, expected %d", i, vol.Size(), a.input.Size())
+		}
+	}
+
+	var history []float64
+	for epoch := 0; epoch < epochs && !a.trainer.Stopped(); epoch++ {
+		var total float64
+		order := a.rng.Perm(len(data))
+		for start := 0; start < len(order); start += batchSize {
+			end := min(start+batchSize, len(order))
+			vols := make([]*volume.Volume, 0, end-start)
+			losses := make([]reticulum.LossFunc, 0, end-start)
+			for _, i := range order[start:end] {
+				vols = append(vols, data[i])
+				losses = append(losses, a.lossFunc(data[i]))
+			}
+			res, err := a.trainer.TrainBatchE(vols, losses)
+			if err != nil {
+				return history, err
+			}
+			total += res.CostLost * float64(len(vols))
+		}
+		loss := total / float64(len(data))
+		history = append(history, loss)
+		a.trainer.EndEpoch(epoch, reticulum.Metrics{"loss": loss})
+	}
+	return history, nil
+}
+
+// lossFunc returns the reconstruction loss of the volume, averaged over its values.
+// The regression layer computes the gradient output - target, so for BCE it is given
+// the target which makes that gradient sigmoid(output) - input, the gradient of the
+// cross-entropy with respect to the logits.
+func (a *Autoencoder) lossFunc(vol *volume.Volume) reticulum.LossFunc {
+	return func(net reticulum.Network) float64 {
+		x := vol.Weights()
+		n := float64(len(x))
+		y := make([]float64, len(x))
+		var loss float64
+		if a.opts.Loss == BCE {
+			out := reticulum.GetRegressionOutput(net)
+			for i, z := range out {
+				y[i] = z - (sigmoid(z) - x[i])
+				loss += pointLoss(BCE, sigmoid(z), x[i])
+			}
+			reticulum.MultiDimensionalLossWeighted(net, y, 1/n)
+			return loss / n
+		}
+		copy(y, x)
+		return reticulum.MultiDimensionalLossWeighted(net, y, 1/n)
+	}
+}
+
+func pointLoss(loss Loss, pred, target float64) float64 {
+	if loss == BCE {
+		const eps = 1e-12
+		return -target*math.Log(max(pred, eps)) - (1-target)*math.Log(max(1-pred, eps))
+	}
+	d := pred - target
+	return 0.5 * d * d
+}
+
+func sigmoid(x float64) float64 {
+	return 1 / (1 + math.Exp(-x))
+}
+
+// tie sets the weights of every tied encoder and decoder layer to the transpose of
+// each other, to their mean when average is set or to the encoder's otherwise.
+func (a *Autoencoder) tie(average bool) {
+	filters := map[string][][]float64{}
+	for _, resp := range a.net.GetResponse() {
+		if !resp.Bias {
+			filters[resp.LayerName] = append(filters[resp.LayerName], resp.Weights)
+		}
+	}
+	for _, pair := range a.tied {
+		enc, dec := filters[pair[0]], filters[pair[1]]
+		for j, w := range enc {
+			for i := range w {
+				if average {
+					w[i] = (w[i] + dec[i][j]) / 2
+				}
+				dec[i][j] = w[i]
+			}
+		}
+	}
+}
+
+// tieCallback ties the weights again after every update.
+type tieCallback struct {
+	reticulum.BaseCallback
+	a *Autoencoder
+}
+
+func (c *tieCallback) OnBatchEnd(t reticulum.Trainer, res reticulum.TrainingResults) {
+	if res.Updated {
+		c.a.tie(true)
+	}
+}
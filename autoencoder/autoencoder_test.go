@@ -0,0 +1,108 @@
+package autoencoder
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/nathanleary/reticulum/volume"
+)
+
+// points returns volumes of size values lying on a line, so they compress to one
+// latent unit.
+func points(n, size int) []*volume.Volume {
+	r := rand.New(rand.NewSource(1))
+	vols := make([]*volume.Volume, n)
+	for i := range vols {
+		t := r.Float64()
+		w := make([]float64, size)
+		for j := range w {
+			w[j] = t * float64(j+1) / float64(size)
+		}
+		vols[i] = volume.NewVolume(volume.NewDimensions(1, 1, size), volume.WithWeights(w))
+	}
+	return vols
+}
+
+func TestNew_Errors(t *testing.T) {
+	for _, test := range []struct {
+		name   string
+		input  volume.Dimensions
+		hidden []int
+		latent int
+		opts   []OptionFunc
+	}{
+		{"empty input", volume.Dimensions{}, []int{4}, 2, nil},
+		{"no latent units", volume.NewDimensions(1, 1, 8), []int{4}, 0, nil},
+		{"empty hidden layer", volume.NewDimensions(1, 1, 8), []int{4, 0}, 2, nil},
+		{"unknown loss", volume.NewDimensions(1, 1, 8), []int{4}, 2, []OptionFunc{WithLoss("hinge")}},
+	} {
+		if _, err := New(test.input, test.hidden, test.latent, test.opts...); err == nil {
+			t.Errorf("%s: expected an error", test.name)
+		}
+	}
+}
+
+func TestAutoencoder_Fit(t *testing.T) {
+	for _, test := range []struct {
+		name string
+		opts []OptionFunc
+	}{
+		{"mse", nil},
+		{"bce", []OptionFunc{WithLoss(BCE)}},
+		{"tied", []OptionFunc{WithTiedWeights()}},
+	} {
+		ae, err := New(volume.NewDimensions(1, 1, 8), []int{6}, 2, append(test.opts, WithSeed(1))...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data := points(64, 8)
+		before := ae.ReconstructionError(data[0])
+		losses, err := ae.Fit(data, 30, 8)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(losses) != 30 || losses[len(losses)-1] >= losses[0] {
+			t.Errorf("%s: losses did not decrease: %v", test.name, losses)
+		}
+		if after := ae.ReconstructionError(data[0]); after >= before {
+			t.Errorf("%s: reconstruction error %v, was %v before training", test.name, after, before)
+		}
+		if code := ae.Encode(data[0]); code.Size() != 2 {
+			t.Errorf("%s: latent code of %d values, want 2", test.name, code.Size())
+		}
+		if rec := ae.Reconstruct(data[0]); rec.Dimensions() != data[0].Dimensions() {
+			t.Errorf("%s: reconstruction dimensions %v, want %v", test.name, rec.Dimensions(), data[0].Dimensions())
+		}
+	}
+
+	if _, err := (&Autoencoder{}).Fit(nil, 1, 1); err == nil {
+		t.Error("expected an error fitting no data")
+	}
+}
+
+func TestAutoencoder_TiedWeightsStayTransposed(t *testing.T) {
+	ae, err := New(volume.NewDimensions(1, 1, 5), []int{4}, 3, WithTiedWeights(), WithSeed(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ae.Fit(points(16, 5), 3, 4); err != nil {
+		t.Fatal(err)
+	}
+
+	filters := map[string][][]float64{}
+	for _, resp := range ae.Network().GetResponse() {
+		if !resp.Bias {
+			filters[resp.LayerName] = append(filters[resp.LayerName], resp.Weights)
+		}
+	}
+	for _, pair := range ae.tied {
+		enc, dec := filters[pair[0]], filters[pair[1]]
+		for j, w := range enc {
+			for i := range w {
+				if w[i] != dec[i][j] {
+					t.Fatalf("%s[%d][%d] = %v, but %s[%d][%d] = %v", pair[0], j, i, w[i], pair[1], i, j, dec[i][j])
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,13 @@
+package reticulum
+
+import (
+	"github.com/nathanleary/reticulum/layers"
+)
+
+// SetBackend sets the backend computing the products of the fc and conv layers of
+// every network, e.g. the OpenBLAS or MKL backend of the cblas package, or restores
+// the default when b is nil. Set it before building or training networks:
+// it is not safe to change while a network is running.
+func SetBackend(b layers.Backend) {
+	layers.SetBackend(b)
+}
@@ -0,0 +1,116 @@
+package reticulum
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+	"sync"
+
+	"github.com/nathanleary/reticulum/layers"
+	"github.com/nathanleary/reticulum/volume"
+)
+
+// ForwardBatch runs net on every volume and returns the outputs in order. The volumes
+// are split across replicas of the network running in parallel, so the layers of the
+// network itself only hold the state of the first chunk and Backward should not be
+// called afterwards. Like Forward it is not safe for concurrent use. It requires a
+// network built by this package.
+func ForwardBatch(net Network, vols []*volume.Volume, training bool) []*volume.Volume {
+	n, ok := net.(*network)
+	if !ok {
+		panic("batches require a network built by NewNetwork or NewGraph")
+	}
+	return n.forwardBatch(vols, training)
+}
+
+// PredictBatch returns the class predicted by net for every volume, see ForwardBatch.
+func PredictBatch(net Network, vols []*volume.Volume) ([]int, error) {
+	n, ok := net.(*network)
+	if !ok {
+		return nil, errors.New("batches require a network built by NewNetwork or NewGraph")
+	}
+	return n.predictBatch(vols)
+}
+
+func (n *network) forwardBatch(vols []*volume.Volume, training bool) []*volume.Volume {
+	outs := make([]*volume.Volume, len(vols))
+	workers := min(runtime.GOMAXPROCS(0), len(vols))
+	if workers <= 1 {
+		for i, vol := range vols {
+			outs[i] = n.Forward(vol, training)
+		}
+		return outs
+	}
+
+	// replicas are kept between calls, but their weights must be refreshed as the
+	// network may have been trained in the meantime, and their passes traced under
+	// the current training step
+	for len(n.replicas) < workers-1 {
+		n.replicas = append(n.replicas, n.clone())
+	}
+	for _, replica := range n.replicas[:workers-1] {
+		if _, err := CopyWeightsByName(replica, n); err != nil {
+			panic(err)
+		}
+		if r, ok := replica.(*network); ok {
+			r.traceCtx = n.traceCtx
+		}
+	}
+
+	chunk := (len(vols) + workers - 1) / workers
+	var wg sync.WaitGroup
+	var panicked any
+	var once sync.Once
+	for w := 0; w < workers; w++ {
+		start, end := w*chunk, min((w+1)*chunk, len(vols))
+		if start >= end {
+			break
+		}
+		net := Network(n)
+		if w > 0 {
+			net = n.replicas[w-1]
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() {
+				if r := recover(); r != nil {
+					once.Do(func() { panicked = r })
+				}
+			}()
+			for i := start; i < end; i++ {
+				outs[i] = net.Forward(vols[i], training)
+			}
+		}()
+	}
+	wg.Wait()
+
+	// surface layer panics on the calling goroutine, as Forward would
+	if panicked != nil {
+		panic(panicked)
+	}
+	return outs
+}
+
+func (n *network) predictBatch(vols []*volume.Volume) ([]int, error) {
+	last := n.layers[n.Size()-1]
+	if last.Type() != layers.SoftMax && last.Type() != layers.SVM {
+		return nil, fmt.Errorf("prediction requires a softmax or svm output layer, got %s", last.Type())
+	} else if len(n.inputDims) != 1 {
+		return nil, fmt.Errorf("network has %d inputs, use ForwardMulti", len(n.inputDims))
+	}
+	for i, vol := range vols {
+		if vol == nil {
+			return nil, fmt.Errorf("volume %d cannot be nil", i)
+		} else if dim := vol.Dimensions(); dim != n.inputDims[0] {
+			return nil, fmt.Errorf("volume %d: dimensions %v do not match the input %v", i, dim, n.inputDims[0])
+		}
+	}
+
+	preds := make([]int, len(vols))
+	for i, out := range n.forwardBatch(vols, false) {
+		preds[i] = argmax(out.Weights())
+	}
+	return preds, nil
+}
@@ -0,0 +1,66 @@
+package reticulum
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/nathanleary/reticulum/layers"
+	"github.com/nathanleary/reticulum/volume"
+)
+
+func TestNetwork_ForwardBatchWithPreallocatedBuffers(t *testing.T) {
+	for _, test := range []struct {
+		name string
+		loss layers.LayerDef
+	}{
+		{"svm", layers.LayerDef{Type: layers.SVM, LayerConfig: layers.NewSVMLayerConfig(3)}},
+		{"regression", layers.LayerDef{Type: layers.Regression, LayerConfig: layers.NewRegressionLayerConfig(3)}},
+	} {
+		defs := []layers.LayerDef{
+			{Type: layers.Input, Output: volume.NewDimensions(1, 1, 4)},
+			{Type: layers.FullyConnected, LayerConfig: layers.NewFullyConnectedLayerConfig(5)},
+			{Type: layers.ReLU},
+			test.loss,
+		}
+		net, err := NewNetwork(defs, WithNetworkSeed(1), WithPreallocatedBuffers())
+		if err != nil {
+			t.Fatal(err)
+		}
+		ref, err := NewNetwork(defs, WithNetworkSeed(1))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		r := rand.New(rand.NewSource(1))
+		vols := make([]*volume.Volume, 8)
+		for i := range vols {
+			vols[i] = randomInput(r, 4)
+		}
+		outs := ForwardBatch(net, vols, false)
+		for i, out := range outs {
+			want := ref.Forward(vols[i], false).Weights()
+			for j, w := range out.Weights() {
+				if w != want[j] {
+					t.Fatalf("%s: output %d = %v, want %v", test.name, i, out.Weights(), want)
+				}
+			}
+			if i > 0 && out == outs[i-1] {
+				t.Fatalf("%s: outputs %d and %d are the same volume", test.name, i-1, i)
+			}
+		}
+
+		if test.loss.Type != layers.SVM {
+			continue
+		}
+		preds, err := PredictBatch(net, vols)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i, pred := range preds {
+			ref.Forward(vols[i], false)
+			if want := GetPredictionFor(ref, layers.SVM); pred != want {
+				t.Errorf("%s: PredictBatch()[%d] = %d, want %d", test.name, i, pred, want)
+			}
+		}
+	}
+}
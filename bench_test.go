@@ -0,0 +1,109 @@
+package reticulum
+
+import (
+	"context"
+	"math/rand"
+	"testing"
+
+	"github.com/nathanleary/reticulum/layers"
+	"github.com/nathanleary/reticulum/volume"
+)
+
+// mnistEpoch is the number of samples in an epoch of the MNIST benchmarks, a sixtieth
+// of the MNIST training set to keep iterations short.
+const mnistEpoch = 1000
+
+// mnistSamples returns n samples shaped like MNIST digits, 28x28 gray images in 10
+// classes, each class a noisy blob at its own position.
+func mnistSamples(n int) []Sample {
+	r := rand.New(rand.NewSource(1))
+	samples := make([]Sample, n)
+	for i := range samples {
+		label := r.Intn(10)
+		vol := volume.NewVolume(volume.NewDimensions(28, 28, 1), volume.WithZeros())
+		cx, cy := 6+(label%5)*4, 8+(label/5)*12
+		for y := 0; y < 28; y++ {
+			for x := 0; x < 28; x++ {
+				v := r.Float64() * 0.2
+				if dx, dy := x-cx, y-cy; dx*dx+dy*dy < 16 {
+					v += 0.8
+				}
+				vol.Set(x, y, 0, v)
+			}
+		}
+		samples[i] = Sample{Input: vol, Label: label}
+	}
+	return samples
+}
+
+// mnistNetwork returns the convnet of ConvNetJS's MNIST demo.
+func mnistNetwork(b *testing.B, opts ...NetworkOptionFunc) Network {
+	net, err := NewNetwork([]layers.LayerDef{
+		{Type: layers.Input, Output: volume.NewDimensions(28, 28, 1)},
+		{Type: layers.Conv, Activation: layers.ReLU, LayerConfig: layers.NewConvLayerConfig(8, layers.WithSx(5), layers.WithPadding(2))},
+		{Type: layers.Pool, LayerConfig: layers.NewPoolLayerConfig(2, layers.WithStride(2))},
+		{Type: layers.Conv, Activation: layers.ReLU, LayerConfig: layers.NewConvLayerConfig(16, layers.WithSx(5), layers.WithPadding(2))},
+		{Type: layers.Pool, LayerConfig: layers.NewPoolLayerConfig(3, layers.WithStride(3))},
+		{Type: layers.SoftMax, LayerConfig: layers.NewSoftmaxLayerConfig(10)},
+	}, append([]NetworkOptionFunc{WithNetworkSeed(1)}, opts...)...)
+	if err != nil {
+		b.Fatal(err)
+	}
+	return net
+}
+
+func BenchmarkFCTrain(b *testing.B) {
+	net, err := NewNetwork([]layers.LayerDef{
+		{Type: layers.Input, Output: volume.NewDimensions(1, 1, 784)},
+		{Type: layers.FullyConnected, Activation: layers.ReLU, LayerConfig: layers.NewFullyConnectedLayerConfig(100)},
+		{Type: layers.FullyConnected, Activation: layers.ReLU, LayerConfig: layers.NewFullyConnectedLayerConfig(100)},
+		{Type: layers.SoftMax, LayerConfig: layers.NewSoftmaxLayerConfig(10)},
+	}, WithNetworkSeed(1))
+	if err != nil {
+		b.Fatal(err)
+	}
+	// the digits flattened into vectors
+	samples := mnistSamples(100)
+	for i := range samples {
+		in := volume.NewVolume(volume.NewDimensions(1, 1, 784), volume.WithZeros())
+		copy(in.Weights(), samples[i].Input.Weights())
+		samples[i].Input = in
+	}
+	trainer := NewTrainer(net, WithBatchSize(10), WithSeed(1))
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		s := samples[i%len(samples)]
+		trainer.Train(s.Input, LabeledLossFunc(s.Label))
+	}
+}
+
+// BenchmarkMNISTEpoch trains the ConvNetJS MNIST convnet for an epoch per
+// iteration. The CPU profile of
+//
+//	go test -run '^$' -bench MNISTEpoch -cpuprofile cpu.out
+//
+// carries the pprof labels of every layer, shown by `go tool pprof -tags cpu.out`.
+func BenchmarkMNISTEpoch(b *testing.B) {
+	samples := mnistSamples(mnistEpoch)
+	for _, bench := range []struct {
+		name string
+		opts []NetworkOptionFunc
+	}{
+		{"default", nil},
+		{"preallocated", []NetworkOptionFunc{WithPreallocatedBuffers()}},
+	} {
+		b.Run(bench.name, func(b *testing.B) {
+			net := mnistNetwork(b, append(bench.opts, WithProfileLabels(context.Background()))...)
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				_, err := Fit(net, samples, nil, FitOptions{Epochs: 1, BatchSize: 20, Options: []OptionFunc{WithSeed(1)}})
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,234 @@
+package reticulum
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/nathanleary/reticulum/layers"
+	"github.com/nathanleary/reticulum/volume"
+)
+
+// Builder assembles a sequential network layer by layer, filling in the input and
+// output dimensions and the layer configs:
+//
+//	net, err := NewBuilder().
+//		Input(28, 28, 1).
+//		Conv(16, 5, layers.WithStride(1), layers.WithPadding(2)).Relu().
+//		Pool(2).
+//		FC(64).Relu().
+//		Softmax(10).
+//		Build()
+//
+// The first error stops the build and is returned by Build.
+type Builder struct {
+	defs []layers.LayerDef
+	opts []NetworkOptionFunc
+	err  error
+}
+
+// NewBuilder creates a builder for a network created with the given options.
+func NewBuilder(opts ...NetworkOptionFunc) *Builder {
+	return &Builder{opts: opts}
+}
+
+// Input declares the size of the network input and must be the first layer.
+func (b *Builder) Input(x, y, z int) *Builder {
+	if b.err == nil && len(b.defs) > 0 {
+		b.err = errors.New("input must be the first layer")
+	} else if x <= 0 || y <= 0 || z <= 0 {
+		b.fail(fmt.Errorf("invalid input dimensions %dx%dx%d", x, y, z))
+	}
+	return b.add(layers.LayerDef{Type: layers.Input, Output: volume.NewDimensions(x, y, z)})
+}
+
+// Conv adds a convolutional layer with the given number of size x size filters.
+func (b *Builder) Conv(filters, size int, opts ...layers.LayerOptionFunc) *Builder {
+	return b.addConfig(layers.Conv, func() layers.LayerConfig {
+		return layers.NewConvLayerConfig(filters, append([]layers.LayerOptionFunc{layers.WithSx(size)}, opts...)...)
+	})
+}
+
+// Depthwise adds a depthwise conv layer with a size x size filter for every channel.
+func (b *Builder) Depthwise(size int, opts ...layers.LayerOptionFunc) *Builder {
+	return b.addConfig(layers.Depthwise, func() layers.LayerConfig {
+		return layers.NewDepthwiseLayerConfig(size, opts...)
+	})
+}
+
+// Pool adds a max pooling layer with a size x size window, with a stride of 2 unless
+// set with layers.WithStride.
+func (b *Builder) Pool(size int, opts ...layers.LayerOptionFunc) *Builder {
+	return b.addConfig(layers.Pool, func() layers.LayerConfig {
+		return layers.NewPoolLayerConfig(size, opts...)
+	})
+}
+
+// Upsample adds a layer scaling the width and height by factor, repeating every value.
+func (b *Builder) Upsample(factor int) *Builder {
+	return b.addConfig(layers.Upsample, func() layers.LayerConfig {
+		return layers.NewUpsampleLayerConfig(factor)
+	})
+}
+
+// Embedding adds a layer looking up a vector of the given size for every token index
+// of its input, out of a vocabulary of the given size.
+func (b *Builder) Embedding(vocabulary, dimensions int, opts ...layers.LayerOptionFunc) *Builder {
+	return b.addConfig(layers.Embedding, func() layers.LayerConfig {
+		return layers.NewEmbeddingLayerConfig(vocabulary, dimensions, opts...)
+	})
+}
+
+// FC adds a fully connected layer.
+func (b *Builder) FC(neurons int, opts ...layers.LayerOptionFunc) *Builder {
+	return b.addConfig(layers.FullyConnected, func() layers.LayerConfig {
+		return layers.NewFullyConnectedLayerConfig(neurons, opts...)
+	})
+}
+
+// Relu applies a ReLU activation to the previous layer.
+func (b *Builder) Relu() *Builder {
+	return b.activate(layers.ReLU)
+}
+
+// Sigmoid applies a sigmoid activation to the previous layer.
+func (b *Builder) Sigmoid() *Builder {
+	return b.activate(layers.Sigmoid)
+}
+
+// Tanh applies a tanh activation to the previous layer.
+func (b *Builder) Tanh() *Builder {
+	return b.activate(layers.Tanh)
+}
+
+// Dropout adds a dropout layer which drops activations with probability p while training.
+func (b *Builder) Dropout(p float64) *Builder {
+	if p < 0 || p >= 1 {
+		b.fail(fmt.Errorf("dropout probability must be in [0, 1), got %v", p))
+	}
+	return b.add(layers.LayerDef{Type: layers.Dropout, LayerConfig: &layers.DropoutLayerConfig{DropoutProbability: p}})
+}
+
+// Softmax adds a softmax classifier over the given number of classes, preceded by a
+// fully connected layer of matching size.
+func (b *Builder) Softmax(classes int) *Builder {
+	return b.addConfig(layers.SoftMax, func() layers.LayerConfig {
+		return layers.NewSoftmaxLayerConfig(classes)
+	})
+}
+
+// PixelSoftmax adds a softmax classifier at every position of the previous layer, whose
+// depth is the number of classes, for segmentation networks trained on label maps.
+func (b *Builder) PixelSoftmax() *Builder {
+	return b.add(layers.LayerDef{Type: layers.PixelSoftMax})
+}
+
+// SVM adds a multiclass SVM classifier over the given number of classes, preceded by
+// a fully connected layer of matching size.
+func (b *Builder) SVM(classes int) *Builder {
+	return b.addConfig(layers.SVM, func() layers.LayerConfig {
+		return layers.NewSVMLayerConfig(classes)
+	})
+}
+
+// Regression adds an L2 regression loss over the given number of outputs, preceded by
+// a fully connected layer of matching size.
+func (b *Builder) Regression(outputs int) *Builder {
+	return b.addConfig(layers.Regression, func() layers.LayerConfig {
+		return layers.NewRegressionLayerConfig(outputs)
+	})
+}
+
+// Named sets the name of the last added layer.
+func (b *Builder) Named(name string) *Builder {
+	if b.err == nil {
+		if len(b.defs) == 0 {
+			b.err = errors.New("no layer to name")
+		} else {
+			b.defs[len(b.defs)-1].Name = name
+		}
+	}
+	return b
+}
+
+// Defs returns the layer definitions built so far.
+func (b *Builder) Defs() ([]layers.LayerDef, error) {
+	if b.err != nil {
+		return nil, b.err
+	}
+	return append([]layers.LayerDef(nil), b.defs...), nil
+}
+
+// Build creates the network.
+func (b *Builder) Build() (Network, error) {
+	defs, err := b.Defs()
+	if err != nil {
+		return nil, err
+	}
+	return NewNetwork(defs, b.opts...)
+}
+
+// fail records the first error.
+func (b *Builder) fail(err error) {
+	if b.err == nil {
+		b.err = err
+	}
+}
+
+// addConfig adds a layer with the config returned by newConfig, which panics on
+// invalid arguments like the layer config constructors do.
+func (b *Builder) addConfig(t layers.LayerType, newConfig func() layers.LayerConfig) *Builder {
+	if b.err != nil {
+		return b
+	}
+	var conf layers.LayerConfig
+	func() {
+		defer recoverError(&b.err)
+		conf = newConfig()
+	}()
+	if b.err != nil {
+		b.err = fmt.Errorf("layer %d (%s): %w", len(b.defs), t, b.err)
+		return b
+	}
+	return b.add(layers.LayerDef{Type: t, LayerConfig: conf})
+}
+
+// add appends def, computing its dimensions from the previous layer.
+func (b *Builder) add(def layers.LayerDef) *Builder {
+	if b.err != nil {
+		return b
+	}
+	if def.Type != layers.Input {
+		if len(b.defs) == 0 {
+			b.err = fmt.Errorf("layer %d (%s): the first layer must be the input", len(b.defs), def.Type)
+			return b
+		}
+		def.Input = b.defs[len(b.defs)-1].Output
+		out, err := layers.OutputDimensions(def)
+		if err != nil {
+			b.err = fmt.Errorf("layer %d (%s): %w", len(b.defs), def.Type, err)
+			return b
+		}
+		def.Output = out
+	}
+	b.defs = append(b.defs, def)
+	return b
+}
+
+// activate applies the activation to the previous fc or conv layer, where it also
+// gets the bias initialization suited to it, and adds a separate activation layer
+// otherwise.
+func (b *Builder) activate(t layers.LayerType) *Builder {
+	if b.err != nil {
+		return b
+	} else if len(b.defs) == 0 {
+		b.err = fmt.Errorf("%s activation needs a previous layer", t)
+		return b
+	}
+
+	last := &b.defs[len(b.defs)-1]
+	if (last.Type == layers.FullyConnected || last.Type == layers.Conv || last.Type == layers.Depthwise) && last.Activation == "" {
+		last.Activation = t
+		return b
+	}
+	return b.add(layers.LayerDef{Type: t})
+}
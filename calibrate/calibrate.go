@@ -0,0 +1,221 @@
+// Package calibrate makes the class probabilities of softmax classifiers match how
+// often they are right. Networks trained with a log loss tend to be overconfident;
+// temperature scaling fits a single divisor for the logits on held-out data, which
+// fixes most of it without changing any prediction. Reliability diagrams and the
+// expected calibration error measure the result.
+package calibrate
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/nathanleary/reticulum"
+	"github.com/nathanleary/reticulum/layers"
+)
+
+// bounds of the temperature search
+const (
+	minTemperature = 0.05
+	maxTemperature = 20.0
+)
+
+// TemperatureScale returns the temperature T minimising the negative log likelihood
+// of the labels of data under softmax(logits / T). T > 1 means the network is
+// overconfident. Use Apply to fold it into the network.
+func TemperatureScale(net reticulum.Network, data []reticulum.Sample) (float64, error) {
+	logits, labels, err := collect(net, data)
+	if err != nil {
+		return 0, err
+	}
+
+	// the likelihood is convex in 1/T, so a golden section search finds the optimum
+	nll := func(beta float64) float64 {
+		var sum float64
+		for i, z := range logits {
+			sum -= math.Log(softmax(z, beta)[labels[i]])
+		}
+		return sum / float64(len(logits))
+	}
+	lo, hi := 1/maxTemperature, 1/minTemperature
+	ratio := (math.Sqrt(5) - 1) / 2
+	a, b := hi-ratio*(hi-lo), lo+ratio*(hi-lo)
+	fa, fb := nll(a), nll(b)
+	for hi-lo > 1e-6 {
+		if fa < fb {
+			hi, b, fb = b, a, fa
+			a = hi - ratio*(hi-lo)
+			fa = nll(a)
+		} else {
+			lo, a, fa = a, b, fb
+			b = lo + ratio*(hi-lo)
+			fb = nll(b)
+		}
+	}
+	return 2 / (lo + hi), nil
+}
+
+// Apply divides the logits of net by temperature, by scaling the weights and biases of
+// the fully connected layer feeding its softmax, so its outputs are the calibrated
+// probabilities from then on.
+func Apply(net reticulum.Network, temperature float64) error {
+	if temperature <= 0 {
+		return fmt.Errorf("temperature must be greater than 0, got %v", temperature)
+	} else if err := checkSoftmax(net); err != nil {
+		return err
+	}
+	all := net.Layers()
+	fc := all[len(all)-2]
+	if fc.Type() != layers.FullyConnected {
+		return fmt.Errorf("softmax must follow a fully connected layer, got %s", fc.Type())
+	}
+	for _, resp := range fc.GetResponse() {
+		for i := range resp.Weights {
+			resp.Weights[i] /= temperature
+		}
+	}
+	return nil
+}
+
+// Scale recomputes softmax probabilities at the given temperature, as Apply would
+// have produced them.
+func Scale(probs []float64, temperature float64) []float64 {
+	return softmax(logitsOf(probs), 1/temperature)
+}
+
+// Bin is a confidence interval of a reliability diagram.
+type Bin struct {
+	// Lower and Upper bound the confidence of the predictions in the bin.
+	Lower, Upper float64
+
+	// Count is the number of predictions in the bin.
+	Count int
+
+	// Confidence is their mean predicted probability and Accuracy the fraction of
+	// them which were correct. They match for a calibrated network.
+	Confidence float64
+	Accuracy   float64
+}
+
+// Reliability sorts the predictions of net on data into the given number of equal
+// width confidence bins.
+func Reliability(net reticulum.Network, data []reticulum.Sample, bins int) ([]Bin, error) {
+	if bins <= 0 {
+		return nil, errors.New("bin count must be greater than 0")
+	}
+	logits, labels, err := collect(net, data)
+	if err != nil {
+		return nil, err
+	}
+
+	diagram := make([]Bin, bins)
+	for i := range diagram {
+		diagram[i].Lower = float64(i) / float64(bins)
+		diagram[i].Upper = float64(i+1) / float64(bins)
+	}
+	for i, z := range logits {
+		p := softmax(z, 1)
+		pred := argmax(p)
+		k := min(int(p[pred]*float64(bins)), bins-1)
+		diagram[k].Count++
+		diagram[k].Confidence += p[pred]
+		if pred == labels[i] {
+			diagram[k].Accuracy++
+		}
+	}
+	for i := range diagram {
+		if n := float64(diagram[i].Count); n > 0 {
+			diagram[i].Confidence /= n
+			diagram[i].Accuracy /= n
+		}
+	}
+	return diagram, nil
+}
+
+// ECE returns the expected calibration error of a reliability diagram: the gap between
+// confidence and accuracy averaged over the bins, weighted by their counts.
+func ECE(diagram []Bin) float64 {
+	var total int
+	var sum float64
+	for _, b := range diagram {
+		total += b.Count
+		sum += float64(b.Count) * math.Abs(b.Confidence-b.Accuracy)
+	}
+	if total == 0 {
+		return 0
+	}
+	return sum / float64(total)
+}
+
+// collect returns the logits of the softmax of net for every sample, recovered from
+// its probabilities, along with the labels.
+func collect(net reticulum.Network, data []reticulum.Sample) ([][]float64, []int, error) {
+	if net == nil {
+		return nil, nil, errors.New("network cannot be nil")
+	} else if len(data) == 0 {
+		return nil, nil, errors.New("data cannot be empty")
+	} else if err := checkSoftmax(net); err != nil {
+		return nil, nil, err
+	}
+
+	logits := make([][]float64, len(data))
+	labels := make([]int, len(data))
+	for i, sample := range data {
+		if sample.Target != nil {
+			return nil, nil, fmt.Errorf("sample %d: calibration requires labeled samples", i)
+		}
+		out, err := reticulum.ForwardE(net, sample.Input, false)
+		if err != nil {
+			return nil, nil, fmt.Errorf("sample %d: %w", i, err)
+		} else if sample.Label < 0 || sample.Label >= out.Size() {
+			return nil, nil, fmt.Errorf("sample %d: label %d out of range", i, sample.Label)
+		}
+		logits[i] = logitsOf(out.Weights())
+		labels[i] = sample.Label
+	}
+	return logits, labels, nil
+}
+
+func checkSoftmax(net reticulum.Network) error {
+	if last := net.Layers()[net.Size()-1]; last.Type() != layers.SoftMax {
+		return fmt.Errorf("calibration requires a softmax output layer, got %s", last.Type())
+	}
+	return nil
+}
+
+// logitsOf returns the logits of softmax probabilities, up to a constant.
+func logitsOf(probs []float64) []float64 {
+	z := make([]float64, len(probs))
+	for i, p := range probs {
+		z[i] = math.Log(math.Max(p, math.SmallestNonzeroFloat64))
+	}
+	return z
+}
+
+// softmax returns the softmax of the logits multiplied by beta.
+func softmax(z []float64, beta float64) []float64 {
+	zmax := z[0]
+	for _, v := range z {
+		zmax = math.Max(zmax, v)
+	}
+	p := make([]float64, len(z))
+	var sum float64
+	for i, v := range z {
+		p[i] = math.Exp(beta * (v - zmax))
+		sum += p[i]
+	}
+	for i := range p {
+		p[i] /= sum
+	}
+	return p
+}
+
+func argmax(values []float64) int {
+	maxi := 0
+	for i, v := range values {
+		if v > values[maxi] {
+			maxi = i
+		}
+	}
+	return maxi
+}
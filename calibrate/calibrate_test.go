@@ -0,0 +1,133 @@
+package calibrate
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/nathanleary/reticulum"
+	"github.com/nathanleary/reticulum/layers"
+	"github.com/nathanleary/reticulum/volume"
+)
+
+func newNet(t *testing.T, last layers.LayerDef) reticulum.Network {
+	t.Helper()
+	net, err := reticulum.NewNetwork([]layers.LayerDef{
+		{Type: layers.Input, Output: volume.NewDimensions(1, 1, 2)},
+		{Type: layers.FullyConnected, LayerConfig: layers.NewFullyConnectedLayerConfig(3)},
+		last,
+	}, reticulum.WithNetworkSeed(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return net
+}
+
+func softmaxNet(t *testing.T) reticulum.Network {
+	return newNet(t, layers.LayerDef{Type: layers.SoftMax, LayerConfig: layers.NewSoftmaxLayerConfig(3)})
+}
+
+// overconfident returns samples whose labels are drawn from the probabilities of net
+// at the given temperature, so net is calibrated after dividing its logits by it.
+func overconfident(net reticulum.Network, n int, temperature float64) []reticulum.Sample {
+	r := rand.New(rand.NewSource(1))
+	data := make([]reticulum.Sample, n)
+	for i := range data {
+		in := volume.NewVolume(volume.NewDimensions(1, 1, 2), volume.WithWeights([]float64{4 * r.NormFloat64(), 4 * r.NormFloat64()}))
+		probs := Scale(net.Forward(in, false).Weights(), temperature)
+		label, u := 0, r.Float64()
+		for ; label < len(probs)-1 && u >= probs[label]; label++ {
+			u -= probs[label]
+		}
+		data[i] = reticulum.Sample{Input: in, Label: label}
+	}
+	return data
+}
+
+func TestTemperatureScale(t *testing.T) {
+	for _, want := range []float64{0.5, 1, 3} {
+		net := softmaxNet(t)
+		data := overconfident(net, 4000, want)
+		before, err := Reliability(net, data, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		got, err := TemperatureScale(net, data)
+		if err != nil {
+			t.Fatal(err)
+		} else if math.Abs(got-want)/want > 0.15 {
+			t.Errorf("TemperatureScale() = %v, want %v", got, want)
+		}
+
+		probs := net.Forward(data[0].Input, false).Weights()
+		scaled := Scale(probs, got)
+		if err := Apply(net, got); err != nil {
+			t.Fatal(err)
+		}
+		for i, p := range net.Forward(data[0].Input, false).Weights() {
+			if math.Abs(p-scaled[i]) > 1e-9 {
+				t.Errorf("T=%v: probability %d = %v after Apply, want %v", want, i, p, scaled[i])
+			}
+		}
+
+		after, err := Reliability(net, data, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want != 1 && ECE(after) >= ECE(before) {
+			t.Errorf("T=%v: ECE %v after calibration, was %v", want, ECE(after), ECE(before))
+		}
+	}
+}
+
+func TestReliability(t *testing.T) {
+	net := softmaxNet(t)
+	data := overconfident(net, 100, 1)
+	diagram, err := Reliability(net, data, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var total int
+	for i, b := range diagram {
+		total += b.Count
+		if b.Count > 0 && (b.Confidence < b.Lower || b.Confidence > b.Upper) {
+			t.Errorf("bin %d [%v, %v] has a mean confidence of %v", i, b.Lower, b.Upper, b.Confidence)
+		}
+	}
+	if total != len(data) {
+		t.Errorf("diagram holds %d predictions, want %d", total, len(data))
+	}
+	if got := ECE([]Bin{{Count: 1, Confidence: 0.9, Accuracy: 1}, {Count: 3, Confidence: 0.5, Accuracy: 0.3}}); math.Abs(got-0.175) > 1e-12 {
+		t.Errorf("ECE() = %v, want 0.175", got)
+	}
+}
+
+func TestCalibrate_Errors(t *testing.T) {
+	net := softmaxNet(t)
+	data := overconfident(net, 10, 1)
+	regression := newNet(t, layers.LayerDef{Type: layers.Regression, LayerConfig: layers.NewRegressionLayerConfig(1)})
+	for _, test := range []struct {
+		name string
+		err  func() error
+	}{
+		{"nil network", func() error { _, err := TemperatureScale(nil, data); return err }},
+		{"no data", func() error { _, err := TemperatureScale(net, nil); return err }},
+		{"not softmax", func() error { _, err := TemperatureScale(regression, data); return err }},
+		{"label out of range", func() error {
+			_, err := TemperatureScale(net, []reticulum.Sample{{Input: data[0].Input, Label: 3}})
+			return err
+		}},
+		{"regression sample", func() error {
+			_, err := TemperatureScale(net, []reticulum.Sample{{Input: data[0].Input, Target: []float64{1}}})
+			return err
+		}},
+		{"no bins", func() error { _, err := Reliability(net, data, 0); return err }},
+		{"zero temperature", func() error { return Apply(net, 0) }},
+		{"apply not softmax", func() error { return Apply(regression, 2) }},
+	} {
+		if err := test.err(); err == nil {
+			t.Errorf("%s: expected an error", test.name)
+		}
+	}
+}
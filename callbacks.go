@@ -0,0 +1,100 @@
+package reticulum
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/nathanleary/reticulum/layers"
+)
+
+// Metrics maps metric names (e.g. "loss", "val_loss", "val_accuracy") to values.
+type Metrics map[string]float64
+
+// Callback receives notifications from the trainer. Embed BaseCallback to only
+// implement the notifications of interest.
+type Callback interface {
+	// OnTrainBegin is called before the first training step.
+	OnTrainBegin(t Trainer)
+
+	// OnBatchEnd is called after every Train or TrainBatch call.
+	OnBatchEnd(t Trainer, res TrainingResults)
+
+	// OnEpochEnd is called when an epoch is marked as complete with Trainer.EndEpoch.
+	OnEpochEnd(t Trainer, epoch int, metrics Metrics)
+}
+
+// GradientCallback is implemented by callbacks which also inspect the gradients of
+// every update, e.g. to log them. OnGradients is called before the weights are
+// updated with the gradients of every parameter averaged over the batch, including
+// weight decay and after clipping. The responses must not be modified or retained.
+type GradientCallback interface {
+	Callback
+	OnGradients(t Trainer, params []layers.LayerResponse)
+}
+
+// BaseCallback implements Callback with no-ops.
+type BaseCallback struct{}
+
+func (BaseCallback) OnTrainBegin(t Trainer)                           {}
+func (BaseCallback) OnBatchEnd(t Trainer, res TrainingResults)        {}
+func (BaseCallback) OnEpochEnd(t Trainer, epoch int, metrics Metrics) {}
+
+// WithCallbacks registers callbacks which are notified in the given order.
+func WithCallbacks(callbacks ...Callback) OptionFunc {
+	return func(opts *Options) {
+		opts.Callbacks = append(opts.Callbacks, callbacks...)
+	}
+}
+
+// NewCheckpointCallback checkpoints the trainer to path at the end of every n epochs.
+// The epoch number is substituted into path if it contains a fmt verb, e.g. "ckpt-%03d".
+func NewCheckpointCallback(path string, n int) *CheckpointCallback {
+	if n <= 0 {
+		panic("checkpoint interval must be greater than 0")
+	}
+	return &CheckpointCallback{path: path, every: n}
+}
+
+// CheckpointCallback periodically checkpoints the trainer.
+type CheckpointCallback struct {
+	BaseCallback
+	path  string
+	every int
+	err   error
+}
+
+// Err returns the error of the most recent failed checkpoint, if any.
+func (c *CheckpointCallback) Err() error {
+	return c.err
+}
+
+func (c *CheckpointCallback) OnEpochEnd(t Trainer, epoch int, metrics Metrics) {
+	if (epoch+1)%c.every != 0 {
+		return
+	}
+
+	path := c.path
+	if strings.Contains(path, "%") {
+		path = fmt.Sprintf(path, epoch)
+	}
+	if err := t.Checkpoint(path); err != nil {
+		c.err = fmt.Errorf("checkpoint epoch %d: %w", epoch, err)
+	}
+}
+
+// MetricSchedulerCallback reports the named metric to the trainer's scheduler at the
+// end of every epoch, e.g. to drive ReduceLROnPlateau with "val_loss".
+func MetricSchedulerCallback(metric string) Callback {
+	return &metricSchedulerCallback{metric: metric}
+}
+
+type metricSchedulerCallback struct {
+	BaseCallback
+	metric string
+}
+
+func (c *metricSchedulerCallback) OnEpochEnd(t Trainer, epoch int, metrics Metrics) {
+	if v, ok := metrics[c.metric]; ok {
+		t.ReportMetric(v)
+	}
+}
@@ -0,0 +1,86 @@
+//go:build cblas
+
+package cblas
+
+/*
+#cgo !mkl LDFLAGS: -lopenblas
+#cgo mkl CFLAGS: -DRETICULUM_MKL
+#cgo mkl LDFLAGS: -lmkl_rt
+
+#ifdef RETICULUM_MKL
+#include <mkl_cblas.h>
+#else
+#include <cblas.h>
+#endif
+
+// the enums differ between the CBLAS headers, so the calls are wrapped with ints
+
+static double reticulum_ddot(int n, const double *x, const double *y) {
+	return cblas_ddot(n, x, 1, y, 1);
+}
+
+static void reticulum_daxpy(int n, double alpha, const double *x, double *y) {
+	cblas_daxpy(n, alpha, x, 1, y, 1);
+}
+
+static void reticulum_dgemm(int transA, int transB, int m, int n, int k, double alpha,
+		const double *a, int lda, const double *b, int ldb, double beta, double *c) {
+	cblas_dgemm(CblasRowMajor, transA ? CblasTrans : CblasNoTrans, transB ? CblasTrans : CblasNoTrans,
+		m, n, k, alpha, a, lda, b, ldb, beta, c, n);
+}
+*/
+import "C"
+
+import (
+	"github.com/nathanleary/reticulum/layers"
+)
+
+// New returns the CBLAS backend.
+func New() (layers.Backend, error) {
+	return backend{}, nil
+}
+
+type backend struct{}
+
+func (backend) Dot(x, y []float64) float64 {
+	if len(x) == 0 {
+		return 0
+	}
+	return float64(C.reticulum_ddot(C.int(len(x)), (*C.double)(&x[0]), (*C.double)(&y[0])))
+}
+
+func (backend) Axpy(alpha float64, x, y []float64) {
+	if len(x) == 0 {
+		return
+	}
+	C.reticulum_daxpy(C.int(len(x)), C.double(alpha), (*C.double)(&x[0]), (*C.double)(&y[0]))
+}
+
+func (backend) Gemm(transA, transB bool, m, n, k int, alpha float64, a, b []float64, beta float64, c []float64) {
+	if m == 0 || n == 0 {
+		return
+	}
+	if k == 0 {
+		for i := range c[:m*n] {
+			c[i] *= beta
+		}
+		return
+	}
+
+	lda, ldb := k, n
+	if transA {
+		lda = m
+	}
+	if transB {
+		ldb = k
+	}
+	C.reticulum_dgemm(flag(transA), flag(transB), C.int(m), C.int(n), C.int(k), C.double(alpha),
+		(*C.double)(&a[0]), C.int(lda), (*C.double)(&b[0]), C.int(ldb), C.double(beta), (*C.double)(&c[0]))
+}
+
+func flag(b bool) C.int {
+	if b {
+		return 1
+	}
+	return 0
+}
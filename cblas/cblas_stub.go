@@ -0,0 +1,14 @@
+//go:build !cblas
+
+package cblas
+
+import (
+	"errors"
+
+	"github.com/nathanleary/reticulum/layers"
+)
+
+// New returns an error: the package was built without the cblas tag.
+func New() (layers.Backend, error) {
+	return nil, errors.New("cblas: built without the cblas build tag")
+}
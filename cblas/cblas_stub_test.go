@@ -0,0 +1,11 @@
+//go:build !cblas
+
+package cblas
+
+import "testing"
+
+func TestNew_WithoutTag(t *testing.T) {
+	if b, err := New(); err == nil {
+		t.Errorf("New() = %v, want an error without the cblas tag", b)
+	}
+}
@@ -0,0 +1,66 @@
+//go:build cblas
+
+package cblas
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/nathanleary/reticulum/layers"
+)
+
+func random(r *rand.Rand, n int) []float64 {
+	s := make([]float64, n)
+	for i := range s {
+		s[i] = r.NormFloat64()
+	}
+	return s
+}
+
+func near(t *testing.T, name string, got, want []float64) {
+	t.Helper()
+	for i, w := range want {
+		if math.Abs(got[i]-w) > 1e-9 {
+			t.Fatalf("%s: value %d = %v, want %v", name, i, got[i], w)
+		}
+	}
+}
+
+// TestBackend_MatchesPureGo checks the CBLAS routines against the pure Go backend.
+func TestBackend_MatchesPureGo(t *testing.T) {
+	b, err := New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ref layers.PureGo
+	r := rand.New(rand.NewSource(1))
+
+	for _, n := range []int{0, 1, 7} {
+		x, y := random(r, n), random(r, n)
+		if got, want := b.Dot(x, y), ref.Dot(x, y); math.Abs(got-want) > 1e-9 {
+			t.Errorf("Dot() of %d values = %v, want %v", n, got, want)
+		}
+		got, want := append([]float64(nil), y...), append([]float64(nil), y...)
+		b.Axpy(0.5, x, got)
+		ref.Axpy(0.5, x, want)
+		near(t, "Axpy", got, want)
+	}
+
+	for _, test := range []struct {
+		transA, transB bool
+		m, n, k        int
+	}{
+		{false, false, 3, 4, 5},
+		{true, false, 3, 4, 5},
+		{false, true, 2, 6, 3},
+		{true, true, 5, 1, 2},
+	} {
+		a, bm := random(r, test.m*test.k), random(r, test.k*test.n)
+		c := random(r, test.m*test.n)
+		got, want := append([]float64(nil), c...), append([]float64(nil), c...)
+		b.Gemm(test.transA, test.transB, test.m, test.n, test.k, 1.5, a, bm, 0.5, got)
+		ref.Gemm(test.transA, test.transB, test.m, test.n, test.k, 1.5, a, bm, 0.5, want)
+		near(t, "Gemm", got, want)
+	}
+}
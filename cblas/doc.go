@@ -0,0 +1,19 @@
+// Package cblas provides a layer backend calling the dgemm, ddot and daxpy routines
+// of a CBLAS library such as OpenBLAS or Intel MKL through cgo. It needs cgo and the
+// library installed, and is built with the cblas tag, adding mkl to link MKL instead
+// of OpenBLAS:
+//
+//	go build -tags cblas ./...
+//	go build -tags cblas,mkl ./...
+//
+// The backend is then selected at runtime, before building or training networks:
+//
+//	b, err := cblas.New()
+//	if err != nil {
+//		return err
+//	}
+//	reticulum.SetBackend(b)
+//
+// Without the tag New returns an error, so the pure Go default can be kept as a
+// fallback. Volumes hold float64 values, so the double precision routines are used.
+package cblas
@@ -0,0 +1,392 @@
+// Package charrnn trains character-level language models on plain text and samples
+// text from them, in the manner of char-rnn:
+//
+//	text, err := os.ReadFile("shakespeare.txt")
+//	if err != nil {
+//		return err
+//	}
+//	vocab := charrnn.NewVocab(string(text))
+//	m, err := charrnn.New(vocab, charrnn.WithContext(32))
+//	if err != nil {
+//		return err
+//	}
+//	losses, err := m.Fit(vocab.Encode(string(text)), 10, 64, 32)
+//	fmt.Println(m.GenerateText("ROMEO:", 500, 0.8))
+//
+// Despite the name, the models are not recurrent: networks have no RNN or LSTM
+// layers, so a model conditions every prediction on a fixed window of the previous
+// Context characters instead of a hidden state, as in the neural language model of
+// Bengio et al., and nothing further back influences it. The window is encoded
+// one-hot, with the latest character last.
+package charrnn
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"strings"
+
+	"github.com/nathanleary/reticulum"
+	"github.com/nathanleary/reticulum/layers"
+	"github.com/nathanleary/reticulum/volume"
+)
+
+// Vocab maps the characters of a text to consecutive indexes.
+type Vocab struct {
+	runes []rune
+	index map[rune]int
+}
+
+// NewVocab creates the vocabulary of the distinct characters of text, sorted.
+func NewVocab(text string) *Vocab {
+	index := map[rune]int{}
+	for _, r := range text {
+		index[r] = 0
+	}
+	runes := make([]rune, 0, len(index))
+	for r := range index {
+		runes = append(runes, r)
+	}
+	sort.Slice(runes, func(i, j int) bool { return runes[i] < runes[j] })
+	for i, r := range runes {
+		index[r] = i
+	}
+	return &Vocab{runes: runes, index: index}
+}
+
+// Size returns the number of characters in the vocabulary.
+func (v *Vocab) Size() int {
+	return len(v.runes)
+}
+
+// Index returns the index of the character, and whether it is in the vocabulary.
+func (v *Vocab) Index(r rune) (int, bool) {
+	i, ok := v.index[r]
+	return i, ok
+}
+
+// Rune returns the character at index i.
+func (v *Vocab) Rune(i int) rune {
+	return v.runes[i]
+}
+
+// Encode returns the indexes of the characters of text, skipping the characters not
+// in the vocabulary.
+func (v *Vocab) Encode(text string) []int {
+	ids := make([]int, 0, len(text))
+	for _, r := range text {
+		if i, ok := v.index[r]; ok {
+			ids = append(ids, i)
+		}
+	}
+	return ids
+}
+
+// Decode returns the text of the character indexes.
+func (v *Vocab) Decode(ids []int) string {
+	var b strings.Builder
+	for _, i := range ids {
+		b.WriteRune(v.runes[i])
+	}
+	return b.String()
+}
+
+// Sequence is a training sequence of characters with the character following each
+// of them.
+type Sequence struct {
+	// Start is the offset of the first input in the encoded text.
+	Start   int
+	Inputs  []int
+	Targets []int
+}
+
+// Sequences splits the encoded text into consecutive, non-overlapping sequences of
+// length characters, each target being the next character of the text. A shorter
+// final sequence is dropped.
+func Sequences(ids []int, length int) []Sequence {
+	var seqs []Sequence
+	for start := 0; start+length < len(ids); start += length {
+		seqs = append(seqs, Sequence{Start: start, Inputs: ids[start : start+length], Targets: ids[start+1 : start+length+1]})
+	}
+	return seqs
+}
+
+// Batches shuffles the sequences and groups them into batches of batchSize, the last
+// one possibly smaller.
+func Batches(seqs []Sequence, batchSize int, rng *rand.Rand) [][]Sequence {
+	order := rng.Perm(len(seqs))
+	var batches [][]Sequence
+	for start := 0; start < len(order); start += batchSize {
+		end := min(start+batchSize, len(order))
+		batch := make([]Sequence, 0, end-start)
+		for _, i := range order[start:end] {
+			batch = append(batch, seqs[i])
+		}
+		batches = append(batches, batch)
+	}
+	return batches
+}
+
+// OptionFunc modifies the Options of a model.
+type OptionFunc func(*Options)
+
+// Options configures a model.
+type Options struct {
+	// Context is the number of previous characters every prediction sees.
+	Context int
+
+	// Hidden are the sizes of the hidden layers of the default network.
+	Hidden     []int
+	Activation layers.LayerType
+
+	// Network replaces the default network. Its input must have depth Context times
+	// the size of the vocabulary and it must end in a softmax over the vocabulary.
+	Network reticulum.Network
+
+	TrainerOptions []reticulum.OptionFunc
+
+	HasSeed bool
+	Seed    int64
+}
+
+// WithContext sets the number of previous characters every prediction sees.
+func WithContext(n int) OptionFunc {
+	return func(opts *Options) {
+		opts.Context = n
+	}
+}
+
+// WithHidden sets the sizes and activation of the hidden layers of the default
+// network.
+func WithHidden(activation layers.LayerType, sizes ...int) OptionFunc {
+	return func(opts *Options) {
+		opts.Activation = activation
+		opts.Hidden = sizes
+	}
+}
+
+// WithNetwork replaces the default network.
+func WithNetwork(net reticulum.Network) OptionFunc {
+	return func(opts *Options) {
+		opts.Network = net
+	}
+}
+
+// WithTrainerOptions replaces the options of the trainer used by Fit.
+func WithTrainerOptions(opts ...reticulum.OptionFunc) OptionFunc {
+	return func(o *Options) {
+		o.TrainerOptions = opts
+	}
+}
+
+// WithSeed makes the initial weights, the order of the sequences and the sampling
+// reproducible.
+func WithSeed(seed int64) OptionFunc {
+	return func(opts *Options) {
+		opts.HasSeed = true
+		opts.Seed = seed
+	}
+}
+
+// Model is a character-level language model. A Model is not safe for concurrent use.
+type Model struct {
+	vocab   *Vocab
+	context int
+	net     reticulum.Network
+	trainer reticulum.Trainer
+	rng     *rand.Rand
+}
+
+// New creates a model of the characters of the vocabulary. Without WithNetwork, its
+// network has one hidden layer of 256 ReLU units.
+func New(vocab *Vocab, optFuncs ...OptionFunc) (*Model, error) {
+	opts := &Options{
+		Context:    16,
+		Hidden:     []int{256},
+		Activation: layers.ReLU,
+		TrainerOptions: []reticulum.OptionFunc{
+			reticulum.WithMethod(reticulum.Adam),
+			reticulum.WithLearningRate(0.002),
+		},
+	}
+	for _, optFn := range optFuncs {
+		optFn(opts)
+	}
+	if vocab.Size() < 2 {
+		return nil, fmt.Errorf("vocabulary must have at least 2 characters, got %d", vocab.Size())
+	} else if opts.Context <= 0 {
+		return nil, fmt.Errorf("context must be greater than 0, got %d", opts.Context)
+	}
+
+	seed := rand.Int63()
+	if opts.HasSeed {
+		seed = opts.Seed
+	}
+	net := opts.Network
+	if net == nil {
+		defs := []layers.LayerDef{{Type: layers.Input, Output: volume.NewDimensions(1, 1, opts.Context*vocab.Size())}}
+		for _, n := range opts.Hidden {
+			defs = append(defs, layers.LayerDef{Type: layers.FullyConnected, Activation: opts.Activation, LayerConfig: layers.NewFullyConnectedLayerConfig(n)})
+		}
+		defs = append(defs, layers.LayerDef{Type: layers.SoftMax, LayerConfig: layers.NewSoftmaxLayerConfig(vocab.Size())})
+		var err error
+		if net, err = reticulum.NewNetwork(defs, reticulum.WithNetworkSeed(seed)); err != nil {
+			return nil, err
+		}
+	}
+	if in := reticulum.InputDimensions(net); len(in) != 1 || in[0].Size() != opts.Context*vocab.Size() {
+		return nil, fmt.Errorf("network input must have %d values, %d characters of %d", opts.Context*vocab.Size(), opts.Context, vocab.Size())
+	} else if net.Layers()[net.Size()-1].Type() != layers.SoftMax {
+		return nil, errors.New("network must end in a softmax layer")
+	}
+
+	trainer, err := reticulum.NewTrainerE(net, opts.TrainerOptions...)
+	if err != nil {
+		return nil, err
+	}
+	return &Model{vocab: vocab, context: opts.Context, net: net, trainer: trainer, rng: rand.New(rand.NewSource(seed))}, nil
+}
+
+// Vocab returns the vocabulary of the model.
+func (m *Model) Vocab() *Vocab {
+	return m.vocab
+}
+
+// Network returns the network of the model.
+func (m *Model) Network() reticulum.Network {
+	return m.net
+}
+
+// Trainer returns the trainer used by Fit.
+func (m *Model) Trainer() reticulum.Trainer {
+	return m.trainer
+}
+
+// Input returns the input volume for predicting the character following history,
+// from its last Context characters. Shorter histories leave the earliest slots
+// empty.
+func (m *Model) Input(history []int) *volume.Volume {
+	vol := volume.NewVolume(reticulum.InputDimensions(m.net)[0], volume.WithZeros())
+	w := vol.Weights()
+	n := m.vocab.Size()
+	if len(history) > m.context {
+		history = history[len(history)-m.context:]
+	}
+	offset := m.context - len(history)
+	for i, id := range history {
+		w[(offset+i)*n+id] = 1
+	}
+	return vol
+}
+
+// Probabilities returns the distribution of the character following history.
+func (m *Model) Probabilities(history []int) []float64 {
+	return append([]float64(nil), m.net.Forward(m.Input(history), false).Weights()...)
+}
+
+// Fit trains the model on the encoded text for the given number of epochs. Every
+// epoch splits the text into sequences of seqLen characters, at a random offset so
+// the sequence boundaries move between epochs, and trains on shuffled batches of
+// batchSize sequences. Training uses teacher forcing: every character of a sequence
+// is predicted from the true characters before it, not from the model's own
+// predictions. Fit returns the mean loss per character of every epoch, in nats, and
+// stops early if a callback of the trainer calls Stop.
+func (m *Model) Fit(ids []int, epochs, seqLen, batchSize int) ([]float64, error) {
+	if epochs <= 0 || seqLen <= 0 || batchSize <= 0 {
+		return nil, fmt.Errorf("epochs, sequence length and batch size must be greater than 0, got %d, %d and %d", epochs, seqLen, batchSize)
+	} else if len(ids) <= seqLen {
+		return nil, fmt.Errorf("text of %d characters is too short for sequences of %d", len(ids), seqLen)
+	}
+
+	var history []float64
+	for epoch := 0; epoch < epochs && !m.trainer.Stopped(); epoch++ {
+		// the offset leaves room for at least one sequence
+		offset := m.rng.Intn(min(seqLen, len(ids)-seqLen))
+		var total float64
+		var count int
+		for _, batch := range Batches(Sequences(ids[offset:], seqLen), batchSize, m.rng) {
+			var vols []*volume.Volume
+			var losses []reticulum.LossFunc
+			for _, seq := range batch {
+				// start every sequence with the text before it, as far as the context reaches
+				start := offset + seq.Start
+				for t, target := range seq.Targets {
+					vols = append(vols, m.Input(ids[max(start+t+1-m.context, 0):start+t+1]))
+					losses = append(losses, reticulum.LabeledLossFunc(target))
+				}
+			}
+			res, err := m.trainer.TrainBatchE(vols, losses)
+			if err != nil {
+				return history, err
+			}
+			total += res.CostLost * float64(len(vols))
+			count += len(vols)
+		}
+		loss := total / float64(count)
+		history = append(history, loss)
+		m.trainer.EndEpoch(epoch, reticulum.Metrics{"loss": loss, "perplexity": math.Exp(loss)})
+	}
+	return history, nil
+}
+
+// Loss returns the mean loss per character of predicting the encoded text, in nats.
+// The perplexity is its exponential.
+func (m *Model) Loss(ids []int) float64 {
+	if len(ids) < 2 {
+		return 0
+	}
+	var loss float64
+	for t := 1; t < len(ids); t++ {
+		p := m.Probabilities(ids[max(t-m.context, 0):t])
+		loss -= math.Log(max(p[ids[t]], 1e-12))
+	}
+	return loss / float64(len(ids)-1)
+}
+
+// GenerateText samples length characters following seed, feeding every sampled
+// character back as input. The temperature scales the log probabilities: 1 samples
+// from the model's distribution, lower values make the text more likely and
+// repetitive, higher values more diverse, and 0 always picks the most likely
+// character. Characters of the seed not in the vocabulary are skipped.
+func (m *Model) GenerateText(seed string, length int, temperature float64) string {
+	if temperature < 0 {
+		panic(fmt.Errorf("temperature cannot be negative, got %v", temperature))
+	}
+	history := m.vocab.Encode(seed)
+	generated := make([]int, 0, length)
+	for i := 0; i < length; i++ {
+		next := sample(m.Probabilities(history), temperature, m.rng)
+		history = append(history, next)
+		generated = append(generated, next)
+	}
+	return m.vocab.Decode(generated)
+}
+
+// sample draws an index from the probabilities p sharpened or flattened to the
+// temperature, p^(1/temperature) renormalized.
+func sample(p []float64, temperature float64, rng *rand.Rand) int {
+	if temperature == 0 {
+		best := 0
+		for i, v := range p {
+			if v > p[best] {
+				best = i
+			}
+		}
+		return best
+	}
+	q := make([]float64, len(p))
+	var sum float64
+	for i, v := range p {
+		q[i] = math.Pow(v, 1/temperature)
+		sum += q[i]
+	}
+	r := rng.Float64() * sum
+	for i, v := range q {
+		if r -= v; r < 0 {
+			return i
+		}
+	}
+	return len(q) - 1
+}
@@ -0,0 +1,77 @@
+package charrnn
+
+import (
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/nathanleary/reticulum/layers"
+)
+
+func TestVocab_RoundTrip(t *testing.T) {
+	for _, text := range []string{"hello world", "ünïcödé", "a"} {
+		v := NewVocab(text)
+		if got := v.Decode(v.Encode(text)); got != text {
+			t.Errorf("Decode(Encode(%q)) = %q", text, got)
+		}
+	}
+}
+
+func TestSequences(t *testing.T) {
+	ids := []int{0, 1, 2, 3, 4, 5, 6}
+	tests := []struct {
+		length int
+		want   []Sequence
+	}{
+		{3, []Sequence{{0, []int{0, 1, 2}, []int{1, 2, 3}}, {3, []int{3, 4, 5}, []int{4, 5, 6}}}},
+		{6, []Sequence{{0, []int{0, 1, 2, 3, 4, 5}, []int{1, 2, 3, 4, 5, 6}}}},
+		{7, nil},
+	}
+	for _, test := range tests {
+		if got := Sequences(ids, test.length); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("Sequences(%v, %d) = %v, want %v", ids, test.length, got, test.want)
+		}
+	}
+}
+
+func TestModel_FitShortText(t *testing.T) {
+	text := "abcdefghij"
+	vocab := NewVocab(text)
+	for seed := int64(1); seed <= 8; seed++ {
+		m, err := New(vocab, WithContext(2), WithHidden(layers.ReLU, 8), WithSeed(seed))
+		if err != nil {
+			t.Fatal(err)
+		}
+		losses, err := m.Fit(vocab.Encode(text), 3, 9, 4)
+		if err != nil {
+			t.Fatalf("seed %d: Fit() error = %v", seed, err)
+		}
+		for epoch, loss := range losses {
+			if math.IsNaN(loss) || loss <= 0 {
+				t.Errorf("seed %d: loss of epoch %d = %v", seed, epoch, loss)
+			}
+		}
+	}
+}
+
+func TestModel_FitErrors(t *testing.T) {
+	vocab := NewVocab("abcdefghij")
+	m, err := New(vocab, WithContext(2), WithSeed(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := vocab.Encode("abcdefghij")
+	tests := []struct {
+		epochs, seqLen, batchSize int
+	}{
+		{0, 4, 2},
+		{1, 0, 2},
+		{1, 4, 0},
+		{1, 10, 2},
+	}
+	for _, test := range tests {
+		if _, err := m.Fit(ids, test.epochs, test.seqLen, test.batchSize); err == nil {
+			t.Errorf("Fit(%d, %d, %d) accepted invalid arguments", test.epochs, test.seqLen, test.batchSize)
+		}
+	}
+}
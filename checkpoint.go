@@ -0,0 +1,295 @@
+package reticulum
+
+import (
+	"encoding"
+	"encoding/gob"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// checkpointVersion is the version of the checkpoint format written by Checkpoint.
+// Checkpoints written before it was introduced decode as version 0.
+const checkpointVersion = 2
+
+// checkpointMigrations upgrade a checkpoint of a version to the next one, given the
+// network it is restored into.
+var checkpointMigrations = map[int]func(*trainerCheckpoint, Network) error{
+	// the layout is unchanged, only the version was added
+	0: func(*trainerCheckpoint, Network) error { return nil },
+
+	// parameters were stored by position, and only for the layers which were not
+	// frozen, so they are matched against the trainable parameters of the network
+	1: func(cp *trainerCheckpoint, net Network) error {
+		pgList := trainable(net)
+		if len(pgList) != len(cp.Weights) {
+			return fmt.Errorf("checkpoint has %d parameter sets, network has %d trainable ones", len(cp.Weights), len(pgList))
+		}
+		cp.Params = map[string]checkpointParam{}
+		for i, name := range parameterNames(pgList) {
+			p := checkpointParam{Weights: cp.Weights[i], Gradients: cp.Gradients[i]}
+			if i < len(cp.Gsum) {
+				p.Gsum, p.Xsum = cp.Gsum[i], cp.Xsum[i]
+			}
+			if i < len(cp.DPSum) {
+				p.DPSum = cp.DPSum[i]
+			}
+			cp.Params[name] = p
+		}
+		cp.Frozen = frozenLayers(net)
+		cp.Weights, cp.Gradients, cp.Gsum, cp.Xsum, cp.DPSum = nil, nil, nil, nil, nil
+		return nil
+	},
+}
+
+// trainerCheckpoint is the serialized state of a trainer and its network.
+type trainerCheckpoint struct {
+	Version int
+
+	LearningRate float64
+
+	// iteration counters
+	K       int
+	Steps   int
+	Samples int
+
+	// Params holds every parameter set of the network, frozen or not, by the name
+	// given by NamedParameters, and Frozen the names of the frozen layers
+	Params map[string]checkpointParam
+	Frozen []string
+
+	// positional parameter sets of version 1 checkpoints
+	Weights   [][]float64
+	Gradients [][]float64
+	Gsum      [][]float64
+	Xsum      [][]float64
+	DPSum     [][]float64
+
+	RNG        []byte
+	Scheduler  []byte
+	Accountant []byte
+}
+
+// checkpointParam is the state of a parameter set.
+type checkpointParam struct {
+	// Weights and (partially accumulated) Gradients
+	Weights   []float64
+	Gradients []float64
+
+	// optimizer accumulators, empty before the first update and for frozen layers
+	Gsum  []float64
+	Xsum  []float64
+	DPSum []float64
+}
+
+func (t *trainer) Checkpoint(path string) error {
+	cp := trainerCheckpoint{
+		Version:      checkpointVersion,
+		LearningRate: t.opts.LearningRate,
+		K:            t.k,
+		Steps:        t.steps,
+		Samples:      t.samples,
+		Params:       map[string]checkpointParam{},
+		Frozen:       frozenLayers(t.net),
+	}
+	pgList := t.net.GetResponse()
+	for i, name := range parameterNames(pgList) {
+		cp.Params[name] = checkpointParam{Weights: pgList[i].Weights, Gradients: pgList[i].Gradients}
+	}
+
+	// the accumulators line up with the trainable parameters, unless layers were
+	// frozen or unfrozen since the last update, which resets them anyway
+	trainablePgs := trainable(t.net)
+	for i, name := range parameterNames(trainablePgs) {
+		p := cp.Params[name]
+		if len(t.gsum) > 0 && sameSizes(trainablePgs, t.gsum) {
+			p.Gsum, p.Xsum = t.gsum[i], t.xsum[i]
+		}
+		if len(t.dpSum) > 0 && sameSizes(trainablePgs, t.dpSum) {
+			p.DPSum = t.dpSum[i]
+		}
+		cp.Params[name] = p
+	}
+
+	var err error
+	if cp.RNG, err = t.src.MarshalBinary(); err != nil {
+		return err
+	}
+	if m, ok := t.opts.Scheduler.(encoding.BinaryMarshaler); ok {
+		if cp.Scheduler, err = m.MarshalBinary(); err != nil {
+			return fmt.Errorf("checkpoint scheduler: %w", err)
+		}
+	}
+	if t.opts.Privacy != nil && t.opts.Privacy.Accountant != nil {
+		if cp.Accountant, err = t.opts.Privacy.Accountant.MarshalBinary(); err != nil {
+			return fmt.Errorf("checkpoint accountant: %w", err)
+		}
+	}
+
+	// write to a temporary file first so a crash never leaves a truncated checkpoint
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := gob.NewEncoder(tmp).Encode(&cp); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// ResumeTrainer restores a trainer saved with Checkpoint. The network must have the
+// same architecture and layer names as the checkpointed one; its weights and frozen
+// layers are overwritten, and left untouched on error. Options are not serialized and
+// must be passed again, and are validated as by NewTrainerE. The state of the
+// scheduler given in opts is restored if it implements encoding.BinaryUnmarshaler,
+// and the state of the privacy accountant if set.
+func ResumeTrainer(path string, net Network, opts ...OptionFunc) (Trainer, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var cp trainerCheckpoint
+	if err := gob.NewDecoder(f).Decode(&cp); err != nil {
+		return nil, fmt.Errorf("decode checkpoint: %w", err)
+	}
+	if cp.Version > checkpointVersion {
+		return nil, fmt.Errorf("checkpoint version %d is newer than the supported version %d", cp.Version, checkpointVersion)
+	}
+	for ; cp.Version < checkpointVersion; cp.Version++ {
+		if err := checkpointMigrations[cp.Version](&cp, net); err != nil {
+			return nil, fmt.Errorf("migrate checkpoint from version %d: %w", cp.Version, err)
+		}
+	}
+	if err := cp.check(net); err != nil {
+		return nil, err
+	}
+
+	tr, err := NewTrainerE(net, opts...)
+	if err != nil {
+		return nil, err
+	}
+	t := tr.(*trainer)
+	if err := t.src.UnmarshalBinary(cp.RNG); err != nil {
+		return nil, fmt.Errorf("restore rng: %w", err)
+	}
+
+	// the accountant is decoded into a copy, replacing the given one once everything
+	// was checked
+	var accountant *PrivacyAccountant
+	if p := t.opts.Privacy; p != nil && p.Accountant != nil && cp.Accountant != nil {
+		accountant = &PrivacyAccountant{}
+		if err := accountant.UnmarshalBinary(cp.Accountant); err != nil {
+			return nil, fmt.Errorf("restore accountant: %w", err)
+		}
+		restored := PrivacyOptions{NoiseMultiplier: p.NoiseMultiplier, Accountant: accountant}
+		if err := restored.accountantMismatch(); err != nil {
+			return nil, fmt.Errorf("restore accountant: %w", err)
+		}
+	}
+
+	// the scheduler can only be checked by restoring it, which is the last step that
+	// can fail
+	if u, ok := t.opts.Scheduler.(encoding.BinaryUnmarshaler); ok && cp.Scheduler != nil {
+		if err := u.UnmarshalBinary(cp.Scheduler); err != nil {
+			return nil, fmt.Errorf("restore scheduler: %w", err)
+		}
+	}
+
+	// everything was checked, so the state is restored completely or not at all
+	if accountant != nil {
+		*t.opts.Privacy.Accountant = *accountant
+	}
+	if err := net.Unfreeze(); err != nil {
+		return nil, err
+	}
+	if len(cp.Frozen) > 0 {
+		if err := net.Freeze(cp.Frozen...); err != nil {
+			return nil, err
+		}
+	}
+	pgList := net.GetResponse()
+	for i, name := range parameterNames(pgList) {
+		p := cp.Params[name]
+		copy(pgList[i].Weights, p.Weights)
+		copy(pgList[i].Gradients, p.Gradients)
+	}
+
+	t.SetLearningRate(cp.LearningRate)
+	t.k, t.steps, t.samples = cp.K, cp.Steps, cp.Samples
+	trainablePgs := trainable(net)
+	for i, name := range parameterNames(trainablePgs) {
+		p := cp.Params[name]
+		if len(p.Gsum) > 0 {
+			t.initAccumulators(trainablePgs)
+			copy(t.gsum[i], p.Gsum)
+			copy(t.xsum[i], p.Xsum)
+		}
+		if len(p.DPSum) > 0 {
+			if t.dpSum == nil {
+				for _, pg := range trainablePgs {
+					t.dpSum = append(t.dpSum, make([]float64, len(pg.Weights)))
+				}
+			}
+			copy(t.dpSum[i], p.DPSum)
+		}
+	}
+	return t, nil
+}
+
+// check returns an error unless the checkpoint holds every parameter of net, and no
+// others, with matching sizes, and its frozen layers exist in net.
+func (cp *trainerCheckpoint) check(net Network) error {
+	pgList := net.GetResponse()
+	names := parameterNames(pgList)
+	if len(cp.Params) != len(names) {
+		return fmt.Errorf("checkpoint has %d parameter sets, network has %d", len(cp.Params), len(names))
+	}
+	for i, name := range names {
+		p, ok := cp.Params[name]
+		n := len(pgList[i].Weights)
+		if !ok {
+			return fmt.Errorf("parameter %q is missing from the checkpoint", name)
+		} else if len(p.Weights) != n {
+			return fmt.Errorf("parameter %q: checkpoint has %d weights, network has %d", name, len(p.Weights), n)
+		}
+		for _, state := range [][]float64{p.Gradients, p.Gsum, p.Xsum, p.DPSum} {
+			if len(state) != 0 && len(state) != n {
+				return fmt.Errorf("parameter %q: checkpoint state does not match the %d weights", name, n)
+			}
+		}
+	}
+
+	layerNames := map[string]bool{}
+	for i := range net.Layers() {
+		layerNames[LayerName(net, i)] = true
+	}
+	for _, name := range cp.Frozen {
+		if !layerNames[name] {
+			return fmt.Errorf("frozen layer %q is missing from the network", name)
+		}
+	}
+	return nil
+}
+
+// frozenLayers returns the names of the frozen layers of net.
+func frozenLayers(net Network) []string {
+	var names []string
+	for i := range net.Layers() {
+		if name := LayerName(net, i); net.Frozen(name) {
+			names = append(names, name)
+		}
+	}
+	return names
+}
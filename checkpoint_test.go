@@ -0,0 +1,85 @@
+package reticulum
+
+import (
+	"math/rand"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/nathanleary/reticulum/volume"
+)
+
+func TestCheckpoint_ResumeContinuesTraining(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "trainer.ckpt")
+	r := rand.New(rand.NewSource(1))
+	var vols []*volume.Volume
+	for i := 0; i < 6; i++ {
+		vols = append(vols, randomInput(r, 4))
+	}
+	opts := []OptionFunc{WithAdam(0.95, 0.9, 0.999), WithSeed(1), WithGradientNoise(0.01, 0.55)}
+
+	net := privacyNetwork(t, 4, 3)
+	trainer := NewTrainer(net, opts...)
+	for i, vol := range vols[:3] {
+		trainer.Train(vol, LabeledLossFunc(i%3))
+	}
+	if err := trainer.Checkpoint(path); err != nil {
+		t.Fatal(err)
+	}
+	resumedNet := Clone(net)
+	for i, vol := range vols[3:] {
+		trainer.Train(vol, LabeledLossFunc(i%3))
+	}
+
+	resumed, err := ResumeTrainer(path, resumedNet, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, vol := range vols[3:] {
+		resumed.Train(vol, LabeledLossFunc(i%3))
+	}
+	if got, want := flatWeights(resumedNet), flatWeights(net); !slices.Equal(got, want) {
+		t.Errorf("weights after resuming = %v, want %v", got, want)
+	}
+}
+
+func TestCheckpoint_ResumeErrorsLeaveStateUntouched(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "trainer.ckpt")
+	privacy := func(sigma float64) (OptionFunc, *PrivacyAccountant) {
+		a := NewPrivacyAccountant(sigma)
+		return WithDifferentialPrivacy(PrivacyOptions{ClipNorm: 1, NoiseMultiplier: sigma, DatasetSize: 10, Accountant: a}), a
+	}
+
+	net := privacyNetwork(t, 4, 3)
+	opt, _ := privacy(1.1)
+	trainer := NewTrainer(net, WithSeed(1), opt)
+	trainer.Train(randomInput(rand.New(rand.NewSource(1)), 4), LabeledLossFunc(1))
+	if err := trainer.Checkpoint(path); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		sigma float64
+		opts  []OptionFunc
+	}{
+		{"invalid options", 1.1, []OptionFunc{WithLearningRate(-1)}},
+		{"accountant of another noise multiplier", 2, nil},
+	}
+	for _, test := range tests {
+		fresh := privacyNetwork(t, 4, 3)
+		fresh.GetResponse()[0].Weights[0] = 42
+		before := flatWeights(fresh)
+		opt, accountant := privacy(test.sigma)
+
+		if _, err := ResumeTrainer(path, fresh, append(test.opts, opt)...); err == nil {
+			t.Errorf("%s: ResumeTrainer() succeeded", test.name)
+		}
+		if after := flatWeights(fresh); !slices.Equal(after, before) {
+			t.Errorf("%s: ResumeTrainer() changed the network on error", test.name)
+		}
+		if accountant.Steps() != 0 {
+			t.Errorf("%s: ResumeTrainer() restored the accountant on error", test.name)
+		}
+	}
+}
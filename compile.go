@@ -0,0 +1,428 @@
+package reticulum
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+
+	"github.com/nathanleary/reticulum/layers"
+	"github.com/nathanleary/reticulum/volume"
+)
+
+// InferenceOptions configures Compile.
+type InferenceOptions struct {
+	// Logits makes the predictor return the scores fed into a softmax or pixelsoftmax
+	// output layer rather than the probabilities.
+	Logits bool
+}
+
+// inferenceOp computes the output of a layer from its input into a preallocated
+// buffer of the output dimensions.
+type inferenceOp struct {
+	out volume.Dimensions
+	run func(in, out []float64)
+}
+
+// Predictor runs a network compiled for inference. It holds its own copy of the
+// weights, so training the network afterwards does not affect it, and is safe for
+// concurrent use. Activations following fc and conv layers are applied as those
+// compute their outputs, rather than in a separate pass.
+type Predictor struct {
+	input  volume.Dimensions
+	output volume.Dimensions
+	ops    []inferenceOp
+
+	// classes is set when the output scores classes
+	classes bool
+
+	sessions sync.Pool
+}
+
+// Compile returns a predictor running net for inference only, with the training-only
+// layers removed and all buffers allocated up front. It copies the weights, so later
+// training does not affect it. It requires a network built by this package.
+//
+// The elementwise activation following an fc, conv or depthwise layer is fused into
+// that layer, and dropout scaling is folded into the weights of the next one. There
+// are no normalization layers to fold, so no other layer is merged.
+func Compile(net Network, opts InferenceOptions) (*Predictor, error) {
+	n, ok := net.(*network)
+	if !ok {
+		return nil, errors.New("compiling requires a network built by NewNetwork")
+	}
+	return n.compile(opts)
+}
+
+func (n *network) compile(opts InferenceOptions) (*Predictor, error) {
+	if n.graph != nil {
+		return nil, errors.New("compiling graph networks is not supported")
+	}
+
+	p := &Predictor{input: n.inputDims[0], output: n.inputDims[0]}
+	p.sessions.New = func() any { return p.NewSession() }
+
+	// the inference scaling of dropout layers is carried forward through layers which
+	// commute with it, and baked into the weights of the next fc, conv or depthwise
+	// layer
+	scale := 1.0
+	flush := func(dims volume.Dimensions) {
+		if scale != 1.0 {
+			p.ops = append(p.ops, scaleOp(dims, scale))
+			scale = 1.0
+		}
+	}
+
+	for i := 1; i < len(n.layers); i++ {
+		def := n.defs[i]
+		switch def.Type {
+		case layers.FullyConnected, layers.Conv, layers.Depthwise:
+			resp := n.layers[i].GetResponse()
+			var act func(float64) float64
+			if i+1 < len(n.layers) {
+				act = activation(n.defs[i+1].Type)
+			}
+			w, _ := layers.WindowOf(def)
+			switch def.Type {
+			case layers.FullyConnected:
+				p.ops = append(p.ops, fcOp(def, resp, scale, act))
+			case layers.Conv:
+				p.ops = append(p.ops, convOp(def, w, resp, scale, act))
+			default:
+				p.ops = append(p.ops, depthwiseOp(def, w, resp, scale, act))
+			}
+			scale = 1.0
+			if act != nil {
+				// the activation is fused into the layer, saving a pass over its outputs
+				i++
+			}
+		case layers.Pool:
+			w, _ := layers.WindowOf(def)
+			p.ops = append(p.ops, poolOp(def, w))
+		case layers.ReLU:
+			p.ops = append(p.ops, activationOp(def.Output, activation(def.Type)))
+		case layers.Sigmoid, layers.Tanh:
+			flush(def.Input)
+			p.ops = append(p.ops, activationOp(def.Output, activation(def.Type)))
+		case layers.Maxout:
+			conf, ok := def.LayerConfig.(*layers.MaxoutLayerConfig)
+			if !ok {
+				return nil, fmt.Errorf("layer %s: invalid maxout config", n.names[i])
+			}
+			p.ops = append(p.ops, maxoutOp(def, conf.GroupSize))
+		case layers.Dropout:
+			scale *= def.LayerConfig.(*layers.DropoutLayerConfig).DropoutProbability
+		case layers.SoftMax:
+			flush(def.Input)
+			p.classes = true
+			if !opts.Logits {
+				p.ops = append(p.ops, softmaxOp(def.Output))
+			}
+		case layers.PixelSoftMax:
+			flush(def.Input)
+			if !opts.Logits {
+				p.ops = append(p.ops, pixelSoftmaxOp(def.Output))
+			}
+		case layers.SVM:
+			p.classes = true
+		case layers.Regression:
+		default:
+			return nil, fmt.Errorf("layer %s: %s layers cannot be compiled", n.names[i], def.Type)
+		}
+		p.output = def.Output
+	}
+	flush(p.output)
+	return p, nil
+}
+
+// InputDimensions returns the dimensions of the input.
+func (p *Predictor) InputDimensions() volume.Dimensions {
+	return p.input
+}
+
+// OutputDimensions returns the dimensions of the output.
+func (p *Predictor) OutputDimensions() volume.Dimensions {
+	return p.output
+}
+
+// Forward returns the output of the network for vol.
+func (p *Predictor) Forward(vol *volume.Volume) (*volume.Volume, error) {
+	if vol == nil {
+		return nil, errors.New("volume cannot be nil")
+	}
+	s := p.sessions.Get().(*Session)
+	defer p.sessions.Put(s)
+
+	res, err := s.Run(vol.Weights())
+	if err != nil {
+		return nil, err
+	}
+	out := volume.NewVolume(p.output, volume.WithZeros())
+	copy(out.Weights(), res)
+	return out, nil
+}
+
+// Predict returns the class with the highest output for vol.
+func (p *Predictor) Predict(vol *volume.Volume) (int, error) {
+	if !p.classes {
+		return 0, errors.New("prediction requires a softmax or svm output layer")
+	} else if vol == nil {
+		return 0, errors.New("volume cannot be nil")
+	}
+	s := p.sessions.Get().(*Session)
+	defer p.sessions.Put(s)
+
+	res, err := s.Run(vol.Weights())
+	if err != nil {
+		return 0, err
+	}
+	return argmax(res), nil
+}
+
+// Session holds the buffers for running a Predictor, allocated once. It is not safe
+// for concurrent use; use one session per goroutine.
+type Session struct {
+	p    *Predictor
+	bufs [][]float64
+}
+
+// NewSession allocates the buffers for running the predictor.
+func (p *Predictor) NewSession() *Session {
+	s := &Session{p: p, bufs: make([][]float64, len(p.ops))}
+	for i, op := range p.ops {
+		s.bufs[i] = make([]float64, op.out.Size())
+	}
+	return s
+}
+
+// Run computes the output of the network for the input values, laid out as the
+// weights of a volume of the input dimensions. The returned slice belongs to the
+// session and is overwritten by the next call.
+func (s *Session) Run(input []float64) ([]float64, error) {
+	if len(input) != s.p.input.Size() {
+		return nil, fmt.Errorf("input has %d values, expected %d", len(input), s.p.input.Size())
+	}
+	cur := input
+	for i, op := range s.p.ops {
+		op.run(cur, s.bufs[i])
+		cur = s.bufs[i]
+	}
+	return cur, nil
+}
+
+// activation returns the function computed by an activation layer, or nil for other
+// layer types.
+func activation(t layers.LayerType) func(float64) float64 {
+	switch t {
+	case layers.ReLU:
+		return func(v float64) float64 { return math.Max(v, 0) }
+	case layers.Sigmoid:
+		return func(v float64) float64 { return 1.0 / (1.0 + math.Exp(-v)) }
+	case layers.Tanh:
+		return math.Tanh
+	}
+	return nil
+}
+
+func scaleOp(dims volume.Dimensions, scale float64) inferenceOp {
+	return inferenceOp{dims, func(in, out []float64) {
+		for i, v := range in {
+			out[i] = v * scale
+		}
+	}}
+}
+
+func activationOp(dims volume.Dimensions, f func(float64) float64) inferenceOp {
+	return inferenceOp{dims, func(in, out []float64) {
+		for i, v := range in {
+			out[i] = f(v)
+		}
+	}}
+}
+
+// fcOp copies the filters of a fully connected layer into a single matrix, with the
+// weights multiplied by scale. The activation act is applied to the outputs unless nil.
+func fcOp(def layers.LayerDef, resp []layers.LayerResponse, scale float64, act func(float64) float64) inferenceOp {
+	n, size := def.Output.Size(), def.Input.Size()
+	weights := make([]float64, 0, n*size)
+	for _, r := range resp[:n] {
+		for _, w := range r.Weights {
+			weights = append(weights, w*scale)
+		}
+	}
+	biases := append([]float64(nil), resp[n].Weights...)
+
+	return inferenceOp{def.Output, func(in, out []float64) {
+		for i := range out {
+			row := weights[i*size : (i+1)*size]
+			a := biases[i]
+			for j, w := range row {
+				a += w * in[j]
+			}
+			if act != nil {
+				a = act(a)
+			}
+			out[i] = a
+		}
+	}}
+}
+
+// convOp copies the filters of a conv layer, with the weights multiplied by scale.
+// The activation act is applied to the outputs unless nil.
+func convOp(def layers.LayerDef, win layers.Window, resp []layers.LayerResponse, scale float64, act func(float64) float64) inferenceOp {
+	in, outDim := def.Input, def.Output
+	fsize := win.Sx * win.Sy * in.Z
+	filters := make([]float64, 0, outDim.Z*fsize)
+	for _, r := range resp[:outDim.Z] {
+		for _, w := range r.Weights {
+			filters = append(filters, w*scale)
+		}
+	}
+	biases := append([]float64(nil), resp[outDim.Z].Weights...)
+
+	return inferenceOp{outDim, func(vol, out []float64) {
+		for d := 0; d < outDim.Z; d++ {
+			f := filters[d*fsize : (d+1)*fsize]
+			y := -win.Padding
+			for ay := 0; ay < outDim.Y; ay, y = ay+1, y+win.Stride {
+				x := -win.Padding
+				for ax := 0; ax < outDim.X; ax, x = ax+1, x+win.Stride {
+					a := biases[d]
+					for fy := 0; fy < win.Sy; fy++ {
+						oy := y + fy
+						if oy < 0 || oy >= in.Y {
+							continue
+						}
+						for fx := 0; fx < win.Sx; fx++ {
+							ox := x + fx
+							if ox < 0 || ox >= in.X {
+								continue
+							}
+							fi, vi := ((win.Sx*fy)+fx)*in.Z, ((in.X*oy)+ox)*in.Z
+							for fz := 0; fz < in.Z; fz++ {
+								a += f[fi+fz] * vol[vi+fz]
+							}
+						}
+					}
+					if act != nil {
+						a = act(a)
+					}
+					out[((outDim.X*ay)+ax)*outDim.Z+d] = a
+				}
+			}
+		}
+	}}
+}
+
+func depthwiseOp(def layers.LayerDef, win layers.Window, resp []layers.LayerResponse, scale float64, act func(float64) float64) inferenceOp {
+	in, outDim := def.Input, def.Output
+	fsize := win.Sx * win.Sy
+	filters := make([]float64, 0, outDim.Z*fsize)
+	for _, r := range resp[:outDim.Z] {
+		for _, w := range r.Weights {
+			filters = append(filters, w*scale)
+		}
+	}
+	biases := append([]float64(nil), resp[outDim.Z].Weights...)
+
+	return inferenceOp{outDim, func(vol, out []float64) {
+		for d := 0; d < outDim.Z; d++ {
+			f := filters[d*fsize : (d+1)*fsize]
+			y := -win.Padding
+			for ay := 0; ay < outDim.Y; ay, y = ay+1, y+win.Stride {
+				x := -win.Padding
+				for ax := 0; ax < outDim.X; ax, x = ax+1, x+win.Stride {
+					a := biases[d]
+					for fy := 0; fy < win.Sy; fy++ {
+						oy := y + fy
+						if oy < 0 || oy >= in.Y {
+							continue
+						}
+						for fx := 0; fx < win.Sx; fx++ {
+							ox := x + fx
+							if ox < 0 || ox >= in.X {
+								continue
+							}
+							a += f[win.Sx*fy+fx] * vol[((in.X*oy)+ox)*in.Z+d]
+						}
+					}
+					if act != nil {
+						a = act(a)
+					}
+					out[((outDim.X*ay)+ax)*outDim.Z+d] = a
+				}
+			}
+		}
+	}}
+}
+
+func poolOp(def layers.LayerDef, win layers.Window) inferenceOp {
+	in, outDim := def.Input, def.Output
+	return inferenceOp{outDim, func(vol, out []float64) {
+		for d := 0; d < outDim.Z; d++ {
+			y := -win.Padding
+			for ay := 0; ay < outDim.Y; ay, y = ay+1, y+win.Stride {
+				x := -win.Padding
+				for ax := 0; ax < outDim.X; ax, x = ax+1, x+win.Stride {
+					a := -1e5
+					for fy := 0; fy < win.Sy; fy++ {
+						oy := y + fy
+						if oy < 0 || oy >= in.Y {
+							continue
+						}
+						for fx := 0; fx < win.Sx; fx++ {
+							ox := x + fx
+							if ox >= 0 && ox < in.X {
+								a = math.Max(a, vol[((in.X*oy)+ox)*in.Z+d])
+							}
+						}
+					}
+					out[((outDim.X*ay)+ax)*outDim.Z+d] = a
+				}
+			}
+		}
+	}}
+}
+
+func maxoutOp(def layers.LayerDef, group int) inferenceOp {
+	in, outDim := def.Input, def.Output
+	return inferenceOp{outDim, func(vol, out []float64) {
+		for p := 0; p < outDim.X*outDim.Y; p++ {
+			src, dst := vol[p*in.Z:], out[p*outDim.Z:]
+			for i := 0; i < outDim.Z; i++ {
+				a := src[i*group]
+				for j := 1; j < group; j++ {
+					a = math.Max(a, src[i*group+j])
+				}
+				dst[i] = a
+			}
+		}
+	}}
+}
+
+// pixelSoftmaxOp applies the softmax along the depth at every position.
+func pixelSoftmaxOp(dims volume.Dimensions) inferenceOp {
+	softmax := softmaxOp(volume.NewDimensions(1, 1, dims.Z)).run
+	return inferenceOp{dims, func(in, out []float64) {
+		for i := 0; i < len(in); i += dims.Z {
+			softmax(in[i:i+dims.Z], out[i:i+dims.Z])
+		}
+	}}
+}
+
+func softmaxOp(dims volume.Dimensions) inferenceOp {
+	return inferenceOp{dims, func(in, out []float64) {
+		amax := in[0]
+		for _, v := range in {
+			amax = math.Max(amax, v)
+		}
+		var sum float64
+		for i, v := range in {
+			out[i] = math.Exp(v - amax)
+			sum += out[i]
+		}
+		for i := range out {
+			out[i] /= sum
+		}
+	}}
+}
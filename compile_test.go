@@ -0,0 +1,62 @@
+package reticulum
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/nathanleary/reticulum/volume"
+)
+
+func TestCompile_FusesActivations(t *testing.T) {
+	for _, test := range []struct {
+		name  string
+		build *Builder
+		ops   int
+	}{
+		// conv+relu, pool, fc+tanh, fc with the dropout scaling, softmax
+		{"relu and tanh", NewBuilder(WithNetworkSeed(1)).Input(6, 6, 2).Conv(3, 3).Relu().Pool(2).FC(4).Tanh().Dropout(0.5).Softmax(3), 5},
+		// depthwise+sigmoid, fc
+		{"sigmoid", NewBuilder(WithNetworkSeed(1)).Input(5, 5, 3).Depthwise(3).Sigmoid().Regression(2), 2},
+	} {
+		net, err := test.build.Build()
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		p, err := Compile(net, InferenceOptions{})
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if len(p.ops) != test.ops {
+			t.Errorf("%s: %d ops, want %d", test.name, len(p.ops), test.ops)
+		}
+
+		r := rand.New(rand.NewSource(1))
+		for i := 0; i < 3; i++ {
+			vol := volume.NewVolume(InputDimensions(net)[0], volume.WithRand(r))
+			want := net.Forward(vol, false).Weights()
+			got, err := p.Forward(vol)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for j, w := range want {
+				if math.Abs(got.Weights()[j]-w) > 1e-9 {
+					t.Fatalf("%s: output %d = %v, want %v", test.name, j, got.Weights()[j], w)
+				}
+			}
+		}
+	}
+}
+
+func TestCompile_Errors(t *testing.T) {
+	net, err := NewBuilder().Input(1, 1, 3).Embedding(4, 2).FC(2).Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Compile(net, InferenceOptions{}); err == nil {
+		t.Error("expected an error compiling an embedding layer")
+	}
+	if _, err := Compile(nil, InferenceOptions{}); err == nil {
+		t.Error("expected an error compiling a foreign network")
+	}
+}
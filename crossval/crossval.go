@@ -0,0 +1,193 @@
+// Package crossval estimates how well a network architecture and training setup
+// generalize by k-fold cross-validation: the data is split into k folds and k networks
+// are trained, each validated on the fold it did not see.
+package crossval
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+
+	"github.com/nathanleary/reticulum"
+)
+
+// Options configures a cross-validation run.
+type Options struct {
+	// Stratified keeps the class proportions of the data in every fold.
+	Stratified bool
+
+	// KeepModels keeps the network trained on every fold in the result, e.g. to
+	// ensemble them.
+	KeepModels bool
+
+	// Seed makes the assignment of samples to folds reproducible when HasSeed is set.
+	Seed    int64
+	HasSeed bool
+}
+
+// OptionFunc modifies the Options of a run.
+type OptionFunc func(*Options)
+
+// WithStratified keeps the class proportions in every fold.
+func WithStratified() OptionFunc {
+	return func(opts *Options) {
+		opts.Stratified = true
+	}
+}
+
+// WithModels keeps the trained networks in the result.
+func WithModels() OptionFunc {
+	return func(opts *Options) {
+		opts.KeepModels = true
+	}
+}
+
+// WithSeed seeds the assignment of samples to folds.
+func WithSeed(seed int64) OptionFunc {
+	return func(opts *Options) {
+		opts.Seed = seed
+		opts.HasSeed = true
+	}
+}
+
+// Fold is the outcome of training on all folds but one.
+type Fold struct {
+	// History is the training history, including the metrics on the held-out fold
+	// prefixed with "val_" after every epoch.
+	History *reticulum.History
+
+	// Metrics are the Evaluate metrics of the final network on the held-out fold.
+	Metrics reticulum.Metrics
+
+	// Model is the trained network, if the models are kept.
+	Model reticulum.Network
+}
+
+// Result aggregates the folds of a run.
+type Result struct {
+	Folds []Fold
+
+	// Mean and Std hold the mean and sample standard deviation of every metric over
+	// the folds.
+	Mean reticulum.Metrics
+	Std  reticulum.Metrics
+}
+
+// Models returns the networks trained on each fold, or nil if they were not kept.
+func (r *Result) Models() []reticulum.Network {
+	var models []reticulum.Network
+	for _, f := range r.Folds {
+		if f.Model != nil {
+			models = append(models, f.Model)
+		}
+	}
+	return models
+}
+
+// String summarizes the metrics as mean ± std, sorted by name.
+func (r *Result) String() string {
+	names := make([]string, 0, len(r.Mean))
+	for name := range r.Mean {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	s := fmt.Sprintf("%d folds:", len(r.Folds))
+	for _, name := range names {
+		s += fmt.Sprintf(" %s=%.4f±%.4f", name, r.Mean[name], r.Std[name])
+	}
+	return s
+}
+
+// Run trains a fresh network from buildNet on every k-1 folds of data with Fit and
+// evaluates it on the remaining fold.
+func Run(k int, buildNet func() reticulum.Network, data []reticulum.Sample, fitOpts reticulum.FitOptions, optFuncs ...OptionFunc) (*Result, error) {
+	opts := &Options{}
+	for _, optFn := range optFuncs {
+		optFn(opts)
+	}
+	if buildNet == nil {
+		return nil, errors.New("network builder cannot be nil")
+	} else if k < 2 {
+		return nil, fmt.Errorf("cross-validation requires at least 2 folds, got %d", k)
+	} else if k > len(data) {
+		return nil, fmt.Errorf("cannot split %d samples into %d folds", len(data), k)
+	}
+
+	seed := rand.Int63()
+	if opts.HasSeed {
+		seed = opts.Seed
+	}
+	folds := assign(data, k, opts.Stratified, rand.New(rand.NewSource(seed)))
+
+	res := &Result{Folds: make([]Fold, k)}
+	for i := range folds {
+		var train, val []reticulum.Sample
+		for j, fold := range folds {
+			for _, idx := range fold {
+				if j == i {
+					val = append(val, data[idx])
+				} else {
+					train = append(train, data[idx])
+				}
+			}
+		}
+
+		net := buildNet()
+		if net == nil {
+			return nil, fmt.Errorf("fold %d: network builder returned nil", i)
+		}
+		history, err := reticulum.Fit(net, train, val, fitOpts)
+		if err != nil {
+			return nil, fmt.Errorf("fold %d: %w", i, err)
+		}
+
+		res.Folds[i] = Fold{History: history, Metrics: reticulum.Evaluate(net, val).Metrics()}
+		if opts.KeepModels {
+			res.Folds[i].Model = net
+		}
+	}
+
+	res.Mean, res.Std = aggregate(res.Folds)
+	return res, nil
+}
+
+// assign shuffles the sample indexes into k folds of nearly equal size. Stratified
+// folds deal the samples of every class in turn, so each fold gets its share.
+func assign(data []reticulum.Sample, k int, stratified bool, rng *rand.Rand) [][]int {
+	order := rng.Perm(len(data))
+	if stratified {
+		// a stable sort by label keeps the shuffled order within each class
+		sort.SliceStable(order, func(a, b int) bool {
+			return data[order[a]].Label < data[order[b]].Label
+		})
+	}
+
+	folds := make([][]int, k)
+	for i, idx := range order {
+		folds[i%k] = append(folds[i%k], idx)
+	}
+	return folds
+}
+
+// aggregate computes the mean and sample standard deviation of the fold metrics.
+func aggregate(folds []Fold) (reticulum.Metrics, reticulum.Metrics) {
+	mean, std := reticulum.Metrics{}, reticulum.Metrics{}
+	for _, f := range folds {
+		for name, v := range f.Metrics {
+			mean[name] += v / float64(len(folds))
+		}
+	}
+	for _, f := range folds {
+		for name, v := range f.Metrics {
+			d := v - mean[name]
+			std[name] += d * d / float64(len(folds)-1)
+		}
+	}
+	for name, v := range std {
+		std[name] = math.Sqrt(v)
+	}
+	return mean, std
+}
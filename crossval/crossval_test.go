@@ -0,0 +1,115 @@
+package crossval
+
+import (
+	"math/rand"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/nathanleary/reticulum"
+	"github.com/nathanleary/reticulum/layers"
+	"github.com/nathanleary/reticulum/volume"
+)
+
+// blobs returns n samples of three classes, each class around its own corner.
+func blobs(n int) []reticulum.Sample {
+	r := rand.New(rand.NewSource(1))
+	data := make([]reticulum.Sample, n)
+	for i := range data {
+		label := i % 3
+		w := []float64{0.3 * r.NormFloat64(), 0.3 * r.NormFloat64()}
+		w[label%2] += float64(2*label - 2)
+		data[i] = reticulum.Sample{Input: volume.NewVolume(volume.NewDimensions(1, 1, 2), volume.WithWeights(w)), Label: label}
+	}
+	return data
+}
+
+func buildNet() reticulum.Network {
+	net, err := reticulum.NewNetwork([]layers.LayerDef{
+		{Type: layers.Input, Output: volume.NewDimensions(1, 1, 2)},
+		{Type: layers.SoftMax, LayerConfig: layers.NewSoftmaxLayerConfig(3)},
+	}, reticulum.WithNetworkSeed(1))
+	if err != nil {
+		panic(err)
+	}
+	return net
+}
+
+func TestRun(t *testing.T) {
+	fitOpts := reticulum.FitOptions{Epochs: 10, BatchSize: 4, Options: []reticulum.OptionFunc{
+		reticulum.WithMethod(reticulum.Adam), reticulum.WithLearningRate(0.05),
+	}}
+	for _, test := range []struct {
+		name   string
+		k      int
+		opts   []OptionFunc
+		models int
+	}{
+		{"plain", 3, []OptionFunc{WithSeed(1)}, 0},
+		{"stratified with models", 4, []OptionFunc{WithStratified(), WithModels(), WithSeed(1)}, 4},
+	} {
+		res, err := Run(test.k, buildNet, blobs(60), fitOpts, test.opts...)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if len(res.Folds) != test.k || len(res.Models()) != test.models {
+			t.Errorf("%s: %d folds and %d models, want %d and %d", test.name, len(res.Folds), len(res.Models()), test.k, test.models)
+		}
+		for i, f := range res.Folds {
+			if len(f.History.Epochs) != fitOpts.Epochs {
+				t.Errorf("%s: fold %d trained for %d epochs, want %d", test.name, i, len(f.History.Epochs), fitOpts.Epochs)
+			}
+		}
+		if acc := res.Mean["accuracy"]; acc < 0.9 {
+			t.Errorf("%s: mean accuracy %v", test.name, acc)
+		}
+		if _, ok := res.Std["loss"]; !ok || !strings.Contains(res.String(), "accuracy=") {
+			t.Errorf("%s: unexpected summary %s", test.name, res)
+		}
+	}
+}
+
+func TestAssign(t *testing.T) {
+	data := blobs(30)
+	for _, stratified := range []bool{false, true} {
+		folds := assign(data, 4, stratified, rand.New(rand.NewSource(1)))
+		var all []int
+		for i, fold := range folds {
+			if len(fold) < 7 || len(fold) > 8 {
+				t.Errorf("stratified=%v: fold %d has %d samples", stratified, i, len(fold))
+			}
+			classes := make([]int, 3)
+			for _, idx := range fold {
+				classes[data[idx].Label]++
+			}
+			if stratified && (slices.Min(classes) < 2 || slices.Max(classes) > 3) {
+				t.Errorf("stratified fold %d has classes %v", i, classes)
+			}
+			all = append(all, fold...)
+		}
+		slices.Sort(all)
+		for i, idx := range all {
+			if idx != i {
+				t.Fatalf("stratified=%v: folds do not hold every sample once: %v", stratified, all)
+			}
+		}
+	}
+}
+
+func TestRun_Errors(t *testing.T) {
+	data := blobs(6)
+	for _, test := range []struct {
+		name  string
+		k     int
+		build func() reticulum.Network
+	}{
+		{"nil builder", 2, nil},
+		{"one fold", 1, buildNet},
+		{"more folds than samples", 7, buildNet},
+		{"nil network", 2, func() reticulum.Network { return nil }},
+	} {
+		if _, err := Run(test.k, test.build, data, reticulum.FitOptions{Epochs: 1}); err == nil {
+			t.Errorf("%s: expected an error", test.name)
+		}
+	}
+}
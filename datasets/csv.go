@@ -0,0 +1,333 @@
+// Package datasets loads training data from common file formats into samples for
+// reticulum networks, and feeds samples to training in shuffled mini-batches loaded
+// in the background.
+package datasets
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/nathanleary/reticulum"
+	"github.com/nathanleary/reticulum/volume"
+)
+
+// Schema maps the columns of a CSV file to the features and label of the samples.
+// Columns are named by the header, or by their zero-based index when the file has
+// none.
+type Schema struct {
+	// Features names the feature columns, defaulting to every column but the label.
+	Features []string
+
+	// Label names the label column, read as a class unless Regression is set.
+	Label string
+
+	// Regression reads the label as a float target.
+	Regression bool
+
+	// Categorical names feature columns to one-hot encode. Columns with values which
+	// are not numbers are encoded this way anyway.
+	Categorical []string
+
+	// Normalize scales numeric features to zero mean and unit variance.
+	Normalize bool
+
+	// NoHeader is set when the first record holds data rather than column names.
+	NoHeader bool
+
+	// Comma is the field delimiter, defaulting to ','.
+	Comma rune
+}
+
+// Dataset holds the samples read from a file and what is needed to encode new data
+// the same way.
+type Dataset struct {
+	Samples []reticulum.Sample
+
+	// Features names every encoded feature in the order of the sample inputs, e.g.
+	// "width" for a numeric column and "color=red" for a one-hot encoded one.
+	Features []string
+
+	// Classes holds the label value of each class index, nil for regression. Integer
+	// labels are used as the class index directly, others are numbered in sorted order.
+	Classes []string
+
+	// Mean and Std hold the statistics of each encoded feature used to normalize it,
+	// nil unless the schema normalizes. One-hot features are not normalized and have
+	// a mean of 0 and a std of 1.
+	Mean []float64
+	Std  []float64
+
+	// Skipped counts the rows dropped for a missing label.
+	Skipped int
+}
+
+// missingValues are the values read as missing, compared case-insensitively. Missing
+// numeric features are replaced by the mean of the column, missing categorical ones
+// leave every one-hot feature at 0.
+var missingValues = []string{"", "na", "n/a", "nan", "null", "?"}
+
+func isMissing(v string) bool {
+	return slices.Contains(missingValues, strings.ToLower(strings.TrimSpace(v)))
+}
+
+// column describes how a feature column is encoded.
+type column struct {
+	index      int
+	name       string
+	categories []string // sorted values of a categorical column, nil if numeric
+	mean       float64
+}
+
+// FromCSV reads the samples described by schema from r. Each sample's input is a 1x1xN
+// volume of the encoded features.
+func FromCSV(r io.Reader, schema Schema) (*Dataset, error) {
+	cr := csv.NewReader(r)
+	cr.TrimLeadingSpace = true
+	if schema.Comma != 0 {
+		cr.Comma = schema.Comma
+	}
+	records, err := cr.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, errors.New("csv has no records")
+	}
+
+	// name the columns
+	names := make([]string, len(records[0]))
+	for i := range names {
+		names[i] = strconv.Itoa(i)
+	}
+	if !schema.NoHeader {
+		for i, name := range records[0] {
+			names[i] = strings.TrimSpace(name)
+		}
+		records = records[1:]
+	}
+	find := func(name string) (int, error) {
+		i := slices.Index(names, name)
+		if i < 0 {
+			return 0, fmt.Errorf("unknown column %q", name)
+		}
+		return i, nil
+	}
+
+	if schema.Label == "" {
+		return nil, errors.New("schema requires a label column")
+	}
+	label, err := find(schema.Label)
+	if err != nil {
+		return nil, err
+	}
+
+	// drop the rows without a label
+	ds := &Dataset{}
+	rows := records[:0]
+	for _, rec := range records {
+		if isMissing(rec[label]) {
+			ds.Skipped++
+			continue
+		}
+		rows = append(rows, rec)
+	}
+	if len(rows) == 0 {
+		return nil, errors.New("csv has no labeled rows")
+	}
+
+	features := schema.Features
+	if len(features) == 0 {
+		for i, name := range names {
+			if i != label {
+				features = append(features, name)
+			}
+		}
+	}
+	cols := make([]*column, len(features))
+	for i, name := range features {
+		idx, err := find(name)
+		if err != nil {
+			return nil, err
+		} else if idx == label {
+			return nil, fmt.Errorf("column %q cannot be both a feature and the label", name)
+		}
+		cols[i] = scanColumn(rows, idx, name, slices.Contains(schema.Categorical, name))
+	}
+
+	for _, c := range cols {
+		if c.categories == nil {
+			ds.Features = append(ds.Features, c.name)
+			continue
+		}
+		for _, v := range c.categories {
+			ds.Features = append(ds.Features, c.name+"="+v)
+		}
+	}
+
+	var classes map[string]int
+	if !schema.Regression {
+		classes, ds.Classes = classIndexes(rows, label)
+	}
+
+	n := len(ds.Features)
+	ds.Samples = make([]reticulum.Sample, len(rows))
+	for r, rec := range rows {
+		w := make([]float64, 0, n)
+		for _, c := range cols {
+			w = c.encode(w, rec[c.index])
+		}
+		s := reticulum.Sample{Input: volume.NewVolume(volume.NewDimensions(1, 1, n), volume.WithWeights(w))}
+		if schema.Regression {
+			y, err := strconv.ParseFloat(strings.TrimSpace(rec[label]), 64)
+			if err != nil {
+				return nil, fmt.Errorf("row %d: label %q is not a number", r+1, rec[label])
+			}
+			s.Target = []float64{y}
+		} else {
+			s.Label = classes[strings.TrimSpace(rec[label])]
+		}
+		ds.Samples[r] = s
+	}
+
+	if schema.Normalize {
+		ds.normalize(cols)
+	}
+	return ds, nil
+}
+
+// scanColumn decides how the column is encoded, collecting its categories or mean.
+func scanColumn(rows [][]string, idx int, name string, categorical bool) *column {
+	c := &column{index: idx, name: name}
+	var sum float64
+	var count int
+	for _, rec := range rows {
+		v := strings.TrimSpace(rec[idx])
+		if isMissing(v) {
+			continue
+		}
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			categorical = true
+			break
+		}
+		sum += f
+		count++
+	}
+	if !categorical {
+		if count > 0 {
+			c.mean = sum / float64(count)
+		}
+		return c
+	}
+
+	seen := map[string]bool{}
+	for _, rec := range rows {
+		v := strings.TrimSpace(rec[idx])
+		if !isMissing(v) && !seen[v] {
+			seen[v] = true
+			c.categories = append(c.categories, v)
+		}
+	}
+	slices.Sort(c.categories)
+	return c
+}
+
+// encode appends the features of value to w.
+func (c *column) encode(w []float64, value string) []float64 {
+	value = strings.TrimSpace(value)
+	if c.categories == nil {
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil || isMissing(value) {
+			f = c.mean
+		}
+		return append(w, f)
+	}
+	for _, v := range c.categories {
+		if v == value {
+			w = append(w, 1)
+		} else {
+			w = append(w, 0)
+		}
+	}
+	return w
+}
+
+// classIndexes numbers the distinct labels. If every label is a non-negative integer
+// it is its own index, otherwise the labels are numbered in sorted order.
+func classIndexes(rows [][]string, label int) (map[string]int, []string) {
+	indexes := map[string]int{}
+	integers := true
+	maxLabel := 0
+	for _, rec := range rows {
+		v := strings.TrimSpace(rec[label])
+		if _, ok := indexes[v]; ok {
+			continue
+		}
+		i, err := strconv.Atoi(v)
+		if err != nil || i < 0 {
+			integers = false
+		}
+		maxLabel = max(maxLabel, i)
+		indexes[v] = i
+	}
+
+	if integers {
+		names := make([]string, maxLabel+1)
+		for i := range names {
+			names[i] = strconv.Itoa(i)
+		}
+		return indexes, names
+	}
+
+	var names []string
+	for v := range indexes {
+		names = append(names, v)
+	}
+	slices.Sort(names)
+	for i, v := range names {
+		indexes[v] = i
+	}
+	return indexes, names
+}
+
+// normalize standardizes the numeric features of every sample.
+func (ds *Dataset) normalize(cols []*column) {
+	n := len(ds.Features)
+	ds.Mean = make([]float64, n)
+	ds.Std = make([]float64, n)
+	for i := range ds.Std {
+		ds.Std[i] = 1
+	}
+
+	var f int
+	for _, c := range cols {
+		if c.categories != nil {
+			f += len(c.categories)
+			continue
+		}
+		var sum, sq float64
+		for _, s := range ds.Samples {
+			sum += s.Input.GetByIndex(f)
+		}
+		mean := sum / float64(len(ds.Samples))
+		for _, s := range ds.Samples {
+			d := s.Input.GetByIndex(f) - mean
+			sq += d * d
+		}
+		std := math.Sqrt(sq / float64(len(ds.Samples)))
+		if std == 0 {
+			std = 1
+		}
+		ds.Mean[f], ds.Std[f] = mean, std
+		for _, s := range ds.Samples {
+			s.Input.SetByIndex(f, (s.Input.GetByIndex(f)-mean)/std)
+		}
+		f++
+	}
+}
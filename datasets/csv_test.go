@@ -0,0 +1,120 @@
+package datasets
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+const flowers = `width, color, size, kind
+1.0, red, 2, rose
+3.0, blue, ?, violet
+NA, red, 4, rose
+2.0, blue, 6,
+`
+
+func TestFromCSV(t *testing.T) {
+	for _, test := range []struct {
+		name     string
+		csv      string
+		schema   Schema
+		features []string
+		classes  []string
+		inputs   [][]float64
+		labels   []int
+		targets  []float64
+	}{
+		{
+			name:     "classes",
+			csv:      flowers,
+			schema:   Schema{Label: "kind"},
+			features: []string{"width", "color=blue", "color=red", "size"},
+			classes:  []string{"rose", "violet"},
+			// missing numbers are replaced by the mean of the column over the labeled rows
+			inputs: [][]float64{{1, 0, 1, 2}, {3, 1, 0, 3}, {2, 0, 1, 4}},
+			labels: []int{0, 1, 0},
+		},
+		{
+			name:     "regression",
+			csv:      flowers,
+			schema:   Schema{Label: "size", Features: []string{"width"}, Regression: true},
+			features: []string{"width"},
+			inputs:   [][]float64{{1}, {1.5}, {2}},
+			targets:  []float64{2, 4, 6},
+		},
+		{
+			name:     "categorical numbers without header",
+			csv:      "1;0\n2;1\n1;2\n",
+			schema:   Schema{Label: "1", Categorical: []string{"0"}, NoHeader: true, Comma: ';'},
+			features: []string{"0=1", "0=2"},
+			classes:  []string{"0", "1", "2"},
+			inputs:   [][]float64{{1, 0}, {0, 1}, {1, 0}},
+			labels:   []int{0, 1, 2},
+		},
+		{
+			name:     "normalized",
+			csv:      "x,y\n1,a\n3,b\n",
+			schema:   Schema{Label: "y", Normalize: true},
+			features: []string{"x"},
+			classes:  []string{"a", "b"},
+			inputs:   [][]float64{{-1}, {1}},
+			labels:   []int{0, 1},
+		},
+	} {
+		ds, err := FromCSV(strings.NewReader(test.csv), test.schema)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if strings.Join(ds.Features, " ") != strings.Join(test.features, " ") || strings.Join(ds.Classes, " ") != strings.Join(test.classes, " ") {
+			t.Errorf("%s: features %v and classes %v, want %v and %v", test.name, ds.Features, ds.Classes, test.features, test.classes)
+		}
+		if len(ds.Samples) != len(test.inputs) {
+			t.Fatalf("%s: %d samples, want %d", test.name, len(ds.Samples), len(test.inputs))
+		}
+		for i, s := range ds.Samples {
+			for j, want := range test.inputs[i] {
+				if got := s.Input.GetByIndex(j); math.Abs(got-want) > 1e-12 {
+					t.Errorf("%s: sample %d feature %d = %v, want %v", test.name, i, j, got, want)
+				}
+			}
+			if test.targets != nil {
+				if s.Target[0] != test.targets[i] {
+					t.Errorf("%s: sample %d target %v, want %v", test.name, i, s.Target, test.targets[i])
+				}
+			} else if s.Label != test.labels[i] {
+				t.Errorf("%s: sample %d label %d, want %d", test.name, i, s.Label, test.labels[i])
+			}
+		}
+	}
+}
+
+func TestFromCSV_SkipsUnlabeledRows(t *testing.T) {
+	ds, err := FromCSV(strings.NewReader(flowers), Schema{Label: "kind"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ds.Skipped != 1 || ds.Len() != 3 {
+		t.Errorf("skipped %d rows and kept %d, want 1 and 3", ds.Skipped, ds.Len())
+	}
+}
+
+func TestFromCSV_Errors(t *testing.T) {
+	for _, test := range []struct {
+		name   string
+		csv    string
+		schema Schema
+	}{
+		{"empty", "", Schema{Label: "y"}},
+		{"no label", flowers, Schema{}},
+		{"unknown label", flowers, Schema{Label: "weight"}},
+		{"unknown feature", flowers, Schema{Label: "kind", Features: []string{"weight"}}},
+		{"label as feature", flowers, Schema{Label: "kind", Features: []string{"kind"}}},
+		{"no labeled rows", "x,y\n1,\n", Schema{Label: "y"}},
+		{"non-numeric target", flowers, Schema{Label: "kind", Regression: true}},
+		{"ragged", "x,y\n1,2,3\n", Schema{Label: "y"}},
+	} {
+		if _, err := FromCSV(strings.NewReader(test.csv), test.schema); err == nil {
+			t.Errorf("%s: expected an error", test.name)
+		}
+	}
+}
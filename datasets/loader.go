@@ -0,0 +1,359 @@
+package datasets
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"runtime"
+
+	"github.com/nathanleary/reticulum"
+	"github.com/nathanleary/reticulum/transform"
+	"github.com/nathanleary/reticulum/volume"
+)
+
+// Source gives random access to the samples of a dataset, which may be read and
+// decoded on demand. Sample is called from several goroutines at once.
+type Source interface {
+	Len() int
+	Sample(i int) (reticulum.Sample, error)
+}
+
+// Samples is a Source of samples held in memory.
+type Samples []reticulum.Sample
+
+func (s Samples) Len() int {
+	return len(s)
+}
+
+func (s Samples) Sample(i int) (reticulum.Sample, error) {
+	return s[i], nil
+}
+
+// Len returns the number of samples.
+func (ds *Dataset) Len() int {
+	return len(ds.Samples)
+}
+
+// Sample returns the sample at index i.
+func (ds *Dataset) Sample(i int) (reticulum.Sample, error) {
+	return ds.Samples[i], nil
+}
+
+// LoaderOptions configures a DataLoader.
+type LoaderOptions struct {
+	// BatchSize is the number of samples per batch, 1 by default.
+	BatchSize int
+
+	// Shuffle visits the samples in a new random order every epoch.
+	Shuffle bool
+
+	// Sampler chooses the samples of every epoch instead, e.g. to oversample rare
+	// classes. Shuffle is ignored when it is set.
+	Sampler Sampler
+
+	// DropLast drops the last batch of an epoch when it is smaller than BatchSize.
+	DropLast bool
+
+	// Workers is the number of goroutines loading batches, GOMAXPROCS by default.
+	Workers int
+
+	// Prefetch bounds the number of batches loaded ahead of the consumer, twice the
+	// number of workers by default.
+	Prefetch int
+
+	// Transform augments the input of every sample as it is loaded.
+	Transform transform.Transform
+
+	// Seed makes the shuffling, sampling and transforms reproducible when HasSeed is
+	// set.
+	Seed    int64
+	HasSeed bool
+}
+
+// LoaderOptionFunc modifies the LoaderOptions.
+type LoaderOptionFunc func(*LoaderOptions)
+
+// WithBatchSize sets the number of samples per batch.
+func WithBatchSize(n int) LoaderOptionFunc {
+	return func(opts *LoaderOptions) {
+		opts.BatchSize = n
+	}
+}
+
+// WithShuffle shuffles the samples every epoch.
+func WithShuffle() LoaderOptionFunc {
+	return func(opts *LoaderOptions) {
+		opts.Shuffle = true
+	}
+}
+
+// WithSampler chooses the samples of every epoch with s.
+func WithSampler(s Sampler) LoaderOptionFunc {
+	return func(opts *LoaderOptions) {
+		opts.Sampler = s
+	}
+}
+
+// WithDropLast drops incomplete batches.
+func WithDropLast() LoaderOptionFunc {
+	return func(opts *LoaderOptions) {
+		opts.DropLast = true
+	}
+}
+
+// WithWorkers sets the number of goroutines loading batches.
+func WithWorkers(n int) LoaderOptionFunc {
+	return func(opts *LoaderOptions) {
+		opts.Workers = n
+	}
+}
+
+// WithPrefetch sets the number of batches loaded ahead of the consumer.
+func WithPrefetch(n int) LoaderOptionFunc {
+	return func(opts *LoaderOptions) {
+		opts.Prefetch = n
+	}
+}
+
+// WithTransform augments every sample input with t.
+func WithTransform(t transform.Transform) LoaderOptionFunc {
+	return func(opts *LoaderOptions) {
+		opts.Transform = t
+	}
+}
+
+// WithSeed seeds the shuffling, sampling and transforms.
+func WithSeed(seed int64) LoaderOptionFunc {
+	return func(opts *LoaderOptions) {
+		opts.Seed = seed
+		opts.HasSeed = true
+	}
+}
+
+// Batch is a mini-batch of samples.
+type Batch struct {
+	// Index is the position of the batch in the epoch.
+	Index int
+
+	Samples []reticulum.Sample
+
+	// Err is the first error loading or transforming a sample of the batch, in which
+	// case Samples is nil.
+	Err error
+}
+
+// Inputs returns the input volumes of the samples, for Trainer.TrainBatch.
+func (b Batch) Inputs() []*volume.Volume {
+	vols := make([]*volume.Volume, len(b.Samples))
+	for i, s := range b.Samples {
+		vols[i] = s.Input
+	}
+	return vols
+}
+
+// Losses returns the loss functions of the samples, for Trainer.TrainBatch.
+func (b Batch) Losses() []reticulum.LossFunc {
+	losses := make([]reticulum.LossFunc, len(b.Samples))
+	for i, s := range b.Samples {
+		losses[i] = s.LossFunc()
+	}
+	return losses
+}
+
+// DataLoader assembles the samples of a source into mini-batches, loading the next
+// batches on background goroutines while the current one is trained on:
+//
+//	loader, err := datasets.NewDataLoader(src, datasets.WithBatchSize(32), datasets.WithShuffle())
+//	for epoch := 0; epoch < epochs; epoch++ {
+//		for batch := range loader.Epoch(ctx) {
+//			if batch.Err != nil {
+//				return batch.Err
+//			}
+//			trainer.TrainBatch(batch.Inputs(), batch.Losses())
+//		}
+//	}
+//
+// A DataLoader is not safe for concurrent use, and an epoch should be drained or its
+// context canceled before the next one is started.
+type DataLoader struct {
+	src  Source
+	opts LoaderOptions
+	rng  *rand.Rand
+}
+
+// NewDataLoader creates a loader over src.
+func NewDataLoader(src Source, opts ...LoaderOptionFunc) (*DataLoader, error) {
+	o := LoaderOptions{BatchSize: 1, Workers: runtime.GOMAXPROCS(0)}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if src == nil {
+		return nil, errors.New("source cannot be nil")
+	} else if o.BatchSize <= 0 {
+		return nil, fmt.Errorf("batch size must be greater than 0, got %d", o.BatchSize)
+	} else if o.Workers <= 0 {
+		return nil, fmt.Errorf("worker count must be greater than 0, got %d", o.Workers)
+	} else if o.Prefetch < 0 {
+		return nil, fmt.Errorf("prefetch cannot be negative, got %d", o.Prefetch)
+	}
+	if o.Prefetch == 0 {
+		o.Prefetch = 2 * o.Workers
+	}
+
+	seed := rand.Int63()
+	if o.HasSeed {
+		seed = o.Seed
+	}
+	return &DataLoader{src: src, opts: o, rng: rand.New(rand.NewSource(seed))}, nil
+}
+
+// Len returns the number of batches per epoch.
+func (l *DataLoader) Len() int {
+	n := l.src.Len()
+	if l.opts.DropLast {
+		return n / l.opts.BatchSize
+	}
+	return (n + l.opts.BatchSize - 1) / l.opts.BatchSize
+}
+
+// Epoch starts a pass over the source and returns its batches in order. The channel
+// is closed after the last batch, or when ctx is done. If the sampler fails, its error
+// is the only batch.
+func (l *DataLoader) Epoch(ctx context.Context) <-chan Batch {
+	order, err := l.order()
+	if err != nil {
+		out := make(chan Batch, 1)
+		out <- Batch{Err: err}
+		close(out)
+		return out
+	}
+
+	type job struct {
+		index   int
+		indexes []int
+		seed    int64
+		res     chan Batch
+	}
+
+	// every batch transforms its samples with its own random source, so the result does not
+	// depend on the worker loading it
+	seeds := make([]int64, l.Len())
+	if l.opts.Transform != nil {
+		for i := range seeds {
+			seeds[i] = l.rng.Int63()
+		}
+	}
+	jobs := make(chan job, l.opts.Workers)
+
+	// pending holds the result of every batch in order, bounding how far the workers
+	// can get ahead of the consumer
+	pending := make(chan chan Batch, l.opts.Prefetch)
+
+	go func() {
+		defer close(jobs)
+		defer close(pending)
+		for b := range seeds {
+			start := b * l.opts.BatchSize
+			end := min(start+l.opts.BatchSize, len(order))
+			j := job{b, order[start:end], seeds[b], make(chan Batch, 1)}
+			select {
+			case pending <- j.res:
+			case <-ctx.Done():
+				return
+			}
+			select {
+			case jobs <- j:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	for w := 0; w < l.opts.Workers; w++ {
+		go func() {
+			for j := range jobs {
+				j.res <- l.load(j.index, j.indexes, j.seed)
+			}
+		}()
+	}
+
+	out := make(chan Batch)
+	go func() {
+		defer close(out)
+		for res := range pending {
+			var batch Batch
+			select {
+			case batch = <-res:
+			case <-ctx.Done():
+				return
+			}
+			select {
+			case out <- batch:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out
+}
+
+// order returns the indexes of the samples visited in an epoch.
+func (l *DataLoader) order() ([]int, error) {
+	if l.opts.Sampler != nil {
+		order, err := l.opts.Sampler.Indexes(l.src, l.rng)
+		if err != nil {
+			return nil, err
+		} else if len(order) != l.src.Len() {
+			return nil, fmt.Errorf("sampler returned %d indexes for %d samples", len(order), l.src.Len())
+		}
+		return order, nil
+	}
+
+	order := make([]int, l.src.Len())
+	for i := range order {
+		order[i] = i
+	}
+	if l.opts.Shuffle {
+		l.rng.Shuffle(len(order), func(i, j int) {
+			order[i], order[j] = order[j], order[i]
+		})
+	}
+	return order, nil
+}
+
+// load reads the samples at the given indexes and transforms their inputs.
+func (l *DataLoader) load(index int, indexes []int, seed int64) Batch {
+	var rng *rand.Rand
+	if l.opts.Transform != nil {
+		rng = rand.New(rand.NewSource(seed))
+	}
+
+	batch := Batch{Index: index, Samples: make([]reticulum.Sample, len(indexes))}
+	for k, i := range indexes {
+		s, err := l.src.Sample(i)
+		if err == nil && rng != nil {
+			s.Input, err = l.transform(s.Input, rng)
+		}
+		if err != nil {
+			return Batch{Index: index, Err: fmt.Errorf("sample %d: %w", i, err)}
+		}
+		batch.Samples[k] = s
+	}
+	return batch
+}
+
+// transform applies the transform to vol, returning its panics as errors.
+func (l *DataLoader) transform(vol *volume.Volume, rng *rand.Rand) (out *volume.Volume, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			switch e := r.(type) {
+			case error:
+				err = e
+			default:
+				err = fmt.Errorf("%v", e)
+			}
+		}
+	}()
+	return l.opts.Transform(vol, rng), nil
+}
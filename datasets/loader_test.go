@@ -0,0 +1,163 @@
+package datasets
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"slices"
+	"testing"
+
+	"github.com/nathanleary/reticulum"
+	"github.com/nathanleary/reticulum/volume"
+)
+
+// numbered returns n samples whose label and single input are their index.
+func numbered(n int) Samples {
+	s := make(Samples, n)
+	for i := range s {
+		s[i] = reticulum.Sample{Input: volume.NewVolume(volume.NewDimensions(1, 1, 1), volume.WithWeights([]float64{float64(i)})), Label: i}
+	}
+	return s
+}
+
+// epoch drains an epoch and returns the inputs of every batch.
+func epoch(t *testing.T, l *DataLoader) [][]float64 {
+	t.Helper()
+	var inputs [][]float64
+	for b := range l.Epoch(context.Background()) {
+		if b.Err != nil {
+			t.Fatal(b.Err)
+		} else if b.Index != len(inputs) {
+			t.Fatalf("batch %d arrived at position %d", b.Index, len(inputs))
+		}
+		var batch []float64
+		for _, vol := range b.Inputs() {
+			batch = append(batch, vol.GetByIndex(0))
+		}
+		inputs = append(inputs, batch)
+	}
+	return inputs
+}
+
+func TestDataLoader(t *testing.T) {
+	double := func(vol *volume.Volume, rng *rand.Rand) *volume.Volume {
+		out := vol.Clone()
+		out.SetByIndex(0, 2*vol.GetByIndex(0))
+		return out
+	}
+	for _, test := range []struct {
+		name string
+		opts []LoaderOptionFunc
+		want [][]float64
+	}{
+		{"in order", []LoaderOptionFunc{WithBatchSize(3), WithWorkers(2)}, [][]float64{{0, 1, 2}, {3, 4, 5}, {6}}},
+		{"drop last", []LoaderOptionFunc{WithBatchSize(3), WithDropLast()}, [][]float64{{0, 1, 2}, {3, 4, 5}}},
+		{"transformed", []LoaderOptionFunc{WithBatchSize(4), WithTransform(double), WithPrefetch(1)}, [][]float64{{0, 2, 4, 6}, {8, 10, 12}}},
+	} {
+		l, err := NewDataLoader(numbered(7), test.opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := epoch(t, l)
+		if len(got) != l.Len() || !slices.EqualFunc(got, test.want, slices.Equal) {
+			t.Errorf("%s: epoch gave %v in %d batches, want %v", test.name, got, l.Len(), test.want)
+		}
+	}
+}
+
+func TestDataLoader_Shuffle(t *testing.T) {
+	orders := make([][]float64, 3)
+	for i := range orders {
+		l, err := NewDataLoader(numbered(20), WithShuffle(), WithSeed(1), WithWorkers(3))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, b := range epoch(t, l) {
+			orders[i] = append(orders[i], b...)
+		}
+		if i == 2 {
+			// the next epoch of the last loader is shuffled anew
+			orders[i] = orders[i][:0]
+			for _, b := range epoch(t, l) {
+				orders[i] = append(orders[i], b...)
+			}
+		}
+	}
+
+	if !slices.Equal(orders[0], orders[1]) {
+		t.Errorf("epochs of loaders with the same seed differ: %v and %v", orders[0], orders[1])
+	}
+	if slices.Equal(orders[0], orders[2]) {
+		t.Errorf("second epoch repeats the order of the first: %v", orders[2])
+	}
+	for _, order := range orders {
+		if slices.IsSorted(order) {
+			t.Errorf("epoch %v is not shuffled", order)
+		}
+		for i, v := range slices.Sorted(slices.Values(order)) {
+			if v != float64(i) {
+				t.Fatalf("epoch %v does not visit every sample once", order)
+			}
+		}
+	}
+}
+
+func mustLoader(t *testing.T, src Source, opts ...LoaderOptionFunc) *DataLoader {
+	t.Helper()
+	l, err := NewDataLoader(src, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return l
+}
+
+func TestDataLoader_Errors(t *testing.T) {
+	for _, test := range []struct {
+		name string
+		src  Source
+		opts []LoaderOptionFunc
+	}{
+		{"nil source", nil, nil},
+		{"no batch size", numbered(2), []LoaderOptionFunc{WithBatchSize(0)}},
+		{"no workers", numbered(2), []LoaderOptionFunc{WithWorkers(0)}},
+		{"negative prefetch", numbered(2), []LoaderOptionFunc{WithPrefetch(-1)}},
+	} {
+		if _, err := NewDataLoader(test.src, test.opts...); err == nil {
+			t.Errorf("%s: expected an error", test.name)
+		}
+	}
+
+	fail := errors.New("bad sample")
+	for _, test := range []struct {
+		name string
+		opts []LoaderOptionFunc
+	}{
+		{"panicking transform", []LoaderOptionFunc{WithTransform(func(*volume.Volume, *rand.Rand) *volume.Volume { panic(fail) })}},
+		{"sampler", []LoaderOptionFunc{WithSampler(Weighted([]float64{1}))}},
+	} {
+		var got error
+		for b := range mustLoader(t, numbered(2), test.opts...).Epoch(context.Background()) {
+			if b.Err != nil && b.Samples == nil {
+				got = b.Err
+			}
+		}
+		if got == nil {
+			t.Errorf("%s: expected a batch error", test.name)
+		}
+	}
+}
+
+func TestDataLoader_Cancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	batches := mustLoader(t, numbered(100), WithPrefetch(1)).Epoch(ctx)
+	<-batches
+	cancel()
+	// the channel is closed without draining the remaining batches
+	var n int
+	for range batches {
+		n++
+	}
+	if n >= 99 {
+		t.Errorf("got %d batches after canceling", n)
+	}
+}
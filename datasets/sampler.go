@@ -0,0 +1,100 @@
+package datasets
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"sort"
+)
+
+// Sampler chooses the samples visited in an epoch. A sampler is meant for a single
+// source and may cache what it learns about it.
+type Sampler interface {
+	// Indexes returns the indexes of src.Len() samples of src to visit, in order.
+	Indexes(src Source, rng *rand.Rand) ([]int, error)
+}
+
+// Weighted draws samples with replacement, each with a probability proportional to
+// its weight, so an epoch may visit some samples several times and others not at all.
+func Weighted(weights []float64) Sampler {
+	return &weightedSampler{weights: func(src Source) ([]float64, error) {
+		if len(weights) != src.Len() {
+			return nil, fmt.Errorf("got %d weights for %d samples", len(weights), src.Len())
+		}
+		return weights, nil
+	}}
+}
+
+// WeightedByClass draws samples with replacement like Weighted, weighting each by the
+// inverse of the frequency of its class, so minority classes appear as often as the
+// others without duplicating any data. freqs holds the frequency or count of every
+// label, or is nil to count the labels of the source.
+func WeightedByClass(freqs map[int]float64) Sampler {
+	return &weightedSampler{weights: func(src Source) ([]float64, error) {
+		labels := make([]int, src.Len())
+		for i := range labels {
+			s, err := src.Sample(i)
+			if err != nil {
+				return nil, fmt.Errorf("sample %d: %w", i, err)
+			}
+			labels[i] = s.Label
+		}
+
+		if freqs == nil {
+			freqs = map[int]float64{}
+			for _, label := range labels {
+				freqs[label]++
+			}
+		}
+		weights := make([]float64, len(labels))
+		for i, label := range labels {
+			f, ok := freqs[label]
+			if !ok || f <= 0 {
+				return nil, fmt.Errorf("no frequency for label %d", label)
+			}
+			weights[i] = 1 / f
+		}
+		return weights, nil
+	}}
+}
+
+type weightedSampler struct {
+	weights func(src Source) ([]float64, error)
+
+	// cumulative sums of the weights, computed on first use
+	cum []float64
+}
+
+func (s *weightedSampler) Indexes(src Source, rng *rand.Rand) ([]int, error) {
+	if s.cum == nil {
+		weights, err := s.weights(src)
+		if err != nil {
+			return nil, err
+		}
+		cum := make([]float64, len(weights))
+		var total float64
+		for i, w := range weights {
+			if w < 0 {
+				return nil, fmt.Errorf("weight %d is negative: %v", i, w)
+			}
+			total += w
+			cum[i] = total
+		}
+		if total <= 0 {
+			return nil, errors.New("weights sum to 0")
+		}
+		s.cum = cum
+	}
+	if len(s.cum) != src.Len() {
+		return nil, fmt.Errorf("sampler was set up for %d samples, source has %d", len(s.cum), src.Len())
+	}
+
+	total := s.cum[len(s.cum)-1]
+	idx := make([]int, len(s.cum))
+	for i := range idx {
+		// the first sample whose cumulative weight exceeds the draw, skipping zero weights
+		r := rng.Float64() * total
+		idx[i] = sort.Search(len(s.cum), func(j int) bool { return s.cum[j] > r })
+	}
+	return idx, nil
+}
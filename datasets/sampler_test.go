@@ -0,0 +1,89 @@
+package datasets
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestSamplers(t *testing.T) {
+	// three samples of class 0 and one of class 1
+	src := numbered(4)
+	for i := range src {
+		src[i].Label = i / 3
+	}
+	for _, test := range []struct {
+		name    string
+		sampler Sampler
+		want    []float64
+	}{
+		{"weighted", Weighted([]float64{1, 0, 1, 2}), []float64{0.25, 0, 0.25, 0.5}},
+		{"by class", WeightedByClass(nil), []float64{1.0 / 6, 1.0 / 6, 1.0 / 6, 0.5}},
+		{"by given frequency", WeightedByClass(map[int]float64{0: 1, 1: 1}), []float64{0.25, 0.25, 0.25, 0.25}},
+	} {
+		rng := rand.New(rand.NewSource(1))
+		counts := make([]float64, src.Len())
+		const epochs = 5000
+		for e := 0; e < epochs; e++ {
+			idx, err := test.sampler.Indexes(src, rng)
+			if err != nil {
+				t.Fatal(err)
+			} else if len(idx) != src.Len() {
+				t.Fatalf("%s: %d indexes for %d samples", test.name, len(idx), src.Len())
+			}
+			for _, i := range idx {
+				counts[i]++
+			}
+		}
+		for i, want := range test.want {
+			if got := counts[i] / (epochs * float64(src.Len())); math.Abs(got-want) > 0.02 {
+				t.Errorf("%s: sample %d drawn %.3f of the time, want %.3f", test.name, i, got, want)
+			}
+		}
+	}
+}
+
+func TestSamplers_Errors(t *testing.T) {
+	for _, test := range []struct {
+		name    string
+		sampler Sampler
+		src     Source
+	}{
+		{"too few weights", Weighted([]float64{1}), numbered(2)},
+		{"negative weight", Weighted([]float64{1, -1}), numbered(2)},
+		{"zero weights", Weighted([]float64{0, 0}), numbered(2)},
+		{"missing class", WeightedByClass(map[int]float64{0: 1}), numbered(2)},
+	} {
+		if _, err := test.sampler.Indexes(test.src, rand.New(rand.NewSource(1))); err == nil {
+			t.Errorf("%s: expected an error", test.name)
+		}
+	}
+
+	// a sampler is set up for the first source it sees
+	s := WeightedByClass(nil)
+	if _, err := s.Indexes(numbered(2), rand.New(rand.NewSource(1))); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Indexes(numbered(3), rand.New(rand.NewSource(1))); err == nil {
+		t.Error("expected an error reusing a sampler for a larger source")
+	}
+}
+
+func TestDataLoader_WithSampler(t *testing.T) {
+	src := Samples{{Label: 0}, {Label: 1}}
+	l, err := NewDataLoader(src, WithSampler(Weighted([]float64{0, 1})), WithBatchSize(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for b := range l.Epoch(context.Background()) {
+		if b.Err != nil {
+			t.Fatal(b.Err)
+		}
+		for _, s := range b.Samples {
+			if s.Label != 1 {
+				t.Errorf("sampled %v, which has no weight", s)
+			}
+		}
+	}
+}
@@ -0,0 +1,17 @@
+package reticulum
+
+import (
+	"github.com/nathanleary/reticulum/device"
+)
+
+// WithDevice runs the products and activations of the fc, conv, relu, sigmoid and
+// tanh layers of the network on d, the CPU when nil. Volumes stay in host memory, so
+// on devices other than the CPU the operands of every product and kernel are copied
+// to the device and the results back, which only pays off for large layers. Clones of
+// the network, e.g. the replicas of ForwardBatch or Hogwild, share d, which must then
+// be safe for concurrent use.
+func WithDevice(d device.Device) NetworkOptionFunc {
+	return func(opts *NetworkOptions) {
+		opts.Device = d
+	}
+}
@@ -0,0 +1,132 @@
+package device
+
+import (
+	"fmt"
+	"math"
+)
+
+// Host is a buffer in host memory, as used by the CPU device.
+type Host []float64
+
+// Len returns the number of values in the buffer.
+func (h Host) Len() int {
+	return len(h)
+}
+
+// CPU computes on the host with plain Go loops. It is the default device, and its
+// buffers are Host slices.
+type CPU struct{}
+
+func (CPU) Name() string {
+	return "cpu"
+}
+
+func (CPU) Alloc(n int) Buffer {
+	return make(Host, n)
+}
+
+func (CPU) Free(Buffer) {}
+
+func (CPU) Wrap(s []float64) Buffer {
+	return Host(s)
+}
+
+func (CPU) Upload(dst Buffer, src []float64) {
+	copy(dst.(Host), src)
+}
+
+func (CPU) Download(dst []float64, src Buffer) {
+	copy(dst, src.(Host))
+}
+
+func (CPU) Copy(dst, src Buffer) {
+	copy(dst.(Host), src.(Host))
+}
+
+func (CPU) Dot(x, y Buffer) float64 {
+	hx, hy := x.(Host), y.(Host)
+	var s float64
+	for i, v := range hx {
+		s += v * hy[i]
+	}
+	return s
+}
+
+func (CPU) Axpy(alpha float64, x, y Buffer) {
+	hx, hy := x.(Host), y.(Host)
+	for i, v := range hx {
+		hy[i] += alpha * v
+	}
+}
+
+func (CPU) Gemm(transA, transB bool, m, n, k int, alpha float64, a, b Buffer, beta float64, c Buffer) {
+	ha, hb, hc := a.(Host), b.(Host), c.(Host)
+	at := func(i, p int) float64 {
+		if transA {
+			return ha[p*m+i]
+		}
+		return ha[i*k+p]
+	}
+	bt := func(p, j int) float64 {
+		if transB {
+			return hb[j*k+p]
+		}
+		return hb[p*n+j]
+	}
+	for i := 0; i < m; i++ {
+		for j := 0; j < n; j++ {
+			var s float64
+			for p := 0; p < k; p++ {
+				s += at(i, p) * bt(p, j)
+			}
+			hc[i*n+j] = alpha*s + beta*hc[i*n+j]
+		}
+	}
+}
+
+func (CPU) Map(k Kernel, x, y Buffer) {
+	hx, hy := x.(Host), y.(Host)
+	switch k {
+	case ReLU:
+		for i, v := range hx {
+			if v < 0 {
+				v = 0
+			}
+			hy[i] = v
+		}
+	case Sigmoid:
+		for i, v := range hx {
+			hy[i] = 1.0 / (1.0 + math.Exp(-v))
+		}
+	case Tanh:
+		for i, v := range hx {
+			hy[i] = math.Tanh(v)
+		}
+	default:
+		panic(fmt.Errorf("device: unknown kernel %v", k))
+	}
+}
+
+func (CPU) MapGrad(k Kernel, y, dy, dx Buffer) {
+	hy, hdy, hdx := y.(Host), dy.(Host), dx.(Host)
+	switch k {
+	case ReLU:
+		for i, v := range hy {
+			if v <= 0 {
+				hdx[i] = 0
+			} else {
+				hdx[i] = hdy[i]
+			}
+		}
+	case Sigmoid:
+		for i, v := range hy {
+			hdx[i] = v * (1 - v) * hdy[i]
+		}
+	case Tanh:
+		for i, v := range hy {
+			hdx[i] = (1.0 - v*v) * hdy[i]
+		}
+	default:
+		panic(fmt.Errorf("device: unknown kernel %v", k))
+	}
+}
@@ -0,0 +1,103 @@
+package device
+
+import (
+	"math"
+	"testing"
+)
+
+func TestCPU_RoundTrip(t *testing.T) {
+	var d Device = CPU{}
+	src := []float64{1, -2, 3}
+	b := d.Alloc(len(src))
+	if b.Len() != len(src) {
+		t.Fatalf("Alloc(%d) has %d values", len(src), b.Len())
+	}
+	d.Upload(b, src)
+	c := d.Alloc(len(src))
+	d.Copy(c, b)
+	got := make([]float64, len(src))
+	d.Download(got, c)
+	for i, v := range src {
+		if got[i] != v {
+			t.Errorf("value %d = %v after the round trip, want %v", i, got[i], v)
+		}
+	}
+
+	// wrapped buffers share the host values
+	h := d.(HostDevice).Wrap(got)
+	d.Axpy(2, b, h)
+	if got[0] != 3 || got[1] != -6 || got[2] != 9 {
+		t.Errorf("Axpy() on a wrapped buffer gave %v, want [3 -6 9]", got)
+	}
+	if dot := d.Dot(b, h); dot != 3+12+27 {
+		t.Errorf("Dot() = %v, want 42", dot)
+	}
+}
+
+func TestCPU_Gemm(t *testing.T) {
+	// a is 2x3 and b is 3x2, stored transposed when the flags are set
+	a, at := Host{1, 2, 3, 4, 5, 6}, Host{1, 4, 2, 5, 3, 6}
+	b, bt := Host{1, 0, 0, 1, 1, 1}, Host{1, 0, 1, 0, 1, 1}
+	want := []float64{4 + 1, 5 + 1, 10 + 1, 11 + 1}
+	for _, test := range []struct {
+		name           string
+		transA, transB bool
+		a, b           Host
+	}{
+		{"plain", false, false, a, b},
+		{"transposed a", true, false, at, b},
+		{"transposed b", false, true, a, bt},
+		{"both transposed", true, true, at, bt},
+	} {
+		c := Host{1, 1, 1, 1}
+		CPU{}.Gemm(test.transA, test.transB, 2, 2, 3, 1, test.a, test.b, 1, c)
+		for i, w := range want {
+			if c[i] != w {
+				t.Errorf("%s: c = %v, want %v", test.name, c, want)
+				break
+			}
+		}
+	}
+}
+
+func TestCPU_Map(t *testing.T) {
+	x := Host{-1, 0, 2}
+	for _, test := range []struct {
+		kernel Kernel
+		f, df  func(y float64) float64
+	}{
+		{ReLU, func(x float64) float64 { return math.Max(x, 0) }, func(y float64) float64 {
+			if y > 0 {
+				return 1
+			}
+			return 0
+		}},
+		{Sigmoid, func(x float64) float64 { return 1 / (1 + math.Exp(-x)) }, func(y float64) float64 { return y * (1 - y) }},
+		{Tanh, math.Tanh, func(y float64) float64 { return 1 - y*y }},
+	} {
+		y, dx := make(Host, len(x)), make(Host, len(x))
+		dy := Host{0.5, 0.5, 0.5}
+		CPU{}.Map(test.kernel, x, y)
+		CPU{}.MapGrad(test.kernel, y, dy, dx)
+		for i, v := range x {
+			if want := test.f(v); math.Abs(y[i]-want) > 1e-12 {
+				t.Errorf("%s(%v) = %v, want %v", test.kernel, v, y[i], want)
+			}
+			if want := 0.5 * test.df(y[i]); math.Abs(dx[i]-want) > 1e-12 {
+				t.Errorf("%s gradient at %v = %v, want %v", test.kernel, v, dx[i], want)
+			}
+		}
+	}
+}
+
+func TestCPU_UnknownKernel(t *testing.T) {
+	if got := Kernel(7).String(); got != "Kernel(7)" {
+		t.Errorf("String() = %q, want Kernel(7)", got)
+	}
+	defer func() {
+		if recover() == nil {
+			t.Error("expected a panic mapping an unknown kernel")
+		}
+	}()
+	CPU{}.Map(Kernel(7), Host{1}, Host{0})
+}
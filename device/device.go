@@ -0,0 +1,96 @@
+// Package device abstracts the hardware executing the arithmetic of networks. A
+// Device allocates buffers in its own memory, copies data in and out of them, and
+// runs matrix products and elementwise kernels on them. CPU is the default; other
+// devices, e.g. CUDA, OpenCL or Metal implementations, are selected per network
+// without changing the layers:
+//
+//	net, err := reticulum.NewNetwork(defs, reticulum.WithDevice(gpu))
+//
+// Only the arithmetic is offloaded: volumes live in host memory, so the layers upload
+// their operands to the device for every product or kernel and download the results,
+// and no data stays on the device between operations. Devices working on host memory
+// implement HostDevice to skip the copies.
+package device
+
+import (
+	"fmt"
+)
+
+// Buffer is a block of float64 values in the memory of a device.
+type Buffer interface {
+	// Len returns the number of values in the buffer.
+	Len() int
+}
+
+// Kernel is an elementwise activation computed by a device.
+type Kernel int
+
+const (
+	// ReLU computes max(x, 0).
+	ReLU Kernel = iota
+	// Sigmoid computes 1/(1+exp(-x)).
+	Sigmoid
+	// Tanh computes tanh(x).
+	Tanh
+)
+
+func (k Kernel) String() string {
+	switch k {
+	case ReLU:
+		return "relu"
+	case Sigmoid:
+		return "sigmoid"
+	case Tanh:
+		return "tanh"
+	}
+	return fmt.Sprintf("Kernel(%d)", int(k))
+}
+
+// Device executes the arithmetic of the layers. The buffers passed to its methods
+// must have been allocated by it, and the vector operands of a call have equal
+// lengths.
+type Device interface {
+	// Name returns the name of the device, e.g. "cpu".
+	Name() string
+
+	// Alloc allocates a zeroed buffer of n values.
+	Alloc(n int) Buffer
+
+	// Free releases a buffer allocated by Alloc.
+	Free(b Buffer)
+
+	// Upload copies the host values src into dst.
+	Upload(dst Buffer, src []float64)
+
+	// Download copies src into the host values dst.
+	Download(dst []float64, src Buffer)
+
+	// Copy copies src into dst.
+	Copy(dst, src Buffer)
+
+	// Dot returns the dot product of x and y.
+	Dot(x, y Buffer) float64
+
+	// Axpy adds alpha*x to y.
+	Axpy(alpha float64, x, y Buffer)
+
+	// Gemm computes c = alpha*op(a)*op(b) + beta*c for dense row-major matrices, where
+	// op(a) is m x k, op(b) is k x n and c is m x n, and op transposes the matrix
+	// when the corresponding flag is set.
+	Gemm(transA, transB bool, m, n, k int, alpha float64, a, b Buffer, beta float64, c Buffer)
+
+	// Map computes y = k(x) elementwise.
+	Map(k Kernel, x, y Buffer)
+
+	// MapGrad computes the input gradients dx of the kernel k from its outputs y and
+	// their gradients dy.
+	MapGrad(k Kernel, y, dy, dx Buffer)
+}
+
+// HostDevice is a Device working directly on host memory.
+type HostDevice interface {
+	Device
+
+	// Wrap returns a buffer sharing the host values s.
+	Wrap(s []float64) Buffer
+}
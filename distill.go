@@ -0,0 +1,96 @@
+package reticulum
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/nathanleary/reticulum/layers"
+)
+
+// DistillOptions configures knowledge distillation.
+type DistillOptions struct {
+	// Temperature softens the teacher and student distributions, exposing how the
+	// teacher ranks the wrong classes. Defaults to 1.
+	Temperature float64
+
+	// Alpha is the share of the loss given to matching the teacher, the rest goes to
+	// the hard labels. 1 ignores the labels.
+	Alpha float64
+
+	// FitOptions configure the training loop, as for Fit.
+	FitOptions
+}
+
+// Distill trains student to mimic teacher on data, minimising
+//
+//	Alpha * T² * CE(teacher at T, student at T) + (1 - Alpha) * CE(label, student)
+//
+// where the T² factor keeps the soft gradients on the scale of the hard ones. Both
+// networks must end in a softmax over the same classes. The teacher is only run
+// forward, once per sample, and is left unchanged.
+func Distill(teacher, student Network, data []Sample, opts DistillOptions) (*History, error) {
+	if teacher == nil || student == nil {
+		return nil, errors.New("teacher and student cannot be nil")
+	} else if opts.Temperature < 0 {
+		return nil, fmt.Errorf("temperature must be greater than 0, got %v", opts.Temperature)
+	} else if opts.Alpha < 0 || opts.Alpha > 1 {
+		return nil, fmt.Errorf("alpha must be in [0, 1], got %v", opts.Alpha)
+	}
+	temperature := opts.Temperature
+	if temperature == 0 {
+		temperature = 1
+	}
+
+	tl, sl := teacher.Layers()[teacher.Size()-1], student.Layers()[student.Size()-1]
+	if tl.Type() != layers.SoftMax || sl.Type() != layers.SoftMax {
+		return nil, errors.New("teacher and student must end in a softmax layer")
+	}
+
+	soft := make([][]float64, len(data))
+	for i, sample := range data {
+		out, err := ForwardE(teacher, sample.Input, false)
+		if err != nil {
+			return nil, fmt.Errorf("teacher: sample %d: %w", i, err)
+		}
+		soft[i] = soften(out.Weights(), temperature)
+	}
+
+	scale := opts.Alpha * temperature * temperature
+	return fit(student, data, nil, opts.FitOptions, func(i int, classWeights map[int]float64) LossFunc {
+		label, target := data[i].Label, soft[i]
+		w := data[i].Weight
+		if w == 0 {
+			w = 1.0
+		}
+		if cw, ok := classWeights[label]; ok {
+			w *= cw
+		}
+		return func(net Network) float64 {
+			var loss float64
+			if opts.Alpha < 1 {
+				loss = BackwardWeighted(net, label, (1-opts.Alpha)*w)
+			}
+			if opts.Alpha > 0 {
+				// the second pass adds its parameter gradients to those of the first
+				loss += BackwardSoftTarget(net, target, temperature, scale*w)
+			}
+			return loss
+		}
+	})
+}
+
+// soften returns the softmax probabilities p recomputed at the given temperature,
+// which is p^(1/T) normalized as the logits are log p up to a constant.
+func soften(p []float64, temperature float64) []float64 {
+	q := make([]float64, len(p))
+	var sum float64
+	for i, v := range p {
+		q[i] = math.Pow(v, 1/temperature)
+		sum += q[i]
+	}
+	for i := range q {
+		q[i] /= sum
+	}
+	return q
+}
@@ -0,0 +1,156 @@
+package distributed
+
+import (
+	"context"
+	"math/rand"
+	"net"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/test/bufconn"
+
+	"github.com/nathanleary/reticulum"
+	"github.com/nathanleary/reticulum/layers"
+	"github.com/nathanleary/reticulum/volume"
+)
+
+func newNet(t *testing.T, inputs int) reticulum.Network {
+	t.Helper()
+	net, err := reticulum.NewNetwork([]layers.LayerDef{
+		{Type: layers.Input, Output: volume.NewDimensions(1, 1, inputs)},
+		{Type: layers.SoftMax, LayerConfig: layers.NewSoftmaxLayerConfig(2)},
+	}, reticulum.WithNetworkSeed(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return net
+}
+
+// dial serves s in memory and returns a client connection to it.
+func dial(t *testing.T, s *ParameterServer) *grpc.ClientConn {
+	t.Helper()
+	lis := bufconn.Listen(1 << 20)
+	go s.Serve(lis)
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		conn.Close()
+		lis.Close()
+	})
+	return conn
+}
+
+// samples returns n samples labeled by the sign of their first input.
+func samples(n int) []reticulum.Sample {
+	r := rand.New(rand.NewSource(1))
+	data := make([]reticulum.Sample, n)
+	for i := range data {
+		w := []float64{r.NormFloat64(), r.NormFloat64()}
+		label := 0
+		if w[0] > 0 {
+			label = 1
+		}
+		data[i] = reticulum.Sample{Input: volume.NewVolume(volume.NewDimensions(1, 1, 2), volume.WithWeights(w)), Label: label}
+	}
+	return data
+}
+
+func TestWorker_TrainBatch(t *testing.T) {
+	shared := newNet(t, 2)
+	server := NewParameterServer(shared, ServerOptions{LearningRate: 0.1, Momentum: 0.5})
+	conn := dial(t, server)
+	workers := []*Worker{NewWorker("a", conn, newNet(t, 2)), NewWorker("b", conn, newNet(t, 2))}
+	// the workers start from other weights, which the first pull replaces
+	for _, resp := range workers[1].Network().GetResponse() {
+		for i := range resp.Weights {
+			resp.Weights[i] = 0
+		}
+	}
+
+	ctx := context.Background()
+	data := samples(64)
+	before := reticulum.Evaluate(shared, data).Loss
+	for step := 0; step < 40; step++ {
+		for k, w := range workers {
+			batch := data[(2*step+k)*8%len(data):][:8]
+			if _, err := w.TrainBatch(ctx, batch); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if got := server.Version(); got != 80 {
+		t.Errorf("Version() = %d after 80 updates", got)
+	}
+	if after := reticulum.Evaluate(shared, data).Loss; after >= before {
+		t.Errorf("loss %v after training, was %v", after, before)
+	}
+
+	// a pull leaves the local weights equal to the server's
+	if err := workers[0].Pull(ctx); err != nil {
+		t.Fatal(err)
+	}
+	params, err := server.Pull(ctx, &PullRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, resp := range workers[0].Network().GetResponse() {
+		for j, w := range resp.Weights {
+			if w != params.Weights[i][j] {
+				t.Fatalf("local weight %d/%d = %v, server has %v", i, j, w, params.Weights[i][j])
+			}
+		}
+	}
+}
+
+func TestParameterServer_Frozen(t *testing.T) {
+	net := newNet(t, 2)
+	before := append([]float64(nil), net.GetResponse()[0].Weights...)
+	net.Freeze(net.GetResponse()[0].LayerName)
+	server := NewParameterServer(net, ServerOptions{LearningRate: 0.1})
+	if _, err := NewWorker("a", dial(t, server), newNet(t, 2)).TrainBatch(context.Background(), samples(8)); err != nil {
+		t.Fatal(err)
+	}
+	for i, w := range net.GetResponse()[0].Weights {
+		if w != before[i] {
+			t.Fatalf("frozen weight %d changed from %v to %v", i, before[i], w)
+		}
+	}
+}
+
+func TestDistributed_Errors(t *testing.T) {
+	server := NewParameterServer(newNet(t, 2), ServerOptions{LearningRate: 0.1})
+	conn := dial(t, server)
+	ctx := context.Background()
+	n := len(server.params)
+	for _, test := range []struct {
+		name string
+		err  func() error
+	}{
+		{"empty batch", func() error { _, err := NewWorker("a", conn, newNet(t, 2)).TrainBatch(ctx, nil); return err }},
+		{"other architecture", func() error { return NewWorker("a", conn, newNet(t, 3)).Pull(ctx) }},
+		{"missing parameter sets", func() error {
+			_, err := server.Push(ctx, &GradientUpdate{Samples: 1, Gradients: make([][]float64, n-1)})
+			return err
+		}},
+		{"short gradients", func() error {
+			_, err := server.Push(ctx, &GradientUpdate{Samples: 1, Gradients: make([][]float64, n)})
+			return err
+		}},
+		{"no samples", func() error {
+			_, err := server.Push(ctx, &GradientUpdate{Gradients: make([][]float64, n)})
+			return err
+		}},
+	} {
+		if err := test.err(); err == nil {
+			t.Errorf("%s: expected an error", test.name)
+		}
+	}
+	if server.Version() != 0 {
+		t.Errorf("rejected updates were applied, version %d", server.Version())
+	}
+}
@@ -0,0 +1,120 @@
+package distributed
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync"
+
+	"google.golang.org/grpc"
+
+	"github.com/nathanleary/reticulum"
+	"github.com/nathanleary/reticulum/layers"
+)
+
+// ServerOptions configures the optimizer applied by the parameter server.
+type ServerOptions struct {
+	LearningRate float64
+	Momentum     float64
+	L1Decay      float64
+	L2Decay      float64
+}
+
+// NewParameterServer creates a parameter server which owns the weights of net.
+func NewParameterServer(net reticulum.Network, opts ServerOptions) *ParameterServer {
+	if net == nil {
+		panic("network cannot be nil")
+	} else if opts.LearningRate <= 0 {
+		panic("learning rate must be greater than 0")
+	}
+
+	params := net.GetResponse()
+	velocity := make([][]float64, len(params))
+	for i := range params {
+		velocity[i] = make([]float64, len(params[i].Weights))
+	}
+	return &ParameterServer{opts: opts, net: net, params: params, velocity: velocity}
+}
+
+// ParameterServer applies gradients pushed by workers to the shared weights.
+type ParameterServer struct {
+	opts ServerOptions
+	net  reticulum.Network
+
+	mu       sync.Mutex
+	version  int64
+	params   []layers.LayerResponse
+	velocity [][]float64
+}
+
+// Register adds the parameter server service to a gRPC server.
+func (s *ParameterServer) Register(g *grpc.Server) {
+	g.RegisterService(&serviceDesc, s)
+}
+
+// Serve accepts worker connections on lis until it fails or is closed.
+func (s *ParameterServer) Serve(lis net.Listener) error {
+	g := grpc.NewServer()
+	s.Register(g)
+	return g.Serve(lis)
+}
+
+// Version returns the number of updates applied so far.
+func (s *ParameterServer) Version() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.version
+}
+
+// Pull returns a copy of the current weights.
+func (s *ParameterServer) Pull(ctx context.Context, req *PullRequest) (*Parameters, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	weights := make([][]float64, len(s.params))
+	for i, p := range s.params {
+		weights[i] = append([]float64(nil), p.Weights...)
+	}
+	return &Parameters{Version: s.version, Weights: weights}, nil
+}
+
+// Push applies an SGD step with the mean of the pushed gradients to the layers which
+// are not frozen.
+func (s *ParameterServer) Push(ctx context.Context, req *GradientUpdate) (*PushReply, error) {
+	if len(req.Gradients) != len(s.params) {
+		return nil, fmt.Errorf("expected gradients for %d parameter sets, got %d", len(s.params), len(req.Gradients))
+	} else if req.Samples <= 0 {
+		return nil, fmt.Errorf("invalid sample count: %d", req.Samples)
+	}
+	// checked up front, so a malformed update is rejected before any step is taken
+	for i, pg := range s.params {
+		if len(req.Gradients[i]) != len(pg.Weights) {
+			return nil, fmt.Errorf("parameter set %d: expected %d gradients, got %d", i, len(pg.Weights), len(req.Gradients[i]))
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, pg := range s.params {
+		if s.net.Frozen(pg.LayerName) {
+			continue
+		}
+		p, g, v := pg.Weights, req.Gradients[i], s.velocity[i]
+		l1Decay := s.opts.L1Decay * pg.L1DecayMul
+		l2Decay := s.opts.L2Decay * pg.L2DecayMul
+		for j := range p {
+			l1Grad := l1Decay
+			if p[j] <= 0 {
+				l1Grad *= -1
+			}
+			gij := (l2Decay*p[j] + l1Grad) + g[j]/float64(req.Samples)
+
+			// momentum update, reduces to vanilla sgd without momentum
+			v[j] = s.opts.Momentum*v[j] - s.opts.LearningRate*gij
+			p[j] += v[j]
+		}
+	}
+	s.version++
+	return &PushReply{Version: s.version}, nil
+}
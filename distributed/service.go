@@ -0,0 +1,119 @@
+// Package distributed trains reticulum networks across machines using a parameter
+// server. Workers pull the latest weights from the server, compute gradients on their
+// share of the data and push them back, where they are applied asynchronously.
+//
+// The service is exposed over gRPC. Messages are plain Go structs encoded with gob,
+// so no protobuf code generation is needed.
+package distributed
+
+import (
+	"bytes"
+	"context"
+	"encoding/gob"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/encoding"
+)
+
+const (
+	serviceName = "reticulum.distributed.ParameterServer"
+	pullMethod  = "/" + serviceName + "/Pull"
+	pushMethod  = "/" + serviceName + "/Push"
+
+	// codecName is the gRPC content-subtype used by the service.
+	codecName = "gob"
+)
+
+func init() {
+	encoding.RegisterCodec(gobCodec{})
+}
+
+// PullRequest asks the server for the current weights.
+type PullRequest struct {
+	WorkerID string
+}
+
+// Parameters holds the weights of every LayerResponse of the network, in order.
+type Parameters struct {
+	Version int64
+	Weights [][]float64
+}
+
+// GradientUpdate holds the gradients summed over Samples samples, computed against
+// the weights of the given version.
+type GradientUpdate struct {
+	WorkerID  string
+	Version   int64
+	Samples   int
+	Gradients [][]float64
+}
+
+// PushReply acknowledges a gradient update with the resulting weight version.
+type PushReply struct {
+	Version int64
+}
+
+// parameterService is the server side of the gRPC service.
+type parameterService interface {
+	Pull(ctx context.Context, req *PullRequest) (*Parameters, error)
+	Push(ctx context.Context, req *GradientUpdate) (*PushReply, error)
+}
+
+var serviceDesc = grpc.ServiceDesc{
+	ServiceName: serviceName,
+	HandlerType: (*parameterService)(nil),
+	Methods: []grpc.MethodDesc{
+		{MethodName: "Pull", Handler: pullHandler},
+		{MethodName: "Push", Handler: pushHandler},
+	},
+	Streams: []grpc.StreamDesc{},
+}
+
+func pullHandler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PullRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(parameterService).Pull(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{Server: srv, FullMethod: pullMethod}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(parameterService).Pull(ctx, req.(*PullRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func pushHandler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GradientUpdate)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(parameterService).Push(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{Server: srv, FullMethod: pushMethod}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(parameterService).Push(ctx, req.(*GradientUpdate))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// gobCodec encodes the service messages with encoding/gob.
+type gobCodec struct{}
+
+func (gobCodec) Marshal(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (gobCodec) Unmarshal(data []byte, v interface{}) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(v)
+}
+
+func (gobCodec) Name() string {
+	return codecName
+}
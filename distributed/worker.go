@@ -0,0 +1,89 @@
+package distributed
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"google.golang.org/grpc"
+
+	"github.com/nathanleary/reticulum"
+	"github.com/nathanleary/reticulum/layers"
+)
+
+// NewWorker creates a worker which trains its local copy of the network against the
+// parameter server reachable through conn. The local network must have the same
+// architecture as the server's network.
+func NewWorker(id string, conn grpc.ClientConnInterface, net reticulum.Network) *Worker {
+	if conn == nil {
+		panic("connection cannot be nil")
+	} else if net == nil {
+		panic("network cannot be nil")
+	}
+	return &Worker{id: id, conn: conn, net: net, params: net.GetResponse()}
+}
+
+// Worker computes gradients on local data and pushes them to the parameter server.
+type Worker struct {
+	id      string
+	conn    grpc.ClientConnInterface
+	net     reticulum.Network
+	params  []layers.LayerResponse
+	version int64
+}
+
+// Network returns the worker's local network.
+func (w *Worker) Network() reticulum.Network {
+	return w.net
+}
+
+// Pull replaces the local weights with the server's current weights.
+func (w *Worker) Pull(ctx context.Context) error {
+	out := new(Parameters)
+	if err := w.conn.Invoke(ctx, pullMethod, &PullRequest{WorkerID: w.id}, out, grpc.CallContentSubtype(codecName)); err != nil {
+		return err
+	}
+	if len(out.Weights) != len(w.params) {
+		return fmt.Errorf("server has %d parameter sets, local network has %d", len(out.Weights), len(w.params))
+	}
+	for i, p := range w.params {
+		if len(out.Weights[i]) != len(p.Weights) {
+			return fmt.Errorf("parameter set %d: server has %d weights, local network has %d", i, len(out.Weights[i]), len(p.Weights))
+		}
+		copy(p.Weights, out.Weights[i])
+	}
+	w.version = out.Version
+	return nil
+}
+
+// TrainBatch pulls the latest weights, runs the forward and backward pass over the
+// samples and pushes the accumulated gradients. Returns the mean cost loss.
+func (w *Worker) TrainBatch(ctx context.Context, samples []reticulum.Sample) (float64, error) {
+	if len(samples) == 0 {
+		return 0, errors.New("batch cannot be empty")
+	}
+	if err := w.Pull(ctx); err != nil {
+		return 0, err
+	}
+
+	var loss float64
+	for _, sample := range samples {
+		w.net.Forward(sample.Input, true)
+		loss += sample.LossFunc()(w.net)
+	}
+
+	update := &GradientUpdate{WorkerID: w.id, Version: w.version, Samples: len(samples)}
+	for _, p := range w.params {
+		update.Gradients = append(update.Gradients, append([]float64(nil), p.Gradients...))
+
+		// zero out gradient so that we can begin accumulating anew
+		for j := range p.Gradients {
+			p.Gradients[j] = 0.0
+		}
+	}
+
+	if err := w.conn.Invoke(ctx, pushMethod, update, new(PushReply), grpc.CallContentSubtype(codecName)); err != nil {
+		return 0, err
+	}
+	return loss / float64(len(samples)), nil
+}
@@ -0,0 +1,92 @@
+package reticulum
+
+// NewEarlyStopping creates a callback which stops training once the monitored metric
+// has not improved by at least minDelta for patience epochs, and restores the weights
+// of the best epoch.
+func NewEarlyStopping(patience int, minDelta float64, monitor string) *EarlyStopping {
+	if patience < 0 {
+		panic("patience cannot be negative")
+	}
+	return &EarlyStopping{Patience: patience, MinDelta: minDelta, Monitor: monitor, RestoreBest: true}
+}
+
+// EarlyStopping halts training when a metric stops improving. By default lower
+// values are considered better (e.g. "val_loss").
+type EarlyStopping struct {
+	BaseCallback
+
+	Patience int
+	MinDelta float64
+	Monitor  string
+
+	// Maximize treats higher metric values as better (e.g. "val_accuracy").
+	Maximize bool
+
+	// RestoreBest restores the weights of the best epoch when training is stopped.
+	RestoreBest bool
+
+	best      float64
+	bestEpoch int
+	seen      bool
+	wait      int
+	weights   [][]float64
+}
+
+// BestEpoch returns the epoch with the best metric value and the value itself.
+func (e *EarlyStopping) BestEpoch() (int, float64) {
+	return e.bestEpoch, e.best
+}
+
+func (e *EarlyStopping) OnTrainBegin(t Trainer) {
+	e.seen, e.wait, e.weights = false, 0, nil
+}
+
+func (e *EarlyStopping) OnEpochEnd(t Trainer, epoch int, metrics Metrics) {
+	v, ok := metrics[e.Monitor]
+	if !ok {
+		return
+	}
+
+	improved := !e.seen
+	if e.seen {
+		if e.Maximize {
+			improved = v > e.best+e.MinDelta
+		} else {
+			improved = v < e.best-e.MinDelta
+		}
+	}
+
+	if improved {
+		e.best, e.bestEpoch, e.seen, e.wait = v, epoch, true, 0
+		if e.RestoreBest {
+			e.snapshot(t.Network())
+		}
+		return
+	}
+
+	e.wait++
+	if e.wait > e.Patience {
+		if e.RestoreBest && e.weights != nil {
+			e.restore(t.Network())
+		}
+		t.Stop()
+	}
+}
+
+// snapshot copies the current weights of the network.
+func (e *EarlyStopping) snapshot(net Network) {
+	pgList := net.GetResponse()
+	if len(e.weights) != len(pgList) {
+		e.weights = make([][]float64, len(pgList))
+	}
+	for i, pg := range pgList {
+		e.weights[i] = append(e.weights[i][:0], pg.Weights...)
+	}
+}
+
+// restore copies the snapshot back into the network.
+func (e *EarlyStopping) restore(net Network) {
+	for i, pg := range net.GetResponse() {
+		copy(pg.Weights, e.weights[i])
+	}
+}
@@ -0,0 +1,212 @@
+package reticulum
+
+import (
+	"errors"
+	"math/rand"
+	"sort"
+	"sync"
+
+	"github.com/nathanleary/reticulum/layers"
+)
+
+// RewardFunc scores a network, higher is better. It is called concurrently on
+// different replicas, so it must not share mutable state between calls.
+type RewardFunc func(net Network) float64
+
+// ESOptions configures the evolution strategies trainer.
+type ESOptions struct {
+	// Population is the number of perturbations evaluated per step. With mirrored
+	// sampling every perturbation is evaluated twice, with opposite signs.
+	Population int
+
+	// Sigma is the standard deviation of the weight perturbations.
+	Sigma float64
+
+	LearningRate float64
+	L2Decay      float64
+
+	// Workers is the number of goroutines evaluating perturbations. Defaults to 1.
+	Workers int
+
+	// Mirrored enables antithetic sampling, which reduces the variance of the estimate.
+	Mirrored bool
+
+	HasSeed bool
+	Seed    int64
+}
+
+// ESResults summarises an evolution strategies step.
+type ESResults struct {
+	MeanReward float64
+	MaxReward  float64
+}
+
+// NewESTrainer creates a trainer which optimises the weights of net with OpenAI-style
+// evolution strategies: the gradient of the expected reward is estimated from the
+// rewards of randomly perturbed copies of the weights, evaluated in parallel on
+// replicas created with newReplica.
+func NewESTrainer(net Network, newReplica func() (Network, error), opts ESOptions) (*ESTrainer, error) {
+	if net == nil {
+		return nil, errors.New("network cannot be nil")
+	} else if newReplica == nil {
+		return nil, errors.New("replica constructor cannot be nil")
+	} else if opts.Population <= 0 {
+		return nil, errors.New("population must be greater than 0")
+	} else if opts.Sigma <= 0 {
+		return nil, errors.New("sigma must be greater than 0")
+	} else if opts.LearningRate <= 0 {
+		return nil, errors.New("learning rate must be greater than 0")
+	}
+	if opts.Workers <= 0 {
+		opts.Workers = 1
+	}
+
+	params := net.GetResponse()
+	frozen := make([]bool, len(params))
+	for i, pg := range params {
+		frozen[i] = net.Frozen(pg.LayerName)
+	}
+	replicas := make([]Network, opts.Workers)
+	for w := range replicas {
+		replica, err := newReplica()
+		if err != nil {
+			return nil, err
+		}
+		if len(replica.GetResponse()) != len(params) {
+			return nil, errors.New("replica does not match the network architecture")
+		}
+		replicas[w] = replica
+	}
+
+	return &ESTrainer{opts: opts, params: params, frozen: frozen, replicas: replicas, rng: newRand(opts.HasSeed, opts.Seed)}, nil
+}
+
+// ESTrainer optimises a network with evolution strategies.
+type ESTrainer struct {
+	opts     ESOptions
+	params   []layers.LayerResponse
+	replicas []Network
+
+	// frozen marks the parameter sets of frozen layers, which are not perturbed
+	frozen []bool
+	rng    *rand.Rand
+}
+
+// perturbation is a single evaluated perturbation. The noise is regenerated from
+// its seed instead of being stored.
+type perturbation struct {
+	seed   int64
+	sign   float64
+	reward float64
+}
+
+// Step evaluates a population of perturbations and moves the weights along the
+// estimated gradient of the reward.
+func (e *ESTrainer) Step(reward RewardFunc) ESResults {
+	var pop []perturbation
+	for i := 0; i < e.opts.Population; i++ {
+		seed := e.rng.Int63()
+		pop = append(pop, perturbation{seed: seed, sign: 1})
+		if e.opts.Mirrored {
+			pop = append(pop, perturbation{seed: seed, sign: -1})
+		}
+	}
+
+	// evaluate perturbations in parallel, one replica per worker
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for _, replica := range e.replicas {
+		wg.Add(1)
+		go func(replica Network) {
+			defer wg.Done()
+			local := replica.GetResponse()
+			for i := range jobs {
+				e.perturb(local, pop[i])
+				pop[i].reward = reward(replica)
+			}
+		}(replica)
+	}
+	for i := range pop {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	res := ESResults{MaxReward: pop[0].reward}
+	for _, p := range pop {
+		res.MeanReward += p.reward / float64(len(pop))
+		if p.reward > res.MaxReward {
+			res.MaxReward = p.reward
+		}
+	}
+
+	e.update(pop, centeredRanks(pop))
+	return res
+}
+
+// perturb sets the replica weights to the current weights plus the scaled noise.
+func (e *ESTrainer) perturb(local []layers.LayerResponse, p perturbation) {
+	noise := rand.New(rand.NewSource(p.seed))
+	for i, pg := range e.params {
+		w := local[i].Weights
+		if e.frozen[i] {
+			copy(w, pg.Weights)
+			continue
+		}
+		for j := range w {
+			w[j] = pg.Weights[j] + p.sign*e.opts.Sigma*noise.NormFloat64()
+		}
+	}
+}
+
+// update applies the gradient estimate sum(F_i * eps_i) / (n * sigma), using the
+// rank-transformed rewards as fitness.
+func (e *ESTrainer) update(pop []perturbation, fitness []float64) {
+	grads := make([][]float64, len(e.params))
+	for i, pg := range e.params {
+		grads[i] = make([]float64, len(pg.Weights))
+	}
+	for k, p := range pop {
+		noise := rand.New(rand.NewSource(p.seed))
+		for i := range grads {
+			if e.frozen[i] {
+				continue
+			}
+			for j := range grads[i] {
+				grads[i][j] += fitness[k] * p.sign * noise.NormFloat64()
+			}
+		}
+	}
+
+	scale := e.opts.LearningRate / (float64(len(pop)) * e.opts.Sigma)
+	for i, pg := range e.params {
+		if e.frozen[i] {
+			continue
+		}
+		l2Decay := e.opts.L2Decay * pg.L2DecayMul
+		for j := range pg.Weights {
+			pg.Weights[j] += scale*grads[i][j] - e.opts.LearningRate*l2Decay*pg.Weights[j]
+		}
+	}
+}
+
+// centeredRanks maps the rewards to their ranks scaled to [-0.5, 0.5], which makes
+// the update invariant to the scale of the reward and robust to outliers.
+func centeredRanks(pop []perturbation) []float64 {
+	order := make([]int, len(pop))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return pop[order[a]].reward < pop[order[b]].reward
+	})
+
+	ranks := make([]float64, len(pop))
+	if len(pop) == 1 {
+		return ranks
+	}
+	for rank, i := range order {
+		ranks[i] = float64(rank)/float64(len(pop)-1) - 0.5
+	}
+	return ranks
+}
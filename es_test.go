@@ -0,0 +1,79 @@
+package reticulum
+
+import (
+	"testing"
+)
+
+// accuracyReward returns the negated mean loss of the network on the samples.
+func accuracyReward(data []Sample) RewardFunc {
+	return func(net Network) float64 {
+		return -meanCost(net, data)
+	}
+}
+
+func TestESTrainer(t *testing.T) {
+	data := separable(32)
+	for _, test := range []struct {
+		name string
+		opts ESOptions
+	}{
+		{"plain", ESOptions{Population: 20, Sigma: 0.05, LearningRate: 0.05, HasSeed: true, Seed: 1}},
+		{"mirrored", ESOptions{Population: 10, Sigma: 0.05, LearningRate: 0.05, Workers: 3, Mirrored: true, HasSeed: true, Seed: 1}},
+	} {
+		net := privacyNetwork(t, 4, 3)
+		es, err := NewESTrainer(net, func() (Network, error) { return Clone(net), nil }, test.opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		reward := accuracyReward(data)
+		before := reward(net)
+		for i := 0; i < 30; i++ {
+			es.Step(reward)
+		}
+		if after := reward(net); after <= before {
+			t.Errorf("%s: reward %v after training, was %v", test.name, after, before)
+		}
+	}
+}
+
+func TestESTrainer_SeedReproducible(t *testing.T) {
+	opts := ESOptions{Population: 8, Sigma: 0.1, LearningRate: 0.1, Workers: 2, HasSeed: true, Seed: 3}
+	reward := accuracyReward(separable(16))
+	var weights [2][]float64
+	for k := range weights {
+		net := privacyNetwork(t, 4, 3)
+		es, err := NewESTrainer(net, func() (Network, error) { return Clone(net), nil }, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < 3; i++ {
+			es.Step(reward)
+		}
+		weights[k] = flatWeights(net)
+	}
+	for i, w := range weights[0] {
+		if w != weights[1][i] {
+			t.Fatalf("weight %d = %v and %v with the same seed", i, w, weights[1][i])
+		}
+	}
+}
+
+func TestNewESTrainer_Errors(t *testing.T) {
+	net := privacyNetwork(t, 4, 3)
+	replica := func() (Network, error) { return Clone(net), nil }
+	for _, test := range []struct {
+		name       string
+		newReplica func() (Network, error)
+		opts       ESOptions
+	}{
+		{"no replicas", nil, ESOptions{Population: 1, Sigma: 1, LearningRate: 1}},
+		{"no population", replica, ESOptions{Sigma: 1, LearningRate: 1}},
+		{"no sigma", replica, ESOptions{Population: 1, LearningRate: 1}},
+		{"no learning rate", replica, ESOptions{Population: 1, Sigma: 1}},
+		{"mismatched replica", func() (Network, error) { return privacyNetwork(t, 5, 2), nil }, ESOptions{Population: 1, Sigma: 1, LearningRate: 1}},
+	} {
+		if _, err := NewESTrainer(net, test.newReplica, test.opts); err == nil {
+			t.Errorf("%s: expected an error", test.name)
+		}
+	}
+}
@@ -0,0 +1,205 @@
+package reticulum
+
+import (
+	"math"
+
+	"github.com/nathanleary/reticulum/layers"
+)
+
+// EvalReport summarises the performance of a network on a dataset.
+type EvalReport struct {
+	// Count is the number of samples evaluated.
+	Count int
+
+	// Loss is the mean cost loss.
+	Loss float64
+
+	// Regression is set when the samples have regression targets.
+	Regression bool
+
+	// Accuracy is the fraction of correctly classified samples (classification only).
+	// For samples with a label map it counts every labeled position as a sample.
+	Accuracy float64
+
+	// Classes holds the per-class breakdown, keyed by label (classification only).
+	Classes map[int]*ClassReport
+
+	// MSE and MAE are the mean squared and mean absolute errors over all
+	// output dimensions (regression only).
+	MSE float64
+	MAE float64
+
+	// Accumulated holds the metrics of the accumulators passed to EvaluateWith.
+	Accumulated Metrics
+}
+
+// Accumulator collects the predictions of a classifier one sample at a time, to
+// compute metrics beyond the accuracy. The metrics package provides accumulators.
+type Accumulator interface {
+	// Add records the prediction for a sample of class truth.
+	Add(pred, truth int)
+
+	// Reset forgets the predictions added so far.
+	Reset()
+
+	// Metrics returns the metrics of the predictions added so far.
+	Metrics() Metrics
+}
+
+// ClassReport is the breakdown for a single class.
+type ClassReport struct {
+	// Support is the number of samples with this label.
+	Support int
+
+	// Predicted is the number of samples predicted as this class.
+	Predicted int
+
+	// Correct is the number of samples with this label predicted correctly.
+	Correct int
+}
+
+// Precision returns the fraction of predictions of this class which were correct.
+func (c *ClassReport) Precision() float64 {
+	if c.Predicted == 0 {
+		return 0
+	}
+	return float64(c.Correct) / float64(c.Predicted)
+}
+
+// Recall returns the fraction of samples of this class which were predicted correctly.
+func (c *ClassReport) Recall() float64 {
+	if c.Support == 0 {
+		return 0
+	}
+	return float64(c.Correct) / float64(c.Support)
+}
+
+// Metrics returns the headline numbers of the report as Metrics.
+func (r EvalReport) Metrics() Metrics {
+	m := Metrics{"loss": r.Loss}
+	if r.Regression {
+		m["mse"] = r.MSE
+		m["mae"] = r.MAE
+	} else {
+		m["accuracy"] = r.Accuracy
+	}
+	for k, v := range r.Accumulated {
+		m[k] = v
+	}
+	return m
+}
+
+// Evaluate runs the network in inference mode over data and reports the mean loss and
+// either the accuracy with a per-class breakdown (classification) or the MSE and MAE
+// (regression). Samples with a Target are treated as regression samples, and samples
+// with a LabelMap are classified at every labeled position.
+func Evaluate(net Network, data []Sample) EvalReport {
+	return EvaluateWith(net, data)
+}
+
+// EvaluateWith evaluates like Evaluate, also adding the prediction for every
+// classification sample to each accumulator. The accumulators are not reset first, and
+// their metrics are included in the report.
+func EvaluateWith(net Network, data []Sample, accs ...Accumulator) EvalReport {
+	report := EvalReport{Count: len(data), Classes: map[int]*ClassReport{}}
+	if len(data) == 0 {
+		return report
+	}
+
+	var correct, predictions, dims int
+	for _, sample := range data {
+		out := net.Forward(sample.Input, false)
+		report.Loss += cost(net, sample)
+
+		if sample.Target != nil {
+			report.Regression = true
+			for i, y := range sample.Target {
+				d := out.GetByIndex(i) - y
+				report.MSE += d * d
+				report.MAE += math.Abs(d)
+			}
+			dims += len(sample.Target)
+			continue
+		}
+
+		add := func(pred, label int) {
+			for _, acc := range accs {
+				acc.Add(pred, label)
+			}
+			report.class(label).Support++
+			report.class(pred).Predicted++
+			if pred == label {
+				report.class(pred).Correct++
+				correct++
+			}
+			predictions++
+		}
+		if sample.LabelMap != nil {
+			w, z := out.Weights(), out.Dimensions().Z
+			for i, label := range sample.LabelMap {
+				if label >= 0 {
+					add(argmax(w[i*z:(i+1)*z]), label)
+				}
+			}
+			continue
+		}
+		add(argmax(out.Weights()), sample.Label)
+	}
+
+	report.Loss /= float64(len(data))
+	if predictions > 0 {
+		report.Accuracy = float64(correct) / float64(predictions)
+	}
+	if dims > 0 {
+		report.MSE /= float64(dims)
+		report.MAE /= float64(dims)
+	}
+	if report.Regression {
+		report.Classes = nil
+		report.Accuracy = 0
+	}
+	if len(accs) > 0 {
+		report.Accumulated = Metrics{}
+		for _, acc := range accs {
+			for k, v := range acc.Metrics() {
+				report.Accumulated[k] = v
+			}
+		}
+	}
+	return report
+}
+
+// class returns the breakdown for a label, creating it if needed.
+func (r *EvalReport) class(label int) *ClassReport {
+	c, ok := r.Classes[label]
+	if !ok {
+		c = &ClassReport{}
+		r.Classes[label] = c
+	}
+	return c
+}
+
+// cost returns the unweighted loss of the last forward pass without propagating
+// gradients into the network parameters.
+func cost(net Network, sample Sample) float64 {
+	last := net.Layers()[net.Size()-1]
+	if sample.LabelMap != nil {
+		lossLayer, ok := last.(layers.DenseLossLayer)
+		if !ok {
+			panic("expecting dense loss layer as last layer in network")
+		}
+		return lossLayer.DenseLoss(sample.LabelMap)
+	}
+	if sample.Target != nil {
+		lossLayer, ok := last.(layers.RegressionLossLayer)
+		if !ok {
+			panic("expecting regression loss layer as last layer in network")
+		}
+		return lossLayer.MultiDimensionalLoss(sample.Target)
+	}
+	lossLayer, ok := last.(layers.LossLayer)
+	if !ok {
+		panic("expecting loss layer as last layer in network")
+	}
+	return lossLayer.Loss(sample.Label)
+}
@@ -0,0 +1,195 @@
+// Package explain computes explanations of the predictions of a network, showing
+// which parts of an input a class score depends on:
+//
+//	class, _ := reticulum.PredictE(net, img)
+//	saliency, err := explain.Saliency(net, img, class)
+//	if err != nil {
+//		return err
+//	}
+//	cam, err := explain.GradCAM(net, img, class, "relu3")
+//	if err != nil {
+//		return err
+//	}
+//	err = png.Encode(f, cam.Overlay)
+package explain
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+
+	"github.com/nathanleary/reticulum"
+	"github.com/nathanleary/reticulum/volume"
+)
+
+// Saliency returns the saliency map of the class for the volume (Simonyan et al.),
+// the magnitude of the gradient of the raw class score with respect to every input
+// value. The map has the width and height of the volume and a depth of 1, holding the
+// largest absolute gradient over the channels of every position; the parameter
+// gradients of the network are left unchanged.
+func Saliency(net reticulum.Network, vol *volume.Volume, class int) (*volume.Volume, error) {
+	grads, err := reticulum.ClassGradients(net, vol, class)
+	if err != nil {
+		return nil, err
+	}
+	return maxAbsOverDepth(grads.Input.Gradients(), vol.Dimensions()), nil
+}
+
+// maxAbsOverDepth reduces values laid out as dim to their largest absolute value at
+// every position.
+func maxAbsOverDepth(values []float64, dim volume.Dimensions) *volume.Volume {
+	out := volume.NewVolume(volume.NewDimensions(dim.X, dim.Y, 1), volume.WithZeros())
+	w := out.Weights()
+	for i := range w {
+		for d := 0; d < dim.Z; d++ {
+			w[i] = math.Max(w[i], math.Abs(values[i*dim.Z+d]))
+		}
+	}
+	return out
+}
+
+// CAM is a class activation map of a volume.
+type CAM struct {
+	// Heatmap has the width and height of the input and a depth of 1, with values in
+	// [0, 1] for how much every position supports the class.
+	Heatmap *volume.Volume
+
+	// Overlay is the input image with the heatmap composited over it.
+	Overlay *image.RGBA
+}
+
+// GradCAM returns the Grad-CAM class activation map of the class for the volume
+// (Selvaraju et al.), from the output of the named layer, usually the last conv layer
+// or the activation following it. Every channel of the layer's output is weighted by
+// the mean gradient of the raw class score over its positions, and the positive part
+// of their sum is scaled bilinearly to the size of the input and normalized to [0, 1].
+func GradCAM(net reticulum.Network, vol *volume.Volume, class int, convLayerName string) (*CAM, error) {
+	grads, err := reticulum.ClassGradients(net, vol, class, convLayerName)
+	if err != nil {
+		return nil, err
+	}
+	act := grads.Activations[convLayerName]
+	dim := act.Dimensions()
+	if dim.X*dim.Y <= 1 {
+		return nil, fmt.Errorf("layer %q has no spatial output, got %dx%dx%d", convLayerName, dim.X, dim.Y, dim.Z)
+	}
+
+	positions := dim.X * dim.Y
+	a, g := act.Weights(), act.Gradients()
+	alpha := make([]float64, dim.Z)
+	for i := 0; i < positions; i++ {
+		for d := range alpha {
+			alpha[d] += g[i*dim.Z+d] / float64(positions)
+		}
+	}
+	cam := make([]float64, positions)
+	for i := range cam {
+		for d, w := range alpha {
+			cam[i] += w * a[i*dim.Z+d]
+		}
+		cam[i] = math.Max(cam[i], 0)
+	}
+
+	in := vol.Dimensions()
+	heatmap := resize(cam, dim.X, dim.Y, in.X, in.Y)
+	normalize(heatmap.Weights())
+	return &CAM{Heatmap: heatmap, Overlay: Composite(vol, heatmap, 0.5)}, nil
+}
+
+// Composite renders the volume as an image, in grayscale for a depth of 1, in color
+// for a depth of 3 and as the mean of the channels otherwise, with its values scaled
+// to the full range, and blends the heatmap over it in a blue to red color map. The
+// heatmap has the width and height of the volume with values in [0, 1], and alpha is
+// its opacity.
+func Composite(vol, heatmap *volume.Volume, alpha float64) *image.RGBA {
+	dim, hdim := vol.Dimensions(), heatmap.Dimensions()
+	if hdim.X != dim.X || hdim.Y != dim.Y {
+		panic(fmt.Errorf("heatmap of %dx%d does not match the volume of %dx%d", hdim.X, hdim.Y, dim.X, dim.Y))
+	}
+
+	lo, hi := math.Inf(1), math.Inf(-1)
+	for _, v := range vol.Weights() {
+		lo, hi = math.Min(lo, v), math.Max(hi, v)
+	}
+	scale := func(v float64) float64 {
+		if hi == lo {
+			return 0
+		}
+		return (v - lo) / (hi - lo)
+	}
+
+	img := image.NewRGBA(image.Rect(0, 0, dim.X, dim.Y))
+	for y := 0; y < dim.Y; y++ {
+		for x := 0; x < dim.X; x++ {
+			var rgb [3]float64
+			if dim.Z == 3 {
+				for c := range rgb {
+					rgb[c] = scale(vol.Get(x, y, c))
+				}
+			} else {
+				var sum float64
+				for d := 0; d < dim.Z; d++ {
+					sum += vol.Get(x, y, d)
+				}
+				v := scale(sum / float64(dim.Z))
+				rgb = [3]float64{v, v, v}
+			}
+			heat := jet(heatmap.Get(x, y, 0))
+			for c := range rgb {
+				rgb[c] = (1-alpha)*rgb[c] + alpha*heat[c]
+			}
+			img.SetRGBA(x, y, color.RGBA{R: toByte(rgb[0]), G: toByte(rgb[1]), B: toByte(rgb[2]), A: 255})
+		}
+	}
+	return img
+}
+
+// resize scales a single channel map of w x h values to a volume of width x height
+// by bilinear interpolation between the centers of the cells.
+func resize(values []float64, w, h, width, height int) *volume.Volume {
+	out := volume.NewVolume(volume.NewDimensions(width, height, 1), volume.WithZeros())
+	sample := func(pos float64, n, size int) (int, int, float64) {
+		f := (pos+0.5)*float64(n)/float64(size) - 0.5
+		f = math.Min(math.Max(f, 0), float64(n-1))
+		i := int(f)
+		return i, min(i+1, n-1), f - float64(i)
+	}
+	for y := 0; y < height; y++ {
+		y0, y1, fy := sample(float64(y), h, height)
+		for x := 0; x < width; x++ {
+			x0, x1, fx := sample(float64(x), w, width)
+			top := values[y0*w+x0]*(1-fx) + values[y0*w+x1]*fx
+			bottom := values[y1*w+x0]*(1-fx) + values[y1*w+x1]*fx
+			out.Set(x, y, 0, top*(1-fy)+bottom*fy)
+		}
+	}
+	return out
+}
+
+// normalize scales non-negative values in place so the largest is 1.
+func normalize(values []float64) {
+	var top float64
+	for _, v := range values {
+		top = math.Max(top, v)
+	}
+	if top == 0 {
+		return
+	}
+	for i := range values {
+		values[i] /= top
+	}
+}
+
+// jet maps a value in [0, 1] to a color going from blue through green to red.
+func jet(v float64) [3]float64 {
+	v = math.Min(math.Max(v, 0), 1)
+	channel := func(center float64) float64 {
+		return math.Min(math.Max(1.5-math.Abs(4*v-center), 0), 1)
+	}
+	return [3]float64{channel(3), channel(2), channel(1)}
+}
+
+func toByte(v float64) uint8 {
+	return uint8(math.Round(math.Min(math.Max(v, 0), 1) * 255))
+}
@@ -0,0 +1,173 @@
+package explain
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/nathanleary/reticulum"
+	"github.com/nathanleary/reticulum/layers"
+	"github.com/nathanleary/reticulum/volume"
+)
+
+func randomVolume(dim volume.Dimensions) *volume.Volume {
+	return volume.NewVolume(dim, volume.WithRand(rand.New(rand.NewSource(1))))
+}
+
+func TestSaliency(t *testing.T) {
+	// the raw scores of a single fully connected layer are linear in the input, so the
+	// saliency of a class is the magnitude of its weights
+	net, err := reticulum.NewNetwork([]layers.LayerDef{
+		{Type: layers.Input, Output: volume.NewDimensions(3, 1, 1)},
+		{Type: layers.SoftMax, LayerConfig: layers.NewSoftmaxLayerConfig(2)},
+	}, reticulum.WithNetworkSeed(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	fc := net.Layers()[1].GetResponse()
+	vol := randomVolume(volume.NewDimensions(3, 1, 1))
+	for class := 0; class < 2; class++ {
+		saliency, err := Saliency(net, vol, class)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if dim := saliency.Dimensions(); dim != volume.NewDimensions(3, 1, 1) {
+			t.Fatalf("saliency map of %v, want 3x1x1", dim)
+		}
+		for x, w := range fc[class].Weights {
+			if got := saliency.Get(x, 0, 0); math.Abs(got-math.Abs(w)) > 1e-12 {
+				t.Errorf("class %d: saliency at %d = %v, want %v", class, x, got, math.Abs(w))
+			}
+		}
+	}
+	for _, resp := range fc {
+		for _, g := range resp.Gradients {
+			if g != 0 {
+				t.Fatal("saliency changed the parameter gradients")
+			}
+		}
+	}
+}
+
+func TestSaliency_Errors(t *testing.T) {
+	net, err := reticulum.NewNetwork([]layers.LayerDef{
+		{Type: layers.Input, Output: volume.NewDimensions(3, 1, 1)},
+		{Type: layers.SoftMax, LayerConfig: layers.NewSoftmaxLayerConfig(2)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	vol := randomVolume(volume.NewDimensions(3, 1, 1))
+	for _, test := range []struct {
+		name  string
+		vol   *volume.Volume
+		class int
+	}{
+		{"class out of range", vol, 2},
+		{"negative class", vol, -1},
+		{"wrong input size", randomVolume(volume.NewDimensions(1, 1, 3)), 0},
+		{"nil volume", nil, 0},
+	} {
+		if _, err := Saliency(net, test.vol, test.class); err == nil {
+			t.Errorf("%s: expected an error", test.name)
+		}
+	}
+}
+
+func convNet(t *testing.T) reticulum.Network {
+	t.Helper()
+	net, err := reticulum.NewNetwork([]layers.LayerDef{
+		{Type: layers.Input, Output: volume.NewDimensions(6, 6, 3)},
+		{Type: layers.Conv, Name: "conv", LayerConfig: layers.NewConvLayerConfig(4, layers.WithSx(3), layers.WithPadding(1)), Activation: layers.ReLU},
+		{Type: layers.Pool, LayerConfig: layers.NewPoolLayerConfig(2)},
+		{Type: layers.FullyConnected, Name: "fc", LayerConfig: layers.NewFullyConnectedLayerConfig(4)},
+		{Type: layers.SoftMax, LayerConfig: layers.NewSoftmaxLayerConfig(3)},
+	}, reticulum.WithNetworkSeed(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return net
+}
+
+func TestGradCAM(t *testing.T) {
+	net := convNet(t)
+	vol := randomVolume(volume.NewDimensions(6, 6, 3))
+	for class := 0; class < 3; class++ {
+		cam, err := GradCAM(net, vol, class, "conv")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if dim := cam.Heatmap.Dimensions(); dim != volume.NewDimensions(6, 6, 1) {
+			t.Fatalf("heatmap of %v, want 6x6x1", dim)
+		}
+		var top float64
+		for _, v := range cam.Heatmap.Weights() {
+			if v < 0 || v > 1 {
+				t.Fatalf("class %d: heatmap value %v outside [0, 1]", class, v)
+			}
+			top = math.Max(top, v)
+		}
+		if top != 0 && top != 1 {
+			t.Errorf("class %d: heatmap peaks at %v, want 1", class, top)
+		}
+		if b := cam.Overlay.Bounds(); b.Dx() != 6 || b.Dy() != 6 {
+			t.Errorf("class %d: overlay of %v, want 6x6", class, b)
+		}
+	}
+}
+
+func TestGradCAM_Errors(t *testing.T) {
+	net := convNet(t)
+	vol := randomVolume(volume.NewDimensions(6, 6, 3))
+	for _, test := range []struct {
+		name  string
+		vol   *volume.Volume
+		class int
+		layer string
+	}{
+		{"class out of range", vol, 3, "conv"},
+		{"wrong input size", randomVolume(volume.NewDimensions(5, 5, 3)), 0, "conv"},
+		{"unknown layer", vol, 0, "conv9"},
+		{"no spatial output", vol, 0, "fc"},
+	} {
+		if _, err := GradCAM(net, test.vol, test.class, test.layer); err == nil {
+			t.Errorf("%s: expected an error", test.name)
+		}
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("expected a panic compositing a heatmap of another size")
+		}
+	}()
+	Composite(vol, volume.NewVolume(volume.NewDimensions(3, 3, 1), volume.WithZeros()), 0.5)
+}
+
+func TestResize(t *testing.T) {
+	// a 2x1 map scaled to 4x2 interpolates between the cell centers
+	out := resize([]float64{0, 1}, 2, 1, 4, 2)
+	for y := 0; y < 2; y++ {
+		for x, want := range []float64{0, 0.25, 0.75, 1} {
+			if got := out.Get(x, y, 0); math.Abs(got-want) > 1e-12 {
+				t.Errorf("resized value at %d,%d = %v, want %v", x, y, got, want)
+			}
+		}
+	}
+}
+
+func TestJet(t *testing.T) {
+	for _, test := range []struct {
+		v    float64
+		want [3]float64
+	}{
+		{-1, [3]float64{0, 0, 0.5}},
+		{0, [3]float64{0, 0, 0.5}},
+		{0.25, [3]float64{0, 0.5, 1}},
+		{0.5, [3]float64{0.5, 1, 0.5}},
+		{1, [3]float64{0.5, 0, 0}},
+	} {
+		if got := jet(test.v); got != test.want {
+			t.Errorf("jet(%v) = %v, want %v", test.v, got, test.want)
+		}
+	}
+}
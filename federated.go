@@ -0,0 +1,117 @@
+package reticulum
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/nathanleary/reticulum/volume"
+)
+
+// CopyWeights copies all parameters from src into dst, frozen or not, matching them by
+// name. Both networks must share the same architecture and layer names; nothing is
+// copied on error.
+func CopyWeights(dst, src Network) error {
+	params := map[string][]float64{}
+	for name, p := range NamedParameters(src) {
+		params[name] = p.Weights()
+	}
+	return LoadNamedParameters(dst, params, true)
+}
+
+// ClientUpdate starts a client from the global weights and trains it locally on its
+// own data for the given number of epochs. Returns the number of samples seen, which
+// is the usual aggregation weight for FederatedAverage.
+func ClientUpdate(global, client Network, data []Sample, epochs int, opts ...OptionFunc) (int, error) {
+	if len(data) == 0 {
+		return 0, errors.New("client has no data")
+	}
+	if err := CopyWeights(client, global); err != nil {
+		return 0, err
+	}
+
+	t, err := NewTrainerE(client, opts...)
+	if err != nil {
+		return 0, err
+	}
+	for e := 0; e < epochs; e++ {
+		for i, sample := range data {
+			if _, err := t.TrainE(sample.Input, sample.LossFunc()); err != nil {
+				return 0, fmt.Errorf("sample %d: %w", i, err)
+			}
+		}
+	}
+	return len(data), nil
+}
+
+// Aggregate replaces the weights of global with the weighted average of the client
+// weights. When weights is nil every client contributes equally.
+func Aggregate(global Network, clients []Network, weights []float64) error {
+	if len(clients) == 0 {
+		return errors.New("at least one client is required")
+	} else if weights != nil && len(weights) != len(clients) {
+		return errors.New("expected one weight per client")
+	}
+
+	var total float64
+	norm := make([]float64, len(clients))
+	for i := range clients {
+		norm[i] = 1.0
+		if weights != nil {
+			if weights[i] < 0 {
+				return fmt.Errorf("client %d: weight cannot be negative", i)
+			}
+			norm[i] = weights[i]
+		}
+		total += norm[i]
+	}
+	if total == 0 {
+		return errors.New("client weights sum to zero")
+	}
+
+	// parameters are matched by name and include frozen layers
+	params := NamedParameters(global)
+	clientParams := make([]map[string]*volume.Volume, len(clients))
+	for c, client := range clients {
+		cp := NamedParameters(client)
+		clientParams[c] = cp
+		if len(cp) != len(params) {
+			return fmt.Errorf("client %d: parameter count mismatch: %d != %d", c, len(cp), len(params))
+		}
+		for name, p := range params {
+			if w, ok := cp[name]; !ok {
+				return fmt.Errorf("client %d: missing parameter %q", c, name)
+			} else if w.Size() != p.Size() {
+				return fmt.Errorf("client %d: parameter %q: size mismatch: %d != %d", c, name, w.Size(), p.Size())
+			}
+		}
+	}
+
+	// the parameters are copies, so global may be one of the clients
+	avg := make(map[string][]float64, len(params))
+	for name, p := range params {
+		sum := make([]float64, p.Size())
+		for c := range clients {
+			w := clientParams[c][name].Weights()
+			scale := norm[c] / total
+			for j := range sum {
+				sum[j] += w[j] * scale
+			}
+		}
+		avg[name] = sum
+	}
+	return LoadNamedParameters(global, avg, true)
+}
+
+// FederatedAverage returns a new model, a clone of the first one, whose weights are
+// the weighted average of those of the models, which are left unchanged. It returns
+// an error if the models do not share an architecture.
+func FederatedAverage(models []Network, weights []float64) (Network, error) {
+	if len(models) == 0 {
+		return nil, errors.New("at least one model is required")
+	}
+	avg := Clone(models[0])
+	if err := Aggregate(avg, models, weights); err != nil {
+		return nil, err
+	}
+	return avg, nil
+}
@@ -0,0 +1,85 @@
+package reticulum
+
+import (
+	"math"
+	"math/rand"
+	"slices"
+	"testing"
+
+	"github.com/nathanleary/reticulum/layers"
+	"github.com/nathanleary/reticulum/volume"
+)
+
+func TestFederatedAverage(t *testing.T) {
+	a, b := privacyNetwork(t, 4, 3), privacyNetwork(t, 4, 3)
+	for _, pg := range b.GetResponse() {
+		for j := range pg.Weights {
+			pg.Weights[j] += 1
+		}
+	}
+	wa, wb := flatWeights(a), flatWeights(b)
+
+	avg, err := FederatedAverage([]Network{a, b}, []float64{3, 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for j, w := range flatWeights(avg) {
+		if want := 0.75*wa[j] + 0.25*wb[j]; math.Abs(w-want) > 1e-12 {
+			t.Fatalf("weight %d = %v, want %v", j, w, want)
+		}
+	}
+	if !slices.Equal(flatWeights(a), wa) || !slices.Equal(flatWeights(b), wb) {
+		t.Error("FederatedAverage() changed the models")
+	}
+}
+
+func TestFederatedAverage_Errors(t *testing.T) {
+	wide := privacyNetwork(t, 4, 5)
+	deep, err := NewNetwork([]layers.LayerDef{
+		{Type: layers.Input, Output: volume.NewDimensions(1, 1, 4)},
+		{Type: layers.FullyConnected, LayerConfig: layers.NewFullyConnectedLayerConfig(3)},
+		{Type: layers.FullyConnected, LayerConfig: layers.NewFullyConnectedLayerConfig(3)},
+		{Type: layers.SoftMax, LayerConfig: layers.NewSoftmaxLayerConfig(3)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		models  []Network
+		weights []float64
+	}{
+		{"no models", nil, nil},
+		{"different sizes", []Network{privacyNetwork(t, 4, 3), wide}, nil},
+		{"different layers", []Network{privacyNetwork(t, 4, 3), deep}, nil},
+		{"weight count", []Network{privacyNetwork(t, 4, 3)}, []float64{1, 1}},
+		{"negative weight", []Network{privacyNetwork(t, 4, 3)}, []float64{-1}},
+	}
+	for _, test := range tests {
+		if _, err := FederatedAverage(test.models, test.weights); err == nil {
+			t.Errorf("%s: FederatedAverage() succeeded", test.name)
+		}
+	}
+}
+
+func TestClientUpdate_Errors(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	data := []Sample{{Input: randomInput(r, 4), Label: 1}}
+	tests := []struct {
+		name string
+		data []Sample
+		opts []OptionFunc
+	}{
+		{"no data", nil, nil},
+		{"invalid options", data, []OptionFunc{WithLearningRate(-1)}},
+		{"invalid label", []Sample{{Input: randomInput(r, 4), Label: 7}}, nil},
+		{"invalid input", []Sample{{Input: randomInput(r, 5), Label: 1}}, nil},
+	}
+	for _, test := range tests {
+		global, client := privacyNetwork(t, 4, 3), privacyNetwork(t, 4, 3)
+		if _, err := ClientUpdate(global, client, test.data, 1, test.opts...); err == nil {
+			t.Errorf("%s: ClientUpdate() succeeded", test.name)
+		}
+	}
+}
@@ -0,0 +1,149 @@
+package reticulum
+
+import (
+	"errors"
+	"fmt"
+	"math/rand/v2"
+
+	"github.com/nathanleary/reticulum/volume"
+)
+
+// FitOptions configures the Fit training loop.
+type FitOptions struct {
+	Epochs int
+
+	// BatchSize is the number of samples per update. Defaults to 1.
+	BatchSize int
+
+	// Shuffle reorders the training data at the start of every epoch.
+	Shuffle bool
+
+	// Callbacks are notified as training progresses.
+	Callbacks []Callback
+
+	// Options configure the trainer used by Fit, e.g. WithLogger to report progress.
+	Options []OptionFunc
+}
+
+// Fit trains the network on trainData for the given number of epochs, using mini-batches
+// of BatchSize samples. At the end of each epoch the mean training loss is recorded as
+// "loss" and, when valData is not empty, the Evaluate metrics prefixed with "val_", e.g.
+// "val_loss" and "val_accuracy" for classifiers or "val_mse" for regression. Training
+// stops early if a callback calls Trainer.Stop.
+func Fit(net Network, trainData, valData []Sample, opts FitOptions) (*History, error) {
+	return fit(net, trainData, valData, opts, func(i int, classWeights map[int]float64) LossFunc {
+		return weightedLossFunc(trainData[i], classWeights)
+	})
+}
+
+// fit runs the Fit training loop, getting the loss function of the i-th training
+// sample from lossFunc.
+func fit(net Network, trainData, valData []Sample, opts FitOptions, lossFunc func(i int, classWeights map[int]float64) LossFunc) (*History, error) {
+	if net == nil {
+		return nil, errors.New("network cannot be nil")
+	} else if len(trainData) == 0 {
+		return nil, errors.New("training data cannot be empty")
+	} else if opts.Epochs <= 0 {
+		return nil, errors.New("epochs must be greater than 0")
+	}
+
+	batchSize := opts.BatchSize
+	if batchSize <= 0 {
+		batchSize = 1
+	}
+
+	// shuffling gets its own source, so it is reproducible under WithSeed
+	baseOpts := newOptions(opts.Options...)
+	rng := rand.New(baseOpts.newSource(shuffleStream))
+
+	trainerOpts := append([]OptionFunc{}, opts.Options...)
+	trainerOpts = append(trainerOpts, WithCallbacks(opts.Callbacks...))
+	t, err := NewTrainerE(net, trainerOpts...)
+	if err != nil {
+		return nil, err
+	}
+
+	classWeights := baseOpts.ClassWeights
+	if baseOpts.BalanceClasses {
+		classWeights = BalancedClassWeights(trainData)
+	}
+
+	order := make([]int, len(trainData))
+	for i := range order {
+		order[i] = i
+	}
+
+	vols := make([]*volume.Volume, 0, batchSize)
+	losses := make([]LossFunc, 0, batchSize)
+	for epoch := 0; epoch < opts.Epochs && !t.Stopped(); epoch++ {
+		if opts.Shuffle {
+			rng.Shuffle(len(order), func(i, j int) { order[i], order[j] = order[j], order[i] })
+		}
+
+		var total float64
+		for start := 0; start < len(order); start += batchSize {
+			vols, losses = vols[:0], losses[:0]
+			for _, i := range order[start:min(start+batchSize, len(order))] {
+				vols = append(vols, trainData[i].Input)
+				losses = append(losses, lossFunc(i, classWeights))
+			}
+			res, err := t.TrainBatchE(vols, losses)
+			if err != nil {
+				return t.History(), fmt.Errorf("epoch %d: %w", epoch, err)
+			}
+			total += res.CostLost * float64(len(vols))
+		}
+
+		metrics := Metrics{"loss": total / float64(len(trainData))}
+		if len(valData) > 0 {
+			for k, v := range Evaluate(net, valData).Metrics() {
+				metrics["val_"+k] = v
+			}
+		}
+		t.EndEpoch(epoch, metrics)
+	}
+	return t.History(), nil
+}
+
+// BalancedClassWeights returns the class weights n / (classes * count) for the labeled
+// samples, so every class contributes equally to the loss regardless of its frequency.
+func BalancedClassWeights(data []Sample) map[int]float64 {
+	counts := map[int]int{}
+	var n int
+	for _, sample := range data {
+		if sample.Target == nil && sample.LabelMap == nil {
+			counts[sample.Label]++
+			n++
+		}
+	}
+
+	weights := make(map[int]float64, len(counts))
+	for class, count := range counts {
+		weights[class] = float64(n) / float64(len(counts)*count)
+	}
+	return weights
+}
+
+// weightedLossFunc returns the sample's loss function, scaled by the weight of its
+// class when it is labeled.
+func weightedLossFunc(sample Sample, classWeights map[int]float64) LossFunc {
+	w, ok := classWeights[sample.Label]
+	if !ok || sample.Target != nil || sample.LabelMap != nil {
+		return sample.LossFunc()
+	}
+	if sample.Weight != 0 {
+		w *= sample.Weight
+	}
+	return LabeledLossFuncWeighted(sample.Label, w)
+}
+
+// argmax returns the index of the largest value.
+func argmax(values []float64) int {
+	maxv, maxi := values[0], 0
+	for i, v := range values {
+		if v > maxv {
+			maxv, maxi = v, i
+		}
+	}
+	return maxi
+}
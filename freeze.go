@@ -0,0 +1,63 @@
+package reticulum
+
+import (
+	"fmt"
+
+	"github.com/nathanleary/reticulum/layers"
+)
+
+func (n *network) Freeze(names ...string) error {
+	return n.setFrozen(names, true)
+}
+
+func (n *network) Unfreeze(names ...string) error {
+	return n.setFrozen(names, false)
+}
+
+func (n *network) Frozen(name string) bool {
+	return n.frozen[name]
+}
+
+// trainable returns the parameters and gradients of the layers of net which are not
+// frozen, the ones training updates.
+func trainable(net Network) []layers.LayerResponse {
+	var pgList []layers.LayerResponse
+	for _, pg := range net.GetResponse() {
+		if !net.Frozen(pg.LayerName) {
+			pgList = append(pgList, pg)
+		}
+	}
+	return pgList
+}
+
+// setFrozen marks the named layers, or every layer when no names are given, as frozen
+// or trainable. The gradients of unfrozen layers are cleared, as they accumulated
+// while the trainer was not looking at them.
+func (n *network) setFrozen(names []string, frozen bool) error {
+	if len(names) == 0 {
+		names = n.names
+	}
+	indices := make([]int, len(names))
+	for i, name := range names {
+		if indices[i] = n.layerIndex(name); indices[i] < 0 {
+			return fmt.Errorf("unknown layer %q", name)
+		}
+	}
+
+	if n.frozen == nil {
+		n.frozen = map[string]bool{}
+	}
+	for _, i := range indices {
+		name := n.names[i]
+		if frozen {
+			n.frozen[name] = true
+			continue
+		} else if n.frozen[name] {
+			for _, resp := range n.layers[i].GetResponse() {
+				clear(resp.Gradients)
+			}
+		}
+		delete(n.frozen, name)
+	}
+	return nil
+}
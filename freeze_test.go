@@ -0,0 +1,54 @@
+package reticulum
+
+import (
+	"math/rand"
+	"slices"
+	"testing"
+
+	"github.com/nathanleary/reticulum/volume"
+)
+
+func TestFreeze_TrainLeavesFrozenWeights(t *testing.T) {
+	tests := []struct {
+		name string
+		opts []OptionFunc
+	}{
+		{"sgd", []OptionFunc{WithMomentum(0.9), WithDecay(0.001, 0.01)}},
+		{"gradient noise", []OptionFunc{WithGradientNoise(0.1, 0.55)}},
+		{"adam", []OptionFunc{WithAdam(0.95, 0.9, 0.999), WithDecay(0, 0.01)}},
+	}
+	for _, test := range tests {
+		net := privacyNetwork(t, 4, 3)
+		frozen := LayerName(net, 1)
+		if err := net.Freeze(frozen); err != nil {
+			t.Fatal(err)
+		}
+		weights := func(frozen bool) []float64 {
+			var w []float64
+			for _, pg := range net.GetResponse() {
+				if net.Frozen(pg.LayerName) == frozen {
+					w = append(w, pg.Weights...)
+				}
+			}
+			return w
+		}
+		before, others := weights(true), weights(false)
+		if len(before) == 0 || len(others) == 0 {
+			t.Fatalf("%s: GetResponse() is missing the frozen or trainable layers", test.name)
+		}
+
+		r := rand.New(rand.NewSource(1))
+		trainer := NewTrainer(net, append(test.opts, WithSeed(1))...)
+		for i := 0; i < 5; i++ {
+			trainer.Train(randomInput(r, 4), LabeledLossFunc(i%3))
+			trainer.TrainBatch([]*volume.Volume{randomInput(r, 4), randomInput(r, 4)}, []LossFunc{LabeledLossFunc(0), LabeledLossFunc(2)})
+		}
+
+		if after := weights(true); !slices.Equal(after, before) {
+			t.Errorf("%s: weights of frozen layer %q changed from %v to %v", test.name, frozen, before, after)
+		}
+		if slices.Equal(weights(false), others) {
+			t.Errorf("%s: training left the trainable layers unchanged", test.name)
+		}
+	}
+}
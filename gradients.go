@@ -0,0 +1,108 @@
+package reticulum
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/nathanleary/reticulum/volume"
+)
+
+// ScoreGradients holds the gradients of the score of a class with respect to the
+// input of a network and the outputs of some of its layers, e.g. for saliency maps.
+type ScoreGradients struct {
+	// Score is the raw score of the class, the input of the loss layer before e.g. the
+	// softmax, summed over the positions of dense outputs.
+	Score float64
+
+	// Input is a copy of the input volume, with the gradients of the score.
+	Input *volume.Volume
+
+	// Activations are copies of the outputs of the requested layers keyed by name,
+	// with the gradients of the score.
+	Activations map[string]*volume.Volume
+}
+
+// ClassGradients runs net in inference mode and backpropagates the raw score of the
+// class down to the input, returning the gradients of the input and of the outputs of
+// the named layers. The score is taken before the loss layer so it does not saturate,
+// and the parameter gradients are left as they were, so it can be called between
+// training steps. It requires a network built by this package.
+func ClassGradients(net Network, vol *volume.Volume, class int, names ...string) (ScoreGradients, error) {
+	n, ok := net.(*network)
+	if !ok {
+		return ScoreGradients{}, errors.New("class gradients require a network built by NewNetwork or NewGraph")
+	}
+	return n.classGradients(vol, class, names...)
+}
+
+func (n *network) classGradients(vol *volume.Volume, class int, names ...string) (grads ScoreGradients, err error) {
+	wanted := map[int]bool{}
+	for _, name := range names {
+		i := n.layerIndex(name)
+		if i < 0 {
+			return ScoreGradients{}, fmt.Errorf("unknown layer %q", name)
+		}
+		wanted[i] = true
+	}
+	if vol == nil {
+		return ScoreGradients{}, errors.New("volume cannot be nil")
+	} else if len(n.inputDims) != 1 {
+		return ScoreGradients{}, fmt.Errorf("network has %d inputs, use ForwardMulti", len(n.inputDims))
+	} else if dim := vol.Dimensions(); dim != n.inputDims[0] {
+		return ScoreGradients{}, fmt.Errorf("volume dimensions %v do not match the input %v", dim, n.inputDims[0])
+	}
+	defer recoverError(&err)
+
+	// keep the parameter gradients accumulated so far by training
+	var saved [][]float64
+	for _, layer := range n.layers {
+		for _, resp := range layer.GetResponse() {
+			saved = append(saved, append([]float64(nil), resp.Gradients...))
+		}
+	}
+	defer func() {
+		k := 0
+		for _, layer := range n.layers {
+			for _, resp := range layer.GetResponse() {
+				copy(resp.Gradients, saved[k])
+				k++
+			}
+		}
+	}()
+
+	// the input layer passes its volume through, so backpropagate on a copy which
+	// then holds the input gradients
+	input := vol.Clone()
+	outputs := map[string]*volume.Volume{}
+	n.forwardActivations(input, func(i int, act *volume.Volume) {
+		if wanted[i] {
+			outputs[n.names[i]] = act
+		}
+	})
+
+	scores := n.lossInput
+	depth := scores.Dimensions().Z
+	if class < 0 || class >= depth {
+		return ScoreGradients{}, fmt.Errorf("class %d out of range [0, %d)", class, depth)
+	}
+	scores.ZeroGrad()
+	for i := class; i < scores.Size(); i += depth {
+		grads.Score += scores.GetByIndex(i)
+		scores.SetGradByIndex(i, 1)
+	}
+	n.backpropagate(1)
+
+	grads.Input = withGradients(input)
+	grads.Activations = make(map[string]*volume.Volume, len(outputs))
+	for name, act := range outputs {
+		grads.Activations[name] = withGradients(act)
+	}
+	return grads, nil
+}
+
+// withGradients returns a copy of the volume with its weights and gradients.
+func withGradients(vol *volume.Volume) *volume.Volume {
+	c := vol.Clone()
+	copy(c.Gradients(), vol.Gradients())
+	return c
+}
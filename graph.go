@@ -0,0 +1,501 @@
+package reticulum
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sort"
+
+	"github.com/nathanleary/reticulum/layers"
+	"github.com/nathanleary/reticulum/volume"
+)
+
+// MultiNetwork is a network with several named inputs or outputs, e.g. a multi-task
+// model with a classification and a regression head. The single input and output
+// methods of Network panic when the network has several inputs or outputs.
+type MultiNetwork interface {
+	Network
+
+	// InputNames and OutputNames return the names of the input and output layers.
+	InputNames() []string
+	OutputNames() []string
+
+	// ForwardMulti runs the network on the volumes keyed by input name and returns
+	// the outputs keyed by output name.
+	ForwardMulti(inputs map[string]*volume.Volume, training bool) map[string]*volume.Volume
+
+	// BackwardMulti computes the loss of every output with a target and propagates
+	// the weighted gradients of all of them. Returns the weighted sum of the losses.
+	BackwardMulti(targets map[string]Target) float64
+}
+
+// Target is the training target of a loss head: a class label, the values for a
+// regression head, or the action taken by a softmax policy head with Policy set.
+type Target struct {
+	Label  int
+	Values []float64
+
+	// Policy trains a softmax head by policy gradient towards the action in Label.
+	Policy *PolicyGradient
+
+	// Weight scales the loss of the head and its gradients, 0 is treated as 1.
+	Weight float64
+}
+
+// PolicyGradient holds the advantage of the action taken by a policy, scaling its
+// log-probability, and the weight of the entropy bonus.
+type PolicyGradient struct {
+	Advantage float64
+	Entropy   float64
+}
+
+// NewGraph creates a network whose layers form a directed acyclic graph, enabling
+// branches, merges and skip connections. Each definition names the layers feeding it
+// in Inputs, defaulting to the previous definition, and layers.Add or layers.Concat
+// merge several inputs. A name refers to the output of the layer after its activation
+// and dropout. Output dimensions left empty are inferred from the inputs.
+//
+// Every input layer and every layer whose output is not consumed by another layer
+// becomes an input and output of the network respectively. Outputs are typically
+// loss layers, trained together with BackwardMulti.
+func NewGraph(defs []layers.LayerDef, optFuncs ...NetworkOptionFunc) (MultiNetwork, error) {
+	opts := &NetworkOptions{}
+	for _, optFn := range optFuncs {
+		optFn(opts)
+	}
+
+	var rng *rand.Rand
+	if opts.HasSeed {
+		rng = rand.New(rand.NewSource(opts.Seed))
+	}
+
+	n, err := newGraph(opts.withLayerOptions(defs), rng, true)
+	if err != nil {
+		return nil, err
+	}
+	n.tracer = opts.Tracer
+	n.profileCtx = opts.ProfileContext
+	return n, nil
+}
+
+// newGraph creates a graph network from defs. Unless expand is set, the definitions
+// are taken to be expanded already, as those of a saved network are, and each becomes
+// a single layer.
+func newGraph(defs []layers.LayerDef, rng *rand.Rand, expand bool) (*network, error) {
+	// resolve the names and the inputs of every definition
+	index := map[string]int{}
+	names := make([]string, len(defs))
+	for i, def := range defs {
+		names[i] = def.Name
+		if names[i] == "" {
+			names[i] = fmt.Sprintf("%s%d", def.Type, i)
+		}
+		if _, ok := index[names[i]]; ok {
+			return nil, fmt.Errorf("duplicate layer name: %s", names[i])
+		}
+		index[names[i]] = i
+	}
+
+	deps := make([][]int, len(defs))
+	var inputs []string
+	for i, def := range defs {
+		if def.Type == layers.Input {
+			if len(def.Inputs) > 0 {
+				return nil, fmt.Errorf("input layer %s cannot have inputs", names[i])
+			}
+			inputs = append(inputs, names[i])
+			continue
+		}
+		if len(def.Inputs) == 0 {
+			if i == 0 {
+				return nil, fmt.Errorf("layer %s has no inputs", names[i])
+			}
+			deps[i] = []int{i - 1}
+			continue
+		}
+		for _, in := range def.Inputs {
+			j, ok := index[in]
+			if !ok {
+				return nil, fmt.Errorf("layer %s: unknown input %q", names[i], in)
+			}
+			deps[i] = append(deps[i], j)
+		}
+		if def.Type != layers.Add && def.Type != layers.Concat && len(deps[i]) != 1 {
+			return nil, fmt.Errorf("layer %s takes a single input, got %d", names[i], len(deps[i]))
+		}
+	}
+	if len(inputs) == 0 {
+		return nil, errors.New("graph requires an input layer")
+	}
+
+	order, err := topologicalOrder(deps)
+	if err != nil {
+		return nil, err
+	}
+
+	consumed := make([]bool, len(defs))
+	for _, d := range deps {
+		for _, j := range d {
+			consumed[j] = true
+		}
+	}
+
+	// expand every definition into its layers, in topological order
+	n := &network{graph: &graphRoutes{}}
+	seen := map[string]bool{}
+	last := make([]int, len(defs))
+	outDims := make([]volume.Dimensions, len(defs))
+	for _, i := range order {
+		def := defs[i]
+		var producers []int
+		for _, j := range deps[i] {
+			producers = append(producers, last[j])
+			def.InputDims = append(def.InputDims, outDims[j])
+		}
+		if len(def.InputDims) > 0 {
+			def.Input = def.InputDims[0]
+		}
+
+		isLoss := def.Type == layers.SoftMax || def.Type == layers.SVM || def.Type == layers.Regression
+		if def.Type == layers.Input {
+			if def.Output.Size() == 0 {
+				return nil, fmt.Errorf("input layer %s must declare its output dimensions", names[i])
+			}
+		} else if def.Output.Size() == 0 && !isLoss {
+			if def.Output, err = layers.OutputDimensions(def); err != nil {
+				return nil, fmt.Errorf("layer %s: %w", names[i], err)
+			}
+		}
+
+		chain := []layers.LayerDef{def}
+		if expand {
+			chain = layers.ActivateLayers(chain)
+		}
+		first := len(n.layers)
+		for k, c := range chain {
+			name := names[i]
+			if c.Type != def.Type {
+				name = fmt.Sprintf("%s_%s", names[i], c.Type)
+			}
+			if seen[name] {
+				return nil, fmt.Errorf("duplicate layer name: %s", name)
+			}
+			seen[name] = true
+
+			if k == 0 {
+				c.Input, c.InputDims = def.Input, def.InputDims
+			} else {
+				c.Input = chain[k-1].Output
+				c.InputDims = nil
+				producers = []int{len(n.layers) - 1}
+			}
+			if c.Output, err = layers.InferOutput(c); err != nil {
+				return nil, fmt.Errorf("layer %s (%s): %w", name, c.Type, err)
+			}
+			chain[k] = c
+			if rng != nil {
+				c.Rand = rng
+			}
+
+			layer, err := newLayer(c)
+			if err != nil {
+				return nil, fmt.Errorf("layer %s: %w", name, err)
+			}
+			n.layers = append(n.layers, layer)
+			n.names = append(n.names, name)
+			n.defs = append(n.defs, c)
+			n.graph.inputs = append(n.graph.inputs, producers)
+		}
+		last[i] = len(n.layers) - 1
+		outDims[i] = chain[len(chain)-1].Output
+		if def.Type == layers.Input {
+			n.graph.sources = append(n.graph.sources, first)
+			n.inputDims = append(n.inputDims, def.Output)
+		}
+	}
+	for i := range defs {
+		if !consumed[i] {
+			n.graph.sinks = append(n.graph.sinks, last[i])
+		}
+	}
+
+	n.graph.init(len(n.layers))
+	return n, nil
+}
+
+// topologicalOrder sorts the nodes so every node comes after its dependencies,
+// keeping the declaration order where the dependencies allow it.
+func topologicalOrder(deps [][]int) ([]int, error) {
+	done := make([]bool, len(deps))
+	var order []int
+	for len(order) < len(deps) {
+		progress := false
+		for i := range deps {
+			if done[i] {
+				continue
+			}
+			ready := true
+			for _, j := range deps[i] {
+				ready = ready && done[j]
+			}
+			if ready {
+				done[i] = true
+				order = append(order, i)
+				progress = true
+				break
+			}
+		}
+		if !progress {
+			return nil, errors.New("graph contains a cycle")
+		}
+	}
+	return order, nil
+}
+
+// graphRoutes holds the connections of a graph network and the volumes routed
+// between its layers by the last forward pass.
+type graphRoutes struct {
+	// inputs holds the indices of the layers feeding each layer
+	inputs [][]int
+
+	// consumers holds the number of inputs each layer's output feeds
+	consumers []int
+
+	// sources and sinks hold the indices of the input and output layers
+	sources []int
+	sinks   []int
+
+	// outputs holds the output of each layer
+	outputs []*volume.Volume
+
+	// in holds the volumes fed into each layer
+	in [][]*volume.Volume
+
+	// views holds a private copy of each input whose producer feeds several layers,
+	// so the gradients of the consumers do not overwrite each other
+	views [][]*volume.Volume
+}
+
+func (g *graphRoutes) init(size int) {
+	g.consumers = make([]int, size)
+	g.outputs = make([]*volume.Volume, size)
+	g.views = make([][]*volume.Volume, size)
+	g.in = make([][]*volume.Volume, size)
+	for i, in := range g.inputs {
+		g.views[i] = make([]*volume.Volume, len(in))
+		g.in[i] = make([]*volume.Volume, len(in))
+		for _, j := range in {
+			g.consumers[j]++
+		}
+	}
+}
+
+func (n *network) InputNames() []string {
+	if n.graph == nil {
+		return []string{n.names[0]}
+	}
+	return n.layerNames(n.graph.sources)
+}
+
+func (n *network) OutputNames() []string {
+	if n.graph == nil {
+		return []string{n.names[len(n.names)-1]}
+	}
+	return n.layerNames(n.graph.sinks)
+}
+
+func (n *network) layerNames(indices []int) []string {
+	names := make([]string, len(indices))
+	for k, i := range indices {
+		names[k] = n.names[i]
+	}
+	return names
+}
+
+func (n *network) ForwardMulti(inputs map[string]*volume.Volume, training bool) map[string]*volume.Volume {
+	names := n.InputNames()
+	if len(inputs) != len(names) {
+		panic(fmt.Errorf("expected %d inputs %v, got %d", len(names), names, len(inputs)))
+	}
+	vols := make([]*volume.Volume, len(names))
+	for k, name := range names {
+		vol, ok := inputs[name]
+		if !ok {
+			panic(fmt.Errorf("missing input %q", name))
+		}
+		vols[k] = vol
+	}
+
+	if n.graph == nil {
+		return map[string]*volume.Volume{n.OutputNames()[0]: n.Forward(vols[0], training)}
+	}
+	n.forwardGraph(vols, training)
+	outputs := map[string]*volume.Volume{}
+	for _, i := range n.graph.sinks {
+		outputs[n.names[i]] = n.graph.outputs[i]
+	}
+	return outputs
+}
+
+func (n *network) BackwardMulti(targets map[string]Target) float64 {
+	if n.graph == nil {
+		name := n.OutputNames()[0]
+		target, ok := targets[name]
+		if len(targets) != 1 || !ok {
+			panic(fmt.Errorf("expected a single target for %q", name))
+		}
+		weight := target.Weight
+		if weight == 0 {
+			weight = 1.0
+		}
+		if target.Values != nil {
+			return n.multiDimensionalLossWeighted(target.Values, weight)
+		} else if target.Policy != nil {
+			return n.backwardPolicy(target.Label, target.Policy.Advantage, target.Policy.Entropy, weight)
+		}
+		return n.backwardWeighted(target.Label, weight)
+	}
+
+	g := n.graph
+	var loss float64
+	found := 0
+	for _, i := range g.sinks {
+		target, ok := targets[n.names[i]]
+		if !ok {
+			// outputs without a target contribute no gradient
+			for _, vol := range g.in[i] {
+				vol.ZeroGrad()
+			}
+			continue
+		}
+		found++
+		loss += n.targetLoss(i, target, g.in[i][0])
+	}
+	if found != len(targets) {
+		panic(fmt.Errorf("targets %v do not all match an output in %v", targetNames(targets), n.OutputNames()))
+	}
+
+	ctx, pass := n.startPass("backward")
+	n.backwardGraph(ctx)
+	endSpan(pass)
+	return loss
+}
+
+// targetLoss computes the loss of the graph output at index for the target, scaling
+// the loss gradients in lossInput by the target weight.
+func (n *network) targetLoss(index int, target Target, lossInput *volume.Volume) float64 {
+	var loss float64
+	if target.Values != nil {
+		lossLayer, ok := n.layers[index].(layers.RegressionLossLayer)
+		if !ok {
+			panic(fmt.Errorf("output %s is not a regression loss layer", n.names[index]))
+		}
+		loss = lossLayer.MultiDimensionalLoss(target.Values)
+	} else if target.Policy != nil {
+		lossLayer, ok := n.layers[index].(layers.PolicyLossLayer)
+		if !ok {
+			panic(fmt.Errorf("output %s is not a softmax layer", n.names[index]))
+		}
+		loss = lossLayer.PolicyLoss(target.Label, target.Policy.Advantage, target.Policy.Entropy)
+	} else {
+		lossLayer, ok := n.layers[index].(layers.LossLayer)
+		if !ok {
+			panic(fmt.Errorf("output %s is not a loss layer", n.names[index]))
+		}
+		loss = lossLayer.Loss(target.Label)
+	}
+
+	weight := target.Weight
+	if weight == 0 {
+		weight = 1.0
+	}
+	if weight != 1.0 {
+		g := lossInput.Gradients()
+		for i := range g {
+			g[i] *= weight
+		}
+	}
+	return loss * weight
+}
+
+func targetNames(targets map[string]Target) []string {
+	var names []string
+	for name := range targets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// forwardGraph runs the graph with one volume per input layer and returns the output
+// of the last layer.
+func (n *network) forwardGraph(inputs []*volume.Volume, training bool) *volume.Volume {
+	ctx, pass := n.startPass("forward")
+	defer endSpan(pass)
+
+	g := n.graph
+	var out *volume.Volume
+	var source int
+	for i, layer := range n.layers {
+		if len(g.inputs[i]) == 0 {
+			span := n.beginLayer(ctx, i, false)
+			out = layer.Forward(inputs[source], training)
+			n.endLayer(i, span)
+			g.outputs[i] = out
+			source++
+			continue
+		}
+
+		vols := g.in[i]
+		for k, j := range g.inputs[i] {
+			vols[k] = g.outputs[j]
+			if g.consumers[j] > 1 {
+				vols[k] = vols[k].Clone()
+				g.views[i][k] = vols[k]
+			}
+		}
+
+		span := n.beginLayer(ctx, i, false)
+		if merge, ok := layer.(layers.MergeLayer); ok {
+			out = merge.ForwardMulti(vols, training)
+		} else {
+			out = layer.Forward(vols[0], training)
+		}
+		n.endLayer(i, span)
+		g.outputs[i] = out
+		if i == len(n.layers)-1 {
+			n.lossInput = vols[0]
+		}
+	}
+	return out
+}
+
+// backwardGraph propagates the loss gradients backwards through the graph, summing
+// the gradients of all consumers at fan-outs, tracing the layers under ctx.
+func (n *network) backwardGraph(ctx context.Context) {
+	g := n.graph
+	for i := len(n.layers) - 1; i >= 0; i-- {
+		if g.consumers[i] == 0 {
+			// outputs start the backward pass with the loss gradients
+			continue
+		}
+		if g.consumers[i] > 1 {
+			grads := g.outputs[i].Gradients()
+			g.outputs[i].ZeroGrad()
+			for c := i + 1; c < len(n.layers); c++ {
+				for k, j := range g.inputs[c] {
+					if j != i {
+						continue
+					}
+					for x, v := range g.views[c][k].Gradients() {
+						grads[x] += v
+					}
+				}
+			}
+		}
+		span := n.beginLayer(ctx, i, true)
+		n.layers[i].Backward()
+		n.endLayer(i, span)
+	}
+}
@@ -0,0 +1,119 @@
+package reticulum
+
+import (
+	"math"
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/nathanleary/reticulum/layers"
+	"github.com/nathanleary/reticulum/volume"
+)
+
+// residualGraph builds a graph whose first hidden layer fans out into a residual
+// add, a concat, the next hidden layer and an auxiliary regression head.
+func residualGraph(t *testing.T) MultiNetwork {
+	t.Helper()
+	net, err := NewGraph([]layers.LayerDef{
+		{Name: "in", Type: layers.Input, Output: volume.NewDimensions(1, 1, 3)},
+		{Name: "a", Type: layers.FullyConnected, Activation: layers.Tanh, LayerConfig: layers.NewFullyConnectedLayerConfig(4)},
+		{Name: "b", Type: layers.FullyConnected, Activation: layers.Tanh, LayerConfig: layers.NewFullyConnectedLayerConfig(4)},
+		{Name: "sum", Type: layers.Add, Inputs: []string{"a", "b"}},
+		{Name: "cat", Type: layers.Concat, Inputs: []string{"sum", "a"}},
+		{Name: "head", Type: layers.SoftMax, Inputs: []string{"cat"}, LayerConfig: layers.NewSoftmaxLayerConfig(3)},
+		{Name: "aux", Type: layers.Regression, Inputs: []string{"a"}, LayerConfig: layers.NewRegressionLayerConfig(2)},
+	}, WithNetworkSeed(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return net
+}
+
+func clearGradients(net Network) {
+	for _, pg := range net.GetResponse() {
+		clear(pg.Gradients)
+	}
+}
+
+// checkGradients compares the gradients of the parameters accumulated by a backward
+// pass with central finite differences of the loss returned by BackwardMulti.
+func checkGradients(t *testing.T, net MultiNetwork, inputs map[string]*volume.Volume, targets map[string]Target) {
+	t.Helper()
+	loss := func() float64 {
+		net.ForwardMulti(inputs, true)
+		l := net.BackwardMulti(targets)
+		clearGradients(net)
+		return l
+	}
+
+	clearGradients(net)
+	net.ForwardMulti(inputs, true)
+	net.BackwardMulti(targets)
+	var analytic [][]float64
+	for _, pg := range net.GetResponse() {
+		analytic = append(analytic, append([]float64(nil), pg.Gradients...))
+	}
+	clearGradients(net)
+
+	const eps = 1e-5
+	for i, pg := range net.GetResponse() {
+		for j := range pg.Weights {
+			w := pg.Weights[j]
+			pg.Weights[j] = w + eps
+			plus := loss()
+			pg.Weights[j] = w - eps
+			minus := loss()
+			pg.Weights[j] = w
+
+			numeric := (plus - minus) / (2 * eps)
+			if diff := math.Abs(numeric - analytic[i][j]); diff > 1e-6*math.Max(1, math.Abs(numeric)) {
+				t.Errorf("%s[%d]: gradient = %v, finite difference = %v", pg.LayerName, j, analytic[i][j], numeric)
+			}
+		}
+	}
+}
+
+func TestGraph_GradientsMatchFiniteDifferences(t *testing.T) {
+	net := residualGraph(t)
+	r := rand.New(rand.NewSource(1))
+	inputs := map[string]*volume.Volume{"in": randomInput(r, 3)}
+	targets := map[string]Target{
+		"head": {Label: 2},
+		"aux":  {Values: []float64{0.5, -0.25}, Weight: 0.5},
+	}
+	checkGradients(t, net, inputs, targets)
+}
+
+func TestGraph_OutputWithoutTargetAddsNoGradient(t *testing.T) {
+	net := residualGraph(t)
+	r := rand.New(rand.NewSource(2))
+	inputs := map[string]*volume.Volume{"in": randomInput(r, 3)}
+
+	// the auxiliary head keeps the gradients of a pass where it had a target, until
+	// the next forward pass
+	targets := map[string]Target{"head": {Label: 1}}
+	net.ForwardMulti(inputs, true)
+	net.BackwardMulti(map[string]Target{"head": {Label: 1}, "aux": {Values: []float64{1, 1}}})
+	clearGradients(net)
+	net.BackwardMulti(targets)
+	var stale [][]float64
+	for _, pg := range net.GetResponse() {
+		stale = append(stale, append([]float64(nil), pg.Gradients...))
+	}
+
+	clearGradients(net)
+	net.ForwardMulti(inputs, true)
+	net.BackwardMulti(targets)
+	for i, pg := range net.GetResponse() {
+		for j, g := range pg.Gradients {
+			if strings.HasPrefix(pg.LayerName, "aux") && g != 0 {
+				t.Fatalf("%s[%d]: gradient = %v, want 0", pg.LayerName, j, g)
+			} else if math.Abs(stale[i][j]-g) > 1e-12 {
+				t.Fatalf("%s[%d]: gradient after a targeted pass = %v, want %v", pg.LayerName, j, stale[i][j], g)
+			}
+		}
+	}
+
+	// the shared layers only see the gradient of the head
+	checkGradients(t, net, inputs, targets)
+}
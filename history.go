@@ -0,0 +1,145 @@
+package reticulum
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// DefaultHistoryLimit is the number of iterations kept in the trainer history unless
+// WithHistoryLimit sets another limit.
+const DefaultHistoryLimit = 10000
+
+// History records the results of the most recent training iterations and the metrics
+// of every epoch.
+type History struct {
+	Epochs []Metrics
+
+	// iterations is a ring of the most recent iterations, starting at next once it
+	// holds limit of them; a negative limit keeps all
+	iterations []IterationRecord
+	next       int
+	limit      int
+}
+
+// IterationRecord is the outcome of a single Train or TrainBatch call.
+type IterationRecord struct {
+	Iteration    int           `json:"iteration"`
+	LearningRate float64       `json:"learning_rate"`
+	CostLoss     float64       `json:"cost_loss"`
+	L1DecayLoss  float64       `json:"l1_decay_loss"`
+	L2DecayLoss  float64       `json:"l2_decay_loss"`
+	TotalLoss    float64       `json:"total_loss"`
+	ForwardTime  time.Duration `json:"forward_time_ns"`
+	BackwardTime time.Duration `json:"backward_time_ns"`
+}
+
+// WithHistoryLimit keeps only the most recent n iterations in the trainer history,
+// instead of DefaultHistoryLimit. A negative n keeps every iteration, which grows
+// without bound for long-running trainers.
+func WithHistoryLimit(n int) OptionFunc {
+	return func(opts *Options) {
+		opts.HistoryLimit = n
+	}
+}
+
+// Iterations returns the recorded iterations, oldest first.
+func (h *History) Iterations() []IterationRecord {
+	its := make([]IterationRecord, 0, len(h.iterations))
+	its = append(its, h.iterations[h.next:]...)
+	return append(its, h.iterations[:h.next]...)
+}
+
+// record adds an iteration, replacing the oldest one once the limit is reached.
+func (h *History) record(k int, res TrainingResults) {
+	it := IterationRecord{
+		Iteration:    k,
+		LearningRate: res.LearningRate,
+		CostLoss:     res.CostLost,
+		L1DecayLoss:  res.L1DecayLoss,
+		L2DecayLoss:  res.L2DecayLoss,
+		TotalLoss:    res.TotalLoss,
+		ForwardTime:  res.ForwardTime,
+		BackwardTime: res.BackwardTime,
+	}
+	if h.limit < 0 || len(h.iterations) < h.limit {
+		h.iterations = append(h.iterations, it)
+		return
+	}
+	h.iterations[h.next] = it
+	h.next = (h.next + 1) % h.limit
+}
+
+// MarshalJSON encodes the iterations, oldest first, and the epoch metrics.
+func (h *History) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Iterations []IterationRecord `json:"iterations"`
+		Epochs     []Metrics         `json:"epochs"`
+	}{h.Iterations(), h.Epochs})
+}
+
+// WriteJSON writes the iterations and epoch metrics as a JSON document.
+func (h *History) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(h)
+}
+
+// WriteCSV writes one row per iteration, with a header row.
+func (h *History) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"iteration", "learning_rate", "cost_loss", "l1_decay_loss", "l2_decay_loss", "total_loss", "forward_time_ns", "backward_time_ns"})
+	for _, it := range h.Iterations() {
+		cw.Write([]string{
+			strconv.Itoa(it.Iteration),
+			formatFloat(it.LearningRate),
+			formatFloat(it.CostLoss),
+			formatFloat(it.L1DecayLoss),
+			formatFloat(it.L2DecayLoss),
+			formatFloat(it.TotalLoss),
+			strconv.FormatInt(int64(it.ForwardTime), 10),
+			strconv.FormatInt(int64(it.BackwardTime), 10),
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// WriteEpochsCSV writes one row per epoch with a column per metric, with a header row.
+// Metrics missing from an epoch are left empty.
+func (h *History) WriteEpochsCSV(w io.Writer) error {
+	seen := map[string]bool{}
+	var names []string
+	for _, m := range h.Epochs {
+		for name := range m {
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+	sort.Strings(names)
+
+	cw := csv.NewWriter(w)
+	cw.Write(append([]string{"epoch"}, names...))
+	for epoch, m := range h.Epochs {
+		row := []string{strconv.Itoa(epoch)}
+		for _, name := range names {
+			if v, ok := m[name]; ok {
+				row = append(row, formatFloat(v))
+			} else {
+				row = append(row, "")
+			}
+		}
+		cw.Write(row)
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
@@ -0,0 +1,201 @@
+package reticulum
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/nathanleary/reticulum/layers"
+)
+
+// HogwildOptions configures asynchronous training.
+type HogwildOptions struct {
+	// Workers is the number of goroutines training concurrently.
+	Workers int
+
+	// Epochs is the number of passes over the data.
+	Epochs int
+
+	LearningRate float64
+	L1Decay      float64
+	L2Decay      float64
+
+	// Stripes guards the shared weights with the given number of striped locks.
+	// When 0 updates are applied lock-free, which is only safe to use when the
+	// updates are sparse and the occasional lost write is acceptable.
+	Stripes int
+
+	// HasSeed seeds the shuffling of the samples with Seed. The order in which the
+	// workers apply their updates still depends on scheduling.
+	HasSeed bool
+	Seed    int64
+}
+
+// HogwildResults summarises an asynchronous training run.
+type HogwildResults struct {
+	Samples  int
+	MeanLoss float64
+}
+
+// TrainHogwild trains net with asynchronous SGD. Each worker runs forward and backward
+// passes on its own replica (created with newReplica, which must build a network with
+// the same architecture), and applies its gradients directly to the shared weights of
+// net without waiting for the other workers. A worker which panics, e.g. in a loss
+// function, stops the run, and its panic is returned as an error.
+func TrainHogwild(net Network, newReplica func() (Network, error), data []Sample, opts HogwildOptions) (HogwildResults, error) {
+	if net == nil {
+		return HogwildResults{}, errors.New("network cannot be nil")
+	} else if newReplica == nil {
+		return HogwildResults{}, errors.New("replica constructor cannot be nil")
+	} else if opts.Workers <= 0 {
+		return HogwildResults{}, errors.New("at least one worker is required")
+	} else if opts.LearningRate <= 0 {
+		return HogwildResults{}, errors.New("learning rate must be greater than 0")
+	}
+
+	epochs := opts.Epochs
+	if epochs <= 0 {
+		epochs = 1
+	}
+
+	shared := net.GetResponse()
+	var locks []sync.Mutex
+	if opts.Stripes > 0 {
+		locks = make([]sync.Mutex, opts.Stripes)
+	}
+
+	replicas := make([]Network, opts.Workers)
+	for w := range replicas {
+		replica, err := newReplica()
+		if err != nil {
+			return HogwildResults{}, err
+		}
+		if len(replica.GetResponse()) != len(shared) {
+			return HogwildResults{}, errors.New("replica does not match the network architecture")
+		}
+		replicas[w] = replica
+	}
+
+	rng := newRand(opts.HasSeed, opts.Seed)
+
+	// the first worker to fail stops the queue
+	done := make(chan struct{})
+	var failed error
+	var once sync.Once
+
+	// work queue of sample indices, shuffled every epoch
+	queue := make(chan int, opts.Workers)
+	go func() {
+		defer close(queue)
+		for e := 0; e < epochs; e++ {
+			for _, i := range rng.Perm(len(data)) {
+				select {
+				case queue <- i:
+				case <-done:
+					return
+				}
+			}
+		}
+	}()
+
+	losses := make([]float64, opts.Workers)
+	counts := make([]int, opts.Workers)
+
+	var wg sync.WaitGroup
+	for w := 0; w < opts.Workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			var err error
+			defer func() {
+				if err != nil {
+					once.Do(func() {
+						failed = fmt.Errorf("worker %d: %w", w, err)
+						close(done)
+					})
+				}
+			}()
+			defer recoverError(&err)
+
+			replica := replicas[w]
+			local := replica.GetResponse()
+			for i := range queue {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				hogwildPull(shared, local, locks)
+
+				sample := data[i]
+				replica.Forward(sample.Input, true)
+				losses[w] += sample.LossFunc()(replica)
+				counts[w]++
+
+				hogwildPush(net, shared, local, locks, opts)
+			}
+		}(w)
+	}
+	wg.Wait()
+	if failed != nil {
+		return HogwildResults{}, failed
+	}
+
+	var res HogwildResults
+	var total float64
+	for w := range losses {
+		res.Samples += counts[w]
+		total += losses[w]
+	}
+	if res.Samples > 0 {
+		res.MeanLoss = total / float64(res.Samples)
+	}
+	return res, nil
+}
+
+// hogwildPull copies the shared weights into the replica.
+func hogwildPull(shared, local []layers.LayerResponse, locks []sync.Mutex) {
+	for i := range shared {
+		if locks != nil {
+			locks[i%len(locks)].Lock()
+		}
+		copy(local[i].Weights, shared[i].Weights)
+		if locks != nil {
+			locks[i%len(locks)].Unlock()
+		}
+	}
+}
+
+// hogwildPush applies an SGD step with the replica's gradients to the shared weights
+// of the layers of net which are not frozen, and clears the replica's gradients.
+func hogwildPush(net Network, shared, local []layers.LayerResponse, locks []sync.Mutex, opts HogwildOptions) {
+	for i := range shared {
+		if net.Frozen(shared[i].LayerName) {
+			clear(local[i].Gradients)
+			continue
+		}
+		p := shared[i].Weights
+		g := local[i].Gradients
+		l1Decay := opts.L1Decay * shared[i].L1DecayMul
+		l2Decay := opts.L2Decay * shared[i].L2DecayMul
+
+		if locks != nil {
+			locks[i%len(locks)].Lock()
+		}
+		for j := range p {
+			// skip untouched weights, this is where the sparsity pays off
+			if g[j] == 0 && l1Decay == 0 && l2Decay == 0 {
+				continue
+			}
+			l1Grad := l1Decay
+			if p[j] <= 0 {
+				l1Grad *= -1
+			}
+			p[j] -= opts.LearningRate * (g[j] + l1Grad + l2Decay*p[j])
+			g[j] = 0.0
+		}
+		if locks != nil {
+			locks[i%len(locks)].Unlock()
+		}
+	}
+}
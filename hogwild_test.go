@@ -0,0 +1,62 @@
+package reticulum
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+// separable returns samples whose label is the largest of the first three inputs.
+func separable(n int) []Sample {
+	r := rand.New(rand.NewSource(1))
+	data := make([]Sample, n)
+	for i := range data {
+		vol := randomInput(r, 4)
+		data[i] = Sample{Input: vol, Label: argmax(vol.Weights()[:3])}
+	}
+	return data
+}
+
+func meanCost(net Network, data []Sample) float64 {
+	var total float64
+	for _, s := range data {
+		total += net.GetCostLoss(s.Input, s.Label)
+	}
+	return total / float64(len(data))
+}
+
+func TestTrainHogwild(t *testing.T) {
+	data := separable(64)
+	for _, test := range []struct {
+		name    string
+		data    []Sample
+		opts    HogwildOptions
+		wantErr string
+	}{
+		{"single worker", data, HogwildOptions{Workers: 1, Epochs: 10, LearningRate: 0.05, HasSeed: true, Seed: 1}, ""},
+		{"striped locks", data, HogwildOptions{Workers: 4, Epochs: 10, LearningRate: 0.05, Stripes: 2}, ""},
+		{"no workers", data, HogwildOptions{LearningRate: 0.05}, "worker"},
+		{"no learning rate", data, HogwildOptions{Workers: 2}, "learning rate"},
+		{"panicking loss", append([]Sample{{Input: data[0].Input, Target: []float64{1}}}, data...), HogwildOptions{Workers: 4, Epochs: 10, LearningRate: 0.05}, "worker"},
+	} {
+		net := privacyNetwork(t, 4, 3)
+		newReplica := func() (Network, error) { return Clone(net), nil }
+		before := meanCost(net, data)
+
+		res, err := TrainHogwild(net, newReplica, test.data, test.opts)
+		if test.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+				t.Errorf("%s: TrainHogwild() = %v, want an error containing %q", test.name, err, test.wantErr)
+			}
+			continue
+		} else if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if want := len(test.data) * test.opts.Epochs; res.Samples != want {
+			t.Errorf("%s: trained on %d samples, want %d", test.name, res.Samples, want)
+		}
+		if after := meanCost(net, data); after >= before {
+			t.Errorf("%s: mean loss %v after training, was %v", test.name, after, before)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package layers
+
+import (
+	"github.com/nathanleary/reticulum/device"
+)
+
+// Backend computes the vector and matrix products of the fc and conv layers. The
+// pure Go backend is the default; building with the gonum tag makes gonum's BLAS
+// the default instead, and SetBackend selects any other at runtime, e.g. the cgo
+// OpenBLAS or MKL backend of the cblas package.
+type Backend interface {
+	// Dot returns the dot product of x and y, of equal length.
+	Dot(x, y []float64) float64
+
+	// Axpy adds alpha*x to y, of equal length.
+	Axpy(alpha float64, x, y []float64)
+
+	// Gemm computes c = alpha*op(a)*op(b) + beta*c for dense row-major matrices, where
+	// op(a) is m x k, op(b) is k x n and c is m x n, and op transposes the matrix
+	// when the corresponding flag is set.
+	Gemm(transA, transB bool, m, n, k int, alpha float64, a, b []float64, beta float64, c []float64)
+}
+
+// blas is the backend used by the layers.
+var blas Backend = defaultBackend
+
+// defaultBackend is the backend restored by SetBackend(nil).
+var defaultBackend Backend = PureGo{}
+
+// SetBackend sets the backend used by all the layers on the CPU device, or restores
+// the default when b is nil. It is not safe to call while a network is running.
+func SetBackend(b Backend) {
+	if b == nil {
+		b = defaultBackend
+	}
+	blas = b
+}
+
+// CurrentBackend returns the backend used by the layers.
+func CurrentBackend() Backend {
+	return blas
+}
+
+// PureGo is the pure Go backend, computing on the CPU device. Conv layers use
+// their direct loops with it rather than Gemm, which only pays off with an optimized
+// implementation.
+type PureGo struct{}
+
+func (PureGo) Dot(x, y []float64) float64 {
+	return device.CPU{}.Dot(device.Host(x), device.Host(y))
+}
+
+func (PureGo) Axpy(alpha float64, x, y []float64) {
+	device.CPU{}.Axpy(alpha, device.Host(x), device.Host(y))
+}
+
+func (PureGo) Gemm(transA, transB bool, m, n, k int, alpha float64, a, b []float64, beta float64, c []float64) {
+	device.CPU{}.Gemm(transA, transB, m, n, k, alpha, device.Host(a), device.Host(b), beta, device.Host(c))
+}
+
+// ops returns the backend computing the products on the device d: the current one
+// on the CPU, or the device itself otherwise.
+func ops(d device.Device) Backend {
+	if _, cpu := d.(device.CPU); cpu {
+		return blas
+	}
+	return deviceBackend{d}
+}
+
+// usesGemm reports whether conv layers on the device d should lower their
+// convolutions to Gemm.
+func usesGemm(d device.Device) bool {
+	_, pure := ops(d).(PureGo)
+	return !pure
+}
@@ -0,0 +1,37 @@
+//go:build gonum
+
+package layers
+
+import (
+	gonum "gonum.org/v1/gonum/blas"
+	"gonum.org/v1/gonum/blas/blas64"
+)
+
+func init() {
+	defaultBackend = gonumBackend{}
+	blas = defaultBackend
+}
+
+// gonumBackend routes the products through gonum's BLAS, which uses assembly kernels
+// where available and can be swapped for a cgo BLAS with blas64.Use.
+type gonumBackend struct{}
+
+func (gonumBackend) Dot(x, y []float64) float64 {
+	return blas64.Implementation().Ddot(len(x), x, 1, y, 1)
+}
+
+func (gonumBackend) Axpy(alpha float64, x, y []float64) {
+	blas64.Implementation().Daxpy(len(x), alpha, x, 1, y, 1)
+}
+
+func (gonumBackend) Gemm(transA, transB bool, m, n, k int, alpha float64, a, b []float64, beta float64, c []float64) {
+	ta, lda := gonum.NoTrans, k
+	if transA {
+		ta, lda = gonum.Trans, m
+	}
+	tb, ldb := gonum.NoTrans, n
+	if transB {
+		tb, ldb = gonum.Trans, k
+	}
+	blas64.Implementation().Dgemm(ta, tb, m, n, k, alpha, a, lda, b, ldb, beta, c, n)
+}
@@ -0,0 +1,82 @@
+package layers
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+
+	"github.com/nathanleary/reticulum/volume"
+)
+
+// convBench is a conv layer shape benchmarked with every algorithm supporting it.
+type convBench struct {
+	in                       volume.Dimensions
+	filters, sx, stride, pad int
+}
+
+var convBenches = []convBench{
+	{volume.NewDimensions(32, 32, 16), 16, 3, 1, 1},
+	{volume.NewDimensions(24, 24, 8), 16, 5, 1, 2},
+	{volume.NewDimensions(32, 32, 3), 16, 9, 1, 4},
+	{volume.NewDimensions(32, 32, 16), 32, 3, 2, 1},
+}
+
+func (c convBench) algorithms() []ConvAlgorithm {
+	algs := []ConvAlgorithm{ConvDirect}
+	if c.stride == 1 {
+		if c.sx == 3 {
+			algs = append(algs, ConvWinograd)
+		}
+		algs = append(algs, ConvFFT)
+	}
+	return algs
+}
+
+func (c convBench) name() string {
+	return fmt.Sprintf("%dx%dx%d/%dx%dx%d/s%d", c.in.X, c.in.Y, c.in.Z, c.sx, c.sx, c.filters, c.stride)
+}
+
+func (c convBench) layer(alg ConvAlgorithm) (Layer, *volume.Volume) {
+	r := rand.New(rand.NewSource(1))
+	conf := NewConvLayerConfig(c.filters, WithSx(c.sx), WithStride(c.stride), WithPadding(c.pad), WithConvAlgorithm(alg))
+	def := LayerDef{Type: Conv, Input: c.in, LayerConfig: conf, Rand: r}
+	out, err := InferOutput(def)
+	if err != nil {
+		panic(err)
+	}
+	def.Output = out
+	return NewConvLayer(def), volume.NewVolume(c.in, volume.WithRand(r))
+}
+
+func BenchmarkConvForward(b *testing.B) {
+	for _, c := range convBenches {
+		for _, alg := range c.algorithms() {
+			b.Run(c.name()+"/"+string(alg), func(b *testing.B) {
+				l, vol := c.layer(alg)
+				b.ReportAllocs()
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					l.Forward(vol, true)
+				}
+			})
+		}
+	}
+}
+
+func BenchmarkConvBackward(b *testing.B) {
+	for _, c := range convBenches {
+		b.Run(c.name(), func(b *testing.B) {
+			l, vol := c.layer(ConvDirect)
+			out := l.Forward(vol, true)
+			g := out.Gradients()
+			for i := range g {
+				g[i] = rand.Float64() - 0.5
+			}
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				l.Backward()
+			}
+		})
+	}
+}
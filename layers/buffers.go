@@ -0,0 +1,45 @@
+package layers
+
+import (
+	"github.com/nathanleary/reticulum/volume"
+)
+
+// buffers allocates the output volume and scratch slices of a layer. When reuse is
+// set they are allocated once and reused by every pass, reallocated only when the
+// shape changes; otherwise every call allocates, so earlier outputs stay valid.
+type buffers struct {
+	reuse   bool
+	out     *volume.Volume
+	scratch [][]float64
+}
+
+// output returns a volume of the dimensions dims with zero gradients. A reused
+// volume keeps the weights of the previous pass, for the layer to overwrite.
+func (b *buffers) output(dims volume.Dimensions) *volume.Volume {
+	if !b.reuse {
+		return volume.NewVolume(dims, volume.WithZeros())
+	}
+	if b.out == nil || b.out.Dimensions() != dims {
+		b.out = volume.NewVolume(dims, volume.WithZeros())
+	} else {
+		b.out.ZeroGrad()
+	}
+	return b.out
+}
+
+// floats returns the scratch slice i holding n zeros.
+func (b *buffers) floats(i, n int) []float64 {
+	if !b.reuse {
+		return make([]float64, n)
+	}
+	for len(b.scratch) <= i {
+		b.scratch = append(b.scratch, nil)
+	}
+	if cap(b.scratch[i]) < n {
+		b.scratch[i] = make([]float64, n)
+		return b.scratch[i]
+	}
+	s := b.scratch[i][:n]
+	clear(s)
+	return s
+}
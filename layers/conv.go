@@ -4,10 +4,11 @@ import (
 	"fmt"
 	"math"
 
+	"github.com/nathanleary/reticulum/device"
 	"github.com/nathanleary/reticulum/volume"
 )
 
-// WithStride sets the stride for the conv or pool layer
+// WithStride sets the stride for the conv, depthwise or pool layer
 func WithStride(stride int) LayerOptionFunc {
 	return func(lc LayerConfig) error {
 		switch conf := lc.(type) {
@@ -15,6 +16,8 @@ func WithStride(stride int) LayerOptionFunc {
 			conf.Stride = stride
 		case *convLayerConfig:
 			conf.Stride = stride
+		case *depthwiseLayerConfig:
+			conf.Stride = stride
 		default:
 			return fmt.Errorf("Invalid LayerConfig for ConvLayer Stride")
 		}
@@ -22,7 +25,7 @@ func WithStride(stride int) LayerOptionFunc {
 	}
 }
 
-// WithPadding sets the padding for the conv or pool layer
+// WithPadding sets the padding for the conv, depthwise or pool layer
 func WithPadding(pad int) LayerOptionFunc {
 	return func(lc LayerConfig) error {
 		switch conf := lc.(type) {
@@ -30,6 +33,8 @@ func WithPadding(pad int) LayerOptionFunc {
 			conf.Padding = pad
 		case *convLayerConfig:
 			conf.Padding = pad
+		case *depthwiseLayerConfig:
+			conf.Padding = pad
 		default:
 			return fmt.Errorf("Invalid LayerConfig for ConvLayer Padding")
 		}
@@ -37,7 +42,7 @@ func WithPadding(pad int) LayerOptionFunc {
 	}
 }
 
-// WithSx sets the sx for the conv or pool layer
+// WithSx sets the sx for the conv, depthwise or pool layer
 func WithSx(sx int) LayerOptionFunc {
 	return func(lc LayerConfig) error {
 		switch conf := lc.(type) {
@@ -45,6 +50,8 @@ func WithSx(sx int) LayerOptionFunc {
 			conf.Sx = sx
 		case *convLayerConfig:
 			conf.Sx = sx
+		case *depthwiseLayerConfig:
+			conf.Sx = sx
 		default:
 			return fmt.Errorf("Invalid LayerConfig for ConvLayer Sx")
 		}
@@ -52,7 +59,7 @@ func WithSx(sx int) LayerOptionFunc {
 	}
 }
 
-// WithSy sets the sy for the conv or pool layer
+// WithSy sets the sy for the conv, depthwise or pool layer
 func WithSy(sy int) LayerOptionFunc {
 	return func(lc LayerConfig) error {
 		switch conf := lc.(type) {
@@ -60,6 +67,8 @@ func WithSy(sy int) LayerOptionFunc {
 			conf.Sy = sy
 		case *convLayerConfig:
 			conf.Sy = sy
+		case *depthwiseLayerConfig:
+			conf.Sy = sy
 		default:
 			return fmt.Errorf("Invalid LayerConfig for ConvLayer Sx")
 		}
@@ -67,6 +76,44 @@ func WithSy(sy int) LayerOptionFunc {
 	}
 }
 
+// ConvAlgorithm selects how a conv layer computes its forward pass.
+type ConvAlgorithm string
+
+// ConvAlgorithm enums
+const (
+	// ConvAuto lowers the convolution to gemm with an optimized backend or on a
+	// device, as ConvDirect does. With the pure Go backend on the CPU it picks
+	// Winograd for 3x3 filters with stride 1, FFT for filters of 7x7 and larger with
+	// stride 1, and the direct loops otherwise.
+	ConvAuto ConvAlgorithm = ""
+	// ConvDirect slides the filters over the input, or lowers the convolution to gemm
+	// with an optimized backend.
+	ConvDirect ConvAlgorithm = "direct"
+	// ConvWinograd uses the Winograd F(2,3) or F(4,3) algorithms, for 3x3 filters
+	// with stride 1.
+	ConvWinograd ConvAlgorithm = "winograd"
+	// ConvFFT correlates in the frequency domain.
+	ConvFFT ConvAlgorithm = "fft"
+)
+
+// WithConvAlgorithm sets the algorithm of the conv layer's forward pass. The
+// backward pass always uses the direct or gemm loops.
+func WithConvAlgorithm(alg ConvAlgorithm) LayerOptionFunc {
+	return func(lc LayerConfig) error {
+		conf, ok := lc.(*convLayerConfig)
+		if !ok {
+			return fmt.Errorf("Invalid LayerConfig for ConvLayer Algorithm")
+		}
+		switch alg {
+		case ConvAuto, ConvDirect, ConvWinograd, ConvFFT:
+		default:
+			return fmt.Errorf("Invalid conv algorithm: %q", alg)
+		}
+		conf.Algorithm = alg
+		return nil
+	}
+}
+
 // NewConvLayerConfig creates a new ConvLayer config with the given options.
 func NewConvLayerConfig(filters int, opts ...LayerOptionFunc) LayerConfig {
 	if filters <= 0 {
@@ -100,6 +147,7 @@ type convLayerConfig struct {
 	L1DecayMult   float64
 	L2DecayMult   float64
 	PreferredBias float64
+	Algorithm     ConvAlgorithm `json:",omitempty"`
 }
 
 // NewConvLayer creates a new convoluted layer.
@@ -134,11 +182,15 @@ func NewConvLayer(def LayerDef) Layer {
 	bias := conf.PreferredBias
 	var filters []*volume.Volume
 	for i := 0; i < outDepth; i++ {
-		filters = append(filters, volume.NewVolume(volume.NewDimensions(conf.Sx, conf.Sy, def.Input.Z)))
+		filters = append(filters, volume.NewVolume(volume.NewDimensions(conf.Sx, conf.Sy, def.Input.Z), volume.WithRand(def.Rand)))
+	}
+
+	if conf.Algorithm == ConvWinograd && !conf.winogradable() {
+		panic(fmt.Errorf("Winograd convolution requires 3x3 filters with stride 1"))
 	}
 
 	biases := volume.NewVolume(volume.NewDimensions(1, 1, outDepth), volume.WithInitialValue(bias))
-	return &convLayer{conf, def.Input, outDim, nil, nil, filters, biases}
+	return &convLayer{conf, def.Input, outDim, nil, nil, filters, biases, conf.Algorithm, def.Parallelism, def.computeDevice(), buffers{reuse: def.ReuseBuffers}}
 }
 
 type convLayer struct {
@@ -151,6 +203,19 @@ type convLayer struct {
 
 	filters []*volume.Volume
 	biases  *volume.Volume
+
+	// algorithm computes the forward pass, ConvAuto being resolved by every pass
+	algorithm ConvAlgorithm
+
+	// parallelism bounds the goroutines computing the filters
+	parallelism int
+
+	// dev computes the gemm products
+	dev device.Device
+
+	// buf holds the output and scratch slices, reused across passes when the network
+	// preallocates
+	buf buffers
 }
 
 func (*convLayer) Type() LayerType {
@@ -159,81 +224,145 @@ func (*convLayer) Type() LayerType {
 
 func (l *convLayer) Forward(vol *volume.Volume, training bool) *volume.Volume {
 	l.inVol = vol
-	A := volume.NewVolume(l.output, volume.WithZeros())
+	switch alg := l.forwardAlgorithm(); {
+	case alg == ConvWinograd:
+		l.outVol = l.forwardWinograd(vol)
+		return l.outVol
+	case alg == ConvFFT:
+		l.outVol = l.forwardFFT(vol)
+		return l.outVol
+	case usesGemm(l.dev):
+		l.outVol = l.forwardGemm(vol)
+		return l.outVol
+	}
+	A := l.buf.output(l.output)
+
+	// every row of every filter's output is computed independently
+	parallelFor(l.parallelism, l.output.Z*l.output.Y, func(_, start, end int) {
+		for row := start; row < end; row++ {
+			l.forwardRow(vol, A, row/l.output.Y, row%l.output.Y)
+		}
+	})
 
+	l.outVol = A
+	return l.outVol
+}
+
+// forwardAlgorithm resolves the algorithm of the forward pass. ConvAuto is resolved
+// against the backend and device in use, as they can be changed after the layer is
+// created.
+func (l *convLayer) forwardAlgorithm() ConvAlgorithm {
+	switch {
+	case l.algorithm != ConvAuto:
+		return l.algorithm
+	case usesGemm(l.dev):
+		return ConvDirect
+	case l.conf.winogradable():
+		return ConvWinograd
+	case l.conf.Sx >= 7 && l.conf.Sy >= 7 && l.conf.Stride == 1:
+		return ConvFFT
+	}
+	return ConvDirect
+}
+
+// winogradable reports whether the Winograd algorithms support the filters.
+func (conf *convLayerConfig) winogradable() bool {
+	return conf.Sx == 3 && conf.Sy == 3 && conf.Stride == 1
+}
+
+// forwardRow computes the output row ay of filter d.
+func (l *convLayer) forwardRow(vol, A *volume.Volume, d, ay int) {
 	vDim := vol.Dimensions()
 	vsx, vsy, stride := vDim.X, vDim.Y, l.conf.Stride
-	for d := 0; d < l.output.Z; d++ {
-		f := l.filters[d]
-		y := -l.conf.Padding
-		for ay := 0; ay < l.output.Y; ay++ {
-			y += stride
-			x := -l.conf.Padding
-			for ax := 0; ax < l.output.X; ax++ {
-				x += stride
-
-				var a float64
-				fDim := f.Dimensions()
-				for fy := 0; fy < fDim.Y; fy++ {
-					oy := y + fy
-					for fx := 0; fx < fDim.X; fx++ {
-						ox := x + fx
-						if oy >= 0 && oy < vsy && ox >= 0 && ox < vsx {
-							for fz := 0; fz < fDim.Z; fz++ {
-								a1 := f.GetByIndex(((fDim.X*fy)+fx)*fDim.Z + fz)
-								a2 := vol.GetByIndex(((vsx*oy)+ox)*vDim.Z + fz)
-								a += a1 * a2
-							}
-						}
+	f := l.filters[d]
+	fDim := f.Dimensions()
+	y := -l.conf.Padding + ay*stride
+	x := -l.conf.Padding
+	for ax := 0; ax < l.output.X; ax, x = ax+1, x+stride {
+
+		var a float64
+		for fy := 0; fy < fDim.Y; fy++ {
+			oy := y + fy
+			for fx := 0; fx < fDim.X; fx++ {
+				ox := x + fx
+				if oy >= 0 && oy < vsy && ox >= 0 && ox < vsx {
+					for fz := 0; fz < fDim.Z; fz++ {
+						a1 := f.GetByIndex(((fDim.X*fy)+fx)*fDim.Z + fz)
+						a2 := vol.GetByIndex(((vsx*oy)+ox)*vDim.Z + fz)
+						a += a1 * a2
 					}
 				}
-				a += l.biases.GetByIndex(d)
-				A.Set(ax, ay, d, a)
 			}
 		}
+		a += l.biases.GetByIndex(d)
+		A.Set(ax, ay, d, a)
 	}
-
-	l.outVol = A
-	return l.outVol
 }
 
 func (l *convLayer) Backward() {
 	l.inVol.ZeroGrad()
+	if usesGemm(l.dev) {
+		l.backwardGemm()
+		return
+	}
+
+	// the filters share the input gradients, so every worker but the first sums its
+	// filters' share into its own buffer, added in worker order once all are done
+	workers := max(min(l.parallelism, l.output.Z), 1)
+	grads := make([][]float64, workers)
+	grads[0] = l.inVol.Gradients()
+	for w := 1; w < workers; w++ {
+		grads[w] = l.buf.floats(scratchWorkers+w, len(grads[0]))
+	}
+	parallelFor(workers, l.output.Z, func(worker, start, end int) {
+		for d := start; d < end; d++ {
+			l.backwardFilter(d, grads[worker])
+		}
+	})
+	for _, g := range grads[1:] {
+		for i, v := range g {
+			grads[0][i] += v
+		}
+	}
+}
 
+// backwardFilter accumulates the gradients of filter d and its bias, and adds its
+// share of the input gradients to dx.
+func (l *convLayer) backwardFilter(d int, dx []float64) {
 	vDim := l.inVol.Dimensions()
 	vsx, vsy, stride := vDim.X, vDim.Y, l.conf.Stride
-
-	for d := 0; d < l.output.Z; d++ {
-		f := l.filters[d]
-		y := -l.conf.Padding
-
-		fDim := f.Dimensions()
-		for ay := 0; ay < l.output.Y; ay++ {
-			y += stride
-			x := -l.conf.Padding
-			for ax := 0; ax < l.output.X; ax++ {
-				x += stride
-				chainGrad := l.outVol.GetGrad(ax, ay, d)
-				for fy := 0; fy < fDim.Y; fy++ {
-					oy := y + fy
-					for fx := 0; fx < fDim.X; fx++ {
-						ox := x + fx
-						if oy >= 0 && oy < vsy && ox >= 0 && ox < vsx {
-							for fz := 0; fz < fDim.Z; fz++ {
-								ix1 := ((vsy*oy)+ox)*vDim.Z + fz
-								ix2 := ((fDim.X*fy)+fx)*fDim.Z + fz
-								f.AddGradByIndex(ix2, l.inVol.GetByIndex(ix1)*chainGrad)
-								l.inVol.AddGradByIndex(ix1, f.GetByIndex(ix2)*chainGrad)
-							}
+	f := l.filters[d]
+	fDim := f.Dimensions()
+	y := -l.conf.Padding
+	for ay := 0; ay < l.output.Y; ay, y = ay+1, y+stride {
+		x := -l.conf.Padding
+		for ax := 0; ax < l.output.X; ax, x = ax+1, x+stride {
+			chainGrad := l.outVol.GetGrad(ax, ay, d)
+			for fy := 0; fy < fDim.Y; fy++ {
+				oy := y + fy
+				for fx := 0; fx < fDim.X; fx++ {
+					ox := x + fx
+					if oy >= 0 && oy < vsy && ox >= 0 && ox < vsx {
+						for fz := 0; fz < fDim.Z; fz++ {
+							ix1 := ((vsx*oy)+ox)*vDim.Z + fz
+							ix2 := ((fDim.X*fy)+fx)*fDim.Z + fz
+							f.AddGradByIndex(ix2, l.inVol.GetByIndex(ix1)*chainGrad)
+							dx[ix1] += f.GetByIndex(ix2) * chainGrad
 						}
 					}
 				}
-				l.biases.AddGradByIndex(d, chainGrad)
 			}
+			l.biases.AddGradByIndex(d, chainGrad)
 		}
 	}
 }
 
+// Filters returns the filters of the layer, each of the window size and the depth of
+// the input.
+func (l *convLayer) Filters() []*volume.Volume {
+	return l.filters
+}
+
 func (l *convLayer) GetResponse() []LayerResponse {
 	var resp []LayerResponse
 	for i := 0; i < l.output.Z; i++ {
@@ -249,6 +378,7 @@ func (l *convLayer) GetResponse() []LayerResponse {
 		Gradients:  l.biases.Gradients(),
 		L1DecayMul: 0.0,
 		L2DecayMul: 0.0,
+		Bias:       true,
 	})
 	return resp
 }
@@ -0,0 +1,140 @@
+package layers
+
+import (
+	"math"
+	"math/bits"
+	"math/cmplx"
+
+	"github.com/nathanleary/reticulum/volume"
+)
+
+// forwardFFT computes the convolution of vol in the frequency domain, where the
+// correlation with each filter becomes a product with the conjugate of its
+// transform. The cost no longer grows with the filter size, which pays off for large
+// filters.
+func (l *convLayer) forwardFFT(vol *volume.Volume) *volume.Volume {
+	A := l.buf.output(l.output)
+	pad, stride := l.conf.Padding, l.conf.Stride
+	inZ := l.input.Z
+
+	// the padded input fits without wrapping around, so the valid outputs are exact
+	ny := 1 << bits.Len(uint(l.input.Y+2*pad-1))
+	nx := 1 << bits.Len(uint(l.input.X+2*pad-1))
+	py, px := newFFTPlan(ny), newFFTPlan(nx)
+
+	w := vol.Weights()
+	X := make([][]complex128, inZ)
+	for z := range X {
+		X[z] = make([]complex128, ny*nx)
+		for y := 0; y < l.input.Y; y++ {
+			for x := 0; x < l.input.X; x++ {
+				X[z][(y+pad)*nx+x+pad] = complex(w[((l.input.X*y)+x)*inZ+z], 0)
+			}
+		}
+		fft2(X[z], py, px, ny, false)
+	}
+
+	parallelFor(l.parallelism, l.output.Z, func(_, start, end int) {
+		F := make([]complex128, ny*nx)
+		S := make([]complex128, ny*nx)
+		for d := start; d < end; d++ {
+			f := l.filters[d]
+			fDim := f.Dimensions()
+			fw := f.Weights()
+			clear(S)
+			for z := 0; z < inZ; z++ {
+				clear(F)
+				for fy := 0; fy < fDim.Y; fy++ {
+					for fx := 0; fx < fDim.X; fx++ {
+						F[fy*nx+fx] = complex(fw[((fDim.X*fy)+fx)*inZ+z], 0)
+					}
+				}
+				fft2(F, py, px, fDim.Y, false)
+				for i, v := range X[z] {
+					S[i] += v * cmplx.Conj(F[i])
+				}
+			}
+			fft2(S, py, px, ny, true)
+
+			bias := l.biases.GetByIndex(d)
+			for ay := 0; ay < l.output.Y; ay++ {
+				for ax := 0; ax < l.output.X; ax++ {
+					A.Set(ax, ay, d, real(S[ay*stride*nx+ax*stride])+bias)
+				}
+			}
+		}
+	})
+	return A
+}
+
+// fftPlan holds the bit reversal permutation and twiddle factors of radix-2 FFTs of
+// length n, a power of two.
+type fftPlan struct {
+	n   int
+	rev []int
+	tw  []complex128
+}
+
+func newFFTPlan(n int) *fftPlan {
+	p := &fftPlan{n: n, rev: make([]int, n), tw: make([]complex128, n/2)}
+	shift := 64 - bits.Len(uint(n-1))
+	for i := range p.rev {
+		if n > 1 {
+			p.rev[i] = int(bits.Reverse64(uint64(i)) >> shift)
+		}
+	}
+	for k := range p.tw {
+		p.tw[k] = cmplx.Rect(1, -2*math.Pi*float64(k)/float64(n))
+	}
+	return p
+}
+
+// fft transforms a in place with the iterative radix-2 Cooley-Tukey algorithm,
+// leaving the inverse transform unscaled.
+func (p *fftPlan) fft(a []complex128, inverse bool) {
+	n := p.n
+	for i, j := range p.rev {
+		if i < j {
+			a[i], a[j] = a[j], a[i]
+		}
+	}
+	for size := 2; size <= n; size <<= 1 {
+		half, step := size/2, n/size
+		for k := 0; k < half; k++ {
+			w := p.tw[k*step]
+			if inverse {
+				w = cmplx.Conj(w)
+			}
+			for start := k; start < n; start += size {
+				u, v := a[start], a[start+half]*w
+				a[start], a[start+half] = u+v, u-v
+			}
+		}
+	}
+}
+
+// fft2 transforms the matrix a in place, with rows of the plan px and columns of the
+// plan py, and the inverse transform scaled. Only the first rows rows may be
+// non-zero, the others are skipped.
+func fft2(a []complex128, py, px *fftPlan, rows int, inverse bool) {
+	ny, nx := py.n, px.n
+	for y := 0; y < rows; y++ {
+		px.fft(a[y*nx:(y+1)*nx], inverse)
+	}
+	col := make([]complex128, ny)
+	for x := 0; x < nx; x++ {
+		for y := range col {
+			col[y] = a[y*nx+x]
+		}
+		py.fft(col, inverse)
+		for y, v := range col {
+			a[y*nx+x] = v
+		}
+	}
+	if inverse {
+		scale := complex(1/float64(nx*ny), 0)
+		for i := range a {
+			a[i] *= scale
+		}
+	}
+}
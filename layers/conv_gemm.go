@@ -0,0 +1,124 @@
+package layers
+
+import (
+	"github.com/nathanleary/reticulum/volume"
+)
+
+// The gemm path lowers the convolution to matrix products: the input windows are
+// unrolled into the rows of a P x K matrix, with P the output positions and K the
+// filter size, and the filters form a D x K matrix with one filter per row. The
+// output volume, indexed by position then depth, is then their P x D product.
+
+// The scratch slices of the conv layer, with a slice per worker from scratchWorkers.
+const (
+	scratchRows = iota
+	scratchFilters
+	scratchFilterGrads
+	scratchRowGrads
+	scratchTransforms
+	scratchWorkers
+)
+
+// forwardGemm computes the convolution of vol with a single gemm.
+func (l *convLayer) forwardGemm(vol *volume.Volume) *volume.Volume {
+	A := l.buf.output(l.output)
+	P, D, K := l.output.X*l.output.Y, l.output.Z, l.filterSize()
+
+	rows := l.im2row(vol)
+	out := A.Weights()
+	ops(l.dev).Gemm(false, true, P, D, K, 1, rows, l.filterMatrix(), 0, out)
+	for p := 0; p < P; p++ {
+		for d := 0; d < D; d++ {
+			out[p*D+d] += l.biases.GetByIndex(d)
+		}
+	}
+	return A
+}
+
+// backwardGemm accumulates the filter, bias and input gradients with two gemms.
+func (l *convLayer) backwardGemm() {
+	P, D, K := l.output.X*l.output.Y, l.output.Z, l.filterSize()
+	dOut := l.outVol.Gradients()
+	b := ops(l.dev)
+
+	// filters: dF = dOut^T * rows
+	dF := l.buf.floats(scratchFilterGrads, D*K)
+	b.Gemm(true, false, D, K, P, 1, dOut, l.im2row(l.inVol), 0, dF)
+	for d, f := range l.filters {
+		b.Axpy(1, dF[d*K:(d+1)*K], f.Gradients())
+	}
+
+	// biases: summed over the output positions
+	for p := 0; p < P; p++ {
+		for d := 0; d < D; d++ {
+			l.biases.AddGradByIndex(d, dOut[p*D+d])
+		}
+	}
+
+	// input: dRows = dOut * F, scattered back onto the windows
+	dRows := l.buf.floats(scratchRowGrads, P*K)
+	b.Gemm(false, false, P, K, D, 1, dOut, l.filterMatrix(), 0, dRows)
+	dx := l.inVol.Gradients()
+	l.windows(func(p, k, i int) {
+		dx[i] += dRows[p*K+k]
+	})
+}
+
+// filterSize returns the number of weights in a filter.
+func (l *convLayer) filterSize() int {
+	return l.conf.Sx * l.conf.Sy * l.input.Z
+}
+
+// filterMatrix returns the D x K matrix of the filters.
+func (l *convLayer) filterMatrix() []float64 {
+	K := l.filterSize()
+	m := l.buf.floats(scratchFilters, len(l.filters)*K)
+	for d, f := range l.filters {
+		copy(m[d*K:], f.Weights())
+	}
+	return m
+}
+
+// im2row returns the P x K matrix of the input windows of vol, with zeros for the
+// padding.
+func (l *convLayer) im2row(vol *volume.Volume) []float64 {
+	K := l.filterSize()
+	rows := l.buf.floats(scratchRows, l.output.X*l.output.Y*K)
+	w := vol.Weights()
+	l.windows(func(p, k, i int) {
+		rows[p*K+k] = w[i]
+	})
+	return rows
+}
+
+// windows calls fn for every weight k of every output position p whose window
+// covers the input index i, skipping the padding. k follows the layout of the filter
+// weights.
+func (l *convLayer) windows(fn func(p, k, i int)) {
+	vsx, vsy, vsz := l.input.X, l.input.Y, l.input.Z
+	sx, sy, stride := l.conf.Sx, l.conf.Sy, l.conf.Stride
+	for ay := 0; ay < l.output.Y; ay++ {
+		y := -l.conf.Padding + ay*stride
+		for ax := 0; ax < l.output.X; ax++ {
+			x := -l.conf.Padding + ax*stride
+			p := ay*l.output.X + ax
+			for fy := 0; fy < sy; fy++ {
+				oy := y + fy
+				if oy < 0 || oy >= vsy {
+					continue
+				}
+				for fx := 0; fx < sx; fx++ {
+					ox := x + fx
+					if ox < 0 || ox >= vsx {
+						continue
+					}
+					k := (fy*sx + fx) * vsz
+					i := (oy*vsx + ox) * vsz
+					for fz := 0; fz < vsz; fz++ {
+						fn(p, k+fz, i+fz)
+					}
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package layers
+
+import (
+	"math"
+	"testing"
+
+	"github.com/nathanleary/reticulum/volume"
+)
+
+// checkForward compares the forward pass of the conv layer with alg to the direct
+// loops, on the same filters and input.
+func checkForward(t *testing.T, c convBench, alg ConvAlgorithm) {
+	t.Helper()
+	l, vol := c.layer(alg)
+	want, _ := c.layer(ConvDirect)
+	got := l.Forward(vol, false).Weights()
+	for i, w := range want.Forward(vol, false).Weights() {
+		if math.Abs(got[i]-w) > 1e-9 {
+			t.Fatalf("%s/%s: output %d = %v, want %v", c.name(), alg, i, got[i], w)
+		}
+	}
+}
+
+func TestConvLayer_WinogradMatchesDirect(t *testing.T) {
+	for _, test := range []struct {
+		c    convBench
+		want *winograd
+	}{
+		{convBench{volume.NewDimensions(5, 7, 3), 4, 3, 1, 1}, winogradF23},
+		{convBench{volume.NewDimensions(7, 5, 2), 3, 3, 1, 0}, winogradF23},
+		{convBench{volume.NewDimensions(11, 9, 3), 4, 3, 1, 1}, winogradF43},
+		{convBench{volume.NewDimensions(13, 10, 2), 3, 3, 1, 0}, winogradF43},
+		{convBench{volume.NewDimensions(9, 9, 1), 2, 3, 1, 2}, winogradF43},
+	} {
+		l, _ := test.c.layer(ConvWinograd)
+		if wg := winogradFor(l.(*convLayer).output); wg != test.want {
+			t.Fatalf("%s: winogradFor() = F(%d,3), want F(%d,3)", test.c.name(), wg.m, test.want.m)
+		}
+		checkForward(t, test.c, ConvWinograd)
+	}
+}
+
+func TestConvLayer_FFTMatchesDirect(t *testing.T) {
+	for _, c := range []convBench{
+		{volume.NewDimensions(9, 7, 3), 4, 3, 1, 1},
+		{volume.NewDimensions(7, 11, 2), 3, 5, 1, 2},
+		{volume.NewDimensions(13, 9, 3), 2, 7, 1, 0},
+		{volume.NewDimensions(15, 15, 1), 2, 9, 1, 4},
+	} {
+		checkForward(t, c, ConvFFT)
+	}
+}
+
+// gemmCounter is an optimized backend in disguise, counting its Gemm calls.
+type gemmCounter struct {
+	PureGo
+	calls int
+}
+
+func (b *gemmCounter) Gemm(transA, transB bool, m, n, k int, alpha float64, a, bm []float64, beta float64, c []float64) {
+	b.calls++
+	b.PureGo.Gemm(transA, transB, m, n, k, alpha, a, bm, beta, c)
+}
+
+func TestConvLayer_AutoUsesBackend(t *testing.T) {
+	benches := []convBench{
+		{volume.NewDimensions(9, 9, 2), 3, 3, 1, 1},
+		{volume.NewDimensions(13, 9, 2), 2, 7, 1, 0},
+	}
+	for _, c := range benches {
+		l, _ := c.layer(ConvAuto)
+		if alg := l.(*convLayer).forwardAlgorithm(); alg == ConvDirect {
+			t.Fatalf("%s: forwardAlgorithm() = %v with the pure Go backend", c.name(), alg)
+		}
+	}
+
+	b := &gemmCounter{}
+	SetBackend(b)
+	defer SetBackend(nil)
+	for _, c := range benches {
+		checkForward(t, c, ConvAuto)
+
+		l, vol := c.layer(ConvAuto)
+		b.calls = 0
+		l.Forward(vol, false)
+		if b.calls == 0 {
+			t.Errorf("%s: ConvAuto did not call the backend", c.name())
+		}
+	}
+}
@@ -0,0 +1,176 @@
+package layers
+
+import (
+	"github.com/nathanleary/reticulum/volume"
+)
+
+// winograd holds the transforms of the Winograd minimal filtering algorithm F(m, 3),
+// which computes m x m outputs of a 3x3 correlation from a tile of (m+2) x (m+2)
+// inputs with (m+2)^2 multiplications instead of 9m^2:
+//
+//	Y = At [(G g Gt) ⊙ (Bt d B)] A
+type winograd struct {
+	m  int
+	bt [][]float64 // input transform, t x t
+	g  [][]float64 // filter transform, t x 3
+	at [][]float64 // output transform, m x t
+}
+
+// winogradF23 computes 2x2 output tiles, 2.25x fewer multiplications than direct.
+var winogradF23 = &winograd{
+	m: 2,
+	bt: [][]float64{
+		{1, 0, -1, 0},
+		{0, 1, 1, 0},
+		{0, -1, 1, 0},
+		{0, 1, 0, -1},
+	},
+	g: [][]float64{
+		{1, 0, 0},
+		{0.5, 0.5, 0.5},
+		{0.5, -0.5, 0.5},
+		{0, 0, 1},
+	},
+	at: [][]float64{
+		{1, 1, 1, 0},
+		{0, 1, -1, -1},
+	},
+}
+
+// winogradF43 computes 4x4 output tiles, 4x fewer multiplications than direct, for
+// outputs large enough to fill them.
+var winogradF43 = &winograd{
+	m: 4,
+	bt: [][]float64{
+		{4, 0, -5, 0, 1, 0},
+		{0, -4, -4, 1, 1, 0},
+		{0, 4, -4, -1, 1, 0},
+		{0, -2, -1, 2, 1, 0},
+		{0, 2, -1, -2, 1, 0},
+		{0, 4, 0, -5, 0, 1},
+	},
+	g: [][]float64{
+		{1.0 / 4, 0, 0},
+		{-1.0 / 6, -1.0 / 6, -1.0 / 6},
+		{-1.0 / 6, 1.0 / 6, -1.0 / 6},
+		{1.0 / 24, 1.0 / 12, 1.0 / 6},
+		{1.0 / 24, -1.0 / 12, 1.0 / 6},
+		{0, 0, 1},
+	},
+	at: [][]float64{
+		{1, 1, 1, 1, 1, 0},
+		{0, 1, -1, 2, -2, 0},
+		{0, 1, 1, 4, 4, 0},
+		{0, 1, -1, 8, -8, 1},
+	},
+}
+
+// winogradFor returns the transforms for the output dimensions out.
+func winogradFor(out volume.Dimensions) *winograd {
+	if out.X >= 8 && out.Y >= 8 {
+		return winogradF43
+	}
+	return winogradF23
+}
+
+// forwardWinograd computes the convolution of vol with 3x3 filters and stride 1.
+func (l *convLayer) forwardWinograd(vol *volume.Volume) *volume.Volume {
+	A := l.buf.output(l.output)
+	wg := winogradFor(l.output)
+	m, t := wg.m, wg.m+2
+	tt := t * t
+	inZ, D := l.input.Z, l.output.Z
+
+	// filters: U[d][z] = G g Gt
+	U := l.buf.floats(scratchTransforms, D*inZ*tt)
+	for d, f := range l.filters {
+		fw := f.Weights()
+		for z := 0; z < inZ; z++ {
+			u := U[(d*inZ+z)*tt : (d*inZ+z+1)*tt]
+			for i := 0; i < t; i++ {
+				for j := 0; j < t; j++ {
+					var s float64
+					for fy := 0; fy < 3; fy++ {
+						for fx := 0; fx < 3; fx++ {
+							s += wg.g[i][fy] * fw[((3*fy)+fx)*inZ+z] * wg.g[j][fx]
+						}
+					}
+					u[i*t+j] = s
+				}
+			}
+		}
+	}
+
+	tilesX := (l.output.X + m - 1) / m
+	tilesY := (l.output.Y + m - 1) / m
+	parallelFor(l.parallelism, tilesX*tilesY, func(_, start, end int) {
+		tile := make([]float64, tt)
+		tmp := make([]float64, tt)
+		V := make([]float64, inZ*tt)
+		M := make([]float64, tt)
+		for n := start; n < end; n++ {
+			y0, x0 := (n/tilesX)*m, (n%tilesX)*m
+			for z := 0; z < inZ; z++ {
+				l.winogradTile(vol, x0, y0, z, t, tile)
+				transform(wg.bt, tile, tmp, V[z*tt:(z+1)*tt], t, t)
+			}
+			for d := 0; d < D; d++ {
+				clear(M)
+				for z := 0; z < inZ; z++ {
+					u, v := U[(d*inZ+z)*tt:(d*inZ+z+1)*tt], V[z*tt:(z+1)*tt]
+					for i := range M {
+						M[i] += u[i] * v[i]
+					}
+				}
+				transform(wg.at, M, tmp, tile, m, t)
+				bias := l.biases.GetByIndex(d)
+				for i := 0; i < m && y0+i < l.output.Y; i++ {
+					for j := 0; j < m && x0+j < l.output.X; j++ {
+						A.Set(x0+j, y0+i, d, tile[i*m+j]+bias)
+					}
+				}
+			}
+		}
+	})
+	return A
+}
+
+// winogradTile copies the t x t input tile of depth z for the outputs from (x0, y0)
+// into tile, with zeros for the padding.
+func (l *convLayer) winogradTile(vol *volume.Volume, x0, y0, z, t int, tile []float64) {
+	w := vol.Weights()
+	for i := 0; i < t; i++ {
+		oy := y0 + i - l.conf.Padding
+		for j := 0; j < t; j++ {
+			ox := x0 + j - l.conf.Padding
+			if oy >= 0 && oy < l.input.Y && ox >= 0 && ox < l.input.X {
+				tile[i*t+j] = w[((l.input.X*oy)+ox)*l.input.Z+z]
+			} else {
+				tile[i*t+j] = 0
+			}
+		}
+	}
+}
+
+// transform computes dst = P src Pt for the r x c matrix P and the c x c matrix src,
+// using tmp for the r x c intermediate.
+func transform(p [][]float64, src, tmp, dst []float64, r, c int) {
+	for i := 0; i < r; i++ {
+		for j := 0; j < c; j++ {
+			var s float64
+			for k := 0; k < c; k++ {
+				s += p[i][k] * src[k*c+j]
+			}
+			tmp[i*c+j] = s
+		}
+	}
+	for i := 0; i < r; i++ {
+		for j := 0; j < r; j++ {
+			var s float64
+			for k := 0; k < c; k++ {
+				s += tmp[i*c+k] * p[j][k]
+			}
+			dst[i*r+j] = s
+		}
+	}
+}
@@ -0,0 +1,168 @@
+package layers
+
+import (
+	"fmt"
+
+	"github.com/nathanleary/reticulum/volume"
+)
+
+// NewDepthwiseLayerConfig creates a new depthwise conv layer config with size x size
+// filters, one per input channel. Stride, padding and a non-square window are set
+// with the conv options.
+func NewDepthwiseLayerConfig(size int, opts ...LayerOptionFunc) LayerConfig {
+	if size <= 0 {
+		panic("Filter size must be greater than 0")
+	}
+
+	conf := &depthwiseLayerConfig{
+		Sx:            size,
+		Stride:        1,
+		Padding:       0,
+		L1DecayMult:   0.0,
+		L2DecayMult:   1.0,
+		PreferredBias: 0.0,
+	}
+	for i := 0; i < len(opts); i++ {
+		err := opts[i](conf)
+		if err != nil {
+			panic(err)
+		}
+	}
+	return conf
+}
+
+type depthwiseLayerConfig struct {
+	Sx            int
+	Sy            int
+	Stride        int
+	Padding       int
+	L1DecayMult   float64
+	L2DecayMult   float64
+	PreferredBias float64
+}
+
+// NewDepthwiseLayer creates a new depthwise conv layer, which convolves every channel
+// of the input with a filter of its own, keeping the depth of the input. Followed by a
+// 1x1 conv layer mixing the channels, it forms the depthwise separable convolution of
+// MobileNet, with far fewer parameters and operations than a full convolution.
+func NewDepthwiseLayer(def LayerDef) Layer {
+	if def.Type != Depthwise {
+		panic(fmt.Errorf("Invalid layer type: %s != depthwise", def.Type))
+	} else if def.Output.Z == 0 {
+		panic(fmt.Errorf("Output depth cannot be 0 for depthwise layer"))
+	}
+
+	conf, ok := def.LayerConfig.(*depthwiseLayerConfig)
+	if !ok {
+		panic("Invalid LayerConfig for DepthwiseLayer")
+	}
+	if conf.Sy <= 0 {
+		conf.Sy = conf.Sx
+	}
+
+	outDim, err := windowOutput(def.Input, conf.Sx, conf.Sy, conf.Stride, conf.Padding, def.Input.Z)
+	if err != nil {
+		panic(err)
+	}
+
+	var filters []*volume.Volume
+	for i := 0; i < outDim.Z; i++ {
+		filters = append(filters, volume.NewVolume(volume.NewDimensions(conf.Sx, conf.Sy, 1), volume.WithRand(def.Rand)))
+	}
+	biases := volume.NewVolume(volume.NewDimensions(1, 1, outDim.Z), volume.WithInitialValue(conf.PreferredBias))
+	return &depthwiseLayer{conf, def.Input, outDim, nil, nil, filters, biases}
+}
+
+type depthwiseLayer struct {
+	conf   *depthwiseLayerConfig
+	input  volume.Dimensions
+	output volume.Dimensions
+
+	inVol  *volume.Volume
+	outVol *volume.Volume
+
+	// filters holds a filter of depth 1 for every channel
+	filters []*volume.Volume
+	biases  *volume.Volume
+}
+
+func (*depthwiseLayer) Type() LayerType {
+	return Depthwise
+}
+
+func (l *depthwiseLayer) Forward(vol *volume.Volume, training bool) *volume.Volume {
+	l.inVol = vol
+	A := volume.NewVolume(l.output, volume.WithZeros())
+
+	stride := l.conf.Stride
+	for d := 0; d < l.output.Z; d++ {
+		f := l.filters[d]
+		y := -l.conf.Padding
+		for ay := 0; ay < l.output.Y; ay, y = ay+1, y+stride {
+			x := -l.conf.Padding
+			for ax := 0; ax < l.output.X; ax, x = ax+1, x+stride {
+				a := l.biases.GetByIndex(d)
+				for fy := 0; fy < l.conf.Sy; fy++ {
+					oy := y + fy
+					for fx := 0; fx < l.conf.Sx; fx++ {
+						ox := x + fx
+						if oy >= 0 && oy < l.input.Y && ox >= 0 && ox < l.input.X {
+							a += f.Get(fx, fy, 0) * vol.Get(ox, oy, d)
+						}
+					}
+				}
+				A.Set(ax, ay, d, a)
+			}
+		}
+	}
+
+	l.outVol = A
+	return l.outVol
+}
+
+func (l *depthwiseLayer) Backward() {
+	l.inVol.ZeroGrad()
+
+	stride := l.conf.Stride
+	for d := 0; d < l.output.Z; d++ {
+		f := l.filters[d]
+		y := -l.conf.Padding
+		for ay := 0; ay < l.output.Y; ay, y = ay+1, y+stride {
+			x := -l.conf.Padding
+			for ax := 0; ax < l.output.X; ax, x = ax+1, x+stride {
+				chainGrad := l.outVol.GetGrad(ax, ay, d)
+				for fy := 0; fy < l.conf.Sy; fy++ {
+					oy := y + fy
+					for fx := 0; fx < l.conf.Sx; fx++ {
+						ox := x + fx
+						if oy >= 0 && oy < l.input.Y && ox >= 0 && ox < l.input.X {
+							f.AddGrad(fx, fy, 0, l.inVol.Get(ox, oy, d)*chainGrad)
+							l.inVol.AddGrad(ox, oy, d, f.Get(fx, fy, 0)*chainGrad)
+						}
+					}
+				}
+				l.biases.AddGradByIndex(d, chainGrad)
+			}
+		}
+	}
+}
+
+func (l *depthwiseLayer) GetResponse() []LayerResponse {
+	var resp []LayerResponse
+	for i := 0; i < l.output.Z; i++ {
+		resp = append(resp, LayerResponse{
+			Weights:    l.filters[i].Weights(),
+			Gradients:  l.filters[i].Gradients(),
+			L1DecayMul: l.conf.L1DecayMult,
+			L2DecayMul: l.conf.L2DecayMult,
+		})
+	}
+	resp = append(resp, LayerResponse{
+		Weights:    l.biases.Weights(),
+		Gradients:  l.biases.Gradients(),
+		L1DecayMul: 0.0,
+		L2DecayMul: 0.0,
+		Bias:       true,
+	})
+	return resp
+}
@@ -0,0 +1,91 @@
+package layers
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/nathanleary/reticulum/volume"
+)
+
+// TestDepthwiseLayer_MatchesConv checks the depthwise layer against a conv layer whose
+// filter d only reads channel d, with the weights of the depthwise filter d.
+func TestDepthwiseLayer_MatchesConv(t *testing.T) {
+	for _, c := range []convBench{
+		{volume.NewDimensions(5, 7, 3), 3, 3, 1, 1},
+		{volume.NewDimensions(8, 6, 4), 4, 3, 2, 1},
+		{volume.NewDimensions(7, 7, 2), 2, 5, 1, 0},
+	} {
+		r := rand.New(rand.NewSource(1))
+		def := LayerDef{Type: Depthwise, Input: c.in, LayerConfig: NewDepthwiseLayerConfig(c.sx, WithStride(c.stride), WithPadding(c.pad), WithBias(0.1)), Rand: r}
+		out, err := InferOutput(def)
+		if err != nil {
+			t.Fatal(err)
+		}
+		def.Output = out
+		dw := NewDepthwiseLayer(def)
+
+		conv, vol := c.layer(ConvDirect)
+		dwResp, convResp := dw.GetResponse(), conv.GetResponse()
+		for d := 0; d < c.in.Z; d++ {
+			for i := range convResp[d].Weights {
+				convResp[d].Weights[i] = 0
+				if i%c.in.Z == d {
+					convResp[d].Weights[i] = dwResp[d].Weights[i/c.in.Z]
+				}
+			}
+		}
+		copy(convResp[c.in.Z].Weights, dwResp[c.in.Z].Weights)
+
+		got, want := dw.Forward(vol, true), conv.Forward(vol, true)
+		for i, w := range want.Weights() {
+			if math.Abs(got.Weights()[i]-w) > 1e-9 {
+				t.Fatalf("%s: output %d = %v, want %v", c.name(), i, got.Weights()[i], w)
+			}
+		}
+
+		// the same output gradients yield the same input and filter gradients
+		for i := range got.Gradients() {
+			g := r.NormFloat64()
+			got.Gradients()[i], want.Gradients()[i] = g, g
+		}
+		dw.Backward()
+		dx := append([]float64(nil), vol.Gradients()...)
+		conv.Backward()
+		for i, g := range vol.Gradients() {
+			if math.Abs(dx[i]-g) > 1e-9 {
+				t.Fatalf("%s: input gradient %d = %v, want %v", c.name(), i, dx[i], g)
+			}
+		}
+		for d := 0; d < c.in.Z; d++ {
+			for i, g := range dwResp[d].Gradients {
+				if w := convResp[d].Gradients[i*c.in.Z+d]; math.Abs(g-w) > 1e-9 {
+					t.Fatalf("%s: filter %d gradient %d = %v, want %v", c.name(), d, i, g, w)
+				}
+			}
+		}
+	}
+}
+
+func TestDepthwiseLayer_OutputDimensions(t *testing.T) {
+	for _, test := range []struct {
+		in      volume.Dimensions
+		conf    LayerConfig
+		want    volume.Dimensions
+		wantErr bool
+	}{
+		{volume.NewDimensions(8, 8, 4), NewDepthwiseLayerConfig(3, WithPadding(1)), volume.NewDimensions(8, 8, 4), false},
+		{volume.NewDimensions(8, 8, 4), NewDepthwiseLayerConfig(3, WithStride(2), WithPadding(1)), volume.NewDimensions(4, 4, 4), false},
+		{volume.NewDimensions(2, 2, 4), NewDepthwiseLayerConfig(3), volume.Dimensions{}, true},
+		{volume.NewDimensions(8, 8, 4), NewConvLayerConfig(3), volume.Dimensions{}, true},
+	} {
+		got, err := OutputDimensions(LayerDef{Type: Depthwise, Input: test.in, LayerConfig: test.conf})
+		if test.wantErr {
+			if err == nil {
+				t.Errorf("%v: expected an error, got %v", test.in, got)
+			}
+		} else if err != nil || got != test.want {
+			t.Errorf("%v: OutputDimensions() = %v, %v, want %v", test.in, got, err, test.want)
+		}
+	}
+}
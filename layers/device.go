@@ -0,0 +1,103 @@
+package layers
+
+import (
+	"github.com/nathanleary/reticulum/device"
+)
+
+// computeDevice returns the device of the layer, the CPU unless the network set one.
+// The backend set by SetBackend only applies on the CPU.
+func (def LayerDef) computeDevice() device.Device {
+	if def.Device == nil {
+		return device.CPU{}
+	}
+	return def.Device
+}
+
+// deviceBackend computes the products of a Backend on a device, staging the host
+// operands through device buffers for every call, as volumes stay in host memory.
+type deviceBackend struct {
+	d device.Device
+}
+
+func (b deviceBackend) Dot(x, y []float64) float64 {
+	bx, by := upload(b.d, x), upload(b.d, y)
+	defer release(b.d, bx)
+	defer release(b.d, by)
+	return b.d.Dot(bx, by)
+}
+
+func (b deviceBackend) Axpy(alpha float64, x, y []float64) {
+	bx, by := upload(b.d, x), upload(b.d, y)
+	defer release(b.d, bx)
+	b.d.Axpy(alpha, bx, by)
+	download(b.d, y, by)
+}
+
+func (b deviceBackend) Gemm(transA, transB bool, m, n, k int, alpha float64, a, bm []float64, beta float64, c []float64) {
+	ba, bb, bc := upload(b.d, a), upload(b.d, bm), upload(b.d, c)
+	defer release(b.d, ba)
+	defer release(b.d, bb)
+	b.d.Gemm(transA, transB, m, n, k, alpha, ba, bb, beta, bc)
+	download(b.d, c, bc)
+}
+
+// apply computes y = k(x) on the device d.
+func apply(d device.Device, k device.Kernel, x, y []float64) {
+	if cpu, ok := d.(device.CPU); ok {
+		// called directly, the host buffers stay on the stack
+		cpu.Map(k, device.Host(x), device.Host(y))
+		return
+	}
+	bx, by := upload(d, x), buffer(d, y)
+	defer release(d, bx)
+	d.Map(k, bx, by)
+	download(d, y, by)
+}
+
+// applyGrad computes the input gradients dx of the kernel k from its outputs y and
+// their gradients dy on the device d.
+func applyGrad(d device.Device, k device.Kernel, y, dy, dx []float64) {
+	if cpu, ok := d.(device.CPU); ok {
+		cpu.MapGrad(k, device.Host(y), device.Host(dy), device.Host(dx))
+		return
+	}
+	by, bdy, bdx := upload(d, y), upload(d, dy), buffer(d, dx)
+	defer release(d, by)
+	defer release(d, bdy)
+	d.MapGrad(k, by, bdy, bdx)
+	download(d, dx, bdx)
+}
+
+// upload returns a device buffer holding the host values s, sharing them on host
+// devices.
+func upload(d device.Device, s []float64) device.Buffer {
+	b := buffer(d, s)
+	if _, host := d.(device.HostDevice); !host {
+		d.Upload(b, s)
+	}
+	return b
+}
+
+// buffer returns a device buffer for the output values s, sharing them on host
+// devices.
+func buffer(d device.Device, s []float64) device.Buffer {
+	if h, ok := d.(device.HostDevice); ok {
+		return h.Wrap(s)
+	}
+	return d.Alloc(len(s))
+}
+
+// download copies the output buffer b back into s and releases it.
+func download(d device.Device, s []float64, b device.Buffer) {
+	if _, host := d.(device.HostDevice); !host {
+		d.Download(s, b)
+	}
+	release(d, b)
+}
+
+// release frees the buffer b, unless it shares host values.
+func release(d device.Device, b device.Buffer) {
+	if _, host := d.(device.HostDevice); !host {
+		d.Free(b)
+	}
+}
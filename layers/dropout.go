@@ -20,7 +20,11 @@ func NewDropoutLayer(def LayerDef) Layer {
 	}
 
 	n := def.Output.Size()
-	return &dropoutLayer{conf, def.Input, def.Output, make([]bool, n, n), nil, nil}
+	random := rand.Float64
+	if def.Rand != nil {
+		random = def.Rand.Float64
+	}
+	return &dropoutLayer{conf, def.Input, def.Output, make([]bool, n, n), nil, nil, random, buffers{reuse: def.ReuseBuffers}}
 }
 
 // DropoutLayerConfig contains the dropout probablity.
@@ -37,6 +41,12 @@ type dropoutLayer struct {
 
 	inVol  *volume.Volume
 	outVol *volume.Volume
+
+	// random source for the dropout mask
+	random func() float64
+
+	// buf holds the output, reused across passes when the network preallocates
+	buf buffers
 }
 
 func (l *dropoutLayer) Type() LayerType {
@@ -45,13 +55,14 @@ func (l *dropoutLayer) Type() LayerType {
 
 func (l *dropoutLayer) Forward(vol *volume.Volume, training bool) *volume.Volume {
 	l.inVol = vol
-	vol2 := vol.Clone()
+	vol2 := l.buf.output(vol.Dimensions())
+	copy(vol2.Weights(), vol.Weights())
 	n := vol.Size()
 
 	if training {
 		// Perform dropout based on probabilty
 		for i := 0; i < n; i++ {
-			if rand.Float64() < l.config.DropoutProbability {
+			if l.random() < l.config.DropoutProbability {
 				vol2.SetByIndex(i, 0.0)
 				l.dropped[i] = true
 			} else {
@@ -0,0 +1,135 @@
+package layers
+
+import (
+	"fmt"
+
+	"github.com/nathanleary/reticulum/volume"
+)
+
+// NewEmbeddingLayerConfig creates a new embedding layer config, with a vector of the
+// given size for every index of a vocabulary. Decay is set with WithDecay, and is
+// off by default so the rows of tokens missing from a batch are left unchanged.
+func NewEmbeddingLayerConfig(vocabulary, dimensions int, opts ...LayerOptionFunc) LayerConfig {
+	if vocabulary <= 0 {
+		panic("Vocabulary size must be greater than 0")
+	} else if dimensions <= 0 {
+		panic("Embedding size must be greater than 0")
+	}
+
+	conf := &embeddingLayerConfig{
+		Vocabulary:  vocabulary,
+		Dimensions:  dimensions,
+		L1DecayMult: 0.0,
+		L2DecayMult: 0.0,
+	}
+	for i := 0; i < len(opts); i++ {
+		err := opts[i](conf)
+		if err != nil {
+			panic(err)
+		}
+	}
+	return conf
+}
+
+type embeddingLayerConfig struct {
+	Vocabulary  int
+	Dimensions  int
+	L1DecayMult float64
+	L2DecayMult float64
+}
+
+// NewEmbeddingLayer creates a new embedding layer, which reads every value of its
+// input as the index of a token and outputs the vector of that token. An input of n
+// tokens yields an n x 1 x dimensions output, the tokens along the width and their
+// vectors along the depth. Indices outside the vocabulary, such as -1 for padding or
+// unknown tokens, yield zero vectors.
+//
+// Every row of the table is a response of its own, and the backward pass only adds
+// gradients to the rows of the tokens it saw, so a batch updates just those rows.
+// The input is not differentiable and receives no gradient.
+func NewEmbeddingLayer(def LayerDef) Layer {
+	if def.Type != Embedding {
+		panic(fmt.Errorf("Invalid layer type: %s != embedding", def.Type))
+	}
+
+	conf, ok := def.LayerConfig.(*embeddingLayerConfig)
+	if !ok {
+		panic("Invalid LayerConfig for EmbeddingLayer")
+	} else if conf.Vocabulary <= 0 || conf.Dimensions <= 0 {
+		panic(fmt.Errorf("Vocabulary and embedding size cannot be <= 0"))
+	}
+
+	outDim := volume.NewDimensions(def.Input.Size(), 1, conf.Dimensions)
+	rows := make([]*volume.Volume, conf.Vocabulary)
+	for i := range rows {
+		rows[i] = volume.NewVolume(volume.NewDimensions(1, 1, conf.Dimensions), volume.WithRand(def.Rand))
+	}
+	return &embeddingLayer{conf, def.Input, outDim, nil, nil, rows}
+}
+
+type embeddingLayer struct {
+	conf   *embeddingLayerConfig
+	input  volume.Dimensions
+	output volume.Dimensions
+
+	inVol  *volume.Volume
+	outVol *volume.Volume
+
+	// rows holds the vector of every token
+	rows []*volume.Volume
+}
+
+func (*embeddingLayer) Type() LayerType {
+	return Embedding
+}
+
+// row returns the vector of the token at position i of the input, or nil when the
+// index is outside the vocabulary.
+func (l *embeddingLayer) row(i int) *volume.Volume {
+	idx := int(l.inVol.GetByIndex(i))
+	if idx < 0 || idx >= len(l.rows) {
+		return nil
+	}
+	return l.rows[idx]
+}
+
+func (l *embeddingLayer) Forward(vol *volume.Volume, training bool) *volume.Volume {
+	l.inVol = vol
+	A := volume.NewVolume(l.output, volume.WithZeros())
+
+	for i := 0; i < l.output.X; i++ {
+		if r := l.row(i); r != nil {
+			copy(A.Weights()[i*l.output.Z:(i+1)*l.output.Z], r.Weights())
+		}
+	}
+
+	l.outVol = A
+	return l.outVol
+}
+
+func (l *embeddingLayer) Backward() {
+	l.inVol.ZeroGrad()
+
+	for i := 0; i < l.output.X; i++ {
+		r := l.row(i)
+		if r == nil {
+			continue
+		}
+		for d := 0; d < l.output.Z; d++ {
+			r.AddGradByIndex(d, l.outVol.GetGrad(i, 0, d))
+		}
+	}
+}
+
+func (l *embeddingLayer) GetResponse() []LayerResponse {
+	resp := make([]LayerResponse, len(l.rows))
+	for i, r := range l.rows {
+		resp[i] = LayerResponse{
+			Weights:    r.Weights(),
+			Gradients:  r.Gradients(),
+			L1DecayMul: l.conf.L1DecayMult,
+			L2DecayMul: l.conf.L2DecayMult,
+		}
+	}
+	return resp
+}
@@ -0,0 +1,87 @@
+package layers
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/nathanleary/reticulum/volume"
+)
+
+func TestEmbeddingLayer(t *testing.T) {
+	def := LayerDef{Type: Embedding, Input: volume.NewDimensions(1, 1, 4), LayerConfig: NewEmbeddingLayerConfig(3, 2), Rand: rand.New(rand.NewSource(1))}
+	out, err := InferOutput(def)
+	if err != nil {
+		t.Fatal(err)
+	} else if want := volume.NewDimensions(4, 1, 2); out != want {
+		t.Fatalf("output %v, want %v", out, want)
+	}
+	def.Output = out
+	l := NewEmbeddingLayer(def)
+	rows := l.GetResponse()
+	if len(rows) != 3 {
+		t.Fatalf("%d rows, want 3", len(rows))
+	}
+	for i, r := range rows {
+		copy(r.Weights, []float64{float64(i), -float64(i)})
+	}
+
+	// tokens 2, 0 and 2 again, and one out of the vocabulary
+	vol := volume.NewVolume(volume.NewDimensions(1, 1, 4), volume.WithWeights([]float64{2, 0, 2, 3}))
+	got := l.Forward(vol, true)
+	for i, w := range []float64{2, -2, 0, 0, 2, -2, 0, 0} {
+		if got.Weights()[i] != w {
+			t.Fatalf("output %v, want the rows of the tokens", got.Weights())
+		}
+	}
+
+	copy(got.Gradients(), []float64{1, 2, 3, 4, 5, 6, 7, 8})
+	l.Backward()
+	for _, test := range []struct {
+		row  int
+		want []float64
+	}{
+		{0, []float64{3, 4}},
+		{1, []float64{0, 0}}, // unused rows are left alone
+		{2, []float64{1 + 5, 2 + 6}},
+	} {
+		for d, g := range rows[test.row].Gradients {
+			if g != test.want[d] {
+				t.Errorf("row %d gradients %v, want %v", test.row, rows[test.row].Gradients, test.want)
+				break
+			}
+		}
+	}
+	for _, g := range vol.Gradients() {
+		if g != 0 {
+			t.Fatalf("input gradients %v, want zeros", vol.Gradients())
+		}
+	}
+}
+
+func TestEmbeddingLayer_Config(t *testing.T) {
+	for _, test := range []struct {
+		name             string
+		vocabulary, dims int
+	}{
+		{"empty vocabulary", 0, 2},
+		{"empty vectors", 3, 0},
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("%s: expected a panic", test.name)
+				}
+			}()
+			NewEmbeddingLayerConfig(test.vocabulary, test.dims)
+		}()
+	}
+
+	conf := NewEmbeddingLayerConfig(3, 2, WithDecay(0.1, 0.2))
+	l := NewEmbeddingLayer(LayerDef{Type: Embedding, Input: volume.NewDimensions(1, 1, 1), LayerConfig: conf})
+	if r := l.GetResponse()[0]; r.L1DecayMul != 0.1 || r.L2DecayMul != 0.2 {
+		t.Errorf("decay %v, %v, want 0.1, 0.2", r.L1DecayMul, r.L2DecayMul)
+	}
+	if _, ok := NewConfig(Embedding).(*embeddingLayerConfig); !ok {
+		t.Error("no config to decode embedding layers into")
+	}
+}
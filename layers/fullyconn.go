@@ -3,10 +3,11 @@ package layers
 import (
 	"fmt"
 
+	"github.com/nathanleary/reticulum/device"
 	"github.com/nathanleary/reticulum/volume"
 )
 
-// WithDecay sets the L1 & L2 decay for the fully conn or conv layer
+// WithDecay sets the L1 & L2 decay for the fully conn, conv, depthwise or embedding layer
 func WithDecay(l1 float64, l2 float64) LayerOptionFunc {
 	return func(lc LayerConfig) error {
 		switch conf := lc.(type) {
@@ -16,6 +17,12 @@ func WithDecay(l1 float64, l2 float64) LayerOptionFunc {
 		case *convLayerConfig:
 			conf.L1DecayMult = l1
 			conf.L2DecayMult = l2
+		case *depthwiseLayerConfig:
+			conf.L1DecayMult = l1
+			conf.L2DecayMult = l2
+		case *embeddingLayerConfig:
+			conf.L1DecayMult = l1
+			conf.L2DecayMult = l2
 		default:
 			return fmt.Errorf("Invalid LayerConfig for FullyConnLayer")
 		}
@@ -31,6 +38,8 @@ func WithBias(bias float64) LayerOptionFunc {
 			conf.PreferredBias = bias
 		case *convLayerConfig:
 			conf.PreferredBias = bias
+		case *depthwiseLayerConfig:
+			conf.PreferredBias = bias
 		default:
 			return fmt.Errorf("Invalid LayerConfig for FullyConnLayer")
 		}
@@ -92,11 +101,11 @@ func NewFullyConnectedLayer(def LayerDef) Layer {
 	bias := conf.PreferredBias
 	var filters []*volume.Volume
 	for i := 0; i < outDepth; i++ {
-		filters = append(filters, volume.NewVolume(volume.Dimensions{X: 1, Y: 1, Z: def.Input.Size()}))
+		filters = append(filters, volume.NewVolume(volume.Dimensions{X: 1, Y: 1, Z: def.Input.Size()}, volume.WithRand(def.Rand)))
 	}
 
 	biases := volume.NewVolume(volume.Dimensions{X: 1, Y: 1, Z: outDepth}, volume.WithInitialValue(bias))
-	return &fullyConnLayer{conf, def.Input, outDim, nil, nil, filters, biases}
+	return &fullyConnLayer{conf, def.Input, outDim, nil, nil, filters, biases, def.computeDevice(), buffers{reuse: def.ReuseBuffers}}
 }
 
 type fullyConnLayer struct {
@@ -109,6 +118,12 @@ type fullyConnLayer struct {
 
 	filters []*volume.Volume
 	biases  *volume.Volume
+
+	// dev computes the products
+	dev device.Device
+
+	// buf holds the output, reused across passes when the network preallocates
+	buf buffers
 }
 
 func (*fullyConnLayer) Type() LayerType {
@@ -117,15 +132,12 @@ func (*fullyConnLayer) Type() LayerType {
 
 func (l *fullyConnLayer) Forward(vol *volume.Volume, training bool) *volume.Volume {
 	l.inVol = vol
-	A := volume.NewVolume(l.output, volume.WithZeros())
+	A := l.buf.output(l.output)
 
+	b := ops(l.dev)
 	w := vol.Weights()
 	for i := 0; i < l.output.Size(); i++ {
-		var a float64
-		wi := l.filters[i].Weights()
-		for d := 0; d < l.input.Size(); d++ {
-			a += w[d] * wi[d]
-		}
+		a := b.Dot(w, l.filters[i].Weights())
 		a += l.biases.GetByIndex(i)
 		A.SetByIndex(i, a)
 	}
@@ -137,14 +149,12 @@ func (l *fullyConnLayer) Forward(vol *volume.Volume, training bool) *volume.Volu
 func (l *fullyConnLayer) Backward() {
 	l.inVol.ZeroGrad()
 
-	numInputs := l.input.Size()
+	b := ops(l.dev)
 	for i := 0; i < l.output.Z; i++ {
 		tfi := l.filters[i]
 		chainGrad := l.outVol.GetGradByIndex(i)
-		for d := 0; d < numInputs; d++ {
-			l.inVol.AddGradByIndex(d, tfi.GetByIndex(d)*chainGrad)
-			tfi.AddGradByIndex(d, l.inVol.GetByIndex(d)*chainGrad)
-		}
+		b.Axpy(chainGrad, tfi.Weights(), l.inVol.Gradients())
+		b.Axpy(chainGrad, l.inVol.Weights(), tfi.Gradients())
 		l.biases.AddGradByIndex(i, chainGrad)
 	}
 }
@@ -164,6 +174,7 @@ func (l *fullyConnLayer) GetResponse() []LayerResponse {
 		Gradients:  l.biases.Gradients(),
 		L1DecayMul: 0.0,
 		L2DecayMul: 0.0,
+		Bias:       true,
 	})
 	return resp
 }
@@ -33,8 +33,8 @@ func (il *inputLayer) Forward(vol *volume.Volume, training bool) *volume.Volume
 	return il.outVol
 }
 
+// Backward is a no-op, there is nothing below the input layer to propagate to.
 func (il *inputLayer) Backward() {
-	panic(fmt.Errorf("Unsupported operation"))
 }
 
 func (il *inputLayer) GetResponse() []LayerResponse {
@@ -1,6 +1,10 @@
 package layers
 
 import (
+	"fmt"
+	"math/rand"
+
+	"github.com/nathanleary/reticulum/device"
 	"github.com/nathanleary/reticulum/volume"
 )
 
@@ -22,11 +26,47 @@ const (
 	Tanh              LayerType = "tanh"
 	Maxout            LayerType = "maxout"
 	SVM               LayerType = "svm"
+	Add               LayerType = "add"
+	Concat            LayerType = "concat"
+	Upsample          LayerType = "upsample"
+	Depthwise         LayerType = "depthwise"
+	PixelSoftMax      LayerType = "pixelsoftmax"
+	Embedding         LayerType = "embedding"
 )
 
 // LayerConfig stores layer specific config
 type LayerConfig interface{}
 
+// NewConfig returns an empty config for layers of type t, to decode a serialized
+// config into, or nil for layers which take no config.
+func NewConfig(t LayerType) LayerConfig {
+	switch t {
+	case FullyConnected:
+		return &fullyConnLayerConfig{}
+	case Conv:
+		return &convLayerConfig{}
+	case Depthwise:
+		return &depthwiseLayerConfig{}
+	case Pool:
+		return &poolLayerConfig{}
+	case Upsample:
+		return &upsampleLayerConfig{}
+	case Embedding:
+		return &embeddingLayerConfig{}
+	case Dropout:
+		return &DropoutLayerConfig{}
+	case Maxout:
+		return &MaxoutLayerConfig{}
+	case SoftMax:
+		return &softMaxLayerConfig{}
+	case SVM:
+		return &svmLayerConfig{}
+	case Regression:
+		return &regressionLayerConfig{}
+	}
+	return nil
+}
+
 // LayerOptionFunc provides for options in LayerConfig
 type LayerOptionFunc func(LayerConfig) error
 
@@ -34,9 +74,19 @@ type LayerOptionFunc func(LayerConfig) error
 type LayerDef struct {
 	Type LayerType
 
+	// Name identifies the layer within the network. Defaults to the type and index, e.g. "conv1".
+	Name string
+
+	// Inputs names the layers feeding this one in a graph network, defaulting to the
+	// previous definition. Merge layers take several inputs, other layers exactly one.
+	Inputs []string
+
 	// Input dimensions
 	Input volume.Dimensions
 
+	// InputDims holds the dimensions of every input of a merge layer, set by the graph.
+	InputDims []volume.Dimensions
+
 	// Output dim
 	Output volume.Dimensions
 
@@ -51,6 +101,27 @@ type LayerDef struct {
 
 	// LayerConfig contains layer specific requirements
 	LayerConfig LayerConfig
+
+	// Rand is the source for weight initialization and dropout masks, defaults to
+	// the global source. Set by the network when it is seeded.
+	Rand *rand.Rand
+
+	// Parallelism bounds the goroutines of the layers which split their work, such
+	// as conv and pool, with 0 or 1 running on the calling goroutine. Set by the
+	// network.
+	Parallelism int
+
+	// ReuseBuffers makes the layer allocate its output and scratch buffers once and
+	// reuse them on every pass, overwriting its previous output. Set by the network.
+	ReuseBuffers bool
+
+	// ProfileLabels labels the CPU profile samples of the layer's passes with pprof
+	// labels. Set and applied by the network.
+	ProfileLabels bool
+
+	// Device runs the products and activations of the layer, the CPU when nil. Set
+	// by the network.
+	Device device.Device
 }
 
 // Layer represents a layer in the neural network.
@@ -74,12 +145,51 @@ type RegressionLossLayer interface {
 	DimensionalLoss(index int, value float64) float64
 }
 
+// SoftTargetLossLayer extends the LossLayer interface with a loss towards a
+// probability distribution over the classes rather than a single class, with the
+// inputs divided by a temperature as in knowledge distillation.
+type SoftTargetLossLayer interface {
+	LossLayer
+	SoftTargetLoss(target []float64, temperature float64) float64
+}
+
+// PolicyLossLayer extends the LossLayer interface with the policy gradient loss of a
+// softmax policy, for reinforcement learning: the log-probability of the action taken
+// scaled by its advantage, with a bonus for the entropy of the policy.
+type PolicyLossLayer interface {
+	LossLayer
+	PolicyLoss(action int, advantage, entropy float64) float64
+}
+
+// DenseLossLayer extends the Layer interface with a loss over a label map holding a
+// class for every position of the input, for segmentation and other dense
+// prediction networks.
+type DenseLossLayer interface {
+	Layer
+	DenseLoss(labels []int) float64
+}
+
+// FilterLayer extends the Layer interface with the spatial filters of layers such as
+// conv, to inspect or visualize their weights. The filters are not copies.
+type FilterLayer interface {
+	Layer
+	Filters() []*volume.Volume
+}
+
 // LayerResponse represents the layer parameters (weights) and gradients.
 type LayerResponse struct {
 	Weights    []float64
 	Gradients  []float64
 	L1DecayMul float64
 	L2DecayMul float64
+
+	// Bias is set when the response holds the layer biases rather than a filter.
+	Bias bool
+
+	// LayerIndex and LayerName identify the layer which owns the parameters.
+	// These are populated by the network.
+	LayerIndex int
+	LayerName  string
 }
 
 // ActivateLayers adds activation, dropout layers, etc.
@@ -94,11 +204,13 @@ func ActivateLayers(defs []LayerDef) []LayerDef {
 			case *softMaxLayerConfig:
 				newDefs = append(newDefs, LayerDef{
 					Type:        FullyConnected,
+					Output:      volume.NewDimensions(1, 1, conf.Classes),
 					LayerConfig: NewFullyConnectedLayerConfig(conf.Classes),
 				})
 			case *svmLayerConfig:
 				newDefs = append(newDefs, LayerDef{
 					Type:        FullyConnected,
+					Output:      volume.NewDimensions(1, 1, conf.Classes),
 					LayerConfig: NewFullyConnectedLayerConfig(conf.Classes),
 				})
 			default:
@@ -115,12 +227,13 @@ func ActivateLayers(defs []LayerDef) []LayerDef {
 			}
 			newDefs = append(newDefs, LayerDef{
 				Type:        FullyConnected,
+				Output:      volume.NewDimensions(1, 1, conf.Neurons),
 				LayerConfig: NewFullyConnectedLayerConfig(conf.Neurons),
 			})
 		}
 
 		// Update bias
-		if def.Type == FullyConnected || def.Type == Conv {
+		if def.Type == FullyConnected || def.Type == Conv || def.Type == Depthwise {
 			// ReLUs like a bit of positive bias to get gradients early
 			// otherwise it's technically possible that a relu unit will never turn on (by chance)
 			// and will never get any gradient and never contribute any computation. Dead relu.
@@ -130,6 +243,8 @@ func ActivateLayers(defs []LayerDef) []LayerDef {
 					conf.PreferredBias = 0.1
 				case *convLayerConfig:
 					conf.PreferredBias = 0.1
+				case *depthwiseLayerConfig:
+					conf.PreferredBias = 0.1
 				default:
 				}
 			}
@@ -142,11 +257,11 @@ func ActivateLayers(defs []LayerDef) []LayerDef {
 		if def.Activation != "" {
 			switch def.Activation {
 			case ReLU:
-				newDefs = append(newDefs, LayerDef{Type: ReLU})
+				newDefs = append(newDefs, LayerDef{Type: ReLU, Output: def.Output})
 			case Sigmoid:
-				newDefs = append(newDefs, LayerDef{Type: Sigmoid})
+				newDefs = append(newDefs, LayerDef{Type: Sigmoid, Output: def.Output})
 			case Tanh:
-				newDefs = append(newDefs, LayerDef{Type: Tanh})
+				newDefs = append(newDefs, LayerDef{Type: Tanh, Output: def.Output})
 			case Maxout:
 				groupSize := 2
 				if def.Maxout != nil {
@@ -167,9 +282,176 @@ func ActivateLayers(defs []LayerDef) []LayerDef {
 		if def.Dropout != nil {
 			newDefs = append(newDefs, LayerDef{
 				Type:        Dropout,
+				Output:      newDefs[len(newDefs)-1].Output,
 				LayerConfig: def.Dropout,
 			})
 		}
 	}
 	return newDefs
 }
+
+// OutputDimensions computes the output dimensions of the layer described by def from
+// its input dimensions and config. Activation, dropout and maxout layers added by
+// ActivateLayers are not included.
+func OutputDimensions(def LayerDef) (volume.Dimensions, error) {
+	in := def.Input
+	switch def.Type {
+	case Input:
+		return def.Output, nil
+	case FullyConnected:
+		conf, ok := def.LayerConfig.(*fullyConnLayerConfig)
+		if !ok {
+			return volume.Dimensions{}, fmt.Errorf("invalid LayerConfig for fc layer")
+		}
+		return volume.NewDimensions(1, 1, conf.Neurons), nil
+	case Conv:
+		conf, ok := def.LayerConfig.(*convLayerConfig)
+		if !ok {
+			return volume.Dimensions{}, fmt.Errorf("invalid LayerConfig for conv layer")
+		}
+		return windowOutput(in, conf.Sx, conf.Sy, conf.Stride, conf.Padding, conf.FilterCount)
+	case Depthwise:
+		conf, ok := def.LayerConfig.(*depthwiseLayerConfig)
+		if !ok {
+			return volume.Dimensions{}, fmt.Errorf("invalid LayerConfig for depthwise layer")
+		}
+		return windowOutput(in, conf.Sx, conf.Sy, conf.Stride, conf.Padding, in.Z)
+	case Pool:
+		conf, ok := def.LayerConfig.(*poolLayerConfig)
+		if !ok {
+			return volume.Dimensions{}, fmt.Errorf("invalid LayerConfig for pool layer")
+		}
+		return windowOutput(in, conf.Sx, conf.Sy, conf.Stride, conf.Padding, in.Z)
+	case Upsample:
+		conf, ok := def.LayerConfig.(*upsampleLayerConfig)
+		if !ok || conf.Factor <= 0 {
+			return volume.Dimensions{}, fmt.Errorf("invalid LayerConfig for upsample layer")
+		}
+		return volume.NewDimensions(in.X*conf.Factor, in.Y*conf.Factor, in.Z), nil
+	case Embedding:
+		conf, ok := def.LayerConfig.(*embeddingLayerConfig)
+		if !ok || conf.Dimensions <= 0 {
+			return volume.Dimensions{}, fmt.Errorf("invalid LayerConfig for embedding layer")
+		}
+		return volume.NewDimensions(in.Size(), 1, conf.Dimensions), nil
+	case ReLU, Sigmoid, Tanh, Dropout, PixelSoftMax:
+		return in, nil
+	case Maxout:
+		conf, ok := def.LayerConfig.(*MaxoutLayerConfig)
+		if !ok || conf.GroupSize <= 0 {
+			return volume.Dimensions{}, fmt.Errorf("invalid LayerConfig for maxout layer")
+		}
+		return volume.NewDimensions(in.X, in.Y, in.Z/conf.GroupSize), nil
+	case SoftMax, SVM, Regression:
+		// ActivateLayers feeds these from an fc layer sized by their config
+		switch conf := def.LayerConfig.(type) {
+		case *softMaxLayerConfig:
+			return volume.NewDimensions(1, 1, conf.Classes), nil
+		case *svmLayerConfig:
+			return volume.NewDimensions(1, 1, conf.Classes), nil
+		case *regressionLayerConfig:
+			return volume.NewDimensions(1, 1, conf.Neurons), nil
+		}
+		return volume.NewDimensions(1, 1, in.Size()), nil
+	case Add, Concat:
+		return mergeOutput(def)
+	}
+	return volume.Dimensions{}, fmt.Errorf("unsupported layer type: %s", def.Type)
+}
+
+// InferOutput computes the output dimensions of def from its input, which is what the
+// layer will produce regardless of the declared Output. A declared Output of another
+// size is an error describing how the output is computed, while one of the same size
+// but another shape is corrected. An empty Output is filled in.
+func InferOutput(def LayerDef) (volume.Dimensions, error) {
+	if def.Type == Input {
+		if def.Output.Size() == 0 {
+			return volume.Dimensions{}, fmt.Errorf("input layer must declare its output dimensions")
+		}
+		return def.Output, nil
+	}
+
+	out, err := OutputDimensions(def)
+	if err != nil {
+		return volume.Dimensions{}, err
+	} else if out.Size() == 0 {
+		return volume.Dimensions{}, fmt.Errorf("input %s%s yields an empty output", formatDims(def.Input), shapeDetail(def))
+	} else if declared := def.Output; declared.Size() != 0 && declared.Size() != out.Size() {
+		return volume.Dimensions{}, fmt.Errorf("input %s%s yields %s, but the output is declared as %s",
+			formatDims(def.Input), shapeDetail(def), formatDims(out), formatDims(declared))
+	}
+	return out, nil
+}
+
+// shapeDetail describes the config determining the output dimensions of def.
+func shapeDetail(def LayerDef) string {
+	window := func(sx, sy int) string {
+		if sy <= 0 {
+			sy = sx
+		}
+		return fmt.Sprintf("%dx%d", sx, sy)
+	}
+	switch conf := def.LayerConfig.(type) {
+	case *convLayerConfig:
+		return fmt.Sprintf(" with %d %s filters stride %d pad %d", conf.FilterCount, window(conf.Sx, conf.Sy), conf.Stride, conf.Padding)
+	case *depthwiseLayerConfig:
+		return fmt.Sprintf(" with %s depthwise filters stride %d pad %d", window(conf.Sx, conf.Sy), conf.Stride, conf.Padding)
+	case *poolLayerConfig:
+		return fmt.Sprintf(" with %s pooling stride %d pad %d", window(conf.Sx, conf.Sy), conf.Stride, conf.Padding)
+	case *fullyConnLayerConfig:
+		return fmt.Sprintf(" with %d neurons", conf.Neurons)
+	case *MaxoutLayerConfig:
+		return fmt.Sprintf(" with groups of %d", conf.GroupSize)
+	case *upsampleLayerConfig:
+		return fmt.Sprintf(" upsampled by %d", conf.Factor)
+	case *embeddingLayerConfig:
+		return fmt.Sprintf(" embedded in %d dimensions", conf.Dimensions)
+	}
+	return ""
+}
+
+func formatDims(d volume.Dimensions) string {
+	return fmt.Sprintf("%dx%dx%d", d.X, d.Y, d.Z)
+}
+
+// Window is the sliding window of a conv or pool layer.
+type Window struct {
+	Sx, Sy  int
+	Stride  int
+	Padding int
+}
+
+// WindowOf returns the window of a conv, depthwise or pool layer definition.
+func WindowOf(def LayerDef) (Window, bool) {
+	var w Window
+	switch conf := def.LayerConfig.(type) {
+	case *convLayerConfig:
+		w = Window{conf.Sx, conf.Sy, conf.Stride, conf.Padding}
+	case *depthwiseLayerConfig:
+		w = Window{conf.Sx, conf.Sy, conf.Stride, conf.Padding}
+	case *poolLayerConfig:
+		w = Window{conf.Sx, conf.Sy, conf.Stride, conf.Padding}
+	default:
+		return Window{}, false
+	}
+	if w.Sy <= 0 {
+		w.Sy = w.Sx
+	}
+	return w, true
+}
+
+// windowOutput computes the output dimensions of a sliding window over the input.
+func windowOutput(in volume.Dimensions, sx, sy, stride, pad, depth int) (volume.Dimensions, error) {
+	if sy <= 0 {
+		sy = sx
+	}
+	if sx <= 0 || stride <= 0 {
+		return volume.Dimensions{}, fmt.Errorf("window size and stride must be greater than 0")
+	}
+	x := (in.X+pad*2-sx)/stride + 1
+	y := (in.Y+pad*2-sy)/stride + 1
+	if in.X+pad*2 < sx || in.Y+pad*2 < sy {
+		return volume.Dimensions{}, fmt.Errorf("window %dx%d does not fit the %dx%d input", sx, sy, in.X, in.Y)
+	}
+	return volume.NewDimensions(x, y, depth), nil
+}
@@ -30,7 +30,7 @@ func NewMaxoutLayer(def LayerDef) Layer {
 		panic(fmt.Errorf("Group size cannot be  <= 0 for maxout layer"))
 	}
 
-	return &maxoutLayer{conf, def.Output, nil, nil, make([]int, def.Output.Size())}
+	return &maxoutLayer{conf, def.Output, nil, nil, make([]int, def.Output.Size()), buffers{reuse: def.ReuseBuffers}}
 }
 
 type maxoutLayer struct {
@@ -41,6 +41,9 @@ type maxoutLayer struct {
 	outVol *volume.Volume
 
 	switches []int
+
+	// buf holds the output, reused across passes when the network preallocates
+	buf buffers
 }
 
 func (l *maxoutLayer) Type() LayerType {
@@ -50,7 +53,7 @@ func (l *maxoutLayer) Type() LayerType {
 func (l *maxoutLayer) Forward(vol *volume.Volume, training bool) *volume.Volume {
 
 	l.inVol = vol
-	v2 := l.outVol.CloneAndZero()
+	v2 := l.buf.output(l.output)
 	n := l.output.Z
 
 	// optimization branch. If we're operating on 1D arrays we dont have
@@ -0,0 +1,150 @@
+package layers
+
+import (
+	"fmt"
+
+	"github.com/nathanleary/reticulum/volume"
+)
+
+// MergeLayer combines the outputs of several layers in a graph network.
+type MergeLayer interface {
+	Layer
+	ForwardMulti(vols []*volume.Volume, training bool) *volume.Volume
+}
+
+// NewAddLayer creates a layer which sums its inputs elementwise, e.g. for residual
+// connections. All inputs must have the same dimensions.
+func NewAddLayer(def LayerDef) Layer {
+	if def.Type != Add {
+		panic(fmt.Errorf("Invalid layer type: %s != add", def.Type))
+	}
+	out, err := mergeOutput(def)
+	if err != nil {
+		panic(err)
+	}
+	return &addLayer{output: out}
+}
+
+// NewConcatLayer creates a layer which stacks its inputs along the depth. All inputs
+// must have the same width and height.
+func NewConcatLayer(def LayerDef) Layer {
+	if def.Type != Concat {
+		panic(fmt.Errorf("Invalid layer type: %s != concat", def.Type))
+	}
+	out, err := mergeOutput(def)
+	if err != nil {
+		panic(err)
+	}
+	return &concatLayer{output: out}
+}
+
+// mergeOutput computes the output dimensions of a merge layer from its inputs.
+func mergeOutput(def LayerDef) (volume.Dimensions, error) {
+	if len(def.InputDims) == 0 {
+		return volume.Dimensions{}, fmt.Errorf("%s layer requires at least one input", def.Type)
+	}
+
+	out := def.InputDims[0]
+	for _, dim := range def.InputDims[1:] {
+		switch def.Type {
+		case Add:
+			if dim != out {
+				return volume.Dimensions{}, fmt.Errorf("add layer inputs differ: %v != %v", dim, out)
+			}
+		case Concat:
+			if dim.X != out.X || dim.Y != out.Y {
+				return volume.Dimensions{}, fmt.Errorf("concat layer inputs differ in size: %dx%d != %dx%d", dim.X, dim.Y, out.X, out.Y)
+			}
+			out.Z += dim.Z
+		}
+	}
+	return out, nil
+}
+
+type addLayer struct {
+	output volume.Dimensions
+
+	inVols []*volume.Volume
+	outVol *volume.Volume
+}
+
+func (*addLayer) Type() LayerType {
+	return Add
+}
+
+func (l *addLayer) Forward(vol *volume.Volume, training bool) *volume.Volume {
+	return l.ForwardMulti([]*volume.Volume{vol}, training)
+}
+
+func (l *addLayer) ForwardMulti(vols []*volume.Volume, training bool) *volume.Volume {
+	l.inVols = vols
+	l.outVol = volume.NewVolume(l.output, volume.WithZeros())
+	for _, vol := range vols {
+		l.outVol.AddFrom(vol)
+	}
+	return l.outVol
+}
+
+func (l *addLayer) Backward() {
+	// the gradient flows unchanged into every input
+	for _, vol := range l.inVols {
+		copy(vol.Gradients(), l.outVol.Gradients())
+	}
+}
+
+func (l *addLayer) GetResponse() []LayerResponse {
+	return []LayerResponse{}
+}
+
+type concatLayer struct {
+	output volume.Dimensions
+
+	inVols []*volume.Volume
+	outVol *volume.Volume
+}
+
+func (*concatLayer) Type() LayerType {
+	return Concat
+}
+
+func (l *concatLayer) Forward(vol *volume.Volume, training bool) *volume.Volume {
+	return l.ForwardMulti([]*volume.Volume{vol}, training)
+}
+
+func (l *concatLayer) ForwardMulti(vols []*volume.Volume, training bool) *volume.Volume {
+	l.inVols = vols
+	l.outVol = volume.NewVolume(l.output, volume.WithZeros())
+
+	var offset int
+	for _, vol := range vols {
+		dim := vol.Dimensions()
+		for x := 0; x < dim.X; x++ {
+			for y := 0; y < dim.Y; y++ {
+				for d := 0; d < dim.Z; d++ {
+					l.outVol.Set(x, y, offset+d, vol.Get(x, y, d))
+				}
+			}
+		}
+		offset += dim.Z
+	}
+	return l.outVol
+}
+
+func (l *concatLayer) Backward() {
+	var offset int
+	for _, vol := range l.inVols {
+		dim := vol.Dimensions()
+		for x := 0; x < dim.X; x++ {
+			for y := 0; y < dim.Y; y++ {
+				for d := 0; d < dim.Z; d++ {
+					vol.SetGrad(x, y, d, l.outVol.GetGrad(x, y, offset+d))
+				}
+			}
+		}
+		offset += dim.Z
+	}
+}
+
+func (l *concatLayer) GetResponse() []LayerResponse {
+	return []LayerResponse{}
+}
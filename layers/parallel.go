@@ -0,0 +1,45 @@
+package layers
+
+import (
+	"sync"
+)
+
+// parallelFor splits the tasks [0, n) into contiguous chunks, one per worker up to
+// workers, and runs fn on every chunk in its own goroutine with the index of the
+// worker. The chunks are fixed, so reductions over per-worker buffers are
+// deterministic. With a single worker fn runs on the calling goroutine, and panics
+// are surfaced on it either way.
+func parallelFor(workers, n int, fn func(worker, start, end int)) {
+	workers = min(workers, n)
+	if workers <= 1 {
+		fn(0, 0, n)
+		return
+	}
+
+	chunk := (n + workers - 1) / workers
+	var wg sync.WaitGroup
+	var panicked any
+	var once sync.Once
+	for w := 0; w < workers; w++ {
+		start, end := w*chunk, min((w+1)*chunk, n)
+		if start >= end {
+			break
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() {
+				if r := recover(); r != nil {
+					once.Do(func() { panicked = r })
+				}
+			}()
+			fn(w, start, end)
+		}()
+	}
+	wg.Wait()
+
+	if panicked != nil {
+		panic(panicked)
+	}
+}
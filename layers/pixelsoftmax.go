@@ -0,0 +1,116 @@
+package layers
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/nathanleary/reticulum/volume"
+)
+
+// NewPixelSoftmaxLayer creates a new pixel-wise softmax layer.
+// This is a dense classifier for fully-convolutional networks: it takes a
+// volume of class scores along the depth at every position and computes the
+// softmax over the depth separately at each position, so the output holds the
+// class probabilities of every pixel. Unlike the softmax layer it takes no
+// config and has no fc layer added in front of it; the depth of its input is
+// the number of classes.
+func NewPixelSoftmaxLayer(def LayerDef) Layer {
+	if def.Type != PixelSoftMax {
+		panic(fmt.Errorf("invalid layer type: %s != pixelsoftmax", def.Type))
+	} else if def.Input.Z == 0 {
+		panic(fmt.Errorf("input depth cannot be 0 for pixelsoftmax layer"))
+	}
+
+	return &pixelSoftmaxLayer{dim: def.Input}
+}
+
+type pixelSoftmaxLayer struct {
+	dim volume.Dimensions
+
+	inVol  *volume.Volume
+	outVol *volume.Volume
+}
+
+func (l *pixelSoftmaxLayer) Type() LayerType {
+	return PixelSoftMax
+}
+
+func (l *pixelSoftmaxLayer) Forward(vol *volume.Volume, training bool) *volume.Volume {
+	l.inVol = vol
+
+	out := volume.NewVolume(l.dim, volume.WithZeros())
+	as, ps := vol.Weights(), out.Weights()
+	n := l.dim.Z
+	for i := 0; i < l.dim.X*l.dim.Y; i++ {
+		a, p := as[i*n:(i+1)*n], ps[i*n:(i+1)*n]
+
+		// compute exponentials of the scores of this position, carefully to not blow up
+		aMax := a[0]
+		for _, v := range a {
+			aMax = math.Max(aMax, v)
+		}
+		esum := 0.0
+		for k, v := range a {
+			p[k] = math.Exp(v - aMax)
+			esum += p[k]
+		}
+		for k := range p {
+			p[k] /= esum
+		}
+	}
+
+	l.outVol = out
+	return l.outVol
+}
+
+// DenseLoss computes the mean negative log likelihood of the labels over the positions
+// of the last forward pass and sets the input gradients. labels holds the class of
+// every position x, y at index y*width + x; positions with a negative label, e.g.
+// unlabeled borders, add no loss or gradient.
+func (l *pixelSoftmaxLayer) DenseLoss(labels []int) float64 {
+	positions := l.dim.X * l.dim.Y
+	if len(labels) != positions {
+		panic(fmt.Errorf("label map has %d positions, expected %d", len(labels), positions))
+	}
+
+	l.inVol.ZeroGrad()
+
+	var count int
+	for _, label := range labels {
+		if label >= l.dim.Z {
+			panic(fmt.Errorf("Invalid label: %d", label))
+		} else if label >= 0 {
+			count++
+		}
+	}
+	if count == 0 {
+		return 0
+	}
+
+	// average over the labeled positions, so the loss doesn't grow with the image size
+	n := l.dim.Z
+	ps, gs := l.outVol.Weights(), l.inVol.Gradients()
+	loss := 0.0
+	for i, label := range labels {
+		if label < 0 {
+			continue
+		}
+		for k := 0; k < n; k++ {
+			indicator := 0.0
+			if k == label {
+				indicator = 1.0
+			}
+			gs[i*n+k] = -(indicator - ps[i*n+k]) / float64(count)
+		}
+		loss -= math.Log(ps[i*n+label])
+	}
+	return loss / float64(count)
+}
+
+func (l *pixelSoftmaxLayer) Backward() {
+	panic(fmt.Errorf("Unsupported operation"))
+}
+
+func (l *pixelSoftmaxLayer) GetResponse() []LayerResponse {
+	return []LayerResponse{}
+}
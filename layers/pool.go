@@ -64,7 +64,7 @@ func NewPoolLayer(def LayerDef) Layer {
 	outSy := math.Floor((float64(def.Input.Y)+float64(conf.Padding)*2.0-float64(conf.Sy))/float64(conf.Stride) + 1)
 	outDim := volume.NewDimensions(int(outSx), int(outSy), outDepth)
 
-	return &poolLayer{conf, def.Input, outDim, nil, nil, make([]int, outDim.Size()), make([]int, outDim.Size())}
+	return &poolLayer{conf, def.Input, outDim, nil, nil, make([]int, outDim.Size()), make([]int, outDim.Size()), def.Parallelism, buffers{reuse: def.ReuseBuffers}}
 }
 
 type poolLayer struct {
@@ -77,6 +77,12 @@ type poolLayer struct {
 
 	switchX []int
 	switchY []int
+
+	// parallelism bounds the goroutines pooling the channels
+	parallelism int
+
+	// buf holds the output, reused across passes when the network preallocates
+	buf buffers
 }
 
 func (*poolLayer) Type() LayerType {
@@ -85,66 +91,69 @@ func (*poolLayer) Type() LayerType {
 
 func (l *poolLayer) Forward(vol *volume.Volume, training bool) *volume.Volume {
 	l.inVol = vol
-	A := volume.NewVolume(l.output, volume.WithZeros())
-
-	var n int
-	for d := 0; d < l.output.Z; d++ {
-		x := -l.conf.Padding
-		for ax := 0; ax < l.output.X; ax++ {
-			x += l.conf.Stride
-			y := -l.conf.Padding
-			for ay := 0; ay < l.output.Y; ay++ {
-				y += l.conf.Stride
-
-				// convolve centered at this particular location
-				a := -1e5
-				winX, winY := -1, -1
-				for fx := 0; fx < l.conf.Sx; fx++ {
-					for fy := 0; fy < l.conf.Sy; fy++ {
-						oy := y + fy
-						ox := x + fx
-						if oy >= 0 && oy < l.input.Y && ox >= 0 && ox < l.input.X {
-							v := l.inVol.Get(ox, oy, d)
-							// perform max pooling and store pointers to where
-							// the max came from. This will speed up backprop
-							// and can help make nice visualizations in future
-							if v > a {
-								a = v
-								winX = ox
-								winY = oy
-							}
-						}
+	A := l.buf.output(l.output)
+
+	// every output column of every channel is pooled independently
+	parallelFor(l.parallelism, l.output.Z*l.output.X, func(_, start, end int) {
+		for col := start; col < end; col++ {
+			l.forwardColumn(A, col/l.output.X, col%l.output.X)
+		}
+	})
+
+	l.outVol = A
+	return l.outVol
+}
+
+// forwardColumn pools the output column ax of channel d.
+func (l *poolLayer) forwardColumn(A *volume.Volume, d, ax int) {
+	n := (d*l.output.X + ax) * l.output.Y
+	x := -l.conf.Padding + ax*l.conf.Stride
+	y := -l.conf.Padding
+	for ay := 0; ay < l.output.Y; ay, y = ay+1, y+l.conf.Stride {
+
+		// convolve centered at this particular location
+		a := -1e5
+		winX, winY := -1, -1
+		for fx := 0; fx < l.conf.Sx; fx++ {
+			for fy := 0; fy < l.conf.Sy; fy++ {
+				oy := y + fy
+				ox := x + fx
+				if oy >= 0 && oy < l.input.Y && ox >= 0 && ox < l.input.X {
+					v := l.inVol.Get(ox, oy, d)
+					// perform max pooling and store pointers to where
+					// the max came from. This will speed up backprop
+					// and can help make nice visualizations in future
+					if v > a {
+						a = v
+						winX = ox
+						winY = oy
 					}
 				}
-				l.switchX[n] = winX
-				l.switchY[n] = winY
-				n++
-				A.Set(ax, ay, d, a)
 			}
 		}
+		l.switchX[n] = winX
+		l.switchY[n] = winY
+		n++
+		A.Set(ax, ay, d, a)
 	}
-
-	l.outVol = A
-	return l.outVol
 }
 
 func (l *poolLayer) Backward() {
 	l.inVol.ZeroGrad()
 
-	var n int
-	for d := 0; d < l.output.Z; d++ {
-		x := -l.conf.Padding
-		for ax := 0; ax < l.output.X; ax++ {
-			x += l.conf.Stride
-			y := -l.conf.Padding
-			for ay := 0; ay < l.output.Y; ay++ {
-				y += l.conf.Stride
-				chainGrad := l.outVol.GetGrad(ax, ay, d)
-				l.inVol.AddGrad(l.switchX[n], l.switchY[n], d, chainGrad)
-				n++
+	// windows overlap within a channel but never across channels
+	parallelFor(l.parallelism, l.output.Z, func(_, start, end int) {
+		for d := start; d < end; d++ {
+			n := d * l.output.X * l.output.Y
+			for ax := 0; ax < l.output.X; ax++ {
+				for ay := 0; ay < l.output.Y; ay++ {
+					chainGrad := l.outVol.GetGrad(ax, ay, d)
+					l.inVol.AddGrad(l.switchX[n], l.switchY[n], d, chainGrad)
+					n++
+				}
 			}
 		}
-	}
+	})
 }
 
 func (l *poolLayer) GetResponse() []LayerResponse {
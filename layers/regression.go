@@ -19,7 +19,7 @@ func NewRegressionLayer(def LayerDef) Layer {
 	}
 
 	n := def.Input.Size()
-	return &regressionLayer{conf, def.Input, volume.NewDimensions(1, 1, n), nil, nil}
+	return &regressionLayer{conf, def.Input, volume.NewDimensions(1, 1, n), nil, nil, def.ReuseBuffers}
 }
 
 // NewRegressionLayerConfig creates a new LayerConfig config with the given options.
@@ -45,6 +45,15 @@ type regressionLayerConfig struct {
 	Neurons int
 }
 
+// GetRegressionOutput returns a copy of the values predicted by the regression layer.
+func GetRegressionOutput(layer Layer) []float64 {
+	regression, ok := layer.(*regressionLayer)
+	if !ok {
+		panic("expected Regression layer")
+	}
+	return append([]float64(nil), regression.outVol.Weights()...)
+}
+
 type regressionLayer struct {
 	conf   *regressionLayerConfig
 	inDim  volume.Dimensions
@@ -52,6 +61,10 @@ type regressionLayer struct {
 
 	inVol  *volume.Volume
 	outVol *volume.Volume
+
+	// copyOut makes Forward return a copy of its input, which is the reused buffer
+	// of the previous layer when the network preallocates buffers
+	copyOut bool
 }
 
 func (l *regressionLayer) Type() LayerType {
@@ -61,7 +74,10 @@ func (l *regressionLayer) Type() LayerType {
 func (l *regressionLayer) Forward(vol *volume.Volume, training bool) *volume.Volume {
 	l.inVol = vol
 	l.outVol = vol
-	return vol
+	if l.copyOut {
+		l.outVol = vol.Clone()
+	}
+	return l.outVol
 }
 
 func (l *regressionLayer) MultiDimensionalLoss(y []float64) float64 {
@@ -3,6 +3,7 @@ package layers
 import (
 	"fmt"
 
+	"github.com/nathanleary/reticulum/device"
 	"github.com/nathanleary/reticulum/volume"
 )
 
@@ -13,7 +14,7 @@ func NewReluLayer(def LayerDef) Layer {
 	} else if def.Output.Z == 0 {
 		panic(fmt.Errorf("Output depth cannot be 0 for relu layer"))
 	}
-	return &reluLayer{def.Output, nil, nil}
+	return &reluLayer{def.Output, nil, nil, def.computeDevice(), buffers{reuse: def.ReuseBuffers}}
 }
 
 type reluLayer struct {
@@ -21,6 +22,12 @@ type reluLayer struct {
 
 	inVol  *volume.Volume
 	outVol *volume.Volume
+
+	// dev computes the activation
+	dev device.Device
+
+	// buf holds the output, reused across passes when the network preallocates
+	buf buffers
 }
 
 func (*reluLayer) Type() LayerType {
@@ -29,33 +36,20 @@ func (*reluLayer) Type() LayerType {
 
 func (l *reluLayer) Forward(vol *volume.Volume, training bool) *volume.Volume {
 	l.inVol = vol
-	v2 := vol.Clone()
+	v2 := l.buf.output(vol.Dimensions())
 
 	// Rectify to zero
-	n := vol.Size()
-	for i := 0; i < n; i++ {
-		if v2.GetByIndex(i) < 0 {
-			v2.SetByIndex(i, 0)
-		}
-	}
+	apply(l.dev, device.ReLU, vol.Weights(), v2.Weights())
 
 	l.outVol = v2
 	return l.outVol
 }
 
 func (l *reluLayer) Backward() {
-	n := l.inVol.Size()
 	l.inVol.ZeroGrad()
 
 	// Set the gradient of the input if the output is below threshold (0)
-	for i := 0; i < n; i++ {
-		// Threshold
-		if l.outVol.GetByIndex(i) <= 0 {
-			l.inVol.SetGradByIndex(i, 0)
-		} else {
-			l.inVol.SetGradByIndex(i, l.outVol.GetGradByIndex(i))
-		}
-	}
+	applyGrad(l.dev, device.ReLU, l.outVol.Weights(), l.outVol.Gradients(), l.inVol.Gradients())
 }
 
 func (*reluLayer) GetResponse() []LayerResponse {
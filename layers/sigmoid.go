@@ -2,8 +2,8 @@ package layers
 
 import (
 	"fmt"
-	"math"
 
+	"github.com/nathanleary/reticulum/device"
 	"github.com/nathanleary/reticulum/volume"
 )
 
@@ -14,7 +14,7 @@ func NewSigmoidLayer(def LayerDef) Layer {
 	} else if def.Output.Z == 0 {
 		panic(fmt.Errorf("Output depth cannot be 0 for sigmoid layer"))
 	}
-	return &sigmoidLayer{def.Output, nil, nil}
+	return &sigmoidLayer{def.Output, nil, nil, def.computeDevice(), buffers{reuse: def.ReuseBuffers}}
 }
 
 type sigmoidLayer struct {
@@ -22,6 +22,12 @@ type sigmoidLayer struct {
 
 	inVol  *volume.Volume
 	outVol *volume.Volume
+
+	// dev computes the activation
+	dev device.Device
+
+	// buf holds the output, reused across passes when the network preallocates
+	buf buffers
 }
 
 func (*sigmoidLayer) Type() LayerType {
@@ -30,26 +36,18 @@ func (*sigmoidLayer) Type() LayerType {
 
 func (l *sigmoidLayer) Forward(vol *volume.Volume, training bool) *volume.Volume {
 	l.inVol = vol
-	v2 := vol.CloneAndZero()
+	v2 := l.buf.output(vol.Dimensions())
 
 	// Rectify to zero
-	n := vol.Size()
-	for i := 0; i < n; i++ {
-		v2.SetByIndex(i, 1.0/(1.0+math.Exp(-vol.GetByIndex(i))))
-	}
+	apply(l.dev, device.Sigmoid, vol.Weights(), v2.Weights())
 
 	l.outVol = v2
 	return l.outVol
 }
 
 func (l *sigmoidLayer) Backward() {
-	n := l.inVol.Size()
 	l.inVol.ZeroGrad()
-
-	for i := 0; i < n; i++ {
-		v2wi := l.outVol.GetByIndex(i)
-		l.inVol.SetGradByIndex(i, v2wi*(1-v2wi)*l.outVol.GetGradByIndex(i))
-	}
+	applyGrad(l.dev, device.Sigmoid, l.outVol.Weights(), l.outVol.Gradients(), l.inVol.Gradients())
 }
 
 func (*sigmoidLayer) GetResponse() []LayerResponse {
@@ -152,6 +152,76 @@ func (l *softmaxLayer) Loss(index int) float64 {
 	return -math.Log(l.es[index])
 }
 
+// SoftTargetLoss computes the cross-entropy between the target distribution and the
+// softmax of the inputs divided by temperature. The input gradients are
+// (p - target) / temperature, so they shrink with the square of the temperature
+// relative to the loss at temperature 1.
+func (l *softmaxLayer) SoftTargetLoss(target []float64, temperature float64) float64 {
+	n := l.outDim.Z
+	if len(target) != n {
+		panic(fmt.Errorf("target has %d classes, expected %d", len(target), n))
+	} else if temperature <= 0 {
+		panic(fmt.Errorf("temperature must be greater than 0, got %v", temperature))
+	}
+
+	as := l.inVol.Weights()
+	aMax := as[0]
+	for i := 0; i < n; i++ {
+		if as[i] > aMax {
+			aMax = as[i]
+		}
+	}
+	ps := make([]float64, n)
+	esum := 0.0
+	for i := 0; i < n; i++ {
+		ps[i] = math.Exp((as[i] - aMax) / temperature)
+		esum += ps[i]
+	}
+
+	l.inVol.ZeroGrad()
+	loss := 0.0
+	for i := 0; i < n; i++ {
+		ps[i] /= esum
+		l.inVol.SetGradByIndex(i, (ps[i]-target[i])/temperature)
+		if target[i] > 0 {
+			loss -= target[i] * math.Log(ps[i])
+		}
+	}
+	return loss
+}
+
+// PolicyLoss computes the policy gradient loss -advantage * log p(action) - entropy * H
+// of a softmax policy over the actions, where H is the entropy of the probabilities, so
+// a positive entropy weight rewards exploration. The input gradients are
+// advantage * (p - onehot(action)) + entropy * p * (log p + H).
+func (l *softmaxLayer) PolicyLoss(action int, advantage, entropy float64) float64 {
+	n := l.outDim.Z
+	if action < 0 || action >= n {
+		panic(fmt.Errorf("Invalid action: %d", action))
+	}
+
+	h := 0.0
+	for _, p := range l.es {
+		if p > 0 {
+			h -= p * math.Log(p)
+		}
+	}
+
+	l.inVol.ZeroGrad()
+	for i := 0; i < n; i++ {
+		indicator := 0.0
+		if i == action {
+			indicator = 1.0
+		}
+		g := advantage * (l.es[i] - indicator)
+		if l.es[i] > 0 {
+			g += entropy * l.es[i] * (math.Log(l.es[i]) + h)
+		}
+		l.inVol.SetGradByIndex(i, g)
+	}
+	return -advantage*math.Log(l.es[action]) - entropy*h
+}
+
 func (l *softmaxLayer) Backward() {
 	panic(fmt.Errorf("Unsupported operation"))
 }
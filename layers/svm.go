@@ -19,7 +19,7 @@ func NewSVMLayer(def LayerDef) Layer {
 	}
 
 	n := def.Input.Size()
-	return &svmLayer{conf, def.Input, volume.Dimensions{X: 1, Y: 1, Z: n}, nil, nil}
+	return &svmLayer{conf, def.Input, volume.Dimensions{X: 1, Y: 1, Z: n}, nil, nil, def.ReuseBuffers}
 }
 
 // NewSVMLayerConfig creates a new LayerConfig config with the given options.
@@ -45,6 +45,24 @@ type svmLayerConfig struct {
 	Classes int
 }
 
+// GetSVMPrediction returns the class with the highest raw score for the svm layer.
+func GetSVMPrediction(layer Layer) int {
+	svm, ok := layer.(*svmLayer)
+	if !ok {
+		panic("expected SVM layer")
+	}
+
+	p := svm.outVol.Weights()
+	maxv, maxi := p[0], 0
+	for index := 0; index < len(p); index++ {
+		if p[index] > maxv {
+			maxv = p[index]
+			maxi = index
+		}
+	}
+	return maxi
+}
+
 type svmLayer struct {
 	conf   *svmLayerConfig
 	inDim  volume.Dimensions
@@ -52,6 +70,10 @@ type svmLayer struct {
 
 	inVol  *volume.Volume
 	outVol *volume.Volume
+
+	// copyOut makes Forward return a copy of its input, which is the reused buffer
+	// of the previous layer when the network preallocates buffers
+	copyOut bool
 }
 
 func (l *svmLayer) Type() LayerType {
@@ -61,7 +83,10 @@ func (l *svmLayer) Type() LayerType {
 func (l *svmLayer) Forward(vol *volume.Volume, training bool) *volume.Volume {
 	l.inVol = vol
 	l.outVol = vol
-	return vol
+	if l.copyOut {
+		l.outVol = vol.Clone()
+	}
+	return l.outVol
 }
 
 func (l *svmLayer) Loss(index int) float64 {
@@ -2,8 +2,8 @@ package layers
 
 import (
 	"fmt"
-	"math"
 
+	"github.com/nathanleary/reticulum/device"
 	"github.com/nathanleary/reticulum/volume"
 )
 
@@ -14,7 +14,7 @@ func NewTanhLayer(def LayerDef) Layer {
 	} else if def.Output.Z == 0 {
 		panic(fmt.Errorf("Output depth cannot be 0 for tanh layer"))
 	}
-	return &tanhLayer{def.Output, nil, nil}
+	return &tanhLayer{def.Output, nil, nil, def.computeDevice(), buffers{reuse: def.ReuseBuffers}}
 }
 
 type tanhLayer struct {
@@ -22,6 +22,12 @@ type tanhLayer struct {
 
 	inVol  *volume.Volume
 	outVol *volume.Volume
+
+	// dev computes the activation
+	dev device.Device
+
+	// buf holds the output, reused across passes when the network preallocates
+	buf buffers
 }
 
 func (l *tanhLayer) Type() LayerType {
@@ -30,25 +36,17 @@ func (l *tanhLayer) Type() LayerType {
 
 func (l *tanhLayer) Forward(vol *volume.Volume, training bool) *volume.Volume {
 	l.inVol = vol
-	v2 := vol.CloneAndZero()
+	v2 := l.buf.output(vol.Dimensions())
 
-	n := vol.Size()
-	for i := 0; i < n; i++ {
-		v2.SetByIndex(i, math.Tanh(vol.GetByIndex(i)))
-	}
+	apply(l.dev, device.Tanh, vol.Weights(), v2.Weights())
 
 	l.outVol = v2
 	return l.outVol
 }
 
 func (l *tanhLayer) Backward() {
-	n := l.inVol.Size()
 	l.inVol.ZeroGrad()
-
-	for i := 0; i < n; i++ {
-		v2wi := l.outVol.GetByIndex(i)
-		l.inVol.SetGradByIndex(i, (1.0-v2wi*v2wi)*l.outVol.GetGradByIndex(i))
-	}
+	applyGrad(l.dev, device.Tanh, l.outVol.Weights(), l.outVol.Gradients(), l.inVol.Gradients())
 }
 
 func (*tanhLayer) GetResponse() []LayerResponse {
@@ -0,0 +1,88 @@
+package layers
+
+import (
+	"fmt"
+
+	"github.com/nathanleary/reticulum/volume"
+)
+
+// NewUpsampleLayerConfig creates a new upsample layer config, which scales the width
+// and height of the input by factor.
+func NewUpsampleLayerConfig(factor int) LayerConfig {
+	if factor <= 0 {
+		panic("Upsample factor must be greater than 0")
+	}
+	return &upsampleLayerConfig{Factor: factor}
+}
+
+type upsampleLayerConfig struct {
+	Factor int
+}
+
+// NewUpsampleLayer creates a new upsample layer, which repeats every input value over a
+// factor x factor block of the output (nearest neighbour upsampling).
+func NewUpsampleLayer(def LayerDef) Layer {
+	if def.Type != Upsample {
+		panic(fmt.Errorf("Invalid layer type: %s != upsample", def.Type))
+	} else if def.Output.Z == 0 {
+		panic(fmt.Errorf("Output depth cannot be 0 for upsample layer"))
+	}
+
+	conf, ok := def.LayerConfig.(*upsampleLayerConfig)
+	if !ok {
+		panic("Invalid LayerConfig for UpsampleLayer")
+	} else if conf.Factor <= 0 {
+		panic(fmt.Errorf("Upsample factor cannot be <= 0"))
+	}
+
+	outDim := volume.NewDimensions(def.Input.X*conf.Factor, def.Input.Y*conf.Factor, def.Input.Z)
+	return &upsampleLayer{conf, def.Input, outDim, nil, nil}
+}
+
+type upsampleLayer struct {
+	conf   *upsampleLayerConfig
+	input  volume.Dimensions
+	output volume.Dimensions
+
+	inVol  *volume.Volume
+	outVol *volume.Volume
+}
+
+func (*upsampleLayer) Type() LayerType {
+	return Upsample
+}
+
+func (l *upsampleLayer) Forward(vol *volume.Volume, training bool) *volume.Volume {
+	l.inVol = vol
+	A := volume.NewVolume(l.output, volume.WithZeros())
+
+	f := l.conf.Factor
+	for ax := 0; ax < l.output.X; ax++ {
+		for ay := 0; ay < l.output.Y; ay++ {
+			for d := 0; d < l.output.Z; d++ {
+				A.Set(ax, ay, d, vol.Get(ax/f, ay/f, d))
+			}
+		}
+	}
+
+	l.outVol = A
+	return l.outVol
+}
+
+func (l *upsampleLayer) Backward() {
+	l.inVol.ZeroGrad()
+
+	// every input value received the gradient of its whole block
+	f := l.conf.Factor
+	for ax := 0; ax < l.output.X; ax++ {
+		for ay := 0; ay < l.output.Y; ay++ {
+			for d := 0; d < l.output.Z; d++ {
+				l.inVol.AddGrad(ax/f, ay/f, d, l.outVol.GetGrad(ax, ay, d))
+			}
+		}
+	}
+}
+
+func (*upsampleLayer) GetResponse() []LayerResponse {
+	return []LayerResponse{}
+}
@@ -0,0 +1,55 @@
+package reticulum
+
+import (
+	"sort"
+)
+
+// Logger receives training progress as structured key-value pairs. It is a subset
+// of *slog.Logger, so a slog logger can be passed directly.
+type Logger interface {
+	Debug(msg string, args ...any)
+	Info(msg string, args ...any)
+}
+
+// WithLogger reports training progress to l: every iteration at debug level and
+// every epoch (as marked by Trainer.EndEpoch, e.g. by Fit) at info level.
+func WithLogger(l Logger) OptionFunc {
+	return WithCallbacks(&logCallback{logger: l})
+}
+
+// logCallback forwards trainer notifications to a Logger.
+type logCallback struct {
+	logger Logger
+	iter   int
+}
+
+func (c *logCallback) OnTrainBegin(t Trainer) {
+	c.logger.Info("training started", "learning_rate", t.LearningRate())
+}
+
+func (c *logCallback) OnBatchEnd(t Trainer, res TrainingResults) {
+	c.iter++
+	c.logger.Debug("iteration",
+		"iteration", c.iter,
+		"loss", res.CostLost,
+		"total_loss", res.TotalLoss,
+		"learning_rate", res.LearningRate,
+		"forward_time", res.ForwardTime,
+		"backward_time", res.BackwardTime,
+		"updated", res.Updated,
+	)
+}
+
+func (c *logCallback) OnEpochEnd(t Trainer, epoch int, metrics Metrics) {
+	names := make([]string, 0, len(metrics))
+	for name := range metrics {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	args := []any{"epoch", epoch, "iterations", c.iter}
+	for _, name := range names {
+		args = append(args, name, metrics[name])
+	}
+	c.logger.Info("epoch completed", args...)
+}
@@ -0,0 +1,64 @@
+package reticulum
+
+import (
+	"errors"
+	"math"
+)
+
+// LRPoint is a single point of the loss-vs-learning-rate curve.
+type LRPoint struct {
+	LearningRate float64
+
+	// Loss is the exponentially smoothed cost loss at this learning rate.
+	Loss float64
+}
+
+// FindLearningRate trains on data while increasing the learning rate exponentially
+// from minLR to maxLR over the given number of steps, and returns the loss observed
+// at each rate. The sweep stops early once the loss diverges. A good learning rate is
+// usually an order of magnitude below the rate with the minimum loss.
+//
+// The trainer's weights are modified by the sweep, so the network should be rebuilt
+// before training for real. The base learning rate is restored afterwards.
+func FindLearningRate(t Trainer, data []Sample, minLR, maxLR float64, steps int) ([]LRPoint, error) {
+	if t == nil {
+		return nil, errors.New("trainer cannot be nil")
+	} else if len(data) == 0 {
+		return nil, errors.New("at least one sample is required")
+	} else if minLR <= 0 || maxLR <= minLR {
+		return nil, errors.New("learning rates must satisfy 0 < minLR < maxLR")
+	} else if steps < 2 {
+		return nil, errors.New("at least two steps are required")
+	}
+
+	orig := t.LearningRate()
+	defer t.SetLearningRate(orig)
+
+	// smoothing factor for the loss, as the raw per-sample loss is very noisy
+	const beta = 0.98
+
+	var avg, best float64
+	curve := make([]LRPoint, 0, steps)
+	mult := math.Pow(maxLR/minLR, 1.0/float64(steps-1))
+	lr := minLR
+	for i := 0; i < steps; i++ {
+		t.SetLearningRate(lr)
+
+		sample := data[i%len(data)]
+		res := t.Train(sample.Input, sample.LossFunc())
+
+		avg = beta*avg + (1-beta)*res.CostLost
+		smoothed := avg / (1 - math.Pow(beta, float64(i+1)))
+		curve = append(curve, LRPoint{LearningRate: lr, Loss: smoothed})
+
+		// stop once the loss has clearly diverged
+		if math.IsNaN(smoothed) || math.IsInf(smoothed, 0) || (i > 0 && smoothed > 4*best) {
+			break
+		}
+		if i == 0 || smoothed < best {
+			best = smoothed
+		}
+		lr *= mult
+	}
+	return curve, nil
+}
@@ -0,0 +1,314 @@
+// Package magicnet is a port of ConvNetJS's MagicNet. It samples candidate network
+// architectures and training hyperparameters, cross-validates them on random folds of
+// the data and combines the best candidates into an ensemble.
+package magicnet
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"strings"
+
+	"github.com/nathanleary/reticulum"
+	"github.com/nathanleary/reticulum/layers"
+	"github.com/nathanleary/reticulum/volume"
+)
+
+// OptionFunc modifies the Options of a search.
+type OptionFunc func(*Options)
+
+// Options configures the search. The ranges of the learning rate and L2 decay are
+// given as powers of 10 and sampled log-uniformly.
+type Options struct {
+	TrainRatio      float64
+	Folds           int
+	Candidates      int
+	Epochs          int
+	EnsembleSize    int
+	BatchSizeMin    int
+	BatchSizeMax    int
+	L2DecayMin      float64
+	L2DecayMax      float64
+	LearningRateMin float64
+	LearningRateMax float64
+	MomentumMin     float64
+	MomentumMax     float64
+	NeuronsMin      int
+	NeuronsMax      int
+
+	HasSeed bool
+	Seed    int64
+}
+
+// WithFolds sets the number of random train/validation folds each candidate is scored on.
+func WithFolds(n int) OptionFunc {
+	return func(opts *Options) {
+		opts.Folds = n
+	}
+}
+
+// WithCandidates sets the number of sampled candidates.
+func WithCandidates(n int) OptionFunc {
+	return func(opts *Options) {
+		opts.Candidates = n
+	}
+}
+
+// WithEpochs sets the number of training epochs per candidate and fold.
+func WithEpochs(n int) OptionFunc {
+	return func(opts *Options) {
+		opts.Epochs = n
+	}
+}
+
+// WithEnsembleSize sets the number of best candidates combined into the ensemble.
+func WithEnsembleSize(n int) OptionFunc {
+	return func(opts *Options) {
+		opts.EnsembleSize = n
+	}
+}
+
+// WithTrainRatio sets the fraction of the data used for training in each fold.
+func WithTrainRatio(r float64) OptionFunc {
+	return func(opts *Options) {
+		opts.TrainRatio = r
+	}
+}
+
+// WithSeed makes the search reproducible.
+func WithSeed(seed int64) OptionFunc {
+	return func(opts *Options) {
+		opts.HasSeed = true
+		opts.Seed = seed
+	}
+}
+
+// Candidate is a sampled architecture and its training hyperparameters.
+type Candidate struct {
+	Hidden       []int
+	Activation   layers.LayerType
+	DropoutProb  float64
+	BatchSize    int
+	LearningRate float64
+	L2Decay      float64
+	Momentum     float64
+
+	// Accuracy is the mean validation accuracy over all folds.
+	Accuracy float64
+}
+
+// String describes the candidate.
+func (c Candidate) String() string {
+	hidden := make([]string, len(c.Hidden))
+	for i, n := range c.Hidden {
+		hidden[i] = fmt.Sprint(n)
+	}
+	return fmt.Sprintf("hidden=[%s] act=%s dropout=%.2f batch=%d lr=%.2g l2=%.2g momentum=%.2f acc=%.4f",
+		strings.Join(hidden, ","), c.Activation, c.DropoutProb, c.BatchSize, c.LearningRate, c.L2Decay, c.Momentum, c.Accuracy)
+}
+
+// Ensemble averages the class probabilities of the best candidates.
+type Ensemble struct {
+	// Candidates are the ensemble members, best first.
+	Candidates []Candidate
+	Networks   []reticulum.Network
+}
+
+// PredictProbabilities returns the mean softmax output of the ensemble members.
+func (e *Ensemble) PredictProbabilities(vol *volume.Volume) []float64 {
+	var probs []float64
+	for _, net := range e.Networks {
+		out := net.Forward(vol, false).Weights()
+		if probs == nil {
+			probs = make([]float64, len(out))
+		}
+		for i, p := range out {
+			probs[i] += p / float64(len(e.Networks))
+		}
+	}
+	return probs
+}
+
+// Predict returns the class with the highest mean probability.
+func (e *Ensemble) Predict(vol *volume.Volume) int {
+	probs := e.PredictProbabilities(vol)
+	best := 0
+	for i, p := range probs {
+		if p > probs[best] {
+			best = i
+		}
+	}
+	return best
+}
+
+// Train searches for good classifiers of data and returns an ensemble of the best
+// candidates, each retrained on all of the data.
+func Train(data []*volume.Volume, labels []int, optFuncs ...OptionFunc) (*Ensemble, error) {
+	opts := &Options{
+		TrainRatio:      0.7,
+		Folds:           10,
+		Candidates:      50,
+		Epochs:          50,
+		EnsembleSize:    10,
+		BatchSizeMin:    10,
+		BatchSizeMax:    300,
+		L2DecayMin:      -4,
+		L2DecayMax:      2,
+		LearningRateMin: -4,
+		LearningRateMax: 0,
+		MomentumMin:     0.9,
+		MomentumMax:     0.9,
+		NeuronsMin:      5,
+		NeuronsMax:      30,
+	}
+	for _, optFn := range optFuncs {
+		optFn(opts)
+	}
+
+	if len(data) == 0 {
+		return nil, errors.New("data cannot be empty")
+	} else if len(data) != len(labels) {
+		return nil, errors.New("expected one label per volume")
+	} else if opts.Folds <= 0 || opts.Candidates <= 0 || opts.EnsembleSize <= 0 || opts.Epochs <= 0 {
+		return nil, errors.New("folds, candidates, ensemble size and epochs must be greater than 0")
+	} else if opts.TrainRatio <= 0 || opts.TrainRatio >= 1 {
+		return nil, errors.New("train ratio must be between 0 and 1")
+	}
+
+	classes := 0
+	samples := make([]reticulum.Sample, len(data))
+	for i := range data {
+		if labels[i] < 0 {
+			return nil, fmt.Errorf("sample %d: label cannot be negative", i)
+		}
+		classes = max(classes, labels[i]+1)
+		samples[i] = reticulum.Sample{Input: data[i], Label: labels[i]}
+	}
+
+	seed := rand.Int63()
+	if opts.HasSeed {
+		seed = opts.Seed
+	}
+	rng := rand.New(rand.NewSource(seed))
+	s := &search{opts: opts, rng: rng, dim: data[0].Dimensions(), classes: classes}
+
+	// every candidate is scored on the same folds
+	nTrain := int(math.Floor(opts.TrainRatio * float64(len(samples))))
+	if nTrain == 0 || nTrain == len(samples) {
+		return nil, errors.New("not enough data to split into training and validation sets")
+	}
+	folds := make([][]int, opts.Folds)
+	for f := range folds {
+		folds[f] = rng.Perm(len(samples))
+	}
+
+	candidates := make([]Candidate, opts.Candidates)
+	for c := range candidates {
+		candidates[c] = s.sample()
+		var acc float64
+		for _, perm := range folds {
+			train, val := subset(samples, perm[:nTrain]), subset(samples, perm[nTrain:])
+			net, err := s.fit(candidates[c], train)
+			if err != nil {
+				return nil, fmt.Errorf("candidate %d: %w", c, err)
+			}
+			acc += reticulum.Evaluate(net, val).Accuracy
+		}
+		candidates[c].Accuracy = acc / float64(len(folds))
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].Accuracy > candidates[j].Accuracy
+	})
+
+	ensemble := &Ensemble{}
+	for _, c := range candidates[:min(opts.EnsembleSize, len(candidates))] {
+		net, err := s.fit(c, samples)
+		if err != nil {
+			return nil, err
+		}
+		ensemble.Candidates = append(ensemble.Candidates, c)
+		ensemble.Networks = append(ensemble.Networks, net)
+	}
+	return ensemble, nil
+}
+
+// search holds the state shared by all candidates.
+type search struct {
+	opts    *Options
+	rng     *rand.Rand
+	dim     volume.Dimensions
+	classes int
+}
+
+// sample draws a random candidate.
+func (s *search) sample() Candidate {
+	o := s.opts
+	c := Candidate{
+		BatchSize:    o.BatchSizeMin + s.rng.Intn(o.BatchSizeMax-o.BatchSizeMin+1),
+		LearningRate: math.Pow(10, o.LearningRateMin+s.rng.Float64()*(o.LearningRateMax-o.LearningRateMin)),
+		L2Decay:      math.Pow(10, o.L2DecayMin+s.rng.Float64()*(o.L2DecayMax-o.L2DecayMin)),
+		Momentum:     o.MomentumMin + s.rng.Float64()*(o.MomentumMax-o.MomentumMin),
+	}
+
+	// between 0 and 2 hidden layers
+	for i := s.rng.Intn(3); i > 0; i-- {
+		c.Hidden = append(c.Hidden, o.NeuronsMin+s.rng.Intn(o.NeuronsMax-o.NeuronsMin+1))
+	}
+	c.Activation = []layers.LayerType{layers.ReLU, layers.Sigmoid, layers.Tanh}[s.rng.Intn(3)]
+	if s.rng.Float64() < 0.5 {
+		c.DropoutProb = s.rng.Float64()
+	}
+	return c
+}
+
+// fit builds the candidate's network and trains it on data.
+func (s *search) fit(c Candidate, data []reticulum.Sample) (reticulum.Network, error) {
+	defs := []layers.LayerDef{{Type: layers.Input, Output: s.dim}}
+	for _, n := range c.Hidden {
+		def := layers.LayerDef{
+			Type:        layers.FullyConnected,
+			Output:      volume.NewDimensions(1, 1, n),
+			Activation:  c.Activation,
+			LayerConfig: layers.NewFullyConnectedLayerConfig(n),
+		}
+		if c.DropoutProb > 0 {
+			def.Dropout = &layers.DropoutLayerConfig{DropoutProbability: c.DropoutProb}
+		}
+		defs = append(defs, def)
+	}
+	defs = append(defs, layers.LayerDef{
+		Type:        layers.SoftMax,
+		Output:      volume.NewDimensions(1, 1, s.classes),
+		LayerConfig: layers.NewSoftmaxLayerConfig(s.classes),
+	})
+
+	net, err := reticulum.NewNetwork(defs, reticulum.WithNetworkSeed(s.rng.Int63()))
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = reticulum.Fit(net, data, nil, reticulum.FitOptions{
+		Epochs:    s.opts.Epochs,
+		BatchSize: c.BatchSize,
+		Shuffle:   true,
+		Options: []reticulum.OptionFunc{
+			reticulum.WithSeed(s.rng.Int63()),
+			reticulum.WithLearningRate(c.LearningRate),
+			reticulum.WithDecay(0, c.L2Decay),
+			reticulum.WithMomentum(c.Momentum),
+		},
+	})
+	return net, err
+}
+
+// subset returns the samples at the given indices.
+func subset(samples []reticulum.Sample, indices []int) []reticulum.Sample {
+	out := make([]reticulum.Sample, len(indices))
+	for i, idx := range indices {
+		out[i] = samples[idx]
+	}
+	return out
+}
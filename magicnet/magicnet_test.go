@@ -0,0 +1,109 @@
+package magicnet
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/nathanleary/reticulum/volume"
+)
+
+// separable returns n volumes labeled by the sign of the sum of their inputs.
+func separable(n int) ([]*volume.Volume, []int) {
+	r := rand.New(rand.NewSource(1))
+	data, labels := make([]*volume.Volume, n), make([]int, n)
+	for i := range data {
+		w := []float64{r.NormFloat64(), r.NormFloat64()}
+		data[i] = volume.NewVolume(volume.NewDimensions(1, 1, 2), volume.WithWeights(w))
+		if w[0]+w[1] > 0 {
+			labels[i] = 1
+		}
+	}
+	return data, labels
+}
+
+func TestTrain(t *testing.T) {
+	data, labels := separable(80)
+	opts := []OptionFunc{WithCandidates(6), WithFolds(2), WithEpochs(20), WithEnsembleSize(3), WithSeed(1)}
+	ensemble, err := Train(data, labels, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ensemble.Candidates) != 3 || len(ensemble.Networks) != 3 {
+		t.Fatalf("ensemble of %d candidates and %d networks, want 3", len(ensemble.Candidates), len(ensemble.Networks))
+	}
+	for i := 1; i < len(ensemble.Candidates); i++ {
+		if ensemble.Candidates[i].Accuracy > ensemble.Candidates[i-1].Accuracy {
+			t.Errorf("candidate %d is more accurate than candidate %d: %v", i, i-1, ensemble.Candidates)
+		}
+	}
+
+	var correct int
+	for i, vol := range data {
+		var sum float64
+		for _, p := range ensemble.PredictProbabilities(vol) {
+			sum += p
+		}
+		if math.Abs(sum-1) > 1e-9 {
+			t.Fatalf("probabilities sum to %v", sum)
+		}
+		if ensemble.Predict(vol) == labels[i] {
+			correct++
+		}
+	}
+	if acc := float64(correct) / float64(len(data)); acc < 0.9 {
+		t.Errorf("ensemble accuracy %v", acc)
+	}
+
+	// the same seed samples the same candidates
+	again, err := Train(data, labels, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, c := range again.Candidates {
+		if c.String() != ensemble.Candidates[i].String() {
+			t.Errorf("candidate %d is %s, was %s with the same seed", i, c, ensemble.Candidates[i])
+		}
+	}
+}
+
+func TestSearch_Sample(t *testing.T) {
+	o := &Options{BatchSizeMin: 10, BatchSizeMax: 20, L2DecayMin: -4, L2DecayMax: 2, LearningRateMin: -4, LearningRateMax: 0,
+		MomentumMin: 0.8, MomentumMax: 0.9, NeuronsMin: 5, NeuronsMax: 30}
+	s := &search{opts: o, rng: rand.New(rand.NewSource(1))}
+	for i := 0; i < 200; i++ {
+		c := s.sample()
+		if c.BatchSize < 10 || c.BatchSize > 20 || c.LearningRate < 1e-4 || c.LearningRate > 1 ||
+			c.L2Decay < 1e-4 || c.L2Decay > 100 || c.Momentum < 0.8 || c.Momentum > 0.9 ||
+			len(c.Hidden) > 2 || c.DropoutProb < 0 || c.DropoutProb >= 1 {
+			t.Fatalf("candidate out of range: %s", c)
+		}
+		for _, n := range c.Hidden {
+			if n < 5 || n > 30 {
+				t.Fatalf("candidate out of range: %s", c)
+			}
+		}
+	}
+}
+
+func TestTrain_Errors(t *testing.T) {
+	data, labels := separable(10)
+	for _, test := range []struct {
+		name   string
+		data   []*volume.Volume
+		labels []int
+		opts   []OptionFunc
+	}{
+		{"no data", nil, nil, nil},
+		{"missing labels", data, labels[:5], nil},
+		{"negative label", data[:2], []int{0, -1}, nil},
+		{"no folds", data, labels, []OptionFunc{WithFolds(0)}},
+		{"no candidates", data, labels, []OptionFunc{WithCandidates(0)}},
+		{"train ratio of 1", data, labels, []OptionFunc{WithTrainRatio(1)}},
+		{"too little data", data[:1], labels[:1], []OptionFunc{WithCandidates(1), WithFolds(1), WithEpochs(1)}},
+	} {
+		if _, err := Train(test.data, test.labels, test.opts...); err == nil {
+			t.Errorf("%s: expected an error", test.name)
+		}
+	}
+}
@@ -0,0 +1,182 @@
+package metrics
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+)
+
+// Point is a point of a ROC or precision-recall curve, reached by predicting
+// positive every score at or above Threshold.
+type Point struct {
+	Threshold float64
+
+	// X and Y are the false and true positive rates of a ROC curve, or the recall and
+	// precision of a precision-recall curve.
+	X, Y float64
+}
+
+// Curve is a ROC or precision-recall curve with its points in order of decreasing
+// threshold.
+type Curve struct {
+	Points []Point
+
+	// AUC is the area under a ROC curve, or the average precision of a
+	// precision-recall curve.
+	AUC float64
+}
+
+// WriteCSV writes the points of the curve as CSV with a header, for plotting.
+func (c Curve) WriteCSV(w io.Writer, xName, yName string) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write([]string{"threshold", xName, yName}); err != nil {
+		return err
+	}
+	format := func(v float64) string { return strconv.FormatFloat(v, 'g', -1, 64) }
+	for _, p := range c.Points {
+		if err := cw.Write([]string{format(p.Threshold), format(p.X), format(p.Y)}); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// counts holds the true and false positives at each distinct threshold, from the
+// highest score down.
+type counts struct {
+	thresholds []float64
+	tp, fp     []int
+	pos, neg   int
+}
+
+func count(scores []float64, positives []bool) (*counts, error) {
+	if len(scores) != len(positives) {
+		return nil, fmt.Errorf("got %d scores for %d labels", len(scores), len(positives))
+	}
+	order := make([]int, len(scores))
+	for i := range order {
+		order[i] = i
+	}
+	sort.Slice(order, func(a, b int) bool { return scores[order[a]] > scores[order[b]] })
+
+	c := &counts{}
+	var tp, fp int
+	for k, i := range order {
+		if math.IsNaN(scores[i]) {
+			return nil, fmt.Errorf("score %d is NaN", i)
+		}
+		if positives[i] {
+			tp++
+		} else {
+			fp++
+		}
+		// tied scores share a single point
+		if k+1 < len(order) && scores[order[k+1]] == scores[i] {
+			continue
+		}
+		c.thresholds = append(c.thresholds, scores[i])
+		c.tp = append(c.tp, tp)
+		c.fp = append(c.fp, fp)
+	}
+	c.pos, c.neg = tp, fp
+	if c.pos == 0 || c.neg == 0 {
+		return nil, errors.New("curves require both positive and negative samples")
+	}
+	return c, nil
+}
+
+// ROC computes the receiver operating characteristic curve of binary scores, where
+// positives marks the samples of the positive class. It starts at (0, 0) with an
+// infinite threshold and the AUC is computed with the trapezoidal rule.
+func ROC(scores []float64, positives []bool) (Curve, error) {
+	c, err := count(scores, positives)
+	if err != nil {
+		return Curve{}, err
+	}
+
+	curve := Curve{Points: []Point{{Threshold: math.Inf(1)}}}
+	for i, t := range c.thresholds {
+		p := Point{t, float64(c.fp[i]) / float64(c.neg), float64(c.tp[i]) / float64(c.pos)}
+		last := curve.Points[len(curve.Points)-1]
+		curve.AUC += (p.X - last.X) * (p.Y + last.Y) / 2
+		curve.Points = append(curve.Points, p)
+	}
+	return curve, nil
+}
+
+// PR computes the precision-recall curve of binary scores, where positives marks the
+// samples of the positive class. It starts at a recall of 0 and a precision of 1 with
+// an infinite threshold, and the AUC is the average precision: the precision at each
+// threshold weighted by the increase in recall.
+func PR(scores []float64, positives []bool) (Curve, error) {
+	c, err := count(scores, positives)
+	if err != nil {
+		return Curve{}, err
+	}
+
+	curve := Curve{Points: []Point{{Threshold: math.Inf(1), X: 0, Y: 1}}}
+	for i, t := range c.thresholds {
+		p := Point{t, float64(c.tp[i]) / float64(c.pos), float64(c.tp[i]) / float64(c.tp[i]+c.fp[i])}
+		curve.AUC += (p.X - curve.Points[len(curve.Points)-1].X) * p.Y
+		curve.Points = append(curve.Points, p)
+	}
+	return curve, nil
+}
+
+// OneVsRest computes a curve, e.g. ROC or PR, for every class of a multiclass
+// classifier from its predicted probabilities, treating the class as positive and all
+// others as negative. probs holds the probabilities of every sample and labels their
+// classes. Classes without both positive and negative samples have no curve.
+func OneVsRest(probs [][]float64, labels []int, curve func(scores []float64, positives []bool) (Curve, error)) (map[int]Curve, error) {
+	if len(probs) != len(labels) {
+		return nil, fmt.Errorf("got %d predictions for %d labels", len(probs), len(labels))
+	} else if len(probs) == 0 {
+		return nil, errors.New("no predictions")
+	}
+	classes := len(probs[0])
+	for i, p := range probs {
+		if len(p) != classes {
+			return nil, fmt.Errorf("prediction %d has %d classes, expected %d", i, len(p), classes)
+		}
+	}
+
+	curves := map[int]Curve{}
+	scores := make([]float64, len(probs))
+	positives := make([]bool, len(probs))
+	for k := 0; k < classes; k++ {
+		var pos int
+		for i, p := range probs {
+			scores[i] = p[k]
+			positives[i] = labels[i] == k
+			if positives[i] {
+				pos++
+			}
+		}
+		if pos == 0 || pos == len(probs) {
+			continue
+		}
+		c, err := curve(scores, positives)
+		if err != nil {
+			return nil, fmt.Errorf("class %d: %w", k, err)
+		}
+		curves[k] = c
+	}
+	return curves, nil
+}
+
+// MacroAUC returns the unweighted mean AUC of the curves.
+func MacroAUC(curves map[int]Curve) float64 {
+	if len(curves) == 0 {
+		return 0
+	}
+	var sum float64
+	for _, c := range curves {
+		sum += c.AUC
+	}
+	return sum / float64(len(curves))
+}
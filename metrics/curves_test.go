@@ -0,0 +1,115 @@
+package metrics
+
+import (
+	"bytes"
+	"encoding/csv"
+	"math"
+	"strconv"
+	"testing"
+)
+
+func TestCurves(t *testing.T) {
+	for _, test := range []struct {
+		name      string
+		scores    []float64
+		positives []bool
+		roc, pr   float64
+		points    int
+	}{
+		{"separated", []float64{0.9, 0.1}, []bool{true, false}, 1, 1, 3},
+		{"interleaved", []float64{0.9, 0.8, 0.7, 0.6}, []bool{true, false, true, false}, 0.75, 5.0 / 6, 5},
+		{"tied", []float64{0.5, 0.5}, []bool{true, false}, 0.5, 0.5, 2},
+	} {
+		roc, err := ROC(test.scores, test.positives)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pr, err := PR(test.scores, test.positives)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if math.Abs(roc.AUC-test.roc) > 1e-12 || math.Abs(pr.AUC-test.pr) > 1e-12 {
+			t.Errorf("%s: ROC AUC %v and average precision %v, want %v and %v", test.name, roc.AUC, pr.AUC, test.roc, test.pr)
+		}
+		if len(roc.Points) != test.points || len(pr.Points) != test.points {
+			t.Errorf("%s: %d ROC and %d PR points, want %d", test.name, len(roc.Points), len(pr.Points), test.points)
+		}
+		if last := roc.Points[len(roc.Points)-1]; last.X != 1 || last.Y != 1 {
+			t.Errorf("%s: ROC curve ends at (%v, %v), want (1, 1)", test.name, last.X, last.Y)
+		}
+	}
+}
+
+func TestCurves_Errors(t *testing.T) {
+	for _, test := range []struct {
+		name      string
+		scores    []float64
+		positives []bool
+	}{
+		{"mismatched", []float64{0.1, 0.2}, []bool{true}},
+		{"NaN", []float64{math.NaN(), 0.2}, []bool{true, false}},
+		{"no negatives", []float64{0.1, 0.2}, []bool{true, true}},
+		{"empty", nil, nil},
+	} {
+		if _, err := ROC(test.scores, test.positives); err == nil {
+			t.Errorf("%s: expected an error from ROC", test.name)
+		}
+		if _, err := PR(test.scores, test.positives); err == nil {
+			t.Errorf("%s: expected an error from PR", test.name)
+		}
+	}
+}
+
+func TestCurve_WriteCSV(t *testing.T) {
+	c, err := ROC([]float64{0.9, 0.8, 0.7, 0.6}, []bool{true, false, true, false})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := c.WriteCSV(&buf, "fpr", "tpr"); err != nil {
+		t.Fatal(err)
+	}
+	records, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != len(c.Points)+1 || records[0][1] != "fpr" || records[0][2] != "tpr" {
+		t.Fatalf("unexpected records %v", records)
+	}
+	for i, p := range c.Points {
+		for j, want := range []float64{p.Threshold, p.X, p.Y} {
+			if got, err := strconv.ParseFloat(records[i+1][j], 64); err != nil || got != want {
+				t.Errorf("point %d column %d = %q, want %v", i, j, records[i+1][j], want)
+			}
+		}
+	}
+}
+
+func TestOneVsRest(t *testing.T) {
+	probs := [][]float64{{0.8, 0.1, 0.1}, {0.2, 0.7, 0.1}, {0.6, 0.3, 0.1}, {0.3, 0.6, 0.1}}
+	curves, err := OneVsRest(probs, []int{0, 1, 0, 1}, ROC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// class 2 has no samples, so no curve
+	if len(curves) != 2 || curves[0].AUC != 1 || curves[1].AUC != 1 {
+		t.Fatalf("OneVsRest() = %v, want perfect curves for classes 0 and 1", curves)
+	}
+	if auc := MacroAUC(curves); auc != 1 {
+		t.Errorf("MacroAUC() = %v, want 1", auc)
+	}
+
+	for _, test := range []struct {
+		name   string
+		probs  [][]float64
+		labels []int
+	}{
+		{"mismatched", probs, []int{0}},
+		{"empty", nil, nil},
+		{"ragged", [][]float64{{0.5, 0.5}, {1}}, []int{0, 1}},
+	} {
+		if _, err := OneVsRest(test.probs, test.labels, PR); err == nil {
+			t.Errorf("%s: expected an error", test.name)
+		}
+	}
+}
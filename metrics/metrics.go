@@ -0,0 +1,259 @@
+// Package metrics computes classification metrics from streams of predictions, and
+// ROC and precision-recall curves from predicted probabilities. Accumulators
+// implement reticulum.Accumulator, so they can be passed to reticulum.EvaluateWith or
+// evaluated after every epoch by a callback:
+//
+//	cm := metrics.NewConfusion()
+//	report := reticulum.EvaluateWith(net, test, cm)
+//	fmt.Println(report.Metrics()["macro_f1"])
+//	fmt.Print(cm)
+package metrics
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/nathanleary/reticulum"
+)
+
+// Confusion is a confusion matrix over integer class labels, growing as new labels
+// are added. Rows are the true classes and columns the predicted ones.
+type Confusion struct {
+	counts [][]int
+	total  int
+}
+
+// NewConfusion creates an empty confusion matrix.
+func NewConfusion() *Confusion {
+	return &Confusion{}
+}
+
+// Add records the prediction for a sample of class truth. Labels must not be negative.
+func (c *Confusion) Add(pred, truth int) {
+	if pred < 0 || truth < 0 {
+		panic(fmt.Errorf("labels cannot be negative: pred %d, truth %d", pred, truth))
+	}
+	c.grow(max(pred, truth) + 1)
+	c.counts[truth][pred]++
+	c.total++
+}
+
+// grow extends the matrix to n classes.
+func (c *Confusion) grow(n int) {
+	for i := range c.counts {
+		for len(c.counts[i]) < n {
+			c.counts[i] = append(c.counts[i], 0)
+		}
+	}
+	for len(c.counts) < n {
+		c.counts = append(c.counts, make([]int, n))
+	}
+}
+
+// Reset clears the matrix.
+func (c *Confusion) Reset() {
+	c.counts, c.total = nil, 0
+}
+
+// Classes returns the number of classes, one more than the largest label seen.
+func (c *Confusion) Classes() int {
+	return len(c.counts)
+}
+
+// Total returns the number of predictions added.
+func (c *Confusion) Total() int {
+	return c.total
+}
+
+// Count returns the number of samples of class truth predicted as pred.
+func (c *Confusion) Count(pred, truth int) int {
+	if pred < 0 || truth < 0 || pred >= len(c.counts) || truth >= len(c.counts) {
+		return 0
+	}
+	return c.counts[truth][pred]
+}
+
+// Accuracy returns the fraction of correct predictions.
+func (c *Confusion) Accuracy() float64 {
+	var correct int
+	for i := range c.counts {
+		correct += c.counts[i][i]
+	}
+	return ratio(correct, c.total)
+}
+
+// tally returns the true positives, false positives and false negatives of class k.
+func (c *Confusion) tally(k int) (tp, fp, fn int) {
+	if k < 0 || k >= len(c.counts) {
+		return 0, 0, 0
+	}
+	for i := range c.counts {
+		if i == k {
+			continue
+		}
+		fp += c.counts[i][k]
+		fn += c.counts[k][i]
+	}
+	return c.counts[k][k], fp, fn
+}
+
+// Precision returns the fraction of predictions of class k which were correct.
+func (c *Confusion) Precision(k int) float64 {
+	tp, fp, _ := c.tally(k)
+	return ratio(tp, tp+fp)
+}
+
+// Recall returns the fraction of samples of class k which were predicted correctly.
+func (c *Confusion) Recall(k int) float64 {
+	tp, _, fn := c.tally(k)
+	return ratio(tp, tp+fn)
+}
+
+// F1 returns the harmonic mean of the precision and recall of class k.
+func (c *Confusion) F1(k int) float64 {
+	tp, fp, fn := c.tally(k)
+	return ratio(2*tp, 2*tp+fp+fn)
+}
+
+// present reports the classes which occur as a truth or a prediction. Labels skipped
+// entirely, e.g. 1 when only 0 and 2 were seen, do not count towards macro averages.
+func (c *Confusion) present() []int {
+	var classes []int
+	for k := range c.counts {
+		tp, fp, fn := c.tally(k)
+		if tp+fp+fn > 0 {
+			classes = append(classes, k)
+		}
+	}
+	return classes
+}
+
+// macro averages the metric over the present classes.
+func (c *Confusion) macro(metric func(k int) float64) float64 {
+	classes := c.present()
+	if len(classes) == 0 {
+		return 0
+	}
+	var sum float64
+	for _, k := range classes {
+		sum += metric(k)
+	}
+	return sum / float64(len(classes))
+}
+
+// MacroPrecision returns the unweighted mean of the precision of every class.
+func (c *Confusion) MacroPrecision() float64 {
+	return c.macro(c.Precision)
+}
+
+// MacroRecall returns the unweighted mean of the recall of every class.
+func (c *Confusion) MacroRecall() float64 {
+	return c.macro(c.Recall)
+}
+
+// MacroF1 returns the unweighted mean of the F1 score of every class.
+func (c *Confusion) MacroF1() float64 {
+	return c.macro(c.F1)
+}
+
+// micro sums the true positives, false positives and false negatives of all classes.
+func (c *Confusion) micro() (tp, fp, fn int) {
+	for k := range c.counts {
+		t, p, n := c.tally(k)
+		tp, fp, fn = tp+t, fp+p, fn+n
+	}
+	return tp, fp, fn
+}
+
+// MicroPrecision returns the precision over the predictions of all classes. With a
+// single label per sample it equals the accuracy.
+func (c *Confusion) MicroPrecision() float64 {
+	tp, fp, _ := c.micro()
+	return ratio(tp, tp+fp)
+}
+
+// MicroRecall returns the recall over the samples of all classes. With a single label
+// per sample it equals the accuracy.
+func (c *Confusion) MicroRecall() float64 {
+	tp, _, fn := c.micro()
+	return ratio(tp, tp+fn)
+}
+
+// MicroF1 returns the F1 score over all classes. With a single label per sample it
+// equals the accuracy.
+func (c *Confusion) MicroF1() float64 {
+	tp, fp, fn := c.micro()
+	return ratio(2*tp, 2*tp+fp+fn)
+}
+
+// Metrics returns the accuracy and the macro and micro averaged precision, recall
+// and F1 score.
+func (c *Confusion) Metrics() reticulum.Metrics {
+	return reticulum.Metrics{
+		"accuracy":        c.Accuracy(),
+		"macro_precision": c.MacroPrecision(),
+		"macro_recall":    c.MacroRecall(),
+		"macro_f1":        c.MacroF1(),
+		"micro_precision": c.MicroPrecision(),
+		"micro_recall":    c.MicroRecall(),
+		"micro_f1":        c.MicroF1(),
+	}
+}
+
+// String prints the matrix with a row per true class and a column per prediction.
+func (c *Confusion) String() string {
+	const corner = "true\\pred"
+	width := len(fmt.Sprint(len(c.counts) - 1))
+	for _, row := range c.counts {
+		for _, n := range row {
+			width = max(width, len(fmt.Sprint(n)))
+		}
+	}
+
+	var b strings.Builder
+	b.WriteString(corner)
+	for k := range c.counts {
+		fmt.Fprintf(&b, " %*d", width, k)
+	}
+	b.WriteString("\n")
+	for k, row := range c.counts {
+		fmt.Fprintf(&b, "%*d", len(corner), k)
+		for _, n := range row {
+			fmt.Fprintf(&b, " %*d", width, n)
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+func ratio(a, b int) float64 {
+	if b == 0 {
+		return 0
+	}
+	return float64(a) / float64(b)
+}
+
+// NewCallback returns a callback evaluating the trainer's network on data at the end
+// of every epoch. The accumulators are reset first and their metrics added to those of
+// the epoch under the given prefix, e.g. "val_", so callbacks registered after it, like
+// early stopping, can act on them.
+func NewCallback(data []reticulum.Sample, prefix string, accs ...reticulum.Accumulator) reticulum.Callback {
+	return &callback{data: data, prefix: prefix, accs: accs}
+}
+
+type callback struct {
+	reticulum.BaseCallback
+	data   []reticulum.Sample
+	prefix string
+	accs   []reticulum.Accumulator
+}
+
+func (c *callback) OnEpochEnd(t reticulum.Trainer, epoch int, metrics reticulum.Metrics) {
+	for _, acc := range c.accs {
+		acc.Reset()
+	}
+	report := reticulum.EvaluateWith(t.Network(), c.data, c.accs...)
+	for k, v := range report.Accumulated {
+		metrics[c.prefix+k] = v
+	}
+}
@@ -0,0 +1,83 @@
+package metrics
+
+import (
+	"math"
+	"testing"
+)
+
+func TestConfusion(t *testing.T) {
+	for _, test := range []struct {
+		name         string
+		preds, truth []int
+		want         map[string]float64
+	}{
+		{"empty", nil, nil, map[string]float64{"accuracy": 0, "macro_f1": 0, "micro_f1": 0}},
+		{"three classes", []int{0, 1, 1, 2}, []int{0, 1, 2, 2}, map[string]float64{
+			"accuracy": 0.75, "macro_precision": 2.5 / 3, "macro_recall": 2.5 / 3, "macro_f1": 7.0 / 9,
+			"micro_precision": 0.75, "micro_recall": 0.75, "micro_f1": 0.75,
+		}},
+		{"skipped label", []int{0, 2, 0}, []int{0, 2, 2}, map[string]float64{
+			"accuracy": 2.0 / 3, "macro_precision": 0.75, "macro_recall": 0.75, "macro_f1": 2.0 / 3,
+		}},
+	} {
+		c := NewConfusion()
+		for i := range test.preds {
+			c.Add(test.preds[i], test.truth[i])
+		}
+		if c.Total() != len(test.preds) {
+			t.Errorf("%s: Total() = %d, want %d", test.name, c.Total(), len(test.preds))
+		}
+		got := c.Metrics()
+		for k, want := range test.want {
+			if math.Abs(got[k]-want) > 1e-12 {
+				t.Errorf("%s: %s = %v, want %v", test.name, k, got[k], want)
+			}
+		}
+
+		c.Reset()
+		if c.Total() != 0 || c.Classes() != 0 {
+			t.Errorf("%s: %d predictions of %d classes after Reset", test.name, c.Total(), c.Classes())
+		}
+	}
+}
+
+func TestConfusion_Count(t *testing.T) {
+	c := NewConfusion()
+	c.Add(1, 0)
+	c.Add(1, 0)
+	c.Add(0, 3)
+	for _, test := range []struct {
+		pred, truth, want int
+	}{
+		{1, 0, 2},
+		{0, 3, 1},
+		{0, 0, 0},
+		{4, 0, 0},
+		{-1, 0, 0},
+	} {
+		if got := c.Count(test.pred, test.truth); got != test.want {
+			t.Errorf("Count(%d, %d) = %d, want %d", test.pred, test.truth, got, test.want)
+		}
+	}
+	if c.Classes() != 4 {
+		t.Errorf("Classes() = %d, want 4", c.Classes())
+	}
+
+	want := "true\\pred 0 1 2 3\n" +
+		"        0 0 2 0 0\n" +
+		"        1 0 0 0 0\n" +
+		"        2 0 0 0 0\n" +
+		"        3 1 0 0 0\n"
+	if got := c.String(); got != want {
+		t.Errorf("String() = \n%s, want \n%s", got, want)
+	}
+}
+
+func TestConfusion_NegativeLabel(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("expected a panic adding a negative label")
+		}
+	}()
+	NewConfusion().Add(0, -1)
+}
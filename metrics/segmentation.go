@@ -0,0 +1,93 @@
+package metrics
+
+import (
+	"fmt"
+
+	"github.com/nathanleary/reticulum"
+	"github.com/nathanleary/reticulum/volume"
+)
+
+// Segmentation accumulates the per-pixel predictions of dense prediction networks, e.g.
+// segmentation networks with an output of class scores at every position, and reports
+// the intersection over union and Dice coefficient of every class. It also accepts
+// single predictions with Add, so it can be used wherever a Confusion can.
+type Segmentation struct {
+	Confusion
+}
+
+// NewSegmentation creates an empty segmentation accumulator.
+func NewSegmentation() *Segmentation {
+	return &Segmentation{}
+}
+
+// AddMap records the predicted and true label maps of an image, which hold the class of
+// every position x, y at index y*width + x. Positions with a negative true label, e.g.
+// unlabeled borders, are ignored.
+func (s *Segmentation) AddMap(pred, truth []int) {
+	if len(pred) != len(truth) {
+		panic(fmt.Errorf("label maps differ in size: %d != %d", len(pred), len(truth)))
+	}
+	for i, t := range truth {
+		if t >= 0 {
+			s.Add(pred[i], t)
+		}
+	}
+}
+
+// AddVolume records the prediction of an output volume of class scores, taking the
+// class with the highest score along the depth at every position, against the true
+// label map.
+func (s *Segmentation) AddVolume(out *volume.Volume, truth []int) {
+	s.AddMap(LabelMap(out), truth)
+}
+
+// LabelMap returns the class with the highest score along the depth at every position
+// of the volume, in the layout taken by AddMap.
+func LabelMap(out *volume.Volume) []int {
+	dim := out.Dimensions()
+	labels := make([]int, dim.X*dim.Y)
+	w := out.Weights()
+	for i := range labels {
+		scores := w[i*dim.Z : (i+1)*dim.Z]
+		best := 0
+		for k, v := range scores {
+			if v > scores[best] {
+				best = k
+			}
+		}
+		labels[i] = best
+	}
+	return labels
+}
+
+// IoU returns the intersection over union of the predicted and true regions of class k.
+func (s *Segmentation) IoU(k int) float64 {
+	tp, fp, fn := s.tally(k)
+	return ratio(tp, tp+fp+fn)
+}
+
+// Dice returns the Dice coefficient of the predicted and true regions of class k,
+// which is its F1 score.
+func (s *Segmentation) Dice(k int) float64 {
+	return s.F1(k)
+}
+
+// MeanIoU returns the mean IoU of the classes which occur as a truth or a prediction.
+func (s *Segmentation) MeanIoU() float64 {
+	return s.macro(s.IoU)
+}
+
+// MeanDice returns the mean Dice coefficient of the classes which occur as a truth or
+// a prediction.
+func (s *Segmentation) MeanDice() float64 {
+	return s.macro(s.Dice)
+}
+
+// Metrics returns the pixel accuracy and the mean IoU and Dice coefficient.
+func (s *Segmentation) Metrics() reticulum.Metrics {
+	return reticulum.Metrics{
+		"pixel_accuracy": s.Accuracy(),
+		"mean_iou":       s.MeanIoU(),
+		"mean_dice":      s.MeanDice(),
+	}
+}
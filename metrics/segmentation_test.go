@@ -0,0 +1,56 @@
+package metrics
+
+import (
+	"math"
+	"testing"
+
+	"github.com/nathanleary/reticulum/volume"
+)
+
+func TestSegmentation(t *testing.T) {
+	for _, test := range []struct {
+		name        string
+		pred, truth []int
+		iou         []float64
+		want        map[string]float64
+	}{
+		{"perfect", []int{0, 1, 1, 0}, []int{0, 1, 1, 0}, []float64{1, 1}, map[string]float64{"pixel_accuracy": 1, "mean_iou": 1, "mean_dice": 1}},
+		{"overlap", []int{0, 1, 1, 1}, []int{0, 0, 1, 1}, []float64{0.5, 2.0 / 3}, map[string]float64{"pixel_accuracy": 0.75, "mean_iou": 7.0 / 12, "mean_dice": 11.0 / 15}},
+		{"unlabeled", []int{0, 1, 1, 1}, []int{0, -1, 1, 1}, []float64{1, 1}, map[string]float64{"pixel_accuracy": 1, "mean_iou": 1, "mean_dice": 1}},
+	} {
+		s := NewSegmentation()
+		s.AddMap(test.pred, test.truth)
+		for k, want := range test.iou {
+			if got := s.IoU(k); math.Abs(got-want) > 1e-12 {
+				t.Errorf("%s: IoU(%d) = %v, want %v", test.name, k, got, want)
+			}
+		}
+		got := s.Metrics()
+		for k, want := range test.want {
+			if math.Abs(got[k]-want) > 1e-12 {
+				t.Errorf("%s: %s = %v, want %v", test.name, k, got[k], want)
+			}
+		}
+	}
+}
+
+func TestSegmentation_AddVolume(t *testing.T) {
+	// a 2x1 output of three class scores per position
+	out := volume.NewVolume(volume.NewDimensions(2, 1, 3), volume.WithZeros())
+	copy(out.Weights(), []float64{0.1, 0.7, 0.2, 0.5, 0.2, 0.3})
+	if got := LabelMap(out); len(got) != 2 || got[0] != 1 || got[1] != 0 {
+		t.Fatalf("LabelMap() = %v, want [1 0]", got)
+	}
+	s := NewSegmentation()
+	s.AddVolume(out, []int{1, 2})
+	if s.Count(1, 1) != 1 || s.Count(0, 2) != 1 || s.Total() != 2 {
+		t.Errorf("unexpected matrix after AddVolume:\n%s", s)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("expected a panic adding label maps of different sizes")
+		}
+	}()
+	s.AddVolume(out, []int{1})
+}
@@ -0,0 +1,191 @@
+package reticulum
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"sync"
+
+	"github.com/nathanleary/reticulum/layers"
+	"github.com/nathanleary/reticulum/volume"
+)
+
+// ModelVersion is the version of the format written by SaveModel.
+const ModelVersion = 1
+
+// modelFormat identifies files written by SaveModel.
+const modelFormat = "reticulum/model"
+
+// ModelMigration upgrades a model saved in one version of the format to the next. It
+// edits the decoded JSON document in place, e.g. renaming the fields of a layer config
+// under doc["layers"][i]["config"].
+type ModelMigration func(doc map[string]any) error
+
+var (
+	migrationsMu    sync.RWMutex
+	modelMigrations = map[int]ModelMigration{}
+)
+
+// RegisterModelMigration registers the migration from version from to from+1 of the
+// model format. LoadModel applies the migrations in turn to models saved by older
+// versions of the package, so they keep loading after the format evolves.
+func RegisterModelMigration(from int, m ModelMigration) {
+	migrationsMu.Lock()
+	defer migrationsMu.Unlock()
+	modelMigrations[from] = m
+}
+
+// savedModel is the serialized form of a network: its expanded layer definitions in
+// order and the weights of every layer keyed as by NamedParameters.
+type savedModel struct {
+	Format  string               `json:"format"`
+	Version int                  `json:"version"`
+	Graph   bool                 `json:"graph,omitempty"`
+	Layers  []savedLayer         `json:"layers"`
+	Weights map[string][]float64 `json:"weights"`
+}
+
+type savedLayer struct {
+	Name   string            `json:"name"`
+	Type   layers.LayerType  `json:"type"`
+	Inputs []string          `json:"inputs,omitempty"`
+	Output volume.Dimensions `json:"output"`
+	Config json.RawMessage   `json:"config,omitempty"`
+}
+
+// SaveModel writes the architecture and weights of net as JSON, so it can be
+// recreated with LoadModel. Frozen layers are saved like the others, but not
+// marked as frozen.
+func SaveModel(w io.Writer, net Network) error {
+	n, ok := net.(*network)
+	if !ok {
+		return fmt.Errorf("unsupported network type %T", net)
+	}
+
+	m := savedModel{Format: modelFormat, Version: ModelVersion, Graph: n.graph != nil, Weights: map[string][]float64{}}
+	for i, def := range n.defs {
+		l := savedLayer{Name: n.names[i], Type: def.Type, Output: def.Output}
+		if n.graph != nil {
+			for _, j := range n.graph.inputs[i] {
+				l.Inputs = append(l.Inputs, n.names[j])
+			}
+		}
+		if def.LayerConfig != nil {
+			conf, err := json.Marshal(def.LayerConfig)
+			if err != nil {
+				return fmt.Errorf("layer %s: %w", l.Name, err)
+			}
+			l.Config = conf
+		}
+		m.Layers = append(m.Layers, l)
+	}
+	for name, vol := range NamedParameters(n) {
+		m.Weights[name] = vol.Weights()
+	}
+	return json.NewEncoder(w).Encode(m)
+}
+
+// LoadModel reads a network written by SaveModel, migrating it from older versions of
+// the format first. The options seed the dropout masks and set the parallelism,
+// buffer reuse, profile labels, tracer and device of the network.
+func LoadModel(r io.Reader, optFuncs ...NetworkOptionFunc) (Network, error) {
+	m, err := decodeModel(r)
+	if err != nil {
+		return nil, err
+	}
+
+	defs := make([]layers.LayerDef, len(m.Layers))
+	for i, l := range m.Layers {
+		defs[i] = layers.LayerDef{Type: l.Type, Name: l.Name, Inputs: l.Inputs, Output: l.Output, LayerConfig: layers.NewConfig(l.Type)}
+		if defs[i].LayerConfig != nil && len(l.Config) > 0 {
+			if err := json.Unmarshal(l.Config, defs[i].LayerConfig); err != nil {
+				return nil, fmt.Errorf("layer %s: decode config: %w", l.Name, err)
+			}
+		}
+	}
+	if len(defs) < 2 {
+		return nil, errors.New("model has no layers")
+	}
+
+	opts := &NetworkOptions{}
+	for _, optFn := range optFuncs {
+		optFn(opts)
+	}
+	var rng *rand.Rand
+	if opts.HasSeed {
+		rng = rand.New(rand.NewSource(opts.Seed))
+	}
+	defs = opts.withLayerOptions(defs)
+
+	var n *network
+	func() {
+		defer recoverError(&err)
+		if m.Graph {
+			n, err = newGraph(defs, rng, false)
+		} else {
+			n, err = newSequential(defs, rng)
+		}
+	}()
+	if err != nil {
+		return nil, err
+	}
+	if err := LoadNamedParameters(n, m.Weights, true); err != nil {
+		return nil, err
+	}
+	n.tracer = opts.Tracer
+	n.profileCtx = opts.ProfileContext
+	return n, nil
+}
+
+// decodeModel reads a model written by SaveModel, migrated to the current version.
+func decodeModel(r io.Reader) (*savedModel, error) {
+	var doc map[string]any
+	if err := json.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("decode model: %w", err)
+	}
+	if err := migrateModel(doc); err != nil {
+		return nil, err
+	}
+
+	// round trip the migrated document into its current form
+	data, err := json.Marshal(doc)
+	if err != nil {
+		return nil, err
+	}
+	var m savedModel
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("decode model: %w", err)
+	}
+	return &m, nil
+}
+
+// migrateModel upgrades the decoded document to ModelVersion.
+func migrateModel(doc map[string]any) error {
+	if doc["format"] != modelFormat {
+		return fmt.Errorf("not a model file: format %v", doc["format"])
+	}
+	v, ok := doc["version"].(float64)
+	if !ok {
+		return errors.New("model has no version")
+	}
+
+	migrationsMu.RLock()
+	defer migrationsMu.RUnlock()
+	version := int(v)
+	if version > ModelVersion {
+		return fmt.Errorf("model version %d is newer than the supported version %d", version, ModelVersion)
+	}
+	for ; version < ModelVersion; version++ {
+		m, ok := modelMigrations[version]
+		if !ok {
+			return fmt.Errorf("no migration from model version %d", version)
+		}
+		if err := m(doc); err != nil {
+			return fmt.Errorf("migrate model from version %d: %w", version, err)
+		}
+	}
+	doc["version"] = ModelVersion
+	return nil
+}
@@ -1,8 +1,12 @@
 package reticulum
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"math/rand"
 
+	device "github.com/nathanleary/reticulum/device"
 	layers "github.com/nathanleary/reticulum/layers"
 	volume "github.com/nathanleary/reticulum/volume"
 )
@@ -19,69 +23,262 @@ type Network interface {
 
 	Forward(vol *volume.Volume, training bool) *volume.Volume
 	Backward(index int) float64
+
 	GetCostLoss(vol *volume.Volume, index int) float64
 
 	// GetPrediction assumes the last layer in the network is a SoftMax layer.
 	GetPrediction() int
+
+	// GetResponse returns the parameters and gradients of every layer, frozen or not.
 	GetResponse() []layers.LayerResponse
 
 	MultiDimensionalLoss(losses []float64) float64
 	DimensionalLoss(index int, value float64) float64
+
+	// Freeze excludes the parameters of the named layers, or of every layer when no
+	// names are given, from training so it leaves them unchanged. Unfreeze makes them
+	// trainable again. Trainers keep per-parameter state, which they reset
+	// when the set of trainable parameters changes.
+	Freeze(names ...string) error
+	Unfreeze(names ...string) error
+
+	// Frozen reports whether the named layer is frozen.
+	Frozen(name string) bool
+}
+
+// NetworkOptionFunc modifies the NetworkOptions when creating a new network.
+type NetworkOptionFunc func(*NetworkOptions)
+
+// NetworkOptions stores network construction options
+type NetworkOptions struct {
+	HasSeed bool
+	Seed    int64
+
+	// Parallelism bounds the goroutines each conv and pool layer splits its forward
+	// and backward passes across, over its filters and output rows. Values of 0 and 1
+	// keep every layer on the calling goroutine.
+	Parallelism int
+
+	// PreallocateBuffers makes the hidden layers reuse their output and scratch
+	// buffers across passes.
+	PreallocateBuffers bool
+
+	// ProfileLabels labels the CPU profile samples of every layer with pprof labels,
+	// added to those of ProfileContext.
+	ProfileLabels  bool
+	ProfileContext context.Context
+
+	// Tracer traces the passes of the network, disabled when nil.
+	Tracer Tracer
+
+	// Device runs the products and activations of the layers, the CPU when nil.
+	Device device.Device
+}
+
+// WithNetworkSeed seeds the weight initialization and dropout masks, so networks
+// built with the same seed start from identical weights.
+func WithNetworkSeed(seed int64) NetworkOptionFunc {
+	return func(opts *NetworkOptions) {
+		opts.HasSeed = true
+		opts.Seed = seed
+	}
+}
+
+// newRand returns a random source seeded with seed if hasSeed is set, or with a random
+// seed otherwise, for the HasSeed and Seed fields of the option structs.
+func newRand(hasSeed bool, seed int64) *rand.Rand {
+	if !hasSeed {
+		seed = rand.Int63()
+	}
+	return rand.New(rand.NewSource(seed))
+}
+
+// WithParallelism splits the work of every conv and pool layer across up to n
+// goroutines, for large layers on otherwise idle cores. Networks run many small
+// layers faster on one goroutine, and ForwardBatch, Hogwild and the like already run
+// in parallel, so it is best kept at 1 for them.
+func WithParallelism(n int) NetworkOptionFunc {
+	return func(opts *NetworkOptions) {
+		opts.Parallelism = n
+	}
+}
+
+// WithPreallocatedBuffers makes the conv, pool, fc, activation, dropout and maxout
+// layers allocate their output and scratch buffers once, on the first pass or when
+// the input shape changes, and reuse them afterwards, so training runs without
+// steady-state allocations in those layers. Their outputs, e.g. as returned by
+// ActivationsOf, are then overwritten by the next pass, and must be cloned to be
+// kept. The output of the network is not: the softmax, svm and regression layers
+// return a new volume from every Forward, so the outputs of Forward and ForwardBatch
+// stay valid across passes. Clones of the network get their own buffers, so they can
+// run concurrently.
+func WithPreallocatedBuffers() NetworkOptionFunc {
+	return func(opts *NetworkOptions) {
+		opts.PreallocateBuffers = true
+	}
+}
+
+// withLayerOptions returns a copy of defs with the parallelism, buffer reuse, profile
+// labels and device of the options.
+func (opts *NetworkOptions) withLayerOptions(defs []layers.LayerDef) []layers.LayerDef {
+	defs = append([]layers.LayerDef(nil), defs...)
+	for i := range defs {
+		defs[i].Parallelism = opts.Parallelism
+		defs[i].ReuseBuffers = opts.PreallocateBuffers
+		defs[i].ProfileLabels = opts.ProfileLabels
+		defs[i].Device = opts.Device
+	}
+	return defs
 }
 
 // NewNetwork creates a new network from the layer definitions
-func NewNetwork(defs []layers.LayerDef) (Network, error) {
-	if len(defs) <= 2 {
+func NewNetwork(defs []layers.LayerDef, optFuncs ...NetworkOptionFunc) (Network, error) {
+	opts := &NetworkOptions{}
+	for _, optFn := range optFuncs {
+		optFn(opts)
+	}
+
+	if len(defs) < 2 {
 		return nil, errors.New("at least one input and one loss layer are required")
 	} else if defs[0].Type != layers.Input {
 		return nil, errors.New("first layer must be the input layer, to declare size of inputs")
 	}
 
 	// Add activation layers
-	defs = layers.ActivateLayers(defs)
+	defs = opts.withLayerOptions(layers.ActivateLayers(defs))
+
+	var rng *rand.Rand
+	if opts.HasSeed {
+		rng = rand.New(rand.NewSource(opts.Seed))
+	}
+
+	n, err := newSequential(defs, rng)
+	if err != nil {
+		return nil, err
+	}
+	n.tracer = opts.Tracer
+	n.profileCtx = opts.ProfileContext
+	return n, nil
+}
 
+// newSequential creates a network from expanded layer definitions, chaining the
+// output of each layer into the next.
+func newSequential(defs []layers.LayerDef, rng *rand.Rand) (*network, error) {
 	var newLayers []layers.Layer
+	var names []string
+	var built []layers.LayerDef
+	seen := map[string]bool{}
 	for i, def := range defs {
 		if i > 0 {
-			prev := defs[i-1]
-			def.Input = prev.Output
-		}
-
-		switch def.Type {
-		case layers.FullyConnected:
-			newLayers = append(newLayers, layers.NewFullyConnectedLayer(def))
-		case layers.Dropout:
-			newLayers = append(newLayers, layers.NewDropoutLayer(def))
-		case layers.Input:
-			newLayers = append(newLayers, layers.NewInputLayer(def))
-		case layers.SoftMax:
-			newLayers = append(newLayers, layers.NewSoftmaxLayer(def))
-		case layers.Regression:
-			newLayers = append(newLayers, layers.NewRegressionLayer(def))
-		case layers.Conv:
-			newLayers = append(newLayers, layers.NewConvLayer(def))
-		case layers.Pool:
-			newLayers = append(newLayers, layers.NewPoolLayer(def))
-		case layers.ReLU:
-			newLayers = append(newLayers, layers.NewReluLayer(def))
-		case layers.Sigmoid:
-			newLayers = append(newLayers, layers.NewSigmoidLayer(def))
-		case layers.Tanh:
-			newLayers = append(newLayers, layers.NewTanhLayer(def))
-		case layers.Maxout:
-			newLayers = append(newLayers, layers.NewMaxoutLayer(def))
-		case layers.SVM:
-			newLayers = append(newLayers, layers.NewSVMLayer(def))
-		// case layers.LocalResponseNorm:
-		default:
-			return nil, errors.New("unrecognized layer type")
-		}
-	}
-	return &network{newLayers}, nil
+			def.Input = built[i-1].Output
+		}
+		out, err := layers.InferOutput(def)
+		if err != nil {
+			return nil, fmt.Errorf("layer %d (%s): %w", i, def.Type, err)
+		}
+		def.Output = out
+		if rng != nil {
+			def.Rand = rng
+		}
+
+		name := def.Name
+		if name == "" {
+			name = fmt.Sprintf("%s%d", def.Type, i)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("duplicate layer name: %s", name)
+		}
+		seen[name] = true
+		names = append(names, name)
+
+		layer, err := newLayer(def)
+		if err != nil {
+			return nil, err
+		}
+		newLayers = append(newLayers, layer)
+		built = append(built, def)
+	}
+	return &network{layers: newLayers, names: names, defs: built, inputDims: []volume.Dimensions{defs[0].Output}}, nil
+}
+
+// newLayer creates the layer described by def.
+func newLayer(def layers.LayerDef) (layers.Layer, error) {
+	switch def.Type {
+	case layers.FullyConnected:
+		return layers.NewFullyConnectedLayer(def), nil
+	case layers.Dropout:
+		return layers.NewDropoutLayer(def), nil
+	case layers.Input:
+		return layers.NewInputLayer(def), nil
+	case layers.SoftMax:
+		return layers.NewSoftmaxLayer(def), nil
+	case layers.Regression:
+		return layers.NewRegressionLayer(def), nil
+	case layers.Conv:
+		return layers.NewConvLayer(def), nil
+	case layers.Depthwise:
+		return layers.NewDepthwiseLayer(def), nil
+	case layers.Pool:
+		return layers.NewPoolLayer(def), nil
+	case layers.ReLU:
+		return layers.NewReluLayer(def), nil
+	case layers.Sigmoid:
+		return layers.NewSigmoidLayer(def), nil
+	case layers.Tanh:
+		return layers.NewTanhLayer(def), nil
+	case layers.Maxout:
+		return layers.NewMaxoutLayer(def), nil
+	case layers.SVM:
+		return layers.NewSVMLayer(def), nil
+	case layers.Add:
+		return layers.NewAddLayer(def), nil
+	case layers.Concat:
+		return layers.NewConcatLayer(def), nil
+	case layers.Upsample:
+		return layers.NewUpsampleLayer(def), nil
+	case layers.PixelSoftMax:
+		return layers.NewPixelSoftmaxLayer(def), nil
+	case layers.Embedding:
+		return layers.NewEmbeddingLayer(def), nil
+	// case layers.LocalResponseNorm:
+	default:
+		return nil, errors.New("unrecognized layer type")
+	}
 }
 
 type network struct {
 	layers []layers.Layer
+	names  []string
+
+	// defs holds the resolved definition of each layer
+	defs []layers.LayerDef
+
+	// inputDims holds the dimensions of each input
+	inputDims []volume.Dimensions
+
+	// lossInput is the volume fed into the loss layer by the last forward pass,
+	// which holds the loss gradients after a loss is computed
+	lossInput *volume.Volume
+
+	// graph routes volumes between the layers of graph networks, nil when the
+	// layers form a chain
+	graph *graphRoutes
+
+	// replicas run ForwardBatch in parallel
+	replicas []Network
+
+	// frozen holds the names of the layers left out of training
+	frozen map[string]bool
+
+	// labels holds the pprof labels of the layers, built on first use from those of
+	// profileCtx, which the goroutine is given back after every layer
+	labels     *layerLabels
+	profileCtx context.Context
+
+	// tracer traces the passes, nil when disabled, under the span of traceCtx while
+	// a traced trainer runs a step
+	tracer   Tracer
+	traceCtx context.Context
 }
 
 func (n *network) Size() int {
@@ -92,15 +289,62 @@ func (n *network) Layers() []layers.Layer {
 	return n.layers
 }
 
+// LayerName returns the name of the layer of net at the given index. It requires a
+// network built by this package.
+func LayerName(net Network, index int) string {
+	n, ok := net.(*network)
+	if !ok {
+		panic("layer names require a network built by NewNetwork or NewGraph")
+	}
+	return n.names[index]
+}
+
+// InputDimensions returns the dimensions of each input of net, in the order of
+// InputNames. It requires a network built by this package.
+func InputDimensions(net Network) []volume.Dimensions {
+	n, ok := net.(*network)
+	if !ok {
+		panic("input dimensions require a network built by NewNetwork or NewGraph")
+	}
+	return append([]volume.Dimensions(nil), n.inputDims...)
+}
+
 func (n *network) Forward(vol *volume.Volume, training bool) *volume.Volume {
-	actions := n.layers[0].Forward(vol, training)
-	for index := 1; index < len(n.layers); index++ {
-		actions = n.layers[index].Forward(vol, training)
+	if n.graph != nil {
+		if len(n.graph.sources) != 1 {
+			panic(fmt.Errorf("network has %d inputs, use ForwardMulti", len(n.graph.sources)))
+		}
+		return n.forwardGraph([]*volume.Volume{vol}, training)
 	}
+	ctx, pass := n.startPass("forward")
+	actions := vol
+	for index := 0; index < len(n.layers); index++ {
+		if index > 0 && index == len(n.layers)-1 {
+			n.lossInput = actions
+		}
+		span := n.beginLayer(ctx, index, false)
+		actions = n.layers[index].Forward(actions, training)
+		n.endLayer(index, span)
+	}
+	endSpan(pass)
 	return actions
 }
 
 func (n *network) Backward(index int) float64 {
+	return n.backwardWeighted(index, 1.0)
+}
+
+// BackwardWeighted is Backward with the loss and gradients of net scaled by weight.
+// It requires a network built by this package.
+func BackwardWeighted(net Network, index int, weight float64) float64 {
+	n, ok := net.(*network)
+	if !ok {
+		panic("weighted losses require a network built by NewNetwork or NewGraph")
+	}
+	return n.backwardWeighted(index, weight)
+}
+
+func (n *network) backwardWeighted(index int, weight float64) float64 {
 	size := n.Size()
 
 	// Calculate loss
@@ -110,11 +354,102 @@ func (n *network) Backward(index int) float64 {
 	}
 	loss := lossLayer.Loss(index)
 
-	// Propogate backwards
+	n.backpropagate(weight)
+	return loss * weight
+}
+
+// BackwardSoftTarget backpropagates the cross-entropy of the last forward pass of net
+// towards a distribution over the classes at the given temperature, with the loss and
+// gradients scaled by weight. It requires a network built by this package ending in a
+// softmax layer.
+func BackwardSoftTarget(net Network, target []float64, temperature, weight float64) float64 {
+	n, ok := net.(*network)
+	if !ok {
+		panic("soft targets require a network built by NewNetwork or NewGraph")
+	}
+	return n.backwardSoftTarget(target, temperature, weight)
+}
+
+func (n *network) backwardSoftTarget(target []float64, temperature, weight float64) float64 {
+	lossLayer, ok := n.layers[n.Size()-1].(layers.SoftTargetLossLayer)
+	if !ok {
+		panic("soft targets require a softmax layer as the last layer in the network")
+	}
+	loss := lossLayer.SoftTargetLoss(target, temperature)
+
+	n.backpropagate(weight)
+	return loss * weight
+}
+
+// BackwardPolicy backpropagates the policy gradient loss of the action taken in the
+// last forward pass of net, with its log-probability scaled by advantage and the
+// entropy of the policy weighted by entropy, and the loss and gradients scaled by
+// weight. It requires a network built by this package ending in a softmax layer.
+func BackwardPolicy(net Network, action int, advantage, entropy, weight float64) float64 {
+	n, ok := net.(*network)
+	if !ok {
+		panic("policy gradients require a network built by NewNetwork or NewGraph")
+	}
+	return n.backwardPolicy(action, advantage, entropy, weight)
+}
+
+func (n *network) backwardPolicy(action int, advantage, entropy, weight float64) float64 {
+	lossLayer, ok := n.layers[n.Size()-1].(layers.PolicyLossLayer)
+	if !ok {
+		panic("policy gradients require a softmax layer as the last layer in the network")
+	}
+	loss := lossLayer.PolicyLoss(action, advantage, entropy)
+
+	n.backpropagate(weight)
+	return loss * weight
+}
+
+// BackwardDense backpropagates the loss of the last forward pass of net towards a
+// label map holding the class of every position, with the loss and gradients scaled by
+// weight. It requires a network built by this package ending in a dense loss layer
+// such as pixelsoftmax.
+func BackwardDense(net Network, labels []int, weight float64) float64 {
+	n, ok := net.(*network)
+	if !ok {
+		panic("label maps require a network built by NewNetwork or NewGraph")
+	}
+	return n.backwardDense(labels, weight)
+}
+
+func (n *network) backwardDense(labels []int, weight float64) float64 {
+	lossLayer, ok := n.layers[n.Size()-1].(layers.DenseLossLayer)
+	if !ok {
+		panic("label maps require a dense loss layer as the last layer in the network")
+	}
+	loss := lossLayer.DenseLoss(labels)
+
+	n.backpropagate(weight)
+	return loss * weight
+}
+
+// backpropagate scales the loss gradients by weight and propagates them backwards
+// through the layers below the loss layer.
+func (n *network) backpropagate(weight float64) {
+	if n.graph != nil && len(n.graph.sinks) != 1 {
+		panic(fmt.Errorf("network has %d outputs, use BackwardMulti", len(n.graph.sinks)))
+	}
+	if weight != 1.0 {
+		g := n.lossInput.Gradients()
+		for i := range g {
+			g[i] *= weight
+		}
+	}
+	ctx, pass := n.startPass("backward")
+	defer endSpan(pass)
+	if n.graph != nil {
+		n.backwardGraph(ctx)
+		return
+	}
 	for index := n.Size() - 2; index >= 0; index-- {
+		span := n.beginLayer(ctx, index, true)
 		n.layers[index].Backward()
+		n.endLayer(index, span)
 	}
-	return loss
 }
 
 func (n *network) GetCostLoss(vol *volume.Volume, index int) float64 {
@@ -138,23 +473,72 @@ func (n *network) GetPrediction() int {
 	return layers.GetSoftMaxPrediction(S)
 }
 
+// GetPredictionFor returns the class predicted by the last forward pass of net, which
+// must end in a loss layer of the given type, the argmax of the probabilities for
+// softmax or of the raw scores for svm.
+func GetPredictionFor(net Network, lossType layers.LayerType) int {
+	last := net.Layers()[net.Size()-1]
+	if last.Type() != lossType {
+		panic(fmt.Errorf("GetPredictionFor(%s) found %s as the last layer in the network", lossType, last.Type()))
+	}
+	switch lossType {
+	case layers.SoftMax:
+		return layers.GetSoftMaxPrediction(last)
+	case layers.SVM:
+		return layers.GetSVMPrediction(last)
+	}
+	panic(fmt.Errorf("%s layers do not predict classes", lossType))
+}
+
+// GetRegressionOutput returns the values predicted by the last forward pass of net,
+// assuming the last layer in the network is a Regression layer.
+func GetRegressionOutput(net Network) []float64 {
+	last := net.Layers()[net.Size()-1]
+	if last.Type() != layers.Regression {
+		panic("GetRegressionOutput assumes Regression is the last layer in the network")
+	}
+	return layers.GetRegressionOutput(last)
+}
+
 func (n *network) GetResponse() []layers.LayerResponse {
 	// accumulate parameters and gradients for the entire network
 	resp := []layers.LayerResponse{}
 	for index := 0; index < len(n.layers); index++ {
 		layerResponse := n.layers[index].GetResponse()
+		for i := range layerResponse {
+			layerResponse[i].LayerIndex = index
+			layerResponse[i].LayerName = n.names[index]
+		}
 		resp = append(resp, layerResponse...)
 	}
 	return resp
 }
 
-// MultiDimensionalLoss computes the total loss for each of the values given.
+// MultiDimensionalLoss computes the total loss for each of the values given and
+// propagates the gradients backwards.
 func (n *network) MultiDimensionalLoss(y []float64) float64 {
+	return n.multiDimensionalLossWeighted(y, 1.0)
+}
+
+// MultiDimensionalLossWeighted is MultiDimensionalLoss with the loss and gradients
+// of net scaled by weight. It requires a network built by this package.
+func MultiDimensionalLossWeighted(net Network, y []float64, weight float64) float64 {
+	n, ok := net.(*network)
+	if !ok {
+		panic("weighted losses require a network built by NewNetwork or NewGraph")
+	}
+	return n.multiDimensionalLossWeighted(y, weight)
+}
+
+func (n *network) multiDimensionalLossWeighted(y []float64, weight float64) float64 {
 	lossLayer, ok := n.layers[n.Size()-1].(layers.RegressionLossLayer)
 	if !ok {
 		panic("MultiDimensionalLoss assumes a Regression layer is the last layer in the network")
 	}
-	return lossLayer.MultiDimensionalLoss(y)
+	loss := lossLayer.MultiDimensionalLoss(y)
+
+	n.backpropagate(weight)
+	return loss * weight
 }
 
 func (n *network) DimensionalLoss(index int, value float64) float64 {
@@ -162,5 +546,196 @@ func (n *network) DimensionalLoss(index int, value float64) float64 {
 	if !ok {
 		panic("DimensionalLoss assumes a Regression layer is the last layer in the network")
 	}
-	return lossLayer.DimensionalLoss(index, value)
+	loss := lossLayer.DimensionalLoss(index, value)
+
+	n.backpropagate(1.0)
+	return loss
+}
+
+// Clone returns a deep copy of net, which shares no weights or state with it. It
+// requires a network built by this package.
+func Clone(net Network) Network {
+	n, ok := net.(*network)
+	if !ok {
+		panic("cloning requires a network built by NewNetwork or NewGraph")
+	}
+	return n.clone()
+}
+
+func (n *network) clone() Network {
+	// seeded networks give the copy its own source for dropout, derived from theirs
+	var rng *rand.Rand
+	for _, def := range n.defs {
+		if def.Rand != nil {
+			rng = rand.New(rand.NewSource(def.Rand.Int63()))
+			break
+		}
+	}
+
+	c := &network{
+		names:     append([]string(nil), n.names...),
+		defs:      append([]layers.LayerDef(nil), n.defs...),
+		inputDims: append([]volume.Dimensions(nil), n.inputDims...),
+	}
+	for i, def := range c.defs {
+		if def.Rand != nil {
+			c.defs[i].Rand = rng
+		}
+		layer, err := newLayer(c.defs[i])
+		if err != nil {
+			panic(err)
+		}
+		c.layers = append(c.layers, layer)
+	}
+	if n.graph != nil {
+		c.graph = &graphRoutes{inputs: n.graph.inputs, sources: n.graph.sources, sinks: n.graph.sinks}
+		c.graph.init(len(c.layers))
+	}
+
+	for name := range n.frozen {
+		if c.frozen == nil {
+			c.frozen = map[string]bool{}
+		}
+		c.frozen[name] = true
+	}
+
+	c.tracer = n.tracer
+	c.profileCtx = n.profileCtx
+
+	// copied by name, so frozen layers are included
+	if _, err := CopyWeightsByName(c, n); err != nil {
+		panic(err)
+	}
+	return c
+}
+
+// ForwardE, BackwardE and PredictE are like the Forward, Backward and GetPrediction
+// methods of net but return an error instead of panicking on mismatched volumes,
+// labels or layers. They require a network built by this package.
+func ForwardE(net Network, vol *volume.Volume, training bool) (*volume.Volume, error) {
+	n, ok := net.(*network)
+	if !ok {
+		return nil, errors.New("checked passes require a network built by NewNetwork or NewGraph")
+	}
+	return n.forwardE(vol, training)
+}
+
+// BackwardE is Backward returning an error, see ForwardE.
+func BackwardE(net Network, index int) (float64, error) {
+	n, ok := net.(*network)
+	if !ok {
+		return 0, errors.New("checked passes require a network built by NewNetwork or NewGraph")
+	}
+	return n.backwardE(index)
+}
+
+// PredictE runs net in inference mode and returns the predicted class, see ForwardE.
+func PredictE(net Network, vol *volume.Volume) (int, error) {
+	n, ok := net.(*network)
+	if !ok {
+		return 0, errors.New("checked passes require a network built by NewNetwork or NewGraph")
+	}
+	return n.predictE(vol)
+}
+
+func (n *network) forwardE(vol *volume.Volume, training bool) (out *volume.Volume, err error) {
+	if vol == nil {
+		return nil, errors.New("volume cannot be nil")
+	} else if len(n.inputDims) != 1 {
+		return nil, fmt.Errorf("network has %d inputs, use ForwardMulti", len(n.inputDims))
+	} else if dim := vol.Dimensions(); dim != n.inputDims[0] {
+		return nil, fmt.Errorf("volume dimensions %v do not match the input %v", dim, n.inputDims[0])
+	}
+	defer recoverError(&err)
+	return n.Forward(vol, training), nil
+}
+
+func (n *network) backwardE(index int) (loss float64, err error) {
+	if _, ok := n.layers[n.Size()-1].(layers.LossLayer); !ok {
+		return 0, fmt.Errorf("last layer must be a classification loss layer, got %s", n.layers[n.Size()-1].Type())
+	} else if n.lossInput == nil {
+		return 0, errors.New("backward pass requires a forward pass first")
+	} else if index < 0 || index >= n.lossInput.Size() {
+		return 0, fmt.Errorf("label %d out of range [0, %d)", index, n.lossInput.Size())
+	}
+	defer recoverError(&err)
+	return n.Backward(index), nil
+}
+
+func (n *network) predictE(vol *volume.Volume) (int, error) {
+	last := n.layers[n.Size()-1]
+	if last.Type() != layers.SoftMax && last.Type() != layers.SVM {
+		return 0, fmt.Errorf("prediction requires a softmax or svm output layer, got %s", last.Type())
+	}
+	out, err := n.forwardE(vol, false)
+	if err != nil {
+		return 0, err
+	}
+	return argmax(out.Weights()), nil
+}
+
+// Validate reports structural problems of net, such as a missing input or loss layer
+// or layers whose dimensions do not line up, by running a forward pass on zeros. It
+// requires a network built by this package.
+func Validate(net Network) error {
+	n, ok := net.(*network)
+	if !ok {
+		return errors.New("validation requires a network built by NewNetwork or NewGraph")
+	}
+	return n.validate()
+}
+
+func (n *network) validate() error {
+	if len(n.layers) == 0 {
+		return errors.New("network has no layers")
+	} else if n.layers[0].Type() != layers.Input {
+		return fmt.Errorf("first layer must be an input layer, got %s", n.layers[0].Type())
+	}
+	if err := checkLossLayers(n); err != nil {
+		return err
+	}
+
+	inputs := map[string]*volume.Volume{}
+	for k, name := range n.InputNames() {
+		inputs[name] = volume.NewVolume(n.inputDims[k], volume.WithZeros())
+	}
+	var err error
+	func() {
+		defer recoverError(&err)
+		n.ForwardMulti(inputs, false)
+	}()
+	if err != nil {
+		return fmt.Errorf("forward pass failed: %w", err)
+	}
+	return nil
+}
+
+// checkLossLayers checks that the network ends in a loss layer, or for networks with
+// several outputs that at least one of them is a loss layer.
+func checkLossLayers(net Network) error {
+	isLoss := func(l layers.Layer) bool {
+		_, isLoss := l.(layers.LossLayer)
+		_, isRegression := l.(layers.RegressionLossLayer)
+		_, isDense := l.(layers.DenseLossLayer)
+		return isLoss || isRegression || isDense
+	}
+
+	if m, ok := net.(MultiNetwork); ok && len(m.OutputNames()) > 1 {
+		outputs := map[string]bool{}
+		for _, name := range m.OutputNames() {
+			outputs[name] = true
+		}
+		for i, l := range net.Layers() {
+			if outputs[LayerName(net, i)] && isLoss(l) {
+				return nil
+			}
+		}
+		return fmt.Errorf("none of the outputs %v is a loss layer", m.OutputNames())
+	}
+
+	last := net.Layers()[net.Size()-1]
+	if !isLoss(last) {
+		return fmt.Errorf("last layer must be a loss layer, got %s", last.Type())
+	}
+	return nil
 }
@@ -0,0 +1,82 @@
+package reticulum
+
+import (
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/nathanleary/reticulum/layers"
+	"github.com/nathanleary/reticulum/volume"
+)
+
+// dense returns the outputs of a fully connected layer given its parameters, the
+// weights of every neuron followed by the biases.
+func dense(pgs []layers.LayerResponse, x []float64) []float64 {
+	bias := pgs[len(pgs)-1].Weights
+	out := make([]float64, len(bias))
+	for i := range out {
+		out[i] = bias[i]
+		for j, w := range pgs[i].Weights {
+			out[i] += w * x[j]
+		}
+	}
+	return out
+}
+
+func TestNetwork_ForwardChainsLayers(t *testing.T) {
+	net, err := NewNetwork([]layers.LayerDef{
+		{Type: layers.Input, Output: volume.NewDimensions(1, 1, 2)},
+		{Type: layers.FullyConnected, LayerConfig: layers.NewFullyConnectedLayerConfig(3, layers.WithBias(0.1))},
+		{Type: layers.FullyConnected, LayerConfig: layers.NewFullyConnectedLayerConfig(4, layers.WithBias(-0.2))},
+		{Type: layers.Regression, LayerConfig: layers.NewRegressionLayerConfig(2)},
+	}, WithNetworkSeed(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the fc layers have 3, 4 and 2 neurons, the last one added for the regression
+	// layer, each followed by its biases
+	pgs := net.GetResponse()
+	x := []float64{0.5, -1.5}
+	want := dense(pgs[9:], dense(pgs[4:9], dense(pgs[:4], x)))
+
+	vol := volume.NewVolume(volume.NewDimensions(1, 1, 2), volume.WithWeights(x))
+	got := net.Forward(vol, false).Weights()
+	for i := range want {
+		if math.Abs(got[i]-want[i]) > 1e-12 {
+			t.Errorf("Forward()[%d] = %v, want %v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	valid := func() Network { return privacyNetwork(t, 4, 3) }
+	for _, test := range []struct {
+		name    string
+		net     func() Network
+		wantErr string
+	}{
+		{"valid", valid, ""},
+		{"forward pass panics", func() Network {
+			// the fc layer reads past the end of its filters on a larger input
+			net := valid()
+			net.(*network).inputDims[0] = volume.NewDimensions(1, 1, 7)
+			return net
+		}, "forward pass failed"},
+		{"no loss layer", func() Network {
+			net := valid()
+			n := net.(*network)
+			n.layers = n.layers[:len(n.layers)-1]
+			return net
+		}, "loss layer"},
+	} {
+		err := Validate(test.net())
+		if test.wantErr == "" {
+			if err != nil {
+				t.Errorf("%s: Validate() = %v", test.name, err)
+			}
+		} else if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+			t.Errorf("%s: Validate() = %v, want an error containing %q", test.name, err, test.wantErr)
+		}
+	}
+}
@@ -1,5 +1,10 @@
 package reticulum
 
+import (
+	"errors"
+	"fmt"
+)
+
 type TrainingMethod string
 
 // Available training methods
@@ -21,11 +26,73 @@ type Options struct {
 	L2Decay      float64
 	BatchSize    int
 
+	// AccumulationSteps is the number of Train calls whose gradients are summed before
+	// an update, overriding BatchSize when > 0. The update always uses the mean
+	// gradient over the accumulated samples, so the effective batch size is the
+	// number of samples per call times AccumulationSteps and the learning rate does
+	// not need to be rescaled.
+	AccumulationSteps int
+
 	Momentum float64
 	Ro       float64
 	Eps      float64
 	Beta1    float64
 	Beta2    float64
+
+	Scheduler Scheduler
+
+	// GradClipValue clips each raw batch gradient to [-v, v] when > 0.
+	GradClipValue float64
+
+	// GradClipNorm rescales the raw batch gradients when their global L2 norm exceeds it (if > 0).
+	GradClipNorm float64
+
+	// NoiseEta and NoiseGamma control the annealed gradient noise, disabled when NoiseEta is 0.
+	NoiseEta   float64
+	NoiseGamma float64
+
+	// GradCentralization subtracts the mean from each filter's gradient before the update.
+	GradCentralization bool
+
+	// Privacy enables differentially private SGD when set.
+	Privacy *PrivacyOptions
+
+	// HistoryLimit bounds the number of iterations kept in the history, 0 keeps
+	// DefaultHistoryLimit and a negative limit keeps all.
+	HistoryLimit int
+
+	// HasSeed seeds the trainer's random source (gradient noise, DP-SGD noise and
+	// Fit shuffling) with Seed.
+	HasSeed bool
+	Seed    int64
+
+	// Callbacks are notified as training progresses.
+	Callbacks []Callback
+
+	// ParamGroups override the options above for subsets of the network parameters.
+	ParamGroups []ParamGroup
+
+	// ClassWeights scale the loss of labeled samples by class in Fit, classes without
+	// an entry keep a weight of 1. BalanceClasses derives them from the label
+	// frequencies of the training data instead.
+	ClassWeights   map[int]float64
+	BalanceClasses bool
+
+	// Tracer traces every training step, disabled when nil.
+	Tracer Tracer
+
+	// track explicitly set options, to detect settings which the method ignores
+	learningRateSet bool
+	momentumSet     bool
+}
+
+// newOptions returns the default options with the given options applied.
+func newOptions(opts ...OptionFunc) *Options {
+	baseOpts := &Options{Method: SGD, LearningRate: 0.01, BatchSize: 1, Momentum: 0.9, Ro: 0.95, Eps: 1e-8, Beta1: 0.9, Beta2: 0.999}
+	for _, optFn := range opts {
+		optFn(baseOpts)
+	}
+	return baseOpts
 }
 
 func WithMethod(m TrainingMethod) OptionFunc {
@@ -37,6 +104,7 @@ func WithMethod(m TrainingMethod) OptionFunc {
 func WithLearningRate(rate float64) OptionFunc {
 	return func(opts *Options) {
 		opts.LearningRate = rate
+		opts.learningRateSet = true
 	}
 }
 
@@ -53,9 +121,18 @@ func WithBatchSize(size int) OptionFunc {
 	}
 }
 
+// WithAccumulationSteps applies an update every n Train calls, averaging the gradients
+// over all samples accumulated in between.
+func WithAccumulationSteps(n int) OptionFunc {
+	return func(opts *Options) {
+		opts.AccumulationSteps = n
+	}
+}
+
 func WithMomentum(m float64) OptionFunc {
 	return func(opts *Options) {
 		opts.Momentum = m
+		opts.momentumSet = true
 	}
 }
 
@@ -73,3 +150,134 @@ func WithAdam(ro, beta1, beta2 float64) OptionFunc {
 		opts.Beta2 = beta2
 	}
 }
+
+func WithScheduler(s Scheduler) OptionFunc {
+	return func(opts *Options) {
+		opts.Scheduler = s
+	}
+}
+
+func WithGradClipValue(v float64) OptionFunc {
+	return func(opts *Options) {
+		opts.GradClipValue = v
+	}
+}
+
+func WithGradClipNorm(maxNorm float64) OptionFunc {
+	return func(opts *Options) {
+		opts.GradClipNorm = maxNorm
+	}
+}
+
+// WithGradientNoise adds gaussian noise with variance eta / (1 + k)^gamma to the
+// gradients at iteration k. Typical values are eta in {0.01, 0.3, 1.0} and gamma 0.55.
+func WithGradientNoise(eta, gamma float64) OptionFunc {
+	return func(opts *Options) {
+		opts.NoiseEta = eta
+		opts.NoiseGamma = gamma
+	}
+}
+
+func WithGradientCentralization() OptionFunc {
+	return func(opts *Options) {
+		opts.GradCentralization = true
+	}
+}
+
+// WithSeed makes training deterministic by seeding the trainer's random source. For
+// identical runs the network must also be built with WithNetworkSeed.
+func WithSeed(seed int64) OptionFunc {
+	return func(opts *Options) {
+		opts.HasSeed = true
+		opts.Seed = seed
+	}
+}
+
+// WithClassWeights scales the loss and gradients of each labeled sample in Fit by the
+// weight of its class.
+func WithClassWeights(weights map[int]float64) OptionFunc {
+	return func(opts *Options) {
+		opts.ClassWeights = weights
+	}
+}
+
+// WithBalancedClassWeights weights the classes in Fit inversely to their frequency in
+// the training data, see BalancedClassWeights.
+func WithBalancedClassWeights() OptionFunc {
+	return func(opts *Options) {
+		opts.BalanceClasses = true
+	}
+}
+
+// Validate reports invalid values and option combinations which would be silently ignored.
+func (o *Options) Validate() error {
+	switch o.Method {
+	case SGD, Adam, Adagrad, Adadelta, Windowgrad, Netsterov:
+	default:
+		return fmt.Errorf("unknown training method: %q", o.Method)
+	}
+
+	if o.Method == Adadelta {
+		if o.learningRateSet {
+			return errors.New("adadelta does not use a learning rate, remove WithLearningRate")
+		} else if o.Scheduler != nil {
+			return errors.New("adadelta does not use a learning rate, remove the scheduler")
+		}
+	} else if o.LearningRate <= 0 {
+		return fmt.Errorf("learning rate must be greater than 0, got %v", o.LearningRate)
+	}
+	if o.momentumSet && o.Method != SGD && o.Method != Netsterov {
+		return fmt.Errorf("momentum is ignored by %s, it only applies to sgd and netsterov", o.Method)
+	}
+
+	if o.BatchSize < 1 {
+		return fmt.Errorf("batch size must be at least 1, got %d", o.BatchSize)
+	} else if o.AccumulationSteps < 0 {
+		return fmt.Errorf("accumulation steps cannot be negative, got %d", o.AccumulationSteps)
+	} else if o.L1Decay < 0 || o.L2Decay < 0 {
+		return errors.New("decay cannot be negative")
+	} else if o.Momentum < 0 || o.Momentum >= 1 {
+		return fmt.Errorf("momentum must be in [0, 1), got %v", o.Momentum)
+	} else if o.Ro <= 0 || o.Ro >= 1 {
+		return fmt.Errorf("ro must be in (0, 1), got %v", o.Ro)
+	} else if o.Beta1 < 0 || o.Beta1 >= 1 || o.Beta2 < 0 || o.Beta2 >= 1 {
+		return errors.New("beta1 and beta2 must be in [0, 1)")
+	} else if o.Eps <= 0 {
+		return fmt.Errorf("eps must be greater than 0, got %v", o.Eps)
+	} else if o.GradClipValue < 0 || o.GradClipNorm < 0 {
+		return errors.New("gradient clipping thresholds cannot be negative")
+	} else if o.NoiseEta < 0 {
+		return errors.New("gradient noise eta cannot be negative")
+	}
+
+	if p := o.Privacy; p != nil {
+		if p.ClipNorm <= 0 {
+			return errors.New("differential privacy requires a clip norm greater than 0")
+		} else if p.NoiseMultiplier <= 0 {
+			return errors.New("differential privacy requires a noise multiplier greater than 0")
+		} else if p.Accountant != nil && p.DatasetSize <= 0 {
+			return errors.New("differential privacy accounting requires the dataset size")
+		} else if err := p.accountantMismatch(); err != nil {
+			return err
+		}
+	}
+
+	if o.BalanceClasses && o.ClassWeights != nil {
+		return errors.New("balanced class weights replace the explicit class weights, set only one")
+	}
+	for class, w := range o.ClassWeights {
+		if w < 0 {
+			return fmt.Errorf("class %d: weight cannot be negative", class)
+		}
+	}
+
+	for i, g := range o.ParamGroups {
+		if g.Selector == nil {
+			return fmt.Errorf("parameter group %d: selector cannot be nil", i)
+		}
+		if err := g.resolve(o).Validate(); err != nil {
+			return fmt.Errorf("parameter group %d: %w", i, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+// Package oteltrace adapts an OpenTelemetry tracer to the Tracer of reticulum, so
+// the layer passes and training steps are exported along with the spans of the
+// rest of an application:
+//
+//	tracer := oteltrace.New(nil) // the global tracer provider
+//	net, err := reticulum.NewNetwork(defs, reticulum.WithNetworkTracer(tracer))
+//	if err != nil {
+//		return err
+//	}
+//	trainer := reticulum.NewTrainer(net, reticulum.WithTracer(tracer))
+package oteltrace
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/nathanleary/reticulum"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// name is the instrumentation scope of the spans.
+const name = "github.com/nathanleary/reticulum"
+
+// New returns a Tracer starting its spans with a tracer of tp, or of the global
+// tracer provider when tp is nil.
+func New(tp trace.TracerProvider) reticulum.Tracer {
+	if tp == nil {
+		tp = otel.GetTracerProvider()
+	}
+	return tracer{tp.Tracer(name)}
+}
+
+type tracer struct {
+	t trace.Tracer
+}
+
+func (t tracer) Start(ctx context.Context, name string, attrs ...reticulum.TraceAttribute) (context.Context, reticulum.Span) {
+	ctx, s := t.t.Start(ctx, name, trace.WithAttributes(convert(attrs)...))
+	return ctx, span{s}
+}
+
+type span struct {
+	s trace.Span
+}
+
+func (s span) SetAttributes(attrs ...reticulum.TraceAttribute) {
+	s.s.SetAttributes(convert(attrs)...)
+}
+
+func (s span) End() {
+	s.s.End()
+}
+
+func convert(attrs []reticulum.TraceAttribute) []attribute.KeyValue {
+	kvs := make([]attribute.KeyValue, len(attrs))
+	for i, a := range attrs {
+		switch v := a.Value.(type) {
+		case string:
+			kvs[i] = attribute.String(a.Key, v)
+		case int:
+			kvs[i] = attribute.Int(a.Key, v)
+		case int64:
+			kvs[i] = attribute.Int64(a.Key, v)
+		case float64:
+			kvs[i] = attribute.Float64(a.Key, v)
+		case bool:
+			kvs[i] = attribute.Bool(a.Key, v)
+		default:
+			kvs[i] = attribute.String(a.Key, fmt.Sprint(v))
+		}
+	}
+	return kvs
+}
@@ -0,0 +1,118 @@
+package oteltrace
+
+import (
+	"context"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/embedded"
+	"go.opentelemetry.io/otel/trace/noop"
+
+	"github.com/nathanleary/reticulum"
+	"github.com/nathanleary/reticulum/layers"
+	"github.com/nathanleary/reticulum/volume"
+)
+
+// recorder is a tracer provider keeping every span started.
+type recorder struct {
+	embedded.TracerProvider
+	spans []*recorded
+}
+
+type recordingTracer struct {
+	embedded.Tracer
+	r *recorder
+}
+
+type recorded struct {
+	trace.Span
+	name   string
+	parent *recorded
+	attrs  map[attribute.Key]attribute.Value
+	ended  bool
+}
+
+func (r *recorder) Tracer(string, ...trace.TracerOption) trace.Tracer {
+	return recordingTracer{r: r}
+}
+
+func (t recordingTracer) Start(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	s := &recorded{Span: noop.Span{}, name: name, attrs: map[attribute.Key]attribute.Value{}}
+	s.parent, _ = trace.SpanFromContext(ctx).(*recorded)
+	cfg := trace.NewSpanStartConfig(opts...)
+	s.SetAttributes(cfg.Attributes()...)
+	t.r.spans = append(t.r.spans, s)
+	return trace.ContextWithSpan(ctx, s), s
+}
+
+func (s *recorded) SetAttributes(kvs ...attribute.KeyValue) {
+	for _, kv := range kvs {
+		s.attrs[kv.Key] = kv.Value
+	}
+}
+
+func (s *recorded) End(...trace.SpanEndOption) {
+	s.ended = true
+}
+
+func TestTracer_Attributes(t *testing.T) {
+	rec := &recorder{}
+	_, span := New(rec).Start(context.Background(), "span",
+		reticulum.TraceAttribute{Key: "string", Value: "a"},
+		reticulum.TraceAttribute{Key: "int", Value: 2},
+		reticulum.TraceAttribute{Key: "int64", Value: int64(3)},
+		reticulum.TraceAttribute{Key: "float", Value: 0.5},
+		reticulum.TraceAttribute{Key: "bool", Value: true})
+	span.SetAttributes(reticulum.TraceAttribute{Key: "other", Value: []int{1}})
+	span.End()
+
+	got := rec.spans[0]
+	for _, want := range []attribute.KeyValue{
+		attribute.String("string", "a"),
+		attribute.Int("int", 2),
+		attribute.Int64("int64", 3),
+		attribute.Float64("float", 0.5),
+		attribute.Bool("bool", true),
+		attribute.String("other", "[1]"),
+	} {
+		if v := got.attrs[want.Key]; v != want.Value {
+			t.Errorf("attribute %s = %v, want %v", want.Key, v.Emit(), want.Value.Emit())
+		}
+	}
+	if !got.ended {
+		t.Error("span was not ended")
+	}
+}
+
+func TestTracer_TrainingStep(t *testing.T) {
+	rec := &recorder{}
+	tracer := New(rec)
+	net, err := reticulum.NewNetwork([]layers.LayerDef{
+		{Type: layers.Input, Output: volume.NewDimensions(1, 1, 2)},
+		{Type: layers.SoftMax, LayerConfig: layers.NewSoftmaxLayerConfig(2)},
+	}, reticulum.WithNetworkSeed(1), reticulum.WithNetworkTracer(tracer))
+	if err != nil {
+		t.Fatal(err)
+	}
+	trainer := reticulum.NewTrainer(net, reticulum.WithTracer(tracer))
+	trainer.Train(volume.NewVolume(volume.NewDimensions(1, 1, 2), volume.WithZeros()), reticulum.LabeledLossFunc(1))
+
+	counts := map[string]int{}
+	for _, s := range rec.spans {
+		counts[s.name]++
+		if !s.ended {
+			t.Errorf("span %s was not ended", s.name)
+		}
+		if s.name == "layer.forward" && (s.parent == nil || s.parent.parent == nil || s.parent.parent.name != "train.step") {
+			t.Errorf("layer span is not under the pass of the training step")
+		}
+	}
+	if counts["train.step"] != 1 || counts["layer.forward"] != net.Size() || counts["layer.backward"] == 0 {
+		t.Errorf("recorded spans %v", counts)
+	}
+	step := rec.spans[0]
+	if step.name != "train.step" || step.attrs["train.iteration"].AsInt64() != 1 || step.attrs["train.loss"].Type() != attribute.FLOAT64 {
+		t.Errorf("training step span %s has attributes %v", step.name, step.attrs)
+	}
+}
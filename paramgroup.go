@@ -0,0 +1,57 @@
+package reticulum
+
+import (
+	"github.com/nathanleary/reticulum/layers"
+)
+
+// ParamSelector reports whether a set of parameters belongs to a parameter group.
+type ParamSelector func(resp layers.LayerResponse) bool
+
+// SelectLayers selects the parameters of the layers with the given names.
+func SelectLayers(names ...string) ParamSelector {
+	set := make(map[string]bool, len(names))
+	for _, name := range names {
+		set[name] = true
+	}
+	return func(resp layers.LayerResponse) bool {
+		return set[resp.LayerName]
+	}
+}
+
+// SelectLayerRange selects the parameters of the layers with an index in [from, to).
+func SelectLayerRange(from, to int) ParamSelector {
+	return func(resp layers.LayerResponse) bool {
+		return resp.LayerIndex >= from && resp.LayerIndex < to
+	}
+}
+
+// ParamGroup overrides the optimizer settings for the selected parameters.
+type ParamGroup struct {
+	Selector ParamSelector
+	Options  []OptionFunc
+}
+
+// WithParamGroup applies the given options to the parameters matched by selector,
+// on top of the trainer-wide options. Only per-parameter settings take effect:
+// Method, LearningRate, L1Decay, L2Decay, Momentum, Ro, Eps, Beta1 and Beta2.
+// When several groups match, the first one registered wins.
+func WithParamGroup(selector ParamSelector, opts ...OptionFunc) OptionFunc {
+	return func(o *Options) {
+		o.ParamGroups = append(o.ParamGroups, ParamGroup{selector, opts})
+	}
+}
+
+// resolve returns the options for the group, layered on top of the base options.
+// What the base options set explicitly, and the trainer-wide scheduler, are cleared
+// first, so validation only judges what the group itself sets, e.g. a group may
+// switch to adadelta under a base WithLearningRate.
+func (g ParamGroup) resolve(base *Options) *Options {
+	opts := *base
+	opts.ParamGroups = nil
+	opts.learningRateSet, opts.momentumSet = false, false
+	opts.Scheduler = nil
+	for _, optFn := range g.Options {
+		optFn(&opts)
+	}
+	return &opts
+}
@@ -0,0 +1,27 @@
+package reticulum
+
+import (
+	"testing"
+)
+
+func TestParamGroup_ValidatesOnlyItsOwnSettings(t *testing.T) {
+	net := privacyNetwork(t, 4, 3)
+	first := SelectLayerRange(0, 2)
+	tests := []struct {
+		name    string
+		opts    []OptionFunc
+		wantErr bool
+	}{
+		{"adadelta under a base learning rate", []OptionFunc{WithLearningRate(0.1), WithParamGroup(first, WithMethod(Adadelta))}, false},
+		{"adadelta under a base scheduler", []OptionFunc{WithScheduler(NewReduceLROnPlateau(0.5, 1)), WithParamGroup(first, WithMethod(Adadelta))}, false},
+		{"adam under a base momentum", []OptionFunc{WithMomentum(0.9), WithParamGroup(first, WithAdam(0.95, 0.9, 0.999))}, false},
+		{"adadelta with its own learning rate", []OptionFunc{WithParamGroup(first, WithMethod(Adadelta), WithLearningRate(0.1))}, true},
+		{"adam with its own momentum", []OptionFunc{WithParamGroup(first, WithAdam(0.95, 0.9, 0.999), WithMomentum(0.9))}, true},
+	}
+	for _, test := range tests {
+		_, err := NewTrainerE(net, test.opts...)
+		if (err != nil) != test.wantErr {
+			t.Errorf("%s: NewTrainerE() error = %v, want error %v", test.name, err, test.wantErr)
+		}
+	}
+}
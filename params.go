@@ -0,0 +1,90 @@
+package reticulum
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/nathanleary/reticulum/layers"
+	"github.com/nathanleary/reticulum/volume"
+)
+
+// NamedParameters returns a copy of the weights of every layer of net, frozen or not,
+// keyed "layer/i" for the i-th filter of a layer and "layer/bias" for its biases.
+func NamedParameters(net Network) map[string]*volume.Volume {
+	params := map[string]*volume.Volume{}
+	eachParameter(net, func(name string, resp layers.LayerResponse) {
+		params[name] = volume.NewVolume(volume.NewDimensions(1, 1, len(resp.Weights)), volume.WithWeights(resp.Weights))
+	})
+	return params
+}
+
+// LoadNamedParameters copies the given weights into the parameters of net of the same
+// name, as returned by NamedParameters. Sizes must match. When strict, every parameter
+// of the network must be given and no others; otherwise unknown names are skipped and
+// missing parameters left unchanged. Nothing is written on error.
+func LoadNamedParameters(net Network, params map[string][]float64, strict bool) error {
+	targets := map[string][]float64{}
+	eachParameter(net, func(name string, resp layers.LayerResponse) {
+		targets[name] = resp.Weights
+	})
+
+	// everything is checked before any weight is written
+	var missing, unknown []string
+	for name, w := range params {
+		dst, ok := targets[name]
+		if !ok {
+			unknown = append(unknown, name)
+		} else if len(dst) != len(w) {
+			return fmt.Errorf("parameter %q: size mismatch: %d != %d", name, len(dst), len(w))
+		}
+	}
+	for name := range targets {
+		if _, ok := params[name]; !ok {
+			missing = append(missing, name)
+		}
+	}
+	if strict && len(unknown) > 0 {
+		sort.Strings(unknown)
+		return fmt.Errorf("unknown parameters: %v", unknown)
+	} else if strict && len(missing) > 0 {
+		sort.Strings(missing)
+		return fmt.Errorf("missing parameters: %v", missing)
+	}
+
+	for name, w := range params {
+		if dst, ok := targets[name]; ok {
+			copy(dst, w)
+		}
+	}
+	return nil
+}
+
+// eachParameter calls fn with every parameter set of net, frozen or not, with its name
+// from parameterNames.
+func eachParameter(net Network, fn func(name string, resp layers.LayerResponse)) {
+	pgList := net.GetResponse()
+	for i, name := range parameterNames(pgList) {
+		fn(name, pgList[i])
+	}
+}
+
+// parameterNames names the parameter sets returned by GetResponse, or a subset of
+// whole layers of them, "layer/i" for the i-th filter of the layer and "layer/bias"
+// for its biases.
+func parameterNames(pgList []layers.LayerResponse) []string {
+	names := make([]string, len(pgList))
+	var filter int
+	for i, pg := range pgList {
+		if i == 0 || pg.LayerIndex != pgList[i-1].LayerIndex {
+			filter = 0
+		}
+		suffix := "bias"
+		if !pg.Bias {
+			suffix = strconv.Itoa(filter)
+			filter++
+		}
+		names[i] = pg.LayerName + "/" + suffix
+	}
+	return names
+}
@@ -0,0 +1,71 @@
+package reticulum
+
+import (
+	"runtime"
+	"sync"
+	"sync/atomic"
+
+	"github.com/nathanleary/reticulum/layers"
+	"github.com/nathanleary/reticulum/volume"
+)
+
+// PredictOptions configures PredictAll.
+type PredictOptions struct {
+	// Parallelism is the number of goroutines, each with its own clone of the
+	// network. Defaults to GOMAXPROCS.
+	Parallelism int
+}
+
+// PredictOptionFunc modifies the PredictOptions.
+type PredictOptionFunc func(*PredictOptions)
+
+// WithPredictParallelism sets the number of goroutines used by PredictAll.
+func WithPredictParallelism(n int) PredictOptionFunc {
+	return func(opts *PredictOptions) {
+		opts.Parallelism = n
+	}
+}
+
+// PredictAll runs the network in inference mode on every volume and returns the
+// predictions in order, for offline scoring. The volumes are shared between
+// goroutines working on their own clones of the network, so net itself is left
+// untouched. An input which fails, e.g. because of its dimensions, only sets the
+// error of its own prediction.
+func PredictAll(net Network, vols []*volume.Volume, opts ...PredictOptionFunc) []Prediction {
+	o := &PredictOptions{Parallelism: runtime.GOMAXPROCS(0)}
+	for _, opt := range opts {
+		opt(o)
+	}
+	workers := min(max(o.Parallelism, 1), len(vols))
+
+	last := net.Layers()[net.Size()-1].Type()
+	classes := last == layers.SoftMax || last == layers.SVM
+
+	preds := make([]Prediction, len(vols))
+	var next atomic.Int64
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(local Network) {
+			defer wg.Done()
+			for {
+				i := int(next.Add(1) - 1)
+				if i >= len(vols) {
+					return
+				}
+				preds[i] = Prediction{Index: i, Class: -1}
+				out, err := ForwardE(local, vols[i], false)
+				if err != nil {
+					preds[i].Err = err
+					continue
+				}
+				preds[i].Output = out.Clone()
+				if classes {
+					preds[i].Class = argmax(out.Weights())
+				}
+			}
+		}(Clone(net))
+	}
+	wg.Wait()
+	return preds
+}
@@ -0,0 +1,116 @@
+package preprocess
+
+import (
+	"bytes"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/nathanleary/reticulum"
+	"github.com/nathanleary/reticulum/datasets"
+	"github.com/nathanleary/reticulum/volume"
+)
+
+// rows returns a source of 1x1xN samples with the given inputs.
+func rows(inputs ...[]float64) datasets.Samples {
+	s := make(datasets.Samples, len(inputs))
+	for i, w := range inputs {
+		s[i] = reticulum.Sample{Input: volume.NewVolume(volume.NewDimensions(1, 1, len(w)), volume.WithWeights(w))}
+	}
+	return s
+}
+
+func TestScalers(t *testing.T) {
+	// the second element is constant
+	train := rows([]float64{1, 5, -2}, []float64{3, 5, 0}, []float64{5, 5, 2})
+	for _, test := range []struct {
+		name   string
+		scaler Scaler
+		in     []float64
+		want   []float64
+	}{
+		{"standard", NewStandardScaler(), []float64{3, 5, 2}, []float64{0, 0, math.Sqrt(1.5)}},
+		{"standard outside the range", NewStandardScaler(), []float64{7, 6, -2}, []float64{math.Sqrt(6), 1, -math.Sqrt(1.5)}},
+		{"minmax", NewMinMaxScaler(0, 1), []float64{3, 5, 2}, []float64{0.5, 0, 1}},
+		{"minmax to [-1, 1]", NewMinMaxScaler(-1, 1), []float64{7, 6, -1}, []float64{2, -1, -0.5}},
+	} {
+		if err := test.scaler.Fit(train); err != nil {
+			t.Fatal(err)
+		}
+
+		// the scaler restored from Save scales like the original
+		var buf bytes.Buffer
+		if err := Save(&buf, test.scaler); err != nil {
+			t.Fatal(err)
+		}
+		loaded, err := Load(&buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		in := volume.NewVolume(volume.NewDimensions(1, 1, 3), volume.WithWeights(test.in))
+		for _, s := range []Scaler{test.scaler, loaded} {
+			out := AsTransform(s)(in, nil)
+			for j, want := range test.want {
+				if got := out.GetByIndex(j); math.Abs(got-want) > 1e-12 {
+					t.Errorf("%s: %T element %d = %v, want %v", test.name, s, j, got, want)
+				}
+			}
+		}
+		if in.GetByIndex(0) != test.in[0] {
+			t.Errorf("%s: Transform modified its input", test.name)
+		}
+	}
+}
+
+func TestScalers_Errors(t *testing.T) {
+	for _, test := range []struct {
+		name string
+		src  datasets.Source
+	}{
+		{"empty", datasets.Samples{}},
+		{"nil input", datasets.Samples{{}}},
+		{"mismatched dimensions", rows([]float64{1, 2}, []float64{1})},
+	} {
+		for _, s := range []Scaler{NewStandardScaler(), NewMinMaxScaler(0, 1)} {
+			if err := s.Fit(test.src); err == nil {
+				t.Errorf("%s: expected an error fitting %T", test.name, s)
+			}
+		}
+	}
+
+	for _, test := range []struct {
+		name string
+		json string
+	}{
+		{"unknown type", `{"type": "robust", "scaler": {}}`},
+		{"malformed", `{"type": "standard", "scaler": [1]}`},
+		{"not json", `scaler`},
+	} {
+		if _, err := Load(strings.NewReader(test.json)); err == nil {
+			t.Errorf("%s: expected an error loading %s", test.name, test.json)
+		}
+	}
+
+	fitted := NewStandardScaler()
+	if err := fitted.Fit(rows([]float64{1, 2})); err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []struct {
+		name string
+		fn   func()
+	}{
+		{"unfitted", func() { NewStandardScaler().Transform(rows([]float64{1})[0].Input) }},
+		{"other dimensions", func() { fitted.Transform(rows([]float64{1, 2, 3})[0].Input) }},
+		{"empty range", func() { NewMinMaxScaler(1, 1) }},
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("%s: expected a panic", test.name)
+				}
+			}()
+			test.fn()
+		}()
+	}
+}
@@ -0,0 +1,232 @@
+// Package preprocess fits feature scaling on training data and applies exactly the
+// same scaling at inference. Scalers are saved next to the model with Save and
+// restored with Load:
+//
+//	scaler := preprocess.NewStandardScaler()
+//	if err := scaler.Fit(train); err != nil {
+//		return err
+//	}
+//	loader, err := datasets.NewDataLoader(train, datasets.WithTransform(preprocess.AsTransform(scaler)))
+//	...
+//	err = preprocess.Save(w, scaler)
+package preprocess
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+
+	"github.com/nathanleary/reticulum/datasets"
+	"github.com/nathanleary/reticulum/transform"
+	"github.com/nathanleary/reticulum/volume"
+)
+
+// Scaler scales every element of the input volumes with statistics fitted on a
+// dataset.
+type Scaler interface {
+	// Fit computes the statistics of the inputs of every sample of src, which must
+	// all have the same dimensions.
+	Fit(src datasets.Source) error
+
+	// Transform returns the scaled copy of vol. It panics if the scaler is not fitted
+	// or vol does not have the dimensions it was fitted on.
+	Transform(vol *volume.Volume) *volume.Volume
+}
+
+// AsTransform adapts the scaler to a transform, e.g. for the data loader.
+func AsTransform(s Scaler) transform.Transform {
+	return func(vol *volume.Volume, _ *rand.Rand) *volume.Volume {
+		return s.Transform(vol)
+	}
+}
+
+// StandardScaler scales every element to zero mean and unit variance. Elements which
+// are constant in the training data are only centered.
+type StandardScaler struct {
+	Dims volume.Dimensions `json:"dims"`
+	Mean []float64         `json:"mean"`
+	Std  []float64         `json:"std"`
+}
+
+// NewStandardScaler creates an unfitted standard scaler.
+func NewStandardScaler() *StandardScaler {
+	return &StandardScaler{}
+}
+
+func (s *StandardScaler) Fit(src datasets.Source) error {
+	dims, err := scan(src, func(i int, w []float64) {
+		if i == 0 {
+			s.Mean = make([]float64, len(w))
+			s.Std = make([]float64, len(w))
+		}
+
+		// Welford's update, keeping the sum of squared deviations in Std
+		for j, v := range w {
+			d := v - s.Mean[j]
+			s.Mean[j] += d / float64(i+1)
+			s.Std[j] += d * (v - s.Mean[j])
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	n := float64(src.Len())
+	for j := range s.Std {
+		s.Std[j] = math.Sqrt(s.Std[j] / n)
+		if s.Std[j] == 0 {
+			s.Std[j] = 1
+		}
+	}
+	s.Dims = dims
+	return nil
+}
+
+func (s *StandardScaler) Transform(vol *volume.Volume) *volume.Volume {
+	out := prepare(vol, s.Dims, s.Mean)
+	w := out.Weights()
+	for j := range w {
+		w[j] = (w[j] - s.Mean[j]) / s.Std[j]
+	}
+	return out
+}
+
+// MinMaxScaler maps every element linearly from its range in the training data to
+// [Lower, Upper]. Elements which are constant in the training data map to Lower.
+// Values outside of the training range are not clipped.
+type MinMaxScaler struct {
+	Lower float64 `json:"lower"`
+	Upper float64 `json:"upper"`
+
+	Dims volume.Dimensions `json:"dims"`
+	Min  []float64         `json:"min"`
+	Max  []float64         `json:"max"`
+}
+
+// NewMinMaxScaler creates an unfitted scaler to the range [lower, upper].
+func NewMinMaxScaler(lower, upper float64) *MinMaxScaler {
+	if lower >= upper {
+		panic(fmt.Errorf("invalid range [%v, %v]", lower, upper))
+	}
+	return &MinMaxScaler{Lower: lower, Upper: upper}
+}
+
+func (s *MinMaxScaler) Fit(src datasets.Source) error {
+	dims, err := scan(src, func(i int, w []float64) {
+		if i == 0 {
+			s.Min = append([]float64(nil), w...)
+			s.Max = append([]float64(nil), w...)
+			return
+		}
+		for j, v := range w {
+			s.Min[j] = min(s.Min[j], v)
+			s.Max[j] = max(s.Max[j], v)
+		}
+	})
+	if err != nil {
+		return err
+	}
+	s.Dims = dims
+	return nil
+}
+
+func (s *MinMaxScaler) Transform(vol *volume.Volume) *volume.Volume {
+	out := prepare(vol, s.Dims, s.Min)
+	w := out.Weights()
+	for j := range w {
+		r := s.Max[j] - s.Min[j]
+		if r == 0 {
+			w[j] = s.Lower
+			continue
+		}
+		w[j] = s.Lower + (w[j]-s.Min[j])/r*(s.Upper-s.Lower)
+	}
+	return out
+}
+
+// scan calls add with the inputs of the samples of src in order, checking that they
+// all have the same dimensions, which it returns.
+func scan(src datasets.Source, add func(i int, w []float64)) (volume.Dimensions, error) {
+	if src == nil || src.Len() == 0 {
+		return volume.Dimensions{}, errors.New("cannot fit an empty dataset")
+	}
+
+	var dims volume.Dimensions
+	for i := 0; i < src.Len(); i++ {
+		s, err := src.Sample(i)
+		if err != nil {
+			return volume.Dimensions{}, fmt.Errorf("sample %d: %w", i, err)
+		} else if s.Input == nil {
+			return volume.Dimensions{}, fmt.Errorf("sample %d: input cannot be nil", i)
+		}
+
+		if i == 0 {
+			dims = s.Input.Dimensions()
+		} else if dim := s.Input.Dimensions(); dim != dims {
+			return volume.Dimensions{}, fmt.Errorf("sample %d: dimensions %v differ from %v", i, dim, dims)
+		}
+		add(i, s.Input.Weights())
+	}
+	return dims, nil
+}
+
+// prepare checks that the scaler is fitted for vol and returns the copy to scale.
+func prepare(vol *volume.Volume, dims volume.Dimensions, stats []float64) *volume.Volume {
+	if stats == nil {
+		panic(errors.New("scaler is not fitted"))
+	} else if dim := vol.Dimensions(); dim != dims {
+		panic(fmt.Errorf("dimensions %v do not match the fitted %v", dim, dims))
+	}
+	return vol.Clone()
+}
+
+// savedScaler is the serialized form of a scaler.
+type savedScaler struct {
+	Type   string          `json:"type"`
+	Scaler json.RawMessage `json:"scaler"`
+}
+
+// Save writes the fitted scaler as JSON, so it can be restored with Load.
+func Save(w io.Writer, s Scaler) error {
+	var saved savedScaler
+	switch s.(type) {
+	case *StandardScaler:
+		saved.Type = "standard"
+	case *MinMaxScaler:
+		saved.Type = "minmax"
+	default:
+		return fmt.Errorf("unsupported scaler type %T", s)
+	}
+
+	data, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	saved.Scaler = data
+	return json.NewEncoder(w).Encode(saved)
+}
+
+// Load reads a scaler written by Save.
+func Load(r io.Reader) (Scaler, error) {
+	var saved savedScaler
+	if err := json.NewDecoder(r).Decode(&saved); err != nil {
+		return nil, err
+	}
+
+	var s Scaler
+	switch saved.Type {
+	case "standard":
+		s = &StandardScaler{}
+	case "minmax":
+		s = &MinMaxScaler{}
+	default:
+		return nil, fmt.Errorf("unknown scaler type %q", saved.Type)
+	}
+	if err := json.Unmarshal(saved.Scaler, s); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
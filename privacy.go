@@ -0,0 +1,174 @@
+package reticulum
+
+import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// PrivacyOptions configures differentially private SGD.
+type PrivacyOptions struct {
+	// ClipNorm bounds the L2 norm of every per-sample gradient.
+	ClipNorm float64
+
+	// NoiseMultiplier is the ratio of the gaussian noise standard deviation to ClipNorm.
+	NoiseMultiplier float64
+
+	// DatasetSize is the number of training samples, used to compute the sampling rate.
+	DatasetSize int
+
+	// Accountant tracks the privacy spent by each update if set. It must be created
+	// with NoiseMultiplier, or the reported guarantee would be wrong.
+	Accountant *PrivacyAccountant
+}
+
+// accountantMismatch returns an error when the accountant accounts for a different
+// noise multiplier than the one used.
+func (p *PrivacyOptions) accountantMismatch() error {
+	if p.Accountant != nil && p.Accountant.sigma != p.NoiseMultiplier {
+		return fmt.Errorf("privacy accountant noise multiplier %g does not match the noise multiplier %g", p.Accountant.sigma, p.NoiseMultiplier)
+	}
+	return nil
+}
+
+// WithDifferentialPrivacy trains with DP-SGD: every per-sample gradient is clipped to
+// ClipNorm and gaussian noise with a standard deviation of NoiseMultiplier * ClipNorm
+// is added to the summed gradients of each update.
+func WithDifferentialPrivacy(p PrivacyOptions) OptionFunc {
+	return func(opts *Options) {
+		opts.Privacy = &p
+	}
+}
+
+// maxPrivacyOrder is the largest Rényi divergence order considered by the accountant.
+const maxPrivacyOrder = 256
+
+// NewPrivacyAccountant creates a Rényi differential privacy accountant for the
+// sampled gaussian mechanism with the given noise multiplier.
+func NewPrivacyAccountant(noiseMultiplier float64) *PrivacyAccountant {
+	if noiseMultiplier <= 0 {
+		panic("noise multiplier must be greater than 0")
+	}
+	return &PrivacyAccountant{sigma: noiseMultiplier, rdp: make([]float64, maxPrivacyOrder+1)}
+}
+
+// PrivacyAccountant accumulates the Rényi differential privacy of every update at the
+// integer orders 2..256 and converts it to an (ε, δ) guarantee.
+type PrivacyAccountant struct {
+	sigma float64
+	steps int
+
+	// rdp[a] is the accumulated privacy at order a
+	rdp []float64
+
+	// per-step privacy of the last sampling rate, as it rarely changes
+	lastQ   float64
+	stepRDP []float64
+}
+
+// PrivacyReport is the (ε, δ) guarantee after a number of updates.
+type PrivacyReport struct {
+	Epsilon float64
+	Delta   float64
+	Order   int
+	Steps   int
+}
+
+// Step records an update which sampled each example with probability q.
+func (a *PrivacyAccountant) Step(q float64) {
+	if a.stepRDP == nil || q != a.lastQ {
+		a.lastQ = q
+		a.stepRDP = make([]float64, maxPrivacyOrder+1)
+		for order := 2; order <= maxPrivacyOrder; order++ {
+			a.stepRDP[order] = sampledGaussianRDP(q, a.sigma, order)
+		}
+	}
+
+	a.steps++
+	for order := 2; order <= maxPrivacyOrder; order++ {
+		a.rdp[order] += a.stepRDP[order]
+	}
+}
+
+// Steps returns the number of updates recorded.
+func (a *PrivacyAccountant) Steps() int {
+	return a.steps
+}
+
+// accountantState is the serialized progress of a PrivacyAccountant.
+type accountantState struct {
+	Sigma float64
+	Steps int
+	RDP   []float64
+}
+
+// MarshalBinary encodes the privacy spent so far, so it can be checkpointed.
+func (a *PrivacyAccountant) MarshalBinary() ([]byte, error) {
+	var buf bytes.Buffer
+	err := gob.NewEncoder(&buf).Encode(accountantState{a.sigma, a.steps, a.rdp})
+	return buf.Bytes(), err
+}
+
+// UnmarshalBinary restores the privacy spent saved with MarshalBinary.
+func (a *PrivacyAccountant) UnmarshalBinary(data []byte) error {
+	var state accountantState
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&state); err != nil {
+		return err
+	} else if len(state.RDP) != maxPrivacyOrder+1 {
+		return errors.New("invalid accountant state")
+	}
+	a.sigma, a.steps, a.rdp = state.Sigma, state.Steps, state.RDP
+	a.stepRDP = nil
+	return nil
+}
+
+// Report returns the smallest ε for which training so far is (ε, δ) differentially private.
+func (a *PrivacyAccountant) Report(delta float64) PrivacyReport {
+	if delta <= 0 || delta >= 1 {
+		panic("delta must be between 0 and 1")
+	}
+
+	report := PrivacyReport{Epsilon: math.Inf(1), Delta: delta, Steps: a.steps}
+	for order := 2; order <= maxPrivacyOrder; order++ {
+		eps := a.rdp[order] + math.Log(1/delta)/float64(order-1)
+		if eps < report.Epsilon {
+			report.Epsilon = eps
+			report.Order = order
+		}
+	}
+	return report
+}
+
+// sampledGaussianRDP computes the Rényi differential privacy of one step of the sampled
+// gaussian mechanism at an integer order, using the binomial expansion of
+// Mironov, Talwar & Zhang (2019):
+//
+//	A = Σ_k C(α, k) (1-q)^(α-k) q^k exp((k² - k) / 2σ²),  RDP = log(A) / (α - 1)
+func sampledGaussianRDP(q, sigma float64, order int) float64 {
+	if q <= 0 {
+		return 0
+	} else if q >= 1 {
+		return float64(order) / (2 * sigma * sigma)
+	}
+
+	// evaluate in log space to avoid overflow at large orders
+	alpha := float64(order)
+	terms := make([]float64, order+1)
+	maxTerm := math.Inf(-1)
+	for k := 0; k <= order; k++ {
+		kf := float64(k)
+		lgA, _ := math.Lgamma(alpha + 1)
+		lgK, _ := math.Lgamma(kf + 1)
+		lgAK, _ := math.Lgamma(alpha - kf + 1)
+		terms[k] = lgA - lgK - lgAK + (alpha-kf)*math.Log1p(-q) + kf*math.Log(q) + (kf*kf-kf)/(2*sigma*sigma)
+		maxTerm = math.Max(maxTerm, terms[k])
+	}
+
+	var sum float64
+	for _, term := range terms {
+		sum += math.Exp(term - maxTerm)
+	}
+	return (maxTerm + math.Log(sum)) / (alpha - 1)
+}
@@ -0,0 +1,164 @@
+package reticulum
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/nathanleary/reticulum/layers"
+	"github.com/nathanleary/reticulum/volume"
+)
+
+func privacyNetwork(t *testing.T, inputs, neurons int) Network {
+	t.Helper()
+	net, err := NewNetwork([]layers.LayerDef{
+		{Type: layers.Input, Output: volume.NewDimensions(1, 1, inputs)},
+		{Type: layers.FullyConnected, LayerConfig: layers.NewFullyConnectedLayerConfig(neurons)},
+		{Type: layers.SoftMax, LayerConfig: layers.NewSoftmaxLayerConfig(neurons)},
+	}, WithNetworkSeed(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return net
+}
+
+func randomInput(r *rand.Rand, size int) *volume.Volume {
+	w := make([]float64, size)
+	for i := range w {
+		w[i] = r.NormFloat64()
+	}
+	return volume.NewVolume(volume.NewDimensions(1, 1, size), volume.WithWeights(w))
+}
+
+// flatWeights returns a copy of all the weights of the network.
+func flatWeights(net Network) []float64 {
+	var w []float64
+	for _, pg := range net.GetResponse() {
+		w = append(w, pg.Weights...)
+	}
+	return w
+}
+
+// sampleGradient returns the gradient of a single sample, computed on a clone so the
+// network is left untouched.
+func sampleGradient(net Network, vol *volume.Volume, label int) []float64 {
+	c := Clone(net)
+	c.Forward(vol, true)
+	LabeledLossFunc(label)(c)
+	var g []float64
+	for _, pg := range c.GetResponse() {
+		g = append(g, pg.Gradients...)
+	}
+	return g
+}
+
+func TestDifferentialPrivacy_ClipsPerSampleGradients(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	net := privacyNetwork(t, 4, 3)
+	vols := []*volume.Volume{randomInput(r, 4), randomInput(r, 4)}
+	labels := []int{0, 2}
+
+	const clipNorm = 0.01
+	want := flatWeights(net)
+	for i, vol := range vols {
+		g := sampleGradient(net, vol, labels[i])
+		var sumSq float64
+		for _, v := range g {
+			sumSq += v * v
+		}
+		if norm := math.Sqrt(sumSq); norm <= clipNorm {
+			t.Fatalf("sample %d: gradient norm %v is too small to be clipped", i, norm)
+		}
+		scale := clipNorm / math.Sqrt(sumSq)
+		for j, v := range g {
+			want[j] -= v * scale / float64(len(vols))
+		}
+	}
+
+	trainer := NewTrainer(net, WithLearningRate(1), WithMomentum(0), WithSeed(1),
+		WithDifferentialPrivacy(PrivacyOptions{ClipNorm: clipNorm, NoiseMultiplier: 1e-12}))
+	trainer.TrainBatch(vols, []LossFunc{LabeledLossFunc(labels[0]), LabeledLossFunc(labels[1])})
+
+	for j, w := range flatWeights(net) {
+		if math.Abs(w-want[j]) > 1e-9 {
+			t.Fatalf("weight %d = %v, want %v", j, w, want[j])
+		}
+	}
+}
+
+func TestDifferentialPrivacy_AddsCalibratedNoise(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	net := privacyNetwork(t, 50, 40)
+	vol := randomInput(r, 50)
+
+	const clipNorm, noiseMultiplier = 1.0, 2.0
+	g := sampleGradient(net, vol, 3)
+	var sumSq float64
+	for _, v := range g {
+		sumSq += v * v
+	}
+	scale := math.Min(1, clipNorm/math.Sqrt(sumSq))
+
+	before := flatWeights(net)
+	trainer := NewTrainer(net, WithLearningRate(1), WithMomentum(0), WithSeed(1),
+		WithDifferentialPrivacy(PrivacyOptions{ClipNorm: clipNorm, NoiseMultiplier: noiseMultiplier}))
+	trainer.Train(vol, LabeledLossFunc(3))
+
+	// what remains of the update once the clipped gradient is removed is the noise
+	var mean, sq float64
+	after := flatWeights(net)
+	for j := range after {
+		noise := before[j] - after[j] - g[j]*scale
+		mean += noise
+		sq += noise * noise
+	}
+	n := float64(len(after))
+	mean /= n
+	std := math.Sqrt(sq/n - mean*mean)
+
+	if want := noiseMultiplier * clipNorm; math.Abs(std-want) > 0.1*want {
+		t.Errorf("noise standard deviation = %v, want %v", std, want)
+	}
+	if math.Abs(mean) > 0.2 {
+		t.Errorf("noise mean = %v, want 0", mean)
+	}
+}
+
+func TestDifferentialPrivacy_AccountantMismatch(t *testing.T) {
+	net := privacyNetwork(t, 4, 3)
+	opts := WithDifferentialPrivacy(PrivacyOptions{
+		ClipNorm:        1,
+		NoiseMultiplier: 1.1,
+		DatasetSize:     100,
+		Accountant:      NewPrivacyAccountant(0.9),
+	})
+
+	if _, err := NewTrainerE(net, opts); err == nil {
+		t.Error("NewTrainerE() accepted an accountant with a different noise multiplier")
+	}
+
+	defer func() {
+		if r := recover(); r == nil {
+			t.Errorf("Expected panic")
+		}
+	}()
+	NewTrainer(net, opts)
+}
+
+func TestPrivacyAccountant_FullBatch(t *testing.T) {
+	// without sampling every step costs order / 2σ² at each order
+	const sigma, steps, delta = 4.0, 10, 1e-5
+	a := NewPrivacyAccountant(sigma)
+	for i := 0; i < steps; i++ {
+		a.Step(1)
+	}
+
+	want := math.Inf(1)
+	for order := 2; order <= maxPrivacyOrder; order++ {
+		alpha := float64(order)
+		want = math.Min(want, steps*alpha/(2*sigma*sigma)+math.Log(1/delta)/(alpha-1))
+	}
+	if got := a.Report(delta); math.Abs(got.Epsilon-want) > 1e-9 || got.Steps != steps {
+		t.Errorf("Report() = %+v, want ε = %v after %d steps", got, want, steps)
+	}
+}
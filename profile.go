@@ -0,0 +1,73 @@
+package reticulum
+
+import (
+	"context"
+	"runtime/pprof"
+
+	"github.com/nathanleary/reticulum/layers"
+)
+
+// WithProfileLabels labels the CPU profile samples of every layer's forward and
+// backward passes with pprof labels: layer holds the name of the layer, e.g.
+// "conv1", layer_type its type and pass either "forward" or "backward". The time of
+// each layer then shows with `go tool pprof -tags`, or filtered with
+// `-tagfocus layer=conv1`. The labels are added to those of ctx, and the goroutine
+// is given the labels of ctx back after each layer, as the labels a goroutine holds
+// cannot be read: a network run inside pprof.Do is passed the context of the Do
+// function to keep its labels, and one passed context.Background() leaves the
+// goroutine unlabeled. Goroutines started by the layers, e.g. with WithParallelism,
+// inherit the labels.
+func WithProfileLabels(ctx context.Context) NetworkOptionFunc {
+	return func(opts *NetworkOptions) {
+		opts.ProfileLabels = true
+		opts.ProfileContext = ctx
+	}
+}
+
+// layerLabels holds the pprof label contexts of the passes of every layer.
+type layerLabels struct {
+	forward, backward []context.Context
+}
+
+func newLayerLabels(ctx context.Context, names []string, defs []layers.LayerDef) *layerLabels {
+	l := &layerLabels{}
+	for i, name := range names {
+		labels := func(pass string) context.Context {
+			return pprof.WithLabels(ctx, pprof.Labels("layer", name, "layer_type", string(defs[i].Type), "pass", pass))
+		}
+		l.forward = append(l.forward, labels("forward"))
+		l.backward = append(l.backward, labels("backward"))
+	}
+	return l
+}
+
+// setLabels labels the goroutine with the forward or backward pass of layer i, when
+// the layer is profiled.
+func (n *network) setLabels(i int, backward bool) {
+	if !n.defs[i].ProfileLabels {
+		return
+	}
+	if n.labels == nil {
+		n.labels = newLayerLabels(n.labelContext(), n.names, n.defs)
+	}
+	if backward {
+		pprof.SetGoroutineLabels(n.labels.backward[i])
+	} else {
+		pprof.SetGoroutineLabels(n.labels.forward[i])
+	}
+}
+
+// clearLabels gives the goroutine back the labels it had before setLabels.
+func (n *network) clearLabels(i int) {
+	if n.defs[i].ProfileLabels {
+		pprof.SetGoroutineLabels(n.labelContext())
+	}
+}
+
+// labelContext returns the context holding the labels of the caller of the network.
+func (n *network) labelContext() context.Context {
+	if n.profileCtx == nil {
+		return context.Background()
+	}
+	return n.profileCtx
+}
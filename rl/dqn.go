@@ -0,0 +1,597 @@
+// Package rl trains agents by reinforcement learning. Brain is a port of ConvNetJS's
+// deepqlearn.Brain, a deep Q-learning agent which picks one of a fixed number of
+// actions for every state it is shown and learns from the rewards it receives.
+// Reinforce and A2C instead learn a softmax policy by policy gradient from rollouts
+// of states, actions and rewards.
+//
+// A brain is driven one step at a time:
+//
+//	net, err := rl.DefaultNetwork(states, actions)
+//	if err != nil {
+//		return err
+//	}
+//	brain, err := rl.NewBrain(net, states, actions)
+//	if err != nil {
+//		return err
+//	}
+//	for {
+//		action := brain.Forward(env.State())
+//		brain.Backward(env.Step(action))
+//	}
+package rl
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+
+	"github.com/nathanleary/reticulum"
+	"github.com/nathanleary/reticulum/volume"
+)
+
+// OptionFunc modifies the Options of an agent.
+type OptionFunc func(*Options)
+
+// Options configures an agent, which uses the fields relevant to it. For a Brain,
+// epsilon, the probability of a random action, decays linearly from 1 to EpsilonMin
+// over LearningStepsTotal steps after the first LearningStepsBurnin, and is
+// EpsilonTestTime while learning is off.
+type Options struct {
+	// TemporalWindow is the number of previous states and actions fed to the network
+	// along with the current state.
+	TemporalWindow int
+
+	// ExperienceSize is the number of experiences kept for replay, and
+	// StartLearnThreshold the number collected before learning starts.
+	ExperienceSize      int
+	StartLearnThreshold int
+
+	// Prioritized replays experiences in proportion to their TD error raised to
+	// PriorityAlpha, weighting their loss by importance sampling weights with an
+	// exponent of PriorityBeta.
+	Prioritized   bool
+	PriorityAlpha float64
+	PriorityBeta  float64
+
+	// Gamma discounts future rewards.
+	Gamma float64
+
+	LearningStepsTotal  int
+	LearningStepsBurnin int
+	EpsilonMin          float64
+	EpsilonTestTime     float64
+
+	// RandomActionDistribution holds the probability of every action when acting
+	// randomly, or is nil to pick them uniformly.
+	RandomActionDistribution []float64
+
+	// BatchSize is the number of experiences replayed for every learning step.
+	BatchSize int
+
+	// TargetUpdate is the number of learning steps after which the target network,
+	// used to estimate the value of the next state, is synced with the trained
+	// network. With 0 the trained network is used, as in deepqlearn.
+	TargetUpdate int
+
+	// Entropy weighs the entropy bonus of policy gradient agents, which keeps the
+	// policy from collapsing onto a single action too early.
+	Entropy float64
+
+	// ValueCoef scales the value loss of an actor-critic agent relative to the policy
+	// loss.
+	ValueCoef float64
+
+	// PolicyOutput and ValueOutput name the policy and value heads of an actor-critic
+	// network sharing its layers between the actor and the critic.
+	PolicyOutput string
+	ValueOutput  string
+
+	// TrainerOptions configure the trainer of the network.
+	TrainerOptions []reticulum.OptionFunc
+
+	HasSeed bool
+	Seed    int64
+}
+
+// WithTemporalWindow feeds the given number of previous states and actions to the
+// network along with the current state.
+func WithTemporalWindow(n int) OptionFunc {
+	return func(opts *Options) {
+		opts.TemporalWindow = n
+	}
+}
+
+// WithExperience sets the size of the replay memory and the number of experiences
+// collected before learning starts.
+func WithExperience(size, startLearnThreshold int) OptionFunc {
+	return func(opts *Options) {
+		opts.ExperienceSize = size
+		opts.StartLearnThreshold = startLearnThreshold
+	}
+}
+
+// WithPrioritizedReplay replays experiences by priority, with the given exponents of
+// the priorities and the importance sampling weights.
+func WithPrioritizedReplay(alpha, beta float64) OptionFunc {
+	return func(opts *Options) {
+		opts.Prioritized = true
+		opts.PriorityAlpha = alpha
+		opts.PriorityBeta = beta
+	}
+}
+
+// WithGamma sets the discount of future rewards.
+func WithGamma(gamma float64) OptionFunc {
+	return func(opts *Options) {
+		opts.Gamma = gamma
+	}
+}
+
+// WithLearningSteps sets the number of steps over which epsilon decays and the number
+// of steps before it starts to.
+func WithLearningSteps(total, burnin int) OptionFunc {
+	return func(opts *Options) {
+		opts.LearningStepsTotal = total
+		opts.LearningStepsBurnin = burnin
+	}
+}
+
+// WithEpsilon sets the lowest epsilon reached while learning and the epsilon used
+// while learning is off.
+func WithEpsilon(min, testTime float64) OptionFunc {
+	return func(opts *Options) {
+		opts.EpsilonMin = min
+		opts.EpsilonTestTime = testTime
+	}
+}
+
+// WithRandomActionDistribution sets the probability of every action when acting
+// randomly.
+func WithRandomActionDistribution(p []float64) OptionFunc {
+	return func(opts *Options) {
+		opts.RandomActionDistribution = p
+	}
+}
+
+// WithBatchSize sets the number of experiences replayed for every learning step.
+func WithBatchSize(n int) OptionFunc {
+	return func(opts *Options) {
+		opts.BatchSize = n
+	}
+}
+
+// WithTargetUpdate sets the number of learning steps between syncs of the target
+// network, 0 disabling it.
+func WithTargetUpdate(steps int) OptionFunc {
+	return func(opts *Options) {
+		opts.TargetUpdate = steps
+	}
+}
+
+// WithEntropy sets the weight of the entropy bonus of policy gradient agents.
+func WithEntropy(beta float64) OptionFunc {
+	return func(opts *Options) {
+		opts.Entropy = beta
+	}
+}
+
+// WithValueCoef sets the weight of the value loss of actor-critic agents.
+func WithValueCoef(c float64) OptionFunc {
+	return func(opts *Options) {
+		opts.ValueCoef = c
+	}
+}
+
+// WithOutputs names the policy and value heads of a shared actor-critic network.
+func WithOutputs(policy, value string) OptionFunc {
+	return func(opts *Options) {
+		opts.PolicyOutput = policy
+		opts.ValueOutput = value
+	}
+}
+
+// WithTrainerOptions replaces the options of the trainer of the network.
+func WithTrainerOptions(opts ...reticulum.OptionFunc) OptionFunc {
+	return func(o *Options) {
+		o.TrainerOptions = opts
+	}
+}
+
+// WithSeed makes the actions and the replayed experiences reproducible.
+func WithSeed(seed int64) OptionFunc {
+	return func(opts *Options) {
+		opts.HasSeed = true
+		opts.Seed = seed
+	}
+}
+
+func newOptions(optFuncs ...OptionFunc) *Options {
+	opts := &Options{
+		TemporalWindow:      1,
+		ExperienceSize:      30000,
+		StartLearnThreshold: 1000,
+		Gamma:               0.8,
+		LearningStepsTotal:  100000,
+		LearningStepsBurnin: 3000,
+		EpsilonMin:          0.05,
+		EpsilonTestTime:     0.01,
+		BatchSize:           64,
+		TargetUpdate:        1000,
+		TrainerOptions: []reticulum.OptionFunc{
+			reticulum.WithLearningRate(0.01),
+			reticulum.WithMomentum(0),
+			reticulum.WithDecay(0, 0.01),
+		},
+	}
+	for _, optFn := range optFuncs {
+		optFn(opts)
+	}
+	return opts
+}
+
+// NetworkInputs returns the number of inputs of the network of a brain: the current
+// state followed by the given number of previous states and actions.
+func NetworkInputs(states, actions, temporalWindow int) int {
+	return states*temporalWindow + actions*temporalWindow + states
+}
+
+// DefaultNetwork builds the network deepqlearn uses by default, two fully connected
+// layers of 50 ReLU neurons followed by a regression of the value of every action,
+// sized for the temporal window in the options.
+func DefaultNetwork(states, actions int, optFuncs ...OptionFunc) (reticulum.Network, error) {
+	opts := newOptions(optFuncs...)
+	return reticulum.NewBuilder().
+		Input(1, 1, NetworkInputs(states, actions, opts.TemporalWindow)).
+		FC(50).Relu().
+		FC(50).Relu().
+		Regression(actions).
+		Build()
+}
+
+// Experience is a transition seen by an agent: taking Action0 in State0 earned Reward0
+// and led to State1. For a brain the states are network inputs, including the temporal
+// window. Terminal marks the last transition of an episode, whose State1 has no value.
+type Experience struct {
+	State0   *volume.Volume
+	Action0  int
+	Reward0  float64
+	State1   *volume.Volume
+	Terminal bool
+}
+
+// Brain is a deep Q-learning agent. Its network estimates the discounted future
+// reward of every action from the current state and previous states and actions. It
+// acts epsilon-greedily and learns by replaying random past experiences, regressing
+// the value of the action taken towards the reward plus the discounted value of the
+// best action in the next state. A Brain is not safe for concurrent use.
+type Brain struct {
+	net     reticulum.Network
+	target  reticulum.Network
+	trainer reticulum.Trainer
+	opts    *Options
+	rng     *rand.Rand
+
+	states, actions int
+
+	// the most recent states, actions, rewards and network inputs, oldest first
+	stateWindow  [][]float64
+	actionWindow []int
+	rewardWindow []float64
+	netWindow    []*volume.Volume
+
+	memory *ReplayBuffer
+
+	age, forwardPasses, updates int
+	epsilon                     float64
+	learning                    bool
+	latestReward                float64
+
+	rewards, losses *window
+}
+
+// NewBrain creates a brain acting on the given number of state values and actions.
+// The network takes NetworkInputs values and must end in a regression over the
+// actions, e.g. as built by DefaultNetwork. Learning is on.
+func NewBrain(net reticulum.Network, states, actions int, optFuncs ...OptionFunc) (*Brain, error) {
+	opts := newOptions(optFuncs...)
+	if net == nil {
+		return nil, errors.New("network cannot be nil")
+	} else if states <= 0 || actions <= 0 {
+		return nil, fmt.Errorf("states and actions must be greater than 0, got %d and %d", states, actions)
+	} else if opts.TemporalWindow < 0 {
+		return nil, fmt.Errorf("temporal window cannot be negative, got %d", opts.TemporalWindow)
+	} else if opts.ExperienceSize <= 0 || opts.BatchSize <= 0 {
+		return nil, errors.New("experience size and batch size must be greater than 0")
+	} else if opts.LearningStepsTotal <= opts.LearningStepsBurnin {
+		return nil, fmt.Errorf("learning steps (%d) must exceed the burn-in steps (%d)", opts.LearningStepsTotal, opts.LearningStepsBurnin)
+	} else if opts.TargetUpdate < 0 {
+		return nil, fmt.Errorf("target update cannot be negative, got %d", opts.TargetUpdate)
+	} else if opts.Prioritized && (opts.PriorityAlpha < 0 || opts.PriorityBeta < 0) {
+		return nil, errors.New("priority exponents cannot be negative")
+	}
+	if p := opts.RandomActionDistribution; p != nil {
+		if len(p) != actions {
+			return nil, fmt.Errorf("random action distribution has %d actions, expected %d", len(p), actions)
+		}
+		var sum float64
+		for _, v := range p {
+			sum += v
+		}
+		if math.Abs(sum-1) > 1e-4 {
+			return nil, fmt.Errorf("random action distribution sums to %v, expected 1", sum)
+		}
+	}
+
+	inputs := NetworkInputs(states, actions, opts.TemporalWindow)
+	dims := reticulum.InputDimensions(net)
+	if len(dims) != 1 || dims[0].Size() != inputs {
+		return nil, fmt.Errorf("network must take %d inputs for %d states and %d actions", inputs, states, actions)
+	}
+	out := net.Forward(volume.NewVolume(dims[0], volume.WithZeros()), false)
+	if out.Size() != actions {
+		return nil, fmt.Errorf("network has %d outputs, expected one per action (%d)", out.Size(), actions)
+	}
+	trainer, err := reticulum.NewTrainerE(net, opts.TrainerOptions...)
+	if err != nil {
+		return nil, err
+	}
+
+	size := max(opts.TemporalWindow, 2)
+	b := &Brain{
+		net:          net,
+		trainer:      trainer,
+		opts:         opts,
+		rng:          opts.newRand(),
+		states:       states,
+		actions:      actions,
+		stateWindow:  make([][]float64, size),
+		actionWindow: make([]int, size),
+		rewardWindow: make([]float64, size),
+		netWindow:    make([]*volume.Volume, size),
+		memory:       NewReplayBuffer(opts.ExperienceSize),
+		epsilon:      1,
+		learning:     true,
+		rewards:      newWindow(1000),
+		losses:       newWindow(1000),
+	}
+	for i := range b.stateWindow {
+		b.stateWindow[i] = make([]float64, states)
+	}
+	if opts.Prioritized {
+		b.memory = NewPrioritizedReplayBuffer(opts.ExperienceSize, opts.PriorityAlpha)
+	}
+	if opts.TargetUpdate > 0 {
+		b.target = reticulum.Clone(net)
+	}
+	return b, nil
+}
+
+// Network returns the network trained by the brain.
+func (b *Brain) Network() reticulum.Network {
+	return b.net
+}
+
+// SetLearning turns learning on or off. Without learning the brain acts greedily
+// except for a probability of EpsilonTestTime, and Backward only records rewards.
+func (b *Brain) SetLearning(on bool) {
+	b.learning = on
+}
+
+// Learning reports whether the brain is learning.
+func (b *Brain) Learning() bool {
+	return b.learning
+}
+
+// Forward returns the action to take in the given state. Until the temporal window
+// has been filled the actions are random.
+func (b *Brain) Forward(state []float64) int {
+	if len(state) != b.states {
+		panic(fmt.Errorf("state has %d values, expected %d", len(state), b.states))
+	}
+	b.forwardPasses++
+
+	var input *volume.Volume
+	var action int
+	if b.forwardPasses > b.opts.TemporalWindow {
+		input = b.netInput(state)
+		if b.learning {
+			progress := float64(b.age-b.opts.LearningStepsBurnin) / float64(b.opts.LearningStepsTotal-b.opts.LearningStepsBurnin)
+			b.epsilon = min(1, max(b.opts.EpsilonMin, 1-progress))
+		} else {
+			b.epsilon = b.opts.EpsilonTestTime
+		}
+		if b.rng.Float64() < b.epsilon {
+			action = b.randomAction()
+		} else {
+			action, _ = policy(b.net, input)
+		}
+	} else {
+		action = b.randomAction()
+	}
+
+	b.stateWindow = append(b.stateWindow[1:], append([]float64(nil), state...))
+	b.actionWindow = append(b.actionWindow[1:], action)
+	b.netWindow = append(b.netWindow[1:], input)
+	return action
+}
+
+// Backward rewards the brain for the action returned by the last Forward and, while
+// learning, replays a batch of experiences once enough have been collected.
+func (b *Brain) Backward(reward float64) {
+	b.latestReward = reward
+	b.rewards.add(reward)
+	b.rewardWindow = append(b.rewardWindow[1:], reward)
+	if !b.learning {
+		return
+	}
+	b.age++
+
+	// the previous step is complete now that the state following it is known
+	if b.forwardPasses > b.opts.TemporalWindow+1 {
+		n := len(b.netWindow)
+		b.memory.Add(Experience{
+			State0:  b.netWindow[n-2],
+			Action0: b.actionWindow[n-2],
+			Reward0: b.rewardWindow[n-2],
+			State1:  b.netWindow[n-1],
+		})
+	}
+
+	if b.memory.Len() > b.opts.StartLearnThreshold {
+		b.learn()
+	}
+}
+
+// learn regresses the value of the actions of a batch of replayed experiences towards
+// their reward plus the discounted value of the best action in the next state.
+func (b *Brain) learn() {
+	next := b.net
+	if b.target != nil {
+		next = b.target
+	}
+
+	batch, err := b.memory.Sample(b.opts.BatchSize, b.opts.PriorityBeta, b.rng)
+	if err != nil {
+		panic(err)
+	}
+	vols := make([]*volume.Volume, len(batch.Experiences))
+	losses := make([]reticulum.LossFunc, len(vols))
+	tdErrors := make([]float64, len(vols))
+	for k, e := range batch.Experiences {
+		r := e.Reward0
+		if !e.Terminal {
+			_, value := policy(next, e.State1)
+			r += b.opts.Gamma * value
+		}
+
+		// only the value of the action taken moves, the others are their own targets
+		y := append([]float64(nil), b.net.Forward(e.State0, false).Weights()...)
+		tdErrors[k] = r - y[e.Action0]
+		y[e.Action0] = r
+
+		vols[k] = e.State0
+		w := batch.Weights[k]
+		losses[k] = func(net reticulum.Network) float64 {
+			return reticulum.MultiDimensionalLossWeighted(net, y, w)
+		}
+	}
+	res := b.trainer.TrainBatch(vols, losses)
+	b.losses.add(res.CostLost)
+	if err := b.memory.UpdatePriorities(batch.Indexes, tdErrors); err != nil {
+		panic(err)
+	}
+
+	b.updates++
+	if b.target != nil && b.updates%b.opts.TargetUpdate == 0 {
+		if err := reticulum.CopyWeights(b.target, b.net); err != nil {
+			panic(err)
+		}
+	}
+}
+
+// netInput returns the network input for the state: the state followed by the
+// previous states and the previous actions one-hot encoded, most recent first. The
+// actions are scaled by the number of states so they weigh as much as a state.
+func (b *Brain) netInput(state []float64) *volume.Volume {
+	w := append([]float64(nil), state...)
+	n := len(b.stateWindow)
+	for k := 0; k < b.opts.TemporalWindow; k++ {
+		w = append(w, b.stateWindow[n-1-k]...)
+		action := make([]float64, b.actions)
+		action[b.actionWindow[n-1-k]] = float64(b.states)
+		w = append(w, action...)
+	}
+	return volume.NewVolume(volume.NewDimensions(1, 1, len(w)), volume.WithWeights(w))
+}
+
+// randomAction draws an action from the random action distribution.
+func (b *Brain) randomAction() int {
+	if b.opts.RandomActionDistribution == nil {
+		return b.rng.Intn(b.actions)
+	}
+	return sample(b.opts.RandomActionDistribution, b.rng)
+}
+
+// policy returns the action with the highest value estimated by net and its value.
+func policy(net reticulum.Network, input *volume.Volume) (int, float64) {
+	values := net.Forward(input, false).Weights()
+	best := 0
+	for k, v := range values {
+		if v > values[best] {
+			best = k
+		}
+	}
+	return best, values[best]
+}
+
+// Epsilon returns the probability of a random action at the last Forward.
+func (b *Brain) Epsilon() float64 {
+	return b.epsilon
+}
+
+// Age returns the number of rewards received while learning.
+func (b *Brain) Age() int {
+	return b.age
+}
+
+// Experiences returns the number of experiences in the replay memory.
+func (b *Brain) Experiences() int {
+	return b.memory.Len()
+}
+
+// Memory returns the replay memory of the brain.
+func (b *Brain) Memory() *ReplayBuffer {
+	return b.memory
+}
+
+// LatestReward returns the reward given to the last Backward.
+func (b *Brain) LatestReward() float64 {
+	return b.latestReward
+}
+
+// AverageReward returns the mean of the last 1000 rewards.
+func (b *Brain) AverageReward() float64 {
+	return b.rewards.mean()
+}
+
+// AverageLoss returns the mean loss of the last 1000 learning steps.
+func (b *Brain) AverageLoss() float64 {
+	return b.losses.mean()
+}
+
+// String summarizes the state of the brain like deepqlearn's visSelf.
+func (b *Brain) String() string {
+	return fmt.Sprintf("experience replay size: %d\nexploration epsilon: %.4f\nage: %d\naverage Q-learning loss: %.4f\nsmooth-ish reward: %.4f",
+		b.memory.Len(), b.epsilon, b.age, b.AverageLoss(), b.AverageReward())
+}
+
+// window keeps the sum of the most recent values up to a size.
+type window struct {
+	values []float64
+	size   int
+	next   int
+	sum    float64
+}
+
+func newWindow(size int) *window {
+	return &window{size: size}
+}
+
+func (w *window) add(v float64) {
+	if len(w.values) < w.size {
+		w.values = append(w.values, v)
+	} else {
+		w.sum -= w.values[w.next]
+		w.values[w.next] = v
+		w.next = (w.next + 1) % w.size
+	}
+	w.sum += v
+}
+
+func (w *window) mean() float64 {
+	if len(w.values) == 0 {
+		return 0
+	}
+	return w.sum / float64(len(w.values))
+}
@@ -0,0 +1,370 @@
+package rl
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+
+	"github.com/nathanleary/reticulum"
+	"github.com/nathanleary/reticulum/layers"
+	"github.com/nathanleary/reticulum/volume"
+)
+
+// Rollout collects the steps of an agent in its environment for a policy gradient
+// update: the state seen, the action taken and the reward received at every step.
+type Rollout struct {
+	States  []*volume.Volume
+	Actions []int
+	Rewards []float64
+
+	// Terminal is set when the rollout ends its episode. Otherwise the return after
+	// the last step is estimated by the value of Next where the agent has a critic.
+	Terminal bool
+	Next     *volume.Volume
+}
+
+// Add records a step.
+func (r *Rollout) Add(state *volume.Volume, action int, reward float64) {
+	r.States = append(r.States, state)
+	r.Actions = append(r.Actions, action)
+	r.Rewards = append(r.Rewards, reward)
+}
+
+// Len returns the number of steps.
+func (r *Rollout) Len() int {
+	return len(r.States)
+}
+
+// Reset clears the rollout, keeping its storage.
+func (r *Rollout) Reset() {
+	r.States, r.Actions, r.Rewards = r.States[:0], r.Actions[:0], r.Rewards[:0]
+	r.Terminal, r.Next = false, nil
+}
+
+// Returns computes the discounted return of every step, the reward plus gamma times
+// the return of the next step, with bootstrap as the return after the last one.
+func Returns(rewards []float64, gamma, bootstrap float64) []float64 {
+	returns := make([]float64, len(rewards))
+	g := bootstrap
+	for t := len(rewards) - 1; t >= 0; t-- {
+		g = rewards[t] + gamma*g
+		returns[t] = g
+	}
+	return returns
+}
+
+// UpdateStats summarizes a policy gradient update, averaged over the steps of the
+// rollout and computed before the update.
+type UpdateStats struct {
+	// PolicyLoss is the mean of -advantage * log p(action).
+	PolicyLoss float64
+
+	// ValueLoss is the mean squared error of the critic, halved. It is 0 for agents
+	// without a critic.
+	ValueLoss float64
+
+	// Entropy is the mean entropy of the policy.
+	Entropy float64
+}
+
+func newPolicyOptions(optFuncs ...OptionFunc) *Options {
+	opts := &Options{
+		Gamma:        0.99,
+		Entropy:      0.01,
+		ValueCoef:    0.5,
+		PolicyOutput: "policy",
+		ValueOutput:  "value",
+		TrainerOptions: []reticulum.OptionFunc{
+			reticulum.WithMethod(reticulum.Adam),
+			reticulum.WithLearningRate(0.001),
+		},
+	}
+	for _, optFn := range optFuncs {
+		optFn(opts)
+	}
+	return opts
+}
+
+func (o *Options) newRand() *rand.Rand {
+	seed := rand.Int63()
+	if o.HasSeed {
+		seed = o.Seed
+	}
+	return rand.New(rand.NewSource(seed))
+}
+
+// Reinforce learns a softmax policy with the REINFORCE algorithm: after every episode
+// the log-probability of each action taken is raised in proportion to the return that
+// followed it. The returns are standardized over the episode, which acts as a baseline
+// and reduces the variance of the updates. By default gamma is 0.99, the entropy
+// weight 0.01 and the network is trained by Adam with a learning rate of 0.001. A
+// Reinforce is not safe for concurrent use.
+type Reinforce struct {
+	net     reticulum.Network
+	trainer reticulum.Trainer
+	opts    *Options
+	rng     *rand.Rand
+}
+
+// NewReinforce creates a REINFORCE agent for a network ending in a softmax over the
+// actions.
+func NewReinforce(net reticulum.Network, optFuncs ...OptionFunc) (*Reinforce, error) {
+	opts := newPolicyOptions(optFuncs...)
+	if err := checkPolicy(net, opts); err != nil {
+		return nil, err
+	}
+	trainer, err := reticulum.NewTrainerE(net, opts.TrainerOptions...)
+	if err != nil {
+		return nil, err
+	}
+	return &Reinforce{net: net, trainer: trainer, opts: opts, rng: opts.newRand()}, nil
+}
+
+// Network returns the policy network.
+func (r *Reinforce) Network() reticulum.Network {
+	return r.net
+}
+
+// Act samples an action from the policy for the state.
+func (r *Reinforce) Act(state *volume.Volume) int {
+	return sample(r.net.Forward(state, false).Weights(), r.rng)
+}
+
+// Train updates the policy from a complete episode. Terminal is not required, but the
+// return after the last step is taken to be 0.
+func (r *Reinforce) Train(ro *Rollout) (UpdateStats, error) {
+	if err := checkRollout(ro); err != nil {
+		return UpdateStats{}, err
+	}
+
+	advantages := standardize(Returns(ro.Rewards, r.opts.Gamma, 0))
+	var stats UpdateStats
+	losses := make([]reticulum.LossFunc, ro.Len())
+	for t, state := range ro.States {
+		probs := r.net.Forward(state, false).Weights()
+		if err := stats.add(probs, ro.Actions[t], advantages[t]); err != nil {
+			return UpdateStats{}, fmt.Errorf("step %d: %w", t, err)
+		}
+		losses[t] = reticulum.PolicyLossFunc(ro.Actions[t], advantages[t], r.opts.Entropy)
+	}
+	if _, err := r.trainer.TrainBatchE(ro.States, losses); err != nil {
+		return UpdateStats{}, err
+	}
+	return stats.mean(ro.Len()), nil
+}
+
+// A2C is a synchronous advantage actor-critic agent. The actor is a softmax policy and
+// the critic estimates the value of a state, the expected return from it. After every
+// rollout the actor raises the log-probability of each action in proportion to its
+// advantage, the n-step return minus the value of the state, and the critic regresses
+// its value towards the return. Rollouts which do not end their episode bootstrap the
+// return from the value of Next.
+//
+// The actor and critic are either two networks, or a single MultiNetwork with a policy
+// and a value head sharing the layers below them, named "policy" and "value" unless
+// set with WithOutputs. By default gamma is 0.99, the entropy weight 0.01, the value
+// loss weight 0.5 and the networks are trained by Adam with a learning rate of 0.001.
+// An A2C is not safe for concurrent use.
+type A2C struct {
+	actor, critic reticulum.Network
+	shared        reticulum.MultiNetwork
+
+	actorTrainer, criticTrainer reticulum.Trainer
+	opts                        *Options
+	rng                         *rand.Rand
+}
+
+// NewA2C creates an actor-critic agent from an actor ending in a softmax over the
+// actions and a critic ending in a regression of a single value. With a nil critic the
+// actor must be a MultiNetwork with a policy and a value head.
+func NewA2C(actor, critic reticulum.Network, optFuncs ...OptionFunc) (*A2C, error) {
+	opts := newPolicyOptions(optFuncs...)
+	a := &A2C{opts: opts, rng: opts.newRand()}
+	if actor == nil {
+		return nil, errors.New("actor cannot be nil")
+	} else if opts.Gamma < 0 || opts.Gamma > 1 {
+		return nil, fmt.Errorf("gamma must be in [0, 1], got %v", opts.Gamma)
+	}
+
+	if critic == nil {
+		m, ok := actor.(reticulum.MultiNetwork)
+		if !ok {
+			return nil, errors.New("an actor without a critic must be a MultiNetwork with a policy and a value head")
+		} else if len(m.InputNames()) != 1 {
+			return nil, fmt.Errorf("shared network must have a single input, got %d", len(m.InputNames()))
+		}
+		heads := map[string]bool{}
+		for _, name := range m.OutputNames() {
+			heads[name] = true
+		}
+		if !heads[opts.PolicyOutput] || !heads[opts.ValueOutput] {
+			return nil, fmt.Errorf("shared network must have outputs %q and %q, got %v", opts.PolicyOutput, opts.ValueOutput, m.OutputNames())
+		}
+		a.shared = m
+	} else {
+		if err := checkPolicy(actor, opts); err != nil {
+			return nil, err
+		}
+		dims := reticulum.InputDimensions(critic)
+		if len(dims) != 1 {
+			return nil, fmt.Errorf("critic must have a single input, got %d", len(dims))
+		} else if out := critic.Forward(volume.NewVolume(dims[0], volume.WithZeros()), false); out.Size() != 1 {
+			return nil, fmt.Errorf("critic must output a single value, got %d", out.Size())
+		}
+		a.actor, a.critic = actor, critic
+	}
+
+	var err error
+	if a.actorTrainer, err = reticulum.NewTrainerE(actor, opts.TrainerOptions...); err != nil {
+		return nil, err
+	}
+	if critic != nil {
+		if a.criticTrainer, err = reticulum.NewTrainerE(critic, opts.TrainerOptions...); err != nil {
+			return nil, err
+		}
+	}
+	return a, nil
+}
+
+// evaluate returns the action probabilities and the value of the state.
+func (a *A2C) evaluate(state *volume.Volume) ([]float64, float64) {
+	if a.shared != nil {
+		out := a.shared.ForwardMulti(map[string]*volume.Volume{a.shared.InputNames()[0]: state}, false)
+		probs := append([]float64(nil), out[a.opts.PolicyOutput].Weights()...)
+		return probs, out[a.opts.ValueOutput].GetByIndex(0)
+	}
+	probs := append([]float64(nil), a.actor.Forward(state, false).Weights()...)
+	return probs, a.critic.Forward(state, false).GetByIndex(0)
+}
+
+// Act samples an action from the policy for the state.
+func (a *A2C) Act(state *volume.Volume) int {
+	probs, _ := a.evaluate(state)
+	return sample(probs, a.rng)
+}
+
+// Value returns the critic's estimate of the return from the state.
+func (a *A2C) Value(state *volume.Volume) float64 {
+	_, value := a.evaluate(state)
+	return value
+}
+
+// Train updates the actor and critic from a rollout.
+func (a *A2C) Train(ro *Rollout) (UpdateStats, error) {
+	if err := checkRollout(ro); err != nil {
+		return UpdateStats{}, err
+	}
+	var bootstrap float64
+	if !ro.Terminal {
+		if ro.Next == nil {
+			return UpdateStats{}, errors.New("rollouts which do not end their episode need the next state")
+		}
+		bootstrap = a.Value(ro.Next)
+	}
+	returns := Returns(ro.Rewards, a.opts.Gamma, bootstrap)
+
+	var stats UpdateStats
+	actorLosses := make([]reticulum.LossFunc, ro.Len())
+	criticLosses := make([]reticulum.LossFunc, ro.Len())
+	for t, state := range ro.States {
+		probs, value := a.evaluate(state)
+		action, advantage := ro.Actions[t], returns[t]-value
+		if err := stats.add(probs, action, advantage); err != nil {
+			return UpdateStats{}, fmt.Errorf("step %d: %w", t, err)
+		}
+		stats.ValueLoss += 0.5 * advantage * advantage
+
+		if a.shared != nil {
+			actorLosses[t] = reticulum.MultiTargetLossFunc(map[string]reticulum.Target{
+				a.opts.PolicyOutput: {Label: action, Policy: &reticulum.PolicyGradient{Advantage: advantage, Entropy: a.opts.Entropy}},
+				a.opts.ValueOutput:  {Values: []float64{returns[t]}, Weight: a.opts.ValueCoef},
+			})
+		} else {
+			actorLosses[t] = reticulum.PolicyLossFunc(action, advantage, a.opts.Entropy)
+			criticLosses[t] = reticulum.RegressionLossFuncWeighted([]float64{returns[t]}, a.opts.ValueCoef)
+		}
+	}
+
+	if _, err := a.actorTrainer.TrainBatchE(ro.States, actorLosses); err != nil {
+		return UpdateStats{}, err
+	}
+	if a.criticTrainer != nil {
+		if _, err := a.criticTrainer.TrainBatchE(ro.States, criticLosses); err != nil {
+			return UpdateStats{}, err
+		}
+	}
+	return stats.mean(ro.Len()), nil
+}
+
+// checkPolicy checks that the network ends in a softmax policy.
+func checkPolicy(net reticulum.Network, opts *Options) error {
+	if net == nil {
+		return errors.New("network cannot be nil")
+	} else if opts.Gamma < 0 || opts.Gamma > 1 {
+		return fmt.Errorf("gamma must be in [0, 1], got %v", opts.Gamma)
+	}
+	if _, ok := net.Layers()[net.Size()-1].(layers.PolicyLossLayer); !ok {
+		return errors.New("policy network must end in a softmax layer")
+	}
+	return nil
+}
+
+func checkRollout(ro *Rollout) error {
+	if ro == nil || ro.Len() == 0 {
+		return errors.New("rollout is empty")
+	} else if len(ro.Actions) != ro.Len() || len(ro.Rewards) != ro.Len() {
+		return fmt.Errorf("rollout has %d states, %d actions and %d rewards", ro.Len(), len(ro.Actions), len(ro.Rewards))
+	}
+	return nil
+}
+
+// add accumulates the policy loss and entropy of a step.
+func (s *UpdateStats) add(probs []float64, action int, advantage float64) error {
+	if action < 0 || action >= len(probs) {
+		return fmt.Errorf("action %d out of range [0, %d)", action, len(probs))
+	}
+	s.PolicyLoss -= advantage * math.Log(probs[action])
+	for _, p := range probs {
+		if p > 0 {
+			s.Entropy -= p * math.Log(p)
+		}
+	}
+	return nil
+}
+
+func (s UpdateStats) mean(n int) UpdateStats {
+	return UpdateStats{s.PolicyLoss / float64(n), s.ValueLoss / float64(n), s.Entropy / float64(n)}
+}
+
+// sample draws an index in proportion to the probabilities.
+func sample(probs []float64, rng *rand.Rand) int {
+	r, cum := rng.Float64(), 0.0
+	for k, p := range probs {
+		cum += p
+		if r < cum {
+			return k
+		}
+	}
+	return len(probs) - 1
+}
+
+// standardize scales the values to a mean of 0 and a standard deviation of 1.
+func standardize(values []float64) []float64 {
+	var mean, variance float64
+	for _, v := range values {
+		mean += v / float64(len(values))
+	}
+	for _, v := range values {
+		variance += (v - mean) * (v - mean) / float64(len(values))
+	}
+	std := math.Sqrt(variance)
+	out := make([]float64, len(values))
+	for i, v := range values {
+		out[i] = v - mean
+		if std > 1e-8 {
+			out[i] /= std
+		}
+	}
+	return out
+}
@@ -0,0 +1,140 @@
+package rl
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/nathanleary/reticulum"
+	"github.com/nathanleary/reticulum/layers"
+	"github.com/nathanleary/reticulum/volume"
+)
+
+func newNet(t *testing.T, defs ...layers.LayerDef) reticulum.Network {
+	t.Helper()
+	net, err := reticulum.NewNetwork(append([]layers.LayerDef{
+		{Type: layers.Input, Output: volume.NewDimensions(1, 1, 2)},
+	}, defs...), reticulum.WithNetworkSeed(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return net
+}
+
+func policyNet(t *testing.T) reticulum.Network {
+	return newNet(t, layers.LayerDef{Type: layers.SoftMax, LayerConfig: layers.NewSoftmaxLayerConfig(2)})
+}
+
+func valueNet(t *testing.T) reticulum.Network {
+	return newNet(t, layers.LayerDef{Type: layers.Regression, LayerConfig: layers.NewRegressionLayerConfig(1)})
+}
+
+type agent interface {
+	Act(state *volume.Volume) int
+	Train(ro *Rollout) (UpdateStats, error)
+}
+
+// bandit returns states whose rewarded action is the index of their larger input.
+func bandit(r *rand.Rand) (*volume.Volume, int) {
+	w := []float64{r.NormFloat64(), r.NormFloat64()}
+	best := 0
+	if w[1] > w[0] {
+		best = 1
+	}
+	return volume.NewVolume(volume.NewDimensions(1, 1, 2), volume.WithWeights(w)), best
+}
+
+// hitRate plays n episodes of one step and returns the fraction rewarded.
+func hitRate(a agent, r *rand.Rand, n int) float64 {
+	var hits int
+	for i := 0; i < n; i++ {
+		state, best := bandit(r)
+		if a.Act(state) == best {
+			hits++
+		}
+	}
+	return float64(hits) / float64(n)
+}
+
+func TestPolicyAgents_LearnBandit(t *testing.T) {
+	// every step is an episode of its own, so the returns are not discounted into
+	// the steps before it
+	trainer := WithTrainerOptions(reticulum.WithMethod(reticulum.Adam), reticulum.WithLearningRate(0.05))
+	for _, test := range []struct {
+		name  string
+		agent func() (agent, error)
+	}{
+		{"reinforce", func() (agent, error) { return NewReinforce(policyNet(t), trainer, WithGamma(0), WithSeed(1)) }},
+		{"a2c", func() (agent, error) { return NewA2C(policyNet(t), valueNet(t), trainer, WithGamma(0), WithSeed(1)) }},
+	} {
+		a, err := test.agent()
+		if err != nil {
+			t.Fatal(err)
+		}
+		r := rand.New(rand.NewSource(1))
+		before := hitRate(a, r, 500)
+		for i := 0; i < 100; i++ {
+			ro := &Rollout{Terminal: true}
+			for s := 0; s < 16; s++ {
+				state, best := bandit(r)
+				action, reward := a.Act(state), 0.0
+				if action == best {
+					reward = 1
+				}
+				ro.Add(state, action, reward)
+			}
+			if _, err := a.Train(ro); err != nil {
+				t.Fatalf("%s: %v", test.name, err)
+			}
+		}
+		if after := hitRate(a, r, 500); after < 0.8 || after <= before {
+			t.Errorf("%s: rewarded %v of the time after training, %v before", test.name, after, before)
+		}
+	}
+}
+
+func TestPolicyAgents_TrainErrors(t *testing.T) {
+	state := volume.NewVolume(volume.NewDimensions(1, 1, 2), volume.WithZeros())
+	reinforce, err := NewReinforce(policyNet(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	a2c, err := NewA2C(policyNet(t), valueNet(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []struct {
+		name    string
+		agent   agent
+		ro      *Rollout
+		wantErr string
+	}{
+		{"reinforce empty", reinforce, &Rollout{}, "empty"},
+		{"reinforce action out of range", reinforce, &Rollout{States: []*volume.Volume{state}, Actions: []int{2}, Rewards: []float64{1}}, "out of range"},
+		{"a2c mismatched", a2c, &Rollout{States: []*volume.Volume{state}, Rewards: []float64{1}, Terminal: true}, "actions"},
+		{"a2c no next state", a2c, &Rollout{States: []*volume.Volume{state}, Actions: []int{0}, Rewards: []float64{1}}, "next state"},
+		{"a2c negative action", a2c, &Rollout{States: []*volume.Volume{state}, Actions: []int{-1}, Rewards: []float64{1}, Terminal: true}, "out of range"},
+	} {
+		if _, err := test.agent.Train(test.ro); err == nil || !strings.Contains(err.Error(), test.wantErr) {
+			t.Errorf("%s: Train() = %v, want an error containing %q", test.name, err, test.wantErr)
+		}
+	}
+}
+
+func TestNewPolicyAgents_Errors(t *testing.T) {
+	for _, test := range []struct {
+		name string
+		new  func() error
+	}{
+		{"reinforce nil", func() error { _, err := NewReinforce(nil); return err }},
+		{"reinforce not softmax", func() error { _, err := NewReinforce(valueNet(t)); return err }},
+		{"reinforce gamma", func() error { _, err := NewReinforce(policyNet(t), WithGamma(2)); return err }},
+		{"a2c nil", func() error { _, err := NewA2C(nil, valueNet(t)); return err }},
+		{"a2c no critic", func() error { _, err := NewA2C(policyNet(t), nil); return err }},
+		{"a2c wide critic", func() error { _, err := NewA2C(policyNet(t), policyNet(t)); return err }},
+	} {
+		if err := test.new(); err == nil {
+			t.Errorf("%s: expected an error", test.name)
+		}
+	}
+}
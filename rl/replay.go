@@ -0,0 +1,201 @@
+package rl
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+)
+
+// ReplayBuffer is a ring buffer of experiences for experience replay, overwriting the
+// oldest experience once full. It samples uniformly or, when prioritized, in
+// proportion to the TD error of every experience as in prioritized experience replay
+// (Schaul et al.). A ReplayBuffer is not safe for concurrent use.
+type ReplayBuffer struct {
+	items []Experience
+	next  int
+
+	// prioritized buffers keep the priority of every experience in a sum tree
+	alpha       float64
+	tree        *sumTree
+	maxPriority float64
+}
+
+// ReplayBatch is a batch of experiences sampled from a replay buffer.
+type ReplayBatch struct {
+	Experiences []Experience
+
+	// Indexes locate the experiences in the buffer, to update their priorities.
+	Indexes []int
+
+	// Weights are the importance sampling weights correcting the bias of prioritized
+	// sampling, scaled to a maximum of 1. They are all 1 for uniform sampling.
+	Weights []float64
+}
+
+// NewReplayBuffer creates a buffer holding up to capacity experiences, sampled
+// uniformly.
+func NewReplayBuffer(capacity int) *ReplayBuffer {
+	if capacity <= 0 {
+		panic(fmt.Errorf("capacity must be greater than 0, got %d", capacity))
+	}
+	return &ReplayBuffer{items: make([]Experience, 0, capacity)}
+}
+
+// NewPrioritizedReplayBuffer creates a buffer holding up to capacity experiences,
+// sampled with probabilities proportional to their priority raised to alpha. An alpha
+// of 0 samples uniformly. New experiences get the highest priority seen so far, so
+// they are replayed at least once soon.
+func NewPrioritizedReplayBuffer(capacity int, alpha float64) *ReplayBuffer {
+	if alpha < 0 {
+		panic(fmt.Errorf("alpha cannot be negative, got %v", alpha))
+	}
+	r := NewReplayBuffer(capacity)
+	r.alpha = alpha
+	r.tree = newSumTree(capacity)
+	r.maxPriority = 1
+	return r
+}
+
+// Len returns the number of experiences in the buffer.
+func (r *ReplayBuffer) Len() int {
+	return len(r.items)
+}
+
+// Capacity returns the number of experiences the buffer holds once full.
+func (r *ReplayBuffer) Capacity() int {
+	return cap(r.items)
+}
+
+// Prioritized reports whether the buffer samples by priority.
+func (r *ReplayBuffer) Prioritized() bool {
+	return r.tree != nil
+}
+
+// At returns the experience at index i.
+func (r *ReplayBuffer) At(i int) Experience {
+	return r.items[i]
+}
+
+// Add stores an experience, overwriting the oldest one when the buffer is full.
+func (r *ReplayBuffer) Add(e Experience) {
+	i := r.next
+	if len(r.items) < cap(r.items) {
+		r.items = append(r.items, e)
+	} else {
+		r.items[i] = e
+	}
+	r.next = (r.next + 1) % cap(r.items)
+	if r.tree != nil {
+		r.tree.set(i, math.Pow(r.maxPriority, r.alpha))
+	}
+}
+
+// Sample draws n experiences with replacement. Prioritized buffers split the total
+// priority into n equal ranges and draw one experience from each, and weight them by
+// (N * P(i))^-beta, where beta of 1 fully corrects the bias; beta is ignored when
+// sampling uniformly.
+func (r *ReplayBuffer) Sample(n int, beta float64, rng *rand.Rand) (ReplayBatch, error) {
+	if len(r.items) == 0 {
+		return ReplayBatch{}, errors.New("replay buffer is empty")
+	} else if n <= 0 {
+		return ReplayBatch{}, fmt.Errorf("sample size must be greater than 0, got %d", n)
+	}
+
+	batch := ReplayBatch{
+		Experiences: make([]Experience, n),
+		Indexes:     make([]int, n),
+		Weights:     make([]float64, n),
+	}
+	if r.tree == nil {
+		for k := range batch.Indexes {
+			i := rng.Intn(len(r.items))
+			batch.Experiences[k], batch.Indexes[k], batch.Weights[k] = r.items[i], i, 1
+		}
+		return batch, nil
+	}
+
+	total := r.tree.total()
+	segment := total / float64(n)
+	var maxWeight float64
+	for k := range batch.Indexes {
+		i := r.tree.find((float64(k) + rng.Float64()) * segment)
+		p := r.tree.get(i) / total
+		w := math.Pow(float64(len(r.items))*p, -beta)
+		batch.Experiences[k], batch.Indexes[k], batch.Weights[k] = r.items[i], i, w
+		maxWeight = max(maxWeight, w)
+	}
+	for k := range batch.Weights {
+		batch.Weights[k] /= maxWeight
+	}
+	return batch, nil
+}
+
+// UpdatePriorities sets the priorities of the experiences at the given indexes from
+// their latest TD errors, as |error| plus a small constant so every experience keeps a
+// chance of being replayed. It does nothing for uniform buffers.
+func (r *ReplayBuffer) UpdatePriorities(indexes []int, tdErrors []float64) error {
+	if len(indexes) != len(tdErrors) {
+		return fmt.Errorf("got %d TD errors for %d indexes", len(tdErrors), len(indexes))
+	}
+	if r.tree == nil {
+		return nil
+	}
+	for k, i := range indexes {
+		if i < 0 || i >= len(r.items) {
+			return fmt.Errorf("index %d out of range [0, %d)", i, len(r.items))
+		}
+		p := math.Abs(tdErrors[k]) + 1e-6
+		r.maxPriority = max(r.maxPriority, p)
+		r.tree.set(i, math.Pow(p, r.alpha))
+	}
+	return nil
+}
+
+// sumTree is a binary tree whose leaves hold the priorities and every inner node the
+// sum of its children, to draw leaves in proportion to their priority in O(log n).
+type sumTree struct {
+	// nodes[1] is the root, the children of node i are 2i and 2i+1, and leaf j is
+	// node leaves+j
+	nodes  []float64
+	leaves int
+}
+
+func newSumTree(capacity int) *sumTree {
+	leaves := 1
+	for leaves < capacity {
+		leaves *= 2
+	}
+	return &sumTree{nodes: make([]float64, 2*leaves), leaves: leaves}
+}
+
+func (t *sumTree) total() float64 {
+	return t.nodes[1]
+}
+
+func (t *sumTree) get(i int) float64 {
+	return t.nodes[t.leaves+i]
+}
+
+func (t *sumTree) set(i int, p float64) {
+	node := t.leaves + i
+	t.nodes[node] = p
+	for node /= 2; node >= 1; node /= 2 {
+		t.nodes[node] = t.nodes[2*node] + t.nodes[2*node+1]
+	}
+}
+
+// find returns the leaf at which the running sum of the priorities exceeds v.
+func (t *sumTree) find(v float64) int {
+	node := 1
+	for node < t.leaves {
+		left := 2 * node
+		if v < t.nodes[left] || t.nodes[left+1] == 0 {
+			node = left
+		} else {
+			v -= t.nodes[left]
+			node = left + 1
+		}
+	}
+	return node - t.leaves
+}
@@ -0,0 +1,102 @@
+package rl
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestReplayBuffer_Add(t *testing.T) {
+	r := NewReplayBuffer(3)
+	for i := 0; i < 5; i++ {
+		r.Add(Experience{Action0: i})
+	}
+	if r.Len() != 3 || r.Capacity() != 3 {
+		t.Fatalf("Len() = %d, Capacity() = %d, want 3 and 3", r.Len(), r.Capacity())
+	}
+	// the two oldest experiences were overwritten in place
+	for i, want := range []int{3, 4, 2} {
+		if got := r.At(i).Action0; got != want {
+			t.Errorf("At(%d) holds action %d, want %d", i, got, want)
+		}
+	}
+}
+
+func TestReplayBuffer_Sample(t *testing.T) {
+	for _, test := range []struct {
+		name   string
+		buffer *ReplayBuffer
+		tdErrs []float64
+		// want is the expected share of the samples drawn from each experience
+		want []float64
+	}{
+		{"uniform", NewReplayBuffer(4), []float64{1, 1, 1, 7}, []float64{0.25, 0.25, 0.25, 0.25}},
+		{"prioritized", NewPrioritizedReplayBuffer(4, 1), []float64{1, 1, 1, 7}, []float64{0.1, 0.1, 0.1, 0.7}},
+		{"alpha 0", NewPrioritizedReplayBuffer(4, 0), []float64{1, 1, 1, 7}, []float64{0.25, 0.25, 0.25, 0.25}},
+	} {
+		for i := range test.tdErrs {
+			test.buffer.Add(Experience{Action0: i})
+		}
+		if err := test.buffer.UpdatePriorities([]int{0, 1, 2, 3}, test.tdErrs); err != nil {
+			t.Fatal(err)
+		}
+
+		rng := rand.New(rand.NewSource(1))
+		counts := make([]float64, len(test.want))
+		const n = 20000
+		batch, err := test.buffer.Sample(n, 1, rng)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for k, i := range batch.Indexes {
+			if batch.Experiences[k].Action0 != i {
+				t.Fatalf("%s: sample %d is experience %d, but indexed %d", test.name, k, batch.Experiences[k].Action0, i)
+			} else if batch.Weights[k] <= 0 || batch.Weights[k] > 1 {
+				t.Fatalf("%s: sample %d has weight %v", test.name, k, batch.Weights[k])
+			}
+			counts[i]++
+		}
+		for i, want := range test.want {
+			if got := counts[i] / n; got < want-0.02 || got > want+0.02 {
+				t.Errorf("%s: experience %d drawn %.3f of the time, want %.3f", test.name, i, got, want)
+			}
+		}
+	}
+}
+
+func TestReplayBuffer_Errors(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	empty := NewPrioritizedReplayBuffer(2, 0.6)
+	if _, err := empty.Sample(1, 1, rng); err == nil {
+		t.Error("expected an error sampling an empty buffer")
+	}
+	full := NewPrioritizedReplayBuffer(2, 0.6)
+	full.Add(Experience{})
+	for _, test := range []struct {
+		name string
+		err  error
+	}{
+		{"no samples", func() error { _, err := full.Sample(0, 1, rng); return err }()},
+		{"mismatched errors", full.UpdatePriorities([]int{0}, []float64{1, 2})},
+		{"index out of range", full.UpdatePriorities([]int{1}, []float64{1})},
+	} {
+		if test.err == nil {
+			t.Errorf("%s: expected an error", test.name)
+		}
+	}
+	for _, test := range []struct {
+		name string
+		new  func()
+	}{
+		{"no capacity", func() { NewReplayBuffer(0) }},
+		{"negative alpha", func() { NewPrioritizedReplayBuffer(2, -1) }},
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("%s: expected a panic", test.name)
+				}
+			}()
+			test.new()
+		}()
+	}
+}
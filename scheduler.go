@@ -0,0 +1,180 @@
+package reticulum
+
+import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"math"
+)
+
+// Scheduler adjusts the learning rate as training progresses.
+type Scheduler interface {
+	// LearningRate returns the learning rate for iteration k given the base rate.
+	LearningRate(base float64, k int) float64
+}
+
+// MetricReporter is implemented by schedulers which react to an externally
+// reported metric, such as the validation loss.
+type MetricReporter interface {
+	ReportMetric(v float64)
+}
+
+// NewReduceLROnPlateau creates a scheduler which multiplies the learning rate
+// by factor after patience reported metrics without improvement. It panics on
+// invalid arguments, see NewReduceLROnPlateauE.
+func NewReduceLROnPlateau(factor float64, patience int) *ReduceLROnPlateau {
+	s, err := NewReduceLROnPlateauE(factor, patience)
+	if err != nil {
+		panic(err)
+	}
+	return s
+}
+
+// NewReduceLROnPlateauE is like NewReduceLROnPlateau but returns an error when factor
+// is not between 0 and 1 or patience is negative.
+func NewReduceLROnPlateauE(factor float64, patience int) (*ReduceLROnPlateau, error) {
+	if factor <= 0 || factor >= 1 {
+		return nil, errors.New("factor must be between 0 and 1")
+	} else if patience < 0 {
+		return nil, errors.New("patience cannot be negative")
+	}
+	return &ReduceLROnPlateau{Factor: factor, Patience: patience, scale: 1.0}, nil
+}
+
+// ReduceLROnPlateau cuts the learning rate when a reported metric stops improving.
+// By default lower metric values are considered better (e.g. validation loss). The
+// zero value leaves the learning rate unchanged until Factor is set.
+type ReduceLROnPlateau struct {
+	Factor   float64
+	Patience int
+
+	// Threshold is the minimum change which counts as an improvement.
+	Threshold float64
+
+	// MinLR is the lower bound the reductions stop at. Base rates already below it
+	// are left unchanged rather than raised.
+	MinLR float64
+
+	// Maximize treats higher metric values as better (e.g. accuracy).
+	Maximize bool
+
+	best float64
+	seen bool
+	bad  int
+
+	// scale is the product of the reductions so far, with 0 standing for 1 so the
+	// zero value starts at the base rate
+	scale float64
+}
+
+// LearningRate returns the base rate scaled by all reductions so far.
+func (s *ReduceLROnPlateau) LearningRate(base float64, k int) float64 {
+	// only the reductions are clamped, so a param group with a lower base rate
+	// than MinLR keeps it
+	return max(base*s.currentScale(), min(base, s.MinLR))
+}
+
+// currentScale returns the product of the reductions so far.
+func (s *ReduceLROnPlateau) currentScale() float64 {
+	if s.scale == 0 {
+		return 1.0
+	}
+	return s.scale
+}
+
+// ReportMetric records an evaluation of the monitored metric.
+func (s *ReduceLROnPlateau) ReportMetric(v float64) {
+	improved := !s.seen
+	if s.seen {
+		if s.Maximize {
+			improved = v > s.best+s.Threshold
+		} else {
+			improved = v < s.best-s.Threshold
+		}
+	}
+
+	if improved {
+		s.best = v
+		s.seen = true
+		s.bad = 0
+		return
+	}
+
+	s.bad++
+	if s.bad > s.Patience {
+		s.scale = s.currentScale() * s.Factor
+		s.bad = 0
+	}
+}
+
+// plateauState is the serialized progress of a ReduceLROnPlateau scheduler.
+type plateauState struct {
+	Best  float64
+	Seen  bool
+	Bad   int
+	Scale float64
+}
+
+// MarshalBinary encodes the scheduler progress, so it can be checkpointed.
+func (s *ReduceLROnPlateau) MarshalBinary() ([]byte, error) {
+	var buf bytes.Buffer
+	err := gob.NewEncoder(&buf).Encode(plateauState{s.best, s.seen, s.bad, s.scale})
+	return buf.Bytes(), err
+}
+
+// UnmarshalBinary restores the scheduler progress saved with MarshalBinary.
+func (s *ReduceLROnPlateau) UnmarshalBinary(data []byte) error {
+	var state plateauState
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&state); err != nil {
+		return err
+	}
+	s.best, s.seen, s.bad, s.scale = state.Best, state.Seen, state.Bad, state.Scale
+	return nil
+}
+
+// CyclicalMode describes how the amplitude of a cyclical schedule evolves.
+type CyclicalMode string
+
+// Available cyclical modes
+const (
+	// Triangular keeps the amplitude constant across cycles.
+	Triangular CyclicalMode = "triangular"
+
+	// Triangular2 halves the amplitude after every cycle.
+	Triangular2 CyclicalMode = "triangular2"
+)
+
+// NewCyclicalLR creates a cyclical scheduler which oscillates linearly between
+// baseLR and maxLR, completing one full cycle every cycleLength iterations.
+func NewCyclicalLR(mode CyclicalMode, baseLR, maxLR float64, cycleLength int) *CyclicalLR {
+	if mode != Triangular && mode != Triangular2 {
+		panic("unsupported cyclical mode")
+	} else if maxLR < baseLR {
+		panic("max learning rate cannot be less than the base learning rate")
+	} else if cycleLength < 2 {
+		panic("cycle length must be at least 2")
+	}
+	return &CyclicalLR{Mode: mode, BaseLR: baseLR, MaxLR: maxLR, CycleLength: cycleLength}
+}
+
+// CyclicalLR implements the triangular cyclical learning rate policy.
+// The trainer's configured learning rate is ignored in favour of BaseLR and MaxLR.
+type CyclicalLR struct {
+	Mode        CyclicalMode
+	BaseLR      float64
+	MaxLR       float64
+	CycleLength int
+}
+
+// LearningRate returns the cyclical learning rate for iteration k.
+func (s *CyclicalLR) LearningRate(base float64, k int) float64 {
+	half := float64(s.CycleLength) / 2.0
+	cycle := math.Floor(1 + float64(k)/float64(s.CycleLength))
+	x := math.Abs(float64(k)/half - 2*cycle + 1)
+
+	amplitude := (s.MaxLR - s.BaseLR) * math.Max(0, 1-x)
+	if s.Mode == Triangular2 {
+		amplitude /= math.Pow(2, cycle-1)
+	}
+	return s.BaseLR + amplitude
+}
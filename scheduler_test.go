@@ -0,0 +1,39 @@
+package reticulum
+
+import (
+	"math"
+	"testing"
+)
+
+func TestReduceLROnPlateau_ZeroValue(t *testing.T) {
+	s := &ReduceLROnPlateau{}
+	if got := s.LearningRate(0.1, 1); got != 0.1 {
+		t.Errorf("LearningRate() = %v, want 0.1", got)
+	}
+
+	s.Factor = 0.5
+	s.ReportMetric(1)
+	s.ReportMetric(2)
+	if got := s.LearningRate(0.1, 2); math.Abs(got-0.05) > 1e-12 {
+		t.Errorf("LearningRate() after a plateau = %v, want 0.05", got)
+	}
+}
+
+func TestNewReduceLROnPlateauE(t *testing.T) {
+	tests := []struct {
+		factor   float64
+		patience int
+		wantErr  bool
+	}{
+		{0.5, 2, false},
+		{0, 2, true},
+		{1, 2, true},
+		{0.5, -1, true},
+	}
+	for _, test := range tests {
+		_, err := NewReduceLROnPlateauE(test.factor, test.patience)
+		if (err != nil) != test.wantErr {
+			t.Errorf("NewReduceLROnPlateauE(%v, %v) error = %v, want error %v", test.factor, test.patience, err, test.wantErr)
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package reticulum
+
+import (
+	"context"
+	"errors"
+	"runtime"
+
+	"github.com/nathanleary/reticulum/volume"
+)
+
+// Prediction is the result of scoring one input of a stream.
+type Prediction struct {
+	// Index is the position of the input in the stream.
+	Index int
+
+	Output *volume.Volume
+
+	// Class is the class with the highest output, for networks ending in a softmax or
+	// svm layer, and -1 otherwise.
+	Class int
+
+	Err error
+}
+
+// Serve compiles net and scores the volumes received from in on a pool of workers,
+// sending the predictions in input order, see Predictor.Serve. A network which fails
+// to compile gets a single prediction holding the error.
+func Serve(ctx context.Context, net Network, in <-chan *volume.Volume) <-chan Prediction {
+	p, err := Compile(net, InferenceOptions{})
+	if err != nil {
+		out := make(chan Prediction, 1)
+		out <- Prediction{Class: -1, Err: err}
+		close(out)
+		return out
+	}
+	return p.Serve(ctx, in)
+}
+
+// Serve scores the volumes received from in on a pool of sessions, one per CPU, and
+// sends the predictions in the order of the inputs. The returned channel is closed
+// once in is closed and every prediction sent, or when ctx is done.
+func (p *Predictor) Serve(ctx context.Context, in <-chan *volume.Volume) <-chan Prediction {
+	workers := runtime.GOMAXPROCS(0)
+	type job struct {
+		index int
+		vol   *volume.Volume
+		res   chan Prediction
+	}
+	jobs := make(chan job, workers)
+
+	// pending holds the result of every job in input order, bounding how far the
+	// workers can get ahead of the consumer
+	pending := make(chan chan Prediction, 2*workers)
+
+	go func() {
+		defer close(jobs)
+		defer close(pending)
+		for i := 0; ; i++ {
+			var vol *volume.Volume
+			var ok bool
+			select {
+			case vol, ok = <-in:
+				if !ok {
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
+
+			j := job{i, vol, make(chan Prediction, 1)}
+			select {
+			case pending <- j.res:
+			case <-ctx.Done():
+				return
+			}
+			select {
+			case jobs <- j:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	for w := 0; w < workers; w++ {
+		go func() {
+			s := p.NewSession()
+			for j := range jobs {
+				j.res <- s.predict(j.index, j.vol)
+			}
+		}()
+	}
+
+	out := make(chan Prediction)
+	go func() {
+		defer close(out)
+		for res := range pending {
+			var pred Prediction
+			select {
+			case pred = <-res:
+			case <-ctx.Done():
+				return
+			}
+			select {
+			case out <- pred:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out
+}
+
+// predict scores a single volume of a stream.
+func (s *Session) predict(index int, vol *volume.Volume) Prediction {
+	pred := Prediction{Index: index, Class: -1}
+	if vol == nil {
+		pred.Err = errors.New("volume cannot be nil")
+		return pred
+	}
+	res, err := s.Run(vol.Weights())
+	if err != nil {
+		pred.Err = err
+		return pred
+	}
+	pred.Output = volume.NewVolume(s.p.output, volume.WithZeros())
+	copy(pred.Output.Weights(), res)
+	if s.p.classes {
+		pred.Class = argmax(res)
+	}
+	return pred
+}
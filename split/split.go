@@ -0,0 +1,128 @@
+// Package split divides datasets into training, validation and test sets, optionally
+// keeping the class balance of the whole dataset in every part.
+package split
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+
+	"github.com/nathanleary/reticulum"
+	"github.com/nathanleary/reticulum/datasets"
+)
+
+// Subset is a view of some of the samples of a source, in the order of Indexes.
+type Subset struct {
+	Source  datasets.Source
+	Indexes []int
+}
+
+func (s *Subset) Len() int {
+	return len(s.Indexes)
+}
+
+func (s *Subset) Sample(i int) (reticulum.Sample, error) {
+	return s.Source.Sample(s.Indexes[i])
+}
+
+// Random shuffles the samples of src and splits them into one subset per fraction,
+// e.g. {0.8, 0.1, 0.1} for training, validation and test sets. Fractions summing to
+// less than 1 leave the remaining samples out.
+func Random(src datasets.Source, fractions []float64, seed int64) ([]*Subset, error) {
+	if err := checkFractions(src, fractions); err != nil {
+		return nil, err
+	}
+	rng := rand.New(rand.NewSource(seed))
+	parts := divide(rng.Perm(src.Len()), fractions)
+
+	subsets := make([]*Subset, len(parts))
+	for i, part := range parts {
+		subsets[i] = &Subset{Source: src, Indexes: part}
+	}
+	return subsets, nil
+}
+
+// Stratified splits the samples of src like Random, but splits every class on its own
+// so each subset has about the class proportions of src. labels holds the class of
+// every sample, or is nil to read the labels from the samples.
+func Stratified(src datasets.Source, labels []int, fractions []float64, seed int64) ([]*Subset, error) {
+	if err := checkFractions(src, fractions); err != nil {
+		return nil, err
+	}
+	if labels == nil {
+		labels = make([]int, src.Len())
+		for i := range labels {
+			s, err := src.Sample(i)
+			if err != nil {
+				return nil, fmt.Errorf("sample %d: %w", i, err)
+			}
+			labels[i] = s.Label
+		}
+	} else if len(labels) != src.Len() {
+		return nil, fmt.Errorf("got %d labels for %d samples", len(labels), src.Len())
+	}
+
+	// group the samples by class, in order of first appearance for reproducibility
+	var classes []int
+	byClass := map[int][]int{}
+	for i, label := range labels {
+		if _, ok := byClass[label]; !ok {
+			classes = append(classes, label)
+		}
+		byClass[label] = append(byClass[label], i)
+	}
+
+	rng := rand.New(rand.NewSource(seed))
+	subsets := make([]*Subset, len(fractions))
+	for i := range subsets {
+		subsets[i] = &Subset{Source: src}
+	}
+	for _, c := range classes {
+		idx := byClass[c]
+		rng.Shuffle(len(idx), func(i, j int) { idx[i], idx[j] = idx[j], idx[i] })
+		for i, part := range divide(idx, fractions) {
+			subsets[i].Indexes = append(subsets[i].Indexes, part...)
+		}
+	}
+
+	// interleave the classes again
+	for _, s := range subsets {
+		rng.Shuffle(len(s.Indexes), func(i, j int) { s.Indexes[i], s.Indexes[j] = s.Indexes[j], s.Indexes[i] })
+	}
+	return subsets, nil
+}
+
+func checkFractions(src datasets.Source, fractions []float64) error {
+	if src == nil {
+		return errors.New("source cannot be nil")
+	} else if len(fractions) == 0 {
+		return errors.New("split requires at least one fraction")
+	}
+	var sum float64
+	for _, f := range fractions {
+		if f <= 0 {
+			return fmt.Errorf("fractions must be greater than 0, got %v", f)
+		}
+		sum += f
+	}
+	if sum > 1+1e-9 {
+		return fmt.Errorf("fractions sum to %v, more than 1", sum)
+	}
+	return nil
+}
+
+// divide cuts idx into consecutive parts of the given fractions, rounding the
+// cumulative boundaries so fractions summing to 1 use every index.
+func divide(idx []int, fractions []float64) [][]int {
+	parts := make([][]int, len(fractions))
+	var cum float64
+	start := 0
+	for i, f := range fractions {
+		cum += f
+		end := min(int(math.Round(cum*float64(len(idx)))), len(idx))
+		parts[i] = append([]int(nil), idx[start:end]...)
+		start = end
+	}
+	return parts
+}
@@ -0,0 +1,100 @@
+package split
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/nathanleary/reticulum/datasets"
+)
+
+// labeled returns a source of n samples, a fifth of them of class 1.
+func labeled(n int) datasets.Samples {
+	s := make(datasets.Samples, n)
+	for i := range s {
+		if i%5 == 0 {
+			s[i].Label = 1
+		}
+	}
+	return s
+}
+
+func TestSplits(t *testing.T) {
+	src := labeled(100)
+	for _, test := range []struct {
+		name      string
+		split     func(fractions []float64, seed int64) ([]*Subset, error)
+		fractions []float64
+		sizes     []int
+		// ones is the number of class 1 samples in each subset, -1 if unchecked
+		ones []int
+	}{
+		{"random", func(f []float64, seed int64) ([]*Subset, error) { return Random(src, f, seed) }, []float64{0.8, 0.1, 0.1}, []int{80, 10, 10}, []int{-1, -1, -1}},
+		{"random partial", func(f []float64, seed int64) ([]*Subset, error) { return Random(src, f, seed) }, []float64{0.25, 0.25}, []int{25, 25}, []int{-1, -1}},
+		{"stratified", func(f []float64, seed int64) ([]*Subset, error) { return Stratified(src, nil, f, seed) }, []float64{0.8, 0.1, 0.1}, []int{80, 10, 10}, []int{16, 2, 2}},
+		{"stratified with labels", func(f []float64, seed int64) ([]*Subset, error) {
+			labels := make([]int, src.Len())
+			for i := range labels {
+				labels[i] = i % 2
+			}
+			return Stratified(src, labels, f, seed)
+		}, []float64{0.5, 0.5}, []int{50, 50}, []int{-1, -1}},
+	} {
+		subsets, err := test.split(test.fractions, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var all []int
+		for i, s := range subsets {
+			if s.Len() != test.sizes[i] {
+				t.Errorf("%s: subset %d has %d samples, want %d", test.name, i, s.Len(), test.sizes[i])
+			}
+			var ones int
+			for k := 0; k < s.Len(); k++ {
+				sample, err := s.Sample(k)
+				if err != nil {
+					t.Fatal(err)
+				}
+				ones += sample.Label
+			}
+			if test.ones[i] >= 0 && ones != test.ones[i] {
+				t.Errorf("%s: subset %d has %d samples of class 1, want %d", test.name, i, ones, test.ones[i])
+			}
+			all = append(all, s.Indexes...)
+		}
+		if len(slices.Compact(slices.Sorted(slices.Values(all)))) != len(all) {
+			t.Errorf("%s: subsets overlap", test.name)
+		}
+
+		// the same seed gives the same split, another seed another one
+		again, _ := test.split(test.fractions, 1)
+		other, _ := test.split(test.fractions, 2)
+		if !slices.Equal(again[0].Indexes, subsets[0].Indexes) || slices.Equal(other[0].Indexes, subsets[0].Indexes) {
+			t.Errorf("%s: splits do not follow the seed", test.name)
+		}
+	}
+}
+
+func TestSplits_Errors(t *testing.T) {
+	src := labeled(10)
+	for _, test := range []struct {
+		name      string
+		src       datasets.Source
+		labels    []int
+		fractions []float64
+	}{
+		{"nil source", nil, nil, []float64{1}},
+		{"no fractions", src, nil, nil},
+		{"zero fraction", src, nil, []float64{0.5, 0}},
+		{"more than all", src, nil, []float64{0.6, 0.6}},
+	} {
+		if _, err := Random(test.src, test.fractions, 1); err == nil {
+			t.Errorf("%s: expected an error from Random", test.name)
+		}
+		if _, err := Stratified(test.src, test.labels, test.fractions, 1); err == nil {
+			t.Errorf("%s: expected an error from Stratified", test.name)
+		}
+	}
+	if _, err := Stratified(src, []int{0, 1}, []float64{1}, 1); err == nil {
+		t.Error("expected an error for missing labels")
+	}
+}
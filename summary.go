@@ -0,0 +1,97 @@
+package reticulum
+
+import (
+	"fmt"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/nathanleary/reticulum/layers"
+	"github.com/nathanleary/reticulum/volume"
+)
+
+// LayerSummary describes a single layer of a network.
+type LayerSummary struct {
+	Name   string
+	Type   layers.LayerType
+	Output volume.Dimensions
+
+	// Inputs names the layers feeding this one.
+	Inputs []string
+
+	// Params is the number of weights and biases of the layer.
+	Params int
+}
+
+// NetworkSummary describes the layers of a network and their parameters.
+type NetworkSummary struct {
+	Layers []LayerSummary
+	Params int
+
+	// Bytes is the memory used by the parameters and their gradients.
+	Bytes int
+}
+
+// Summarize returns the layers of net with their output shape and parameter count. It
+// requires a network built by this package.
+func Summarize(net Network) NetworkSummary {
+	n, ok := net.(*network)
+	if !ok {
+		panic("summaries require a network built by NewNetwork or NewGraph")
+	}
+	return n.summarize()
+}
+
+// Summary returns the summary of net formatted as a table, see Summarize.
+func Summary(net Network) string {
+	return Summarize(net).String()
+}
+
+func (n *network) summarize() NetworkSummary {
+	var s NetworkSummary
+	for i, layer := range n.layers {
+		ls := LayerSummary{Name: n.names[i], Type: layer.Type(), Output: n.defs[i].Output}
+		if n.graph != nil {
+			for _, j := range n.graph.inputs[i] {
+				ls.Inputs = append(ls.Inputs, n.names[j])
+			}
+		} else if i > 0 {
+			ls.Inputs = []string{n.names[i-1]}
+		}
+		for _, resp := range layer.GetResponse() {
+			ls.Params += len(resp.Weights)
+		}
+		s.Params += ls.Params
+		s.Layers = append(s.Layers, ls)
+	}
+
+	// float64 weights and gradients
+	s.Bytes = s.Params * 8 * 2
+	return s
+}
+
+// String formats the summary as a table with a row per layer and the totals.
+func (s NetworkSummary) String() string {
+	var b strings.Builder
+	w := tabwriter.NewWriter(&b, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "Layer (type)\tOutput shape\tParams\tInputs")
+	for _, l := range s.Layers {
+		fmt.Fprintf(w, "%s (%s)\t%dx%dx%d\t%d\t%s\n", l.Name, l.Type, l.Output.X, l.Output.Y, l.Output.Z, l.Params, strings.Join(l.Inputs, ", "))
+	}
+	w.Flush()
+	fmt.Fprintf(&b, "Total params: %d (%s with gradients)\n", s.Params, formatBytes(s.Bytes))
+	return b.String()
+}
+
+// formatBytes formats a byte count with a binary unit.
+func formatBytes(n int) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	value, exp := float64(n)/unit, 0
+	for value >= unit && exp < 3 {
+		value /= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", value, "KMGT"[exp])
+}
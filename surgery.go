@@ -0,0 +1,244 @@
+package reticulum
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+
+	"github.com/nathanleary/reticulum/layers"
+)
+
+// The surgery functions below return an edited copy of a sequential network built by
+// this package, which is left unchanged. The dimensions of every layer are recomputed,
+// and layers whose parameters keep their shape keep their weights while the others
+// are freshly initialized. Frozen layers stay frozen in the copy.
+
+// InsertLayers returns a copy of net with the layers built from defs inserted after
+// the named layer.
+func InsertLayers(net Network, after string, defs ...layers.LayerDef) (Network, error) {
+	n, ok := net.(*network)
+	if !ok {
+		return nil, errors.New("editing layers requires a network built by NewNetwork")
+	}
+	return n.insertLayers(after, defs...)
+}
+
+// RemoveLayers returns a copy of net without the named layers.
+func RemoveLayers(net Network, names ...string) (Network, error) {
+	n, ok := net.(*network)
+	if !ok {
+		return nil, errors.New("editing layers requires a network built by NewNetwork")
+	}
+	return n.removeLayers(names...)
+}
+
+// ReplaceLayer returns a copy of net with the named layer replaced by the layers built
+// from def, which keep its name unless def is named.
+func ReplaceLayer(net Network, name string, def layers.LayerDef) (Network, error) {
+	n, ok := net.(*network)
+	if !ok {
+		return nil, errors.New("editing layers requires a network built by NewNetwork")
+	}
+	return n.replaceLayer(name, def)
+}
+
+// StripDropout returns a copy of net without its dropout layers, e.g. for export.
+func StripDropout(net Network) (Network, error) {
+	n, ok := net.(*network)
+	if !ok {
+		return nil, errors.New("editing layers requires a network built by NewNetwork")
+	}
+	return n.stripDropout()
+}
+
+func (n *network) insertLayers(after string, defs ...layers.LayerDef) (Network, error) {
+	idx := n.layerIndex(after)
+	if idx < 0 {
+		return nil, fmt.Errorf("unknown layer %q", after)
+	} else if idx == len(n.layers)-1 {
+		return nil, errors.New("cannot insert layers after the loss layer")
+	}
+
+	seen := n.nameSet()
+	var inserted []layers.LayerDef
+	for _, def := range defs {
+		inserted = append(inserted, expandNamed(def, seen)...)
+	}
+
+	edited := append([]layers.LayerDef{}, n.namedDefs()[:idx+1]...)
+	edited = append(edited, inserted...)
+	edited = append(edited, n.namedDefs()[idx+1:]...)
+	return n.rebuild(edited)
+}
+
+func (n *network) removeLayers(names ...string) (Network, error) {
+	remove := map[int]bool{}
+	for _, name := range names {
+		i := n.layerIndex(name)
+		if i < 0 {
+			return nil, fmt.Errorf("unknown layer %q", name)
+		} else if i == 0 || i == len(n.layers)-1 {
+			return nil, fmt.Errorf("cannot remove the input or loss layer %q", name)
+		}
+		remove[i] = true
+	}
+
+	var edited []layers.LayerDef
+	for i, def := range n.namedDefs() {
+		if !remove[i] {
+			edited = append(edited, def)
+		}
+	}
+	return n.rebuild(edited)
+}
+
+func (n *network) replaceLayer(name string, def layers.LayerDef) (Network, error) {
+	idx := n.layerIndex(name)
+	if idx < 0 {
+		return nil, fmt.Errorf("unknown layer %q", name)
+	} else if idx == 0 && def.Type != layers.Input {
+		return nil, errors.New("the input layer can only be replaced with an input layer")
+	}
+
+	seen := n.nameSet()
+	delete(seen, name)
+	if def.Name == "" {
+		def.Name = name
+	}
+
+	defs := n.namedDefs()
+	edited := append([]layers.LayerDef{}, defs[:idx]...)
+	edited = append(edited, expandNamed(def, seen)...)
+	edited = append(edited, defs[idx+1:]...)
+	return n.rebuild(edited)
+}
+
+func (n *network) stripDropout() (Network, error) {
+	var names []string
+	for i, l := range n.layers {
+		if l.Type() == layers.Dropout {
+			names = append(names, n.names[i])
+		}
+	}
+	if len(names) == 0 {
+		return n.clone(), nil
+	}
+	return n.removeLayers(names...)
+}
+
+// namedDefs returns the definitions of the layers with their names filled in.
+func (n *network) namedDefs() []layers.LayerDef {
+	defs := make([]layers.LayerDef, len(n.defs))
+	for i, def := range n.defs {
+		def.Name = n.names[i]
+		defs[i] = def
+	}
+	return defs
+}
+
+func (n *network) nameSet() map[string]bool {
+	seen := make(map[string]bool, len(n.names))
+	for _, name := range n.names {
+		seen[name] = true
+	}
+	return seen
+}
+
+// expandNamed adds the activation and dropout layers of def, giving every resulting
+// layer a name which is not in seen.
+func expandNamed(def layers.LayerDef, seen map[string]bool) []layers.LayerDef {
+	expanded := layers.ActivateLayers([]layers.LayerDef{def})
+	for i := range expanded {
+		base := expanded[i].Name
+		if base == "" || expanded[i].Type != def.Type {
+			base = string(expanded[i].Type)
+			if def.Name != "" {
+				base = def.Name + "_" + base
+			}
+		}
+
+		name := base
+		for k := 1; seen[name]; k++ {
+			name = fmt.Sprintf("%s%d", base, k)
+		}
+		seen[name] = true
+		expanded[i].Name = name
+	}
+	return expanded
+}
+
+// rebuild returns a network with layers built from defs, recomputing their dimensions
+// and keeping the weights of layers of n whose parameters are unchanged.
+func (n *network) rebuild(defs []layers.LayerDef) (Network, error) {
+	if n.graph != nil {
+		return nil, errors.New("editing the layers of a graph network is not supported")
+	} else if len(defs) < 2 || defs[0].Type != layers.Input {
+		return nil, errors.New("network requires an input and a loss layer")
+	}
+
+	for i := 1; i < len(defs); i++ {
+		defs[i].Input = defs[i-1].Output
+		out, err := layers.OutputDimensions(defs[i])
+		if err != nil {
+			return nil, fmt.Errorf("layer %s: %w", defs[i].Name, err)
+		}
+		defs[i].Output = out
+	}
+
+	var rng *rand.Rand
+	if r := n.defs[0].Rand; r != nil {
+		rng = rand.New(rand.NewSource(r.Int63()))
+	}
+	for i := range defs {
+		defs[i].Rand = nil
+	}
+
+	var c *network
+	var err error
+	func() {
+		defer recoverError(&err)
+		c, err = newSequential(defs, rng)
+	}()
+	if err != nil {
+		return nil, err
+	}
+	if err := checkLossLayers(c); err != nil {
+		return nil, err
+	}
+
+	old := layersByName(n)
+	for i, l := range c.layers {
+		prev, ok := old[c.names[i]]
+		if !ok || prev.Type() != l.Type() {
+			continue
+		}
+		dp, sp := l.GetResponse(), prev.GetResponse()
+		if sameShapes(dp, sp) {
+			for k := range dp {
+				copy(dp[k].Weights, sp[k].Weights)
+			}
+		}
+	}
+
+	c.tracer = n.tracer
+	c.profileCtx = n.profileCtx
+	for name := range n.frozen {
+		if c.layerIndex(name) >= 0 {
+			c.Freeze(name)
+		}
+	}
+	return c, nil
+}
+
+// sameShapes reports whether both parameter lists have the same sizes.
+func sameShapes(a, b []layers.LayerResponse) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if len(a[i].Weights) != len(b[i].Weights) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,60 @@
+package reticulum
+
+import (
+	"testing"
+
+	"github.com/nathanleary/reticulum/layers"
+	"github.com/nathanleary/reticulum/volume"
+)
+
+func TestSurgery_ReturnsEditedCopy(t *testing.T) {
+	defs := []layers.LayerDef{
+		{Type: layers.Input, Output: volume.NewDimensions(1, 1, 4)},
+		{Type: layers.FullyConnected, Name: "hidden", LayerConfig: layers.NewFullyConnectedLayerConfig(6)},
+		{Type: layers.Dropout, Name: "drop", LayerConfig: &layers.DropoutLayerConfig{DropoutProbability: 0.5}},
+		{Type: layers.SoftMax, Name: "out", LayerConfig: layers.NewSoftmaxLayerConfig(3)},
+	}
+	extra := layers.LayerDef{Type: layers.FullyConnected, Name: "extra", LayerConfig: layers.NewFullyConnectedLayerConfig(5)}
+	// the softmax layer is preceded by an fc layer of its own
+	for _, test := range []struct {
+		name    string
+		edit    func(Network) (Network, error)
+		size    int
+		wantErr bool
+	}{
+		{"insert", func(net Network) (Network, error) { return InsertLayers(net, "hidden", extra) }, 6, false},
+		{"remove", func(net Network) (Network, error) { return RemoveLayers(net, "drop") }, 4, false},
+		{"replace", func(net Network) (Network, error) { return ReplaceLayer(net, "drop", extra) }, 5, false},
+		{"strip dropout", StripDropout, 4, false},
+		{"unknown layer", func(net Network) (Network, error) { return RemoveLayers(net, "missing") }, 0, true},
+		{"loss layer", func(net Network) (Network, error) { return RemoveLayers(net, "out") }, 0, true},
+	} {
+		net, err := NewNetwork(defs, WithNetworkSeed(1))
+		if err != nil {
+			t.Fatal(err)
+		}
+		size, weights := net.Size(), flatWeights(net)
+
+		edited, err := test.edit(net)
+		if test.wantErr {
+			if err == nil {
+				t.Errorf("%s: expected an error", test.name)
+			}
+		} else if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+		} else if edited.Size() != test.size {
+			t.Errorf("%s: edited network has %d layers, want %d", test.name, edited.Size(), test.size)
+		} else if got := edited.GetResponse()[0].Weights[0]; got != weights[0] {
+			t.Errorf("%s: first weight of hidden = %v, want %v", test.name, got, weights[0])
+		}
+
+		if net.Size() != size {
+			t.Fatalf("%s: original network has %d layers, want %d", test.name, net.Size(), size)
+		}
+		for i, w := range flatWeights(net) {
+			if w != weights[i] {
+				t.Fatalf("%s: original weight %d changed", test.name, i)
+			}
+		}
+	}
+}
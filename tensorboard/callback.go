@@ -0,0 +1,135 @@
+package tensorboard
+
+import (
+	"fmt"
+	"image"
+
+	"github.com/nathanleary/reticulum"
+	"github.com/nathanleary/reticulum/layers"
+)
+
+// OptionFunc modifies the Options of a Callback.
+type OptionFunc func(*Options)
+
+// Options configures what a Callback logs.
+type Options struct {
+	// ScalarEvery logs the loss and learning rate every n iterations. 0 disables them.
+	ScalarEvery int
+
+	// HistogramEvery logs the histograms of the weights and gradients of every layer
+	// every n updates. 0 disables them.
+	HistogramEvery int
+
+	// Images returns images to log by tag at the end of every epoch, e.g. filter grids
+	// from the viz package.
+	Images func(t reticulum.Trainer) map[string]image.Image
+}
+
+// WithScalarEvery logs the loss and learning rate every n iterations.
+func WithScalarEvery(n int) OptionFunc {
+	return func(opts *Options) {
+		opts.ScalarEvery = n
+	}
+}
+
+// WithHistogramEvery logs the histograms of the weights and gradients every n updates.
+func WithHistogramEvery(n int) OptionFunc {
+	return func(opts *Options) {
+		opts.HistogramEvery = n
+	}
+}
+
+// WithImages logs the images returned by fn at the end of every epoch.
+func WithImages(fn func(t reticulum.Trainer) map[string]image.Image) OptionFunc {
+	return func(opts *Options) {
+		opts.Images = fn
+	}
+}
+
+// NewCallback creates a callback logging training to w:
+//   - train/loss, train/total_loss and train/learning_rate every iteration,
+//   - the epoch metrics, e.g. epoch/loss and epoch/val_accuracy, every epoch,
+//   - weights/<layer> and gradients/<layer> histograms every 100 updates,
+//   - the images of WithImages every epoch.
+//
+// Iterations are counted from 1 and epochs from 0, as marked by Trainer.EndEpoch.
+// The writer is flushed at the end of every epoch.
+func NewCallback(w *Writer, optFuncs ...OptionFunc) *Callback {
+	opts := &Options{ScalarEvery: 1, HistogramEvery: 100}
+	for _, optFn := range optFuncs {
+		optFn(opts)
+	}
+	return &Callback{w: w, opts: opts}
+}
+
+// Callback logs training to a TensorBoard event file. It implements
+// reticulum.GradientCallback.
+type Callback struct {
+	reticulum.BaseCallback
+	w    *Writer
+	opts *Options
+
+	iter    int64
+	updates int
+	err     error
+}
+
+// Err returns the first error writing the logs, if any. Logging stops at the first
+// error.
+func (c *Callback) Err() error {
+	return c.err
+}
+
+func (c *Callback) OnBatchEnd(t reticulum.Trainer, res reticulum.TrainingResults) {
+	c.iter++
+	if c.err != nil || c.opts.ScalarEvery <= 0 || c.iter%int64(c.opts.ScalarEvery) != 0 {
+		return
+	}
+	c.check(c.w.AddScalar("train/loss", c.iter, res.CostLost))
+	c.check(c.w.AddScalar("train/total_loss", c.iter, res.TotalLoss))
+	c.check(c.w.AddScalar("train/learning_rate", c.iter, res.LearningRate))
+}
+
+func (c *Callback) OnGradients(t reticulum.Trainer, params []layers.LayerResponse) {
+	c.updates++
+	if c.err != nil || c.opts.HistogramEvery <= 0 || (c.updates-1)%c.opts.HistogramEvery != 0 {
+		return
+	}
+
+	// the iteration is counted at the end of the batch, after the update
+	step := c.iter + 1
+	var order []string
+	weights, grads := map[string][]float64{}, map[string][]float64{}
+	for _, p := range params {
+		if _, ok := weights[p.LayerName]; !ok {
+			order = append(order, p.LayerName)
+		}
+		weights[p.LayerName] = append(weights[p.LayerName], p.Weights...)
+		grads[p.LayerName] = append(grads[p.LayerName], p.Gradients...)
+	}
+	for _, name := range order {
+		c.check(c.w.AddHistogram("weights/"+name, step, weights[name]))
+		c.check(c.w.AddHistogram("gradients/"+name, step, grads[name]))
+	}
+}
+
+func (c *Callback) OnEpochEnd(t reticulum.Trainer, epoch int, metrics reticulum.Metrics) {
+	if c.err != nil {
+		return
+	}
+	for name, v := range metrics {
+		c.check(c.w.AddScalar("epoch/"+name, int64(epoch), v))
+	}
+	if c.opts.Images != nil {
+		for tag, img := range c.opts.Images(t) {
+			c.check(c.w.AddImage(tag, int64(epoch), img))
+		}
+	}
+	c.check(c.w.Flush())
+}
+
+func (c *Callback) check(err error) {
+	if err != nil && c.err == nil {
+		c.err = fmt.Errorf("tensorboard: %w", err)
+	}
+}
@@ -0,0 +1,232 @@
+package tensorboard
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/nathanleary/reticulum"
+	"github.com/nathanleary/reticulum/layers"
+	"github.com/nathanleary/reticulum/volume"
+)
+
+// fields decodes a protocol buffer message into the raw values of every field: the
+// value of varints, the 8 or 4 bytes of fixed width values and the contents of length
+// delimited ones.
+func fields(t *testing.T, msg []byte) map[int][][]byte {
+	t.Helper()
+	out := map[int][][]byte{}
+	for len(msg) > 0 {
+		key, n := binary.Uvarint(msg)
+		msg = msg[n:]
+		var v []byte
+		switch key & 7 {
+		case 0:
+			_, n := binary.Uvarint(msg)
+			v, msg = msg[:n], msg[n:]
+		case 1:
+			v, msg = msg[:8], msg[8:]
+		case 2:
+			l, n := binary.Uvarint(msg)
+			v, msg = msg[n:n+int(l)], msg[n+int(l):]
+		case 5:
+			v, msg = msg[:4], msg[4:]
+		default:
+			t.Fatalf("unexpected wire type %d", key&7)
+		}
+		out[int(key>>3)] = append(out[int(key>>3)], v)
+	}
+	return out
+}
+
+func double(b []byte) float64 {
+	return math.Float64frombits(binary.LittleEndian.Uint64(b))
+}
+
+// event is a decoded event holding a single summary value.
+type event struct {
+	step  int64
+	tag   string
+	value map[int][][]byte
+}
+
+// readEvents reads the records of the event file, checking their CRCs, and decodes
+// the events after the file version.
+func readEvents(t *testing.T, path string) []event {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var records [][]byte
+	for len(data) > 0 {
+		n := binary.LittleEndian.Uint64(data[:8])
+		if crc := binary.LittleEndian.Uint32(data[8:12]); crc != maskedCRC(data[:8]) {
+			t.Fatalf("record %d: bad length CRC", len(records))
+		}
+		rec := data[12 : 12+n]
+		if crc := binary.LittleEndian.Uint32(data[12+n:]); crc != maskedCRC(rec) {
+			t.Fatalf("record %d: bad data CRC", len(records))
+		}
+		records = append(records, rec)
+		data = data[16+n:]
+	}
+
+	if len(records) == 0 || string(fields(t, records[0])[3][0]) != "brain.Event:2" {
+		t.Fatal("event file does not start with the file version")
+	}
+	var events []event
+	for _, rec := range records[1:] {
+		e := fields(t, rec)
+		step, _ := binary.Uvarint(e[2][0])
+		value := fields(t, fields(t, e[5][0])[1][0])
+		events = append(events, event{int64(step), string(value[1][0]), value})
+	}
+	return events
+}
+
+func TestWriter(t *testing.T) {
+	w, err := NewWriter(filepath.Join(t.TempDir(), "run"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	img := image.NewRGBA(image.Rect(0, 0, 3, 2))
+	img.Set(1, 1, color.RGBA{R: 255, A: 255})
+	for _, err := range []error{
+		w.AddScalar("loss", 3, 0.25),
+		w.AddHistogram("weights", 4, []float64{-1, 0, 0, 2}),
+		w.AddImage("filters", 5, img),
+	} {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	events := readEvents(t, w.Path())
+	if len(events) != 3 {
+		t.Fatalf("read %d events, want 3", len(events))
+	}
+	for i, want := range []struct {
+		step int64
+		tag  string
+	}{{3, "loss"}, {4, "weights"}, {5, "filters"}} {
+		if events[i].step != want.step || events[i].tag != want.tag {
+			t.Errorf("event %d is %s at step %d, want %s at step %d", i, events[i].tag, events[i].step, want.tag, want.step)
+		}
+	}
+
+	if v := math.Float32frombits(binary.LittleEndian.Uint32(events[0].value[2][0])); v != 0.25 {
+		t.Errorf("scalar value %v, want 0.25", v)
+	}
+
+	h := fields(t, events[1].value[5][0])
+	if lo, hi, n, sum := double(h[1][0]), double(h[2][0]), double(h[3][0]), double(h[4][0]); lo != -1 || hi != 2 || n != 4 || sum != 1 {
+		t.Errorf("histogram min %v, max %v, count %v and sum %v, want -1, 2, 4 and 1", lo, hi, n, sum)
+	}
+	var counted float64
+	for b := h[7][0]; len(b) > 0; b = b[8:] {
+		counted += double(b)
+	}
+	if counted != 4 {
+		t.Errorf("histogram buckets hold %v values, want 4", counted)
+	}
+
+	im := fields(t, events[2].value[4][0])
+	decoded, err := png.Decode(bytes.NewReader(im[4][0]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r, _, _, _ := decoded.At(1, 1).RGBA(); decoded.Bounds() != img.Bounds() || r != 0xffff {
+		t.Errorf("decoded image of %v, want %v with a red pixel", decoded.Bounds(), img.Bounds())
+	}
+}
+
+func TestWriter_Errors(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "file")
+	if err := os.WriteFile(file, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewWriter(file); err == nil {
+		t.Error("expected an error creating a writer in a file")
+	}
+
+	w, err := NewWriter(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	if err := w.AddHistogram("empty", 0, nil); err == nil {
+		t.Error("expected an error for an empty histogram")
+	}
+}
+
+func TestCallback(t *testing.T) {
+	w, err := NewWriter(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	net, err := reticulum.NewNetwork([]layers.LayerDef{
+		{Type: layers.Input, Output: volume.NewDimensions(1, 1, 2)},
+		{Type: layers.SoftMax, LayerConfig: layers.NewSoftmaxLayerConfig(2)},
+	}, reticulum.WithNetworkSeed(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cb := NewCallback(w, WithScalarEvery(2), WithHistogramEvery(3), WithImages(func(reticulum.Trainer) map[string]image.Image {
+		return map[string]image.Image{"image": image.NewGray(image.Rect(0, 0, 1, 1))}
+	}))
+	trainer := reticulum.NewTrainer(net, reticulum.WithCallbacks(cb))
+	vol := volume.NewVolume(volume.NewDimensions(1, 1, 2), volume.WithZeros())
+	for epoch := 0; epoch < 2; epoch++ {
+		for i := 0; i < 4; i++ {
+			trainer.Train(vol, reticulum.LabeledLossFunc(i%2))
+		}
+		trainer.EndEpoch(epoch, reticulum.Metrics{"loss": 1})
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	tags := map[string][]int64{}
+	for _, e := range readEvents(t, w.Path()) {
+		tags[e.tag] = append(tags[e.tag], e.step)
+	}
+	for tag, want := range map[string][]int64{
+		"train/loss":          {2, 4, 6, 8},
+		"train/learning_rate": {2, 4, 6, 8},
+		"epoch/loss":          {0, 1},
+		"image":               {0, 1},
+	} {
+		if got := tags[tag]; len(got) != len(want) || got[len(got)-1] != want[len(want)-1] {
+			t.Errorf("%s logged at steps %v, want %v", tag, got, want)
+		}
+	}
+	var histograms int
+	for tag, steps := range tags {
+		if len(tag) > 8 && tag[:8] == "weights/" {
+			histograms++
+			if len(steps) != 3 || steps[0] != 1 || steps[1] != 4 {
+				t.Errorf("%s logged at steps %v, want [1 4 7]", tag, steps)
+			}
+		}
+	}
+	if histograms == 0 {
+		t.Errorf("no weight histograms among %v", tags)
+	}
+
+	// logging to a closed writer fails at the next flush
+	cb.OnEpochEnd(trainer, 2, reticulum.Metrics{})
+	if cb.Err() == nil {
+		t.Error("expected an error logging to a closed writer")
+	}
+}
@@ -0,0 +1,245 @@
+// Package tensorboard writes training logs as TensorBoard event files, to monitor
+// training runs visually with `tensorboard --logdir runs`:
+//
+//	w, err := tensorboard.NewWriter("runs/mnist")
+//	if err != nil {
+//		return err
+//	}
+//	defer w.Close()
+//	trainer := reticulum.NewTrainer(net, reticulum.WithCallbacks(tensorboard.NewCallback(w)))
+//
+// The event files are written directly in the TFRecord and protocol buffer formats,
+// so no TensorFlow installation is needed to produce them.
+package tensorboard
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"image"
+	"image/png"
+	"math"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Writer appends summaries to an event file. A Writer is safe for concurrent use.
+type Writer struct {
+	mu   sync.Mutex
+	f    *os.File
+	w    *bufio.Writer
+	path string
+}
+
+// NewWriter creates an event file in dir, creating dir if needed. Every writer
+// creates its own file, named as TensorBoard expects.
+func NewWriter(dir string) (*Writer, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	host, err := os.Hostname()
+	if err != nil {
+		host = "localhost"
+	}
+	now := time.Now()
+	path := filepath.Join(dir, fmt.Sprintf("events.out.tfevents.%d.%s.%d", now.Unix(), host, now.UnixNano()%1e9))
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	w := &Writer{f: f, w: bufio.NewWriter(f), path: path}
+	var event message
+	event.double(1, wallTime())
+	event.bytes(3, []byte("brain.Event:2"))
+	if err := w.write(event.buf.Bytes()); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return w, nil
+}
+
+// Path returns the path of the event file.
+func (w *Writer) Path() string {
+	return w.path
+}
+
+// AddScalar records the value of the scalar tag at step.
+func (w *Writer) AddScalar(tag string, step int64, value float64) error {
+	var v message
+	v.bytes(1, []byte(tag))
+	v.float(2, float32(value))
+	return w.addValue(step, v)
+}
+
+// AddHistogram records the distribution of values of the tag at step, in buckets of
+// equal width between the lowest and highest values.
+func (w *Writer) AddHistogram(tag string, step int64, values []float64) error {
+	if len(values) == 0 {
+		return errors.New("histogram values cannot be empty")
+	}
+	lo, hi := math.Inf(1), math.Inf(-1)
+	var sum, sumSq float64
+	for _, x := range values {
+		lo, hi = math.Min(lo, x), math.Max(hi, x)
+		sum += x
+		sumSq += x * x
+	}
+
+	// every bucket counts the values up to its limit, from the previous limit
+	const buckets = 30
+	limits := make([]float64, buckets)
+	counts := make([]float64, buckets)
+	width := (hi - lo) / buckets
+	for i := range limits {
+		limits[i] = lo + float64(i+1)*width
+	}
+	limits[buckets-1] = hi
+	for _, x := range values {
+		i := buckets - 1
+		if width > 0 {
+			i = min(int((x-lo)/width), buckets-1)
+		}
+		counts[i]++
+	}
+
+	var h message
+	h.double(1, lo)
+	h.double(2, hi)
+	h.double(3, float64(len(values)))
+	h.double(4, sum)
+	h.double(5, sumSq)
+	h.packedDoubles(6, limits)
+	h.packedDoubles(7, counts)
+
+	var v message
+	v.bytes(1, []byte(tag))
+	v.bytes(5, h.buf.Bytes())
+	return w.addValue(step, v)
+}
+
+// AddImage records the image of the tag at step, encoded as PNG.
+func (w *Writer) AddImage(tag string, step int64, img image.Image) error {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return err
+	}
+	b := img.Bounds()
+
+	var im message
+	im.varint(1, uint64(b.Dy()))
+	im.varint(2, uint64(b.Dx()))
+	im.varint(3, 4) // RGBA
+	im.bytes(4, buf.Bytes())
+
+	var v message
+	v.bytes(1, []byte(tag))
+	v.bytes(4, im.buf.Bytes())
+	return w.addValue(step, v)
+}
+
+// Flush writes the buffered events to the file, for TensorBoard to pick them up.
+func (w *Writer) Flush() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.w.Flush()
+}
+
+// Close flushes and closes the event file.
+func (w *Writer) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if err := w.w.Flush(); err != nil {
+		w.f.Close()
+		return err
+	}
+	return w.f.Close()
+}
+
+// addValue writes an event with a summary holding the value.
+func (w *Writer) addValue(step int64, value message) error {
+	var summary message
+	summary.bytes(1, value.buf.Bytes())
+
+	var event message
+	event.double(1, wallTime())
+	event.varint(2, uint64(step))
+	event.bytes(5, summary.buf.Bytes())
+	return w.write(event.buf.Bytes())
+}
+
+// write appends a TFRecord: the length of the data, its masked CRC, the data and its
+// masked CRC.
+func (w *Writer) write(data []byte) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	var header [12]byte
+	binary.LittleEndian.PutUint64(header[:8], uint64(len(data)))
+	binary.LittleEndian.PutUint32(header[8:], maskedCRC(header[:8]))
+	var footer [4]byte
+	binary.LittleEndian.PutUint32(footer[:], maskedCRC(data))
+	for _, b := range [][]byte{header[:], data, footer[:]} {
+		if _, err := w.w.Write(b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+func maskedCRC(data []byte) uint32 {
+	crc := crc32.Checksum(data, castagnoli)
+	return (crc>>15 | crc<<17) + 0xa282ead8
+}
+
+func wallTime() float64 {
+	return float64(time.Now().UnixNano()) / 1e9
+}
+
+// message encodes the fields of a protocol buffer message.
+type message struct {
+	buf bytes.Buffer
+}
+
+func (m *message) key(field, wireType int) {
+	m.putVarint(uint64(field<<3 | wireType))
+}
+
+func (m *message) putVarint(v uint64) {
+	m.buf.Write(binary.AppendUvarint(nil, v))
+}
+
+func (m *message) varint(field int, v uint64) {
+	m.key(field, 0)
+	m.putVarint(v)
+}
+
+func (m *message) double(field int, v float64) {
+	m.key(field, 1)
+	m.buf.Write(binary.LittleEndian.AppendUint64(nil, math.Float64bits(v)))
+}
+
+func (m *message) float(field int, v float32) {
+	m.key(field, 5)
+	m.buf.Write(binary.LittleEndian.AppendUint32(nil, math.Float32bits(v)))
+}
+
+func (m *message) bytes(field int, b []byte) {
+	m.key(field, 2)
+	m.putVarint(uint64(len(b)))
+	m.buf.Write(b)
+}
+
+func (m *message) packedDoubles(field int, vs []float64) {
+	b := make([]byte, 0, 8*len(vs))
+	for _, v := range vs {
+		b = binary.LittleEndian.AppendUint64(b, math.Float64bits(v))
+	}
+	m.bytes(field, b)
+}
@@ -0,0 +1,131 @@
+package reticulum
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/nathanleary/reticulum/volume"
+)
+
+// Tracer starts the tracing spans of networks and trainers, to diagnose slow layers
+// and stalls with standard observability tooling. The oteltrace package adapts an
+// OpenTelemetry tracer. Tracers are called from every goroutine running a network.
+type Tracer interface {
+	// Start starts a span named name as a child of the span in ctx, if any, and
+	// returns it along with a context holding it.
+	Start(ctx context.Context, name string, attrs ...TraceAttribute) (context.Context, Span)
+}
+
+// Span is an operation traced by a Tracer.
+type Span interface {
+	// SetAttributes adds attributes describing the operation.
+	SetAttributes(attrs ...TraceAttribute)
+
+	// End ends the span.
+	End()
+}
+
+// TraceAttribute describes a span with a string, int, float64 or bool value.
+type TraceAttribute struct {
+	Key   string
+	Value any
+}
+
+// WithNetworkTracer traces the passes of the network. Every forward and backward
+// pass starts a "forward" or "backward" span, with a "layer.forward" or
+// "layer.backward" child for each layer carrying its layer.name, layer.type,
+// layer.input_shape and layer.output_shape. Passes run by a trainer built with
+// WithTracer are children of its "train.step" spans.
+func WithNetworkTracer(t Tracer) NetworkOptionFunc {
+	return func(opts *NetworkOptions) {
+		opts.Tracer = t
+	}
+}
+
+// WithTracer traces the training steps: every Train, TrainBatch and TrainMulti call
+// starts a "train.step" span with the train.iteration and train.samples, ended with
+// the train.loss and whether the parameters were train.updated. Pass the same
+// tracer to WithNetworkTracer to nest the layer spans under the steps.
+func WithTracer(t Tracer) OptionFunc {
+	return func(opts *Options) {
+		opts.Tracer = t
+	}
+}
+
+// startPass starts the span of a forward or backward pass, under the span of the
+// current training step if any. It returns a nil span when the network is not
+// traced.
+func (n *network) startPass(name string) (context.Context, Span) {
+	if n.tracer == nil {
+		return nil, nil
+	}
+	ctx := n.traceCtx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return n.tracer.Start(ctx, name)
+}
+
+// beginLayer starts the span and sets the profile labels of the forward or backward
+// pass of layer i, under the pass span in ctx.
+func (n *network) beginLayer(ctx context.Context, i int, backward bool) Span {
+	n.setLabels(i, backward)
+	if n.tracer == nil {
+		return nil
+	}
+
+	name := "layer.forward"
+	if backward {
+		name = "layer.backward"
+	}
+	def := n.defs[i]
+	attrs := []TraceAttribute{{"layer.name", n.names[i]}, {"layer.type", string(def.Type)}}
+	if def.Input.Size() > 0 {
+		attrs = append(attrs, TraceAttribute{"layer.input_shape", shape(def.Input)})
+	}
+	attrs = append(attrs, TraceAttribute{"layer.output_shape", shape(def.Output)})
+	_, span := n.tracer.Start(ctx, name, attrs...)
+	return span
+}
+
+// endLayer ends the span and clears the profile labels of beginLayer.
+func (n *network) endLayer(i int, span Span) {
+	endSpan(span)
+	n.clearLabels(i)
+}
+
+// startStep starts the span of a training step of samples samples, under which the
+// passes of the network are traced until endStep.
+func (t *trainer) startStep(samples int) Span {
+	if t.opts.Tracer == nil {
+		return nil
+	}
+	ctx, span := t.opts.Tracer.Start(context.Background(), "train.step",
+		TraceAttribute{"train.iteration", t.k + 1}, TraceAttribute{"train.samples", samples})
+	if n, ok := t.net.(*network); ok {
+		n.traceCtx = ctx
+	}
+	return span
+}
+
+// endStep ends the span of startStep with the results of the step.
+func (t *trainer) endStep(span Span, res *TrainingResults) {
+	if span == nil {
+		return
+	}
+	if n, ok := t.net.(*network); ok {
+		n.traceCtx = nil
+	}
+	span.SetAttributes(TraceAttribute{"train.loss", res.CostLost}, TraceAttribute{"train.updated", res.Updated})
+	span.End()
+}
+
+func endSpan(span Span) {
+	if span != nil {
+		span.End()
+	}
+}
+
+func shape(d volume.Dimensions) string {
+	return fmt.Sprintf("%dx%dx%d", d.X, d.Y, d.Z)
+}
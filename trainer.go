@@ -1,7 +1,10 @@
 package reticulum
 
 import (
+	"errors"
+	"fmt"
 	"math"
+	"math/rand/v2"
 	"time"
 
 	"github.com/nathanleary/reticulum/layers"
@@ -10,6 +13,52 @@ import (
 
 type Trainer interface {
 	Train(vol *volume.Volume, lossFn LossFunc) TrainingResults
+
+	// TrainE is like Train but returns an error instead of panicking on invalid input.
+	TrainE(vol *volume.Volume, lossFn LossFunc) (TrainingResults, error)
+
+	// TrainBatch runs the forward and backward pass for each sample and applies
+	// a single update using the mean gradient over the batch. The reported
+	// CostLost is the mean loss over the batch.
+	TrainBatch(vols []*volume.Volume, losses []LossFunc) TrainingResults
+
+	// TrainBatchE is like TrainBatch but returns an error instead of panicking on invalid input.
+	TrainBatchE(vols []*volume.Volume, losses []LossFunc) (TrainingResults, error)
+
+	// TrainMulti trains a MultiNetwork on the volumes keyed by input name towards the
+	// targets keyed by output name.
+	TrainMulti(inputs map[string]*volume.Volume, targets map[string]Target) (TrainingResults, error)
+
+	// LearningRate returns the base learning rate.
+	LearningRate() float64
+
+	// SetLearningRate updates the base learning rate.
+	SetLearningRate(lr float64)
+
+	// Accumulation returns the gradient accumulation state since the last update.
+	Accumulation() AccumulationState
+
+	// ReportMetric forwards an evaluation metric (e.g. validation loss) to the scheduler.
+	ReportMetric(v float64)
+
+	// Network returns the network being trained.
+	Network() Network
+
+	// Stop asks training loops such as Fit to stop after the current epoch.
+	Stop()
+
+	// Stopped reports whether Stop has been called.
+	Stopped() bool
+
+	// History returns the results of every iteration and the metrics of every epoch.
+	History() *History
+
+	// EndEpoch marks the end of an epoch, records its metrics in the history and
+	// notifies the callbacks.
+	EndEpoch(epoch int, metrics Metrics)
+
+	// Checkpoint saves the model weights and the complete trainer state to path.
+	Checkpoint(path string) error
 }
 
 func NewTrainer(net Network, opts ...OptionFunc) Trainer {
@@ -17,18 +66,60 @@ func NewTrainer(net Network, opts ...OptionFunc) Trainer {
 		panic("network cannot be nil")
 	}
 
-	// Read opts
-	baseOpts := &Options{Method: SGD, LearningRate: 0.01, BatchSize: 1, Momentum: 0.9, Ro: 0.95, Eps: 1e-8, Beta1: 0.9, Beta2: 0.999}
-	for _, optFn := range opts {
-		optFn(baseOpts)
+	// checked even without validation, as a mismatch reports the wrong guarantee
+	baseOpts := newOptions(opts...)
+	if p := baseOpts.Privacy; p != nil {
+		if err := p.accountantMismatch(); err != nil {
+			panic(err)
+		}
+	}
+	return newTrainer(net, baseOpts)
+}
+
+// NewTrainerE is like NewTrainer but returns an error for a nil or incomplete network,
+// invalid options and option combinations which would be silently ignored.
+func NewTrainerE(net Network, opts ...OptionFunc) (Trainer, error) {
+	if net == nil {
+		return nil, errors.New("network cannot be nil")
+	} else if err := Validate(net); err != nil {
+		return nil, err
+	}
+
+	baseOpts := newOptions(opts...)
+	if err := baseOpts.Validate(); err != nil {
+		return nil, err
 	}
+	return newTrainer(net, baseOpts), nil
+}
 
+func newTrainer(net Network, baseOpts *Options) *trainer {
 	var isRegression bool
 	l := net.Layers()
 	if _, ok := l[net.Size()-1].(layers.RegressionLossLayer); ok {
 		isRegression = true
 	}
-	return &trainer{net, baseOpts, 0, [][]float64{}, [][]float64{}, isRegression}
+	src := baseOpts.newSource(trainerStream)
+	t := &trainer{net: net, opts: baseOpts, regression: isRegression, src: src, rng: rand.New(src)}
+	t.history = &History{limit: baseOpts.HistoryLimit}
+	if t.history.limit == 0 {
+		t.history.limit = DefaultHistoryLimit
+	}
+	t.resolveGroups()
+	return t
+}
+
+// Random streams derived from the seed, so the trainer and Fit don't share a sequence.
+const (
+	trainerStream uint64 = 0x9e3779b97f4a7c15
+	shuffleStream uint64 = 0xbf58476d1ce4e5b9
+)
+
+// newSource returns the random source for the options, seeded with the given stream if requested.
+func (o *Options) newSource(stream uint64) *rand.PCG {
+	if o.HasSeed {
+		return rand.NewPCG(uint64(o.Seed), stream)
+	}
+	return rand.NewPCG(rand.Uint64(), rand.Uint64())
 }
 
 type trainer struct {
@@ -38,6 +129,29 @@ type trainer struct {
 	// iteration counter
 	k int
 
+	// set once the callbacks have been notified that training began
+	began bool
+
+	// set when training has been asked to stop
+	stopped bool
+
+	history *History
+
+	// Train calls and samples accumulated since the last update
+	steps   int
+	samples int
+
+	// raw batch gradients of the current update
+	grads [][]float64
+
+	// sum of the clipped per-sample gradients (DP-SGD only)
+	dpSum [][]float64
+
+	// options for each parameter group, resolved against the base options, and
+	// the group options used for each set of weights
+	groupOpts []*Options
+	pgOpts    []*Options
+
 	// last iteration gradients (used for momentum calculations)
 	gsum [][]float64
 
@@ -46,6 +160,10 @@ type trainer struct {
 
 	// check if regression is used
 	regression bool
+
+	// random source for gradient noise, kept so its state can be checkpointed
+	src *rand.PCG
+	rng *rand.Rand
 }
 
 type LossFunc func(net Network) float64
@@ -62,133 +180,652 @@ func RegressionLossFunc(y []float64) LossFunc {
 	}
 }
 
-func (t *trainer) Train(vol *volume.Volume, lossFunc LossFunc) TrainingResults {
+// LabeledLossFuncWeighted is LabeledLossFunc with the loss and gradients scaled by w,
+// for importance weighting or boosting.
+func LabeledLossFuncWeighted(label int, w float64) LossFunc {
+	return func(net Network) float64 {
+		return BackwardWeighted(net, label, w)
+	}
+}
+
+// RegressionLossFuncWeighted is RegressionLossFunc with the loss and gradients scaled by w.
+func RegressionLossFuncWeighted(y []float64, w float64) LossFunc {
+	return func(net Network) float64 {
+		return MultiDimensionalLossWeighted(net, y, w)
+	}
+}
+
+// SoftTargetLossFunc trains a softmax classifier towards a distribution over the
+// classes, e.g. label smoothed or teacher probabilities, at the given temperature.
+func SoftTargetLossFunc(target []float64, temperature float64) LossFunc {
+	return func(net Network) float64 {
+		return BackwardSoftTarget(net, target, temperature, 1.0)
+	}
+}
+
+// PolicyLossFunc trains a softmax policy by policy gradient towards the action taken,
+// scaled by its advantage, with an entropy bonus weighted by entropy.
+func PolicyLossFunc(action int, advantage, entropy float64) LossFunc {
+	return func(net Network) float64 {
+		return BackwardPolicy(net, action, advantage, entropy, 1.0)
+	}
+}
+
+// DenseLossFunc trains a segmentation network, ending in a dense loss layer such as
+// pixelsoftmax, towards a label map holding the class of every position.
+func DenseLossFunc(labels []int) LossFunc {
+	return func(net Network) float64 {
+		return BackwardDense(net, labels, 1.0)
+	}
+}
+
+// DenseLossFuncWeighted is DenseLossFunc with the loss and gradients scaled by w.
+func DenseLossFuncWeighted(labels []int, w float64) LossFunc {
+	return func(net Network) float64 {
+		return BackwardDense(net, labels, w)
+	}
+}
+
+// MultiTargetLossFunc returns the loss function training the heads of a MultiNetwork
+// towards the targets keyed by output name.
+func MultiTargetLossFunc(targets map[string]Target) LossFunc {
+	return func(net Network) float64 {
+		m, ok := net.(MultiNetwork)
+		if !ok {
+			panic("multiple targets require a MultiNetwork")
+		}
+		return m.BackwardMulti(targets)
+	}
+}
+
+// Sample is a single training example. Target is used for regression networks and
+// LabelMap for segmentation networks, otherwise Label holds the class index.
+type Sample struct {
+	Input  *volume.Volume
+	Label  int
+	Target []float64
+
+	// LabelMap holds the class of every position x, y of the network output at index
+	// y*width + x, with negative labels ignored.
+	LabelMap []int
+
+	// Weight scales the sample's loss and gradients during training, 0 is treated as 1.
+	Weight float64
+}
+
+// LossFunc returns the loss function matching the sample's label, target or label map.
+func (s Sample) LossFunc() LossFunc {
+	w := s.Weight
+	if w == 0 {
+		w = 1.0
+	}
+	if s.Target != nil {
+		return RegressionLossFuncWeighted(s.Target, w)
+	} else if s.LabelMap != nil {
+		return DenseLossFuncWeighted(s.LabelMap, w)
+	}
+	return LabeledLossFuncWeighted(s.Label, w)
+}
+
+func (t *trainer) LearningRate() float64 {
+	return t.opts.LearningRate
+}
+
+func (t *trainer) SetLearningRate(lr float64) {
+	t.opts.LearningRate = lr
+	t.resolveGroups()
+}
+
+// resolveGroups recomputes the parameter group options from the base options, so
+// groups which don't override a setting follow changes to it.
+func (t *trainer) resolveGroups() {
+	t.groupOpts = t.groupOpts[:0]
+	for _, g := range t.opts.ParamGroups {
+		t.groupOpts = append(t.groupOpts, g.resolve(t.opts))
+	}
+	t.pgOpts = nil
+}
+
+// optionsFor returns the options of the first parameter group matching the response.
+func (t *trainer) optionsFor(pg layers.LayerResponse) *Options {
+	for i, g := range t.opts.ParamGroups {
+		if g.Selector(pg) {
+			return t.groupOpts[i]
+		}
+	}
+	return t.opts
+}
+
+func (t *trainer) ReportMetric(v float64) {
+	if r, ok := t.opts.Scheduler.(MetricReporter); ok {
+		r.ReportMetric(v)
+	}
+}
+
+func (t *trainer) Accumulation() AccumulationState {
+	return AccumulationState{Steps: t.steps, Samples: t.samples, Target: t.accumulationSteps()}
+}
+
+// accumulationSteps returns the number of Train calls between updates.
+func (t *trainer) accumulationSteps() int {
+	if t.opts.AccumulationSteps > 0 {
+		return t.opts.AccumulationSteps
+	}
+	return t.opts.BatchSize
+}
+
+// learningRate returns the current learning rate of the given options, as adjusted by the scheduler.
+func (t *trainer) learningRate(o *Options) float64 {
+	if t.opts.Scheduler == nil {
+		return o.LearningRate
+	}
+	return t.opts.Scheduler.LearningRate(o.LearningRate, t.k)
+}
+
+func (t *trainer) Network() Network {
+	return t.net
+}
+
+func (t *trainer) Stop() {
+	t.stopped = true
+}
+
+func (t *trainer) Stopped() bool {
+	return t.stopped
+}
+
+func (t *trainer) History() *History {
+	return t.history
+}
+
+func (t *trainer) EndEpoch(epoch int, metrics Metrics) {
+	t.history.Epochs = append(t.history.Epochs, metrics)
+	for _, cb := range t.opts.Callbacks {
+		cb.OnEpochEnd(t, epoch, metrics)
+	}
+}
+
+// begin notifies the callbacks before the first training step.
+func (t *trainer) begin() {
+	if t.began {
+		return
+	}
+	t.began = true
+	for _, cb := range t.opts.Callbacks {
+		cb.OnTrainBegin(t)
+	}
+}
+
+func (t *trainer) Train(vol *volume.Volume, lossFunc LossFunc) (res TrainingResults) {
+	t.begin()
+	span := t.startStep(1)
+	defer t.endStep(span, &res)
+
 	start := time.Now()
 	t.net.Forward(vol, true)
 	fwdTime := time.Now().Sub(start)
 
 	start = time.Now()
 	costLoss := lossFunc(t.net)
+	t.clipSampleGradients()
+	bwdTime := time.Now().Sub(start)
+
+	return t.step(1, t.accumulationSteps(), fwdTime, bwdTime, costLoss)
+}
+
+func (t *trainer) TrainE(vol *volume.Volume, lossFunc LossFunc) (res TrainingResults, err error) {
+	if vol == nil {
+		return res, errors.New("volume cannot be nil")
+	} else if lossFunc == nil {
+		return res, errors.New("loss function cannot be nil")
+	}
+	defer recoverError(&err)
+	return t.Train(vol, lossFunc), nil
+}
+
+func (t *trainer) TrainBatchE(vols []*volume.Volume, losses []LossFunc) (res TrainingResults, err error) {
+	if len(vols) == 0 {
+		return res, errors.New("batch cannot be empty")
+	} else if len(vols) != len(losses) {
+		return res, fmt.Errorf("batch has %d volumes but %d loss functions", len(vols), len(losses))
+	}
+	for i := range vols {
+		if vols[i] == nil {
+			return res, fmt.Errorf("volume %d cannot be nil", i)
+		} else if losses[i] == nil {
+			return res, fmt.Errorf("loss function %d cannot be nil", i)
+		}
+	}
+	defer recoverError(&err)
+	return t.TrainBatch(vols, losses), nil
+}
+
+func (t *trainer) TrainMulti(inputs map[string]*volume.Volume, targets map[string]Target) (res TrainingResults, err error) {
+	m, ok := t.net.(MultiNetwork)
+	if !ok {
+		return res, errors.New("network does not support multiple inputs and outputs")
+	} else if len(targets) == 0 {
+		return res, errors.New("at least one target is required")
+	}
+	defer recoverError(&err)
+
+	t.begin()
+	span := t.startStep(1)
+	defer t.endStep(span, &res)
+
+	start := time.Now()
+	m.ForwardMulti(inputs, true)
+	fwdTime := time.Now().Sub(start)
+
+	start = time.Now()
+	costLoss := m.BackwardMulti(targets)
+	t.clipSampleGradients()
 	bwdTime := time.Now().Sub(start)
 
+	return t.step(1, t.accumulationSteps(), fwdTime, bwdTime, costLoss), nil
+}
+
+// recoverError converts a panic raised by a layer (e.g. a mismatched volume or an
+// invalid label) into an error.
+func recoverError(err *error) {
+	if r := recover(); r != nil {
+		switch e := r.(type) {
+		case error:
+			*err = e
+		default:
+			*err = fmt.Errorf("%v", e)
+		}
+	}
+}
+
+func (t *trainer) TrainBatch(vols []*volume.Volume, losses []LossFunc) (res TrainingResults) {
+	if len(vols) == 0 {
+		panic("batch cannot be empty")
+	} else if len(vols) != len(losses) {
+		panic("batch must have one loss function per volume")
+	}
+
+	t.begin()
+	span := t.startStep(len(vols))
+	defer t.endStep(span, &res)
+
+	// gradients accumulate in the parameters across the samples of the batch
+	var fwdTime, bwdTime time.Duration
+	var costLoss float64
+	for i, vol := range vols {
+		start := time.Now()
+		t.net.Forward(vol, true)
+		fwdTime += time.Now().Sub(start)
+
+		start = time.Now()
+		costLoss += losses[i](t.net)
+		t.clipSampleGradients()
+		bwdTime += time.Now().Sub(start)
+	}
+
+	// a batch is a single step, BatchSize only delays updates for Train
+	target := t.opts.AccumulationSteps
+	if target <= 0 {
+		target = 1
+	}
+	return t.step(len(vols), target, fwdTime, bwdTime, costLoss/float64(len(vols)))
+}
+
+// step records a training step over the given number of samples and applies an
+// update once target steps have been accumulated.
+func (t *trainer) step(samples, target int, fwdTime, bwdTime time.Duration, costLoss float64) TrainingResults {
 	t.k++
+	t.steps++
+	t.samples += samples
 	var l1DecayLoss, l2DecayLoss float64
-	if t.k%t.opts.BatchSize == 0 {
-		pgList := t.net.GetResponse()
-
-		// initialize lists for accumulators. Will only be done once on first iteration
-		if len(t.gsum) == 0 && t.opts.Method == SGD || t.opts.Momentum > 0.0 {
-			for i := 0; i < len(pgList); i++ {
-				t.gsum = append(t.gsum, make([]float64, len(pgList[i].Weights)))
-				if t.opts.Method == Adam || t.opts.Method == Adadelta {
-					t.xsum = append(t.xsum, make([]float64, len(pgList[i].Weights)))
-				} else {
-					t.xsum = append(t.xsum, []float64{})
-				}
-			}
+	updated := t.steps >= target
+	if updated {
+		pgList := trainable(t.net)
+		t.initAccumulators(pgList)
+		t.addPrivacyNoise(pgList)
+
+		l1DecayLoss, l2DecayLoss = t.batchGradients(pgList)
+		t.centralizeGradients(pgList)
+		t.clipGradients()
+		t.addGradientNoise()
+		t.notifyGradients(pgList)
+		t.update(pgList)
+		t.steps, t.samples = 0, 0
+	}
+
+	res := TrainingResults{
+		Updated:      updated,
+		LearningRate: t.learningRate(t.opts),
+		ForwardTime:  fwdTime,
+		BackwardTime: bwdTime,
+		L1DecayLoss:  l1DecayLoss,
+		L2DecayLoss:  l2DecayLoss,
+		CostLost:     costLoss,
+		TotalLoss:    costLoss + l1DecayLoss + l2DecayLoss,
+	}
+	t.history.record(t.k, res)
+	for _, cb := range t.opts.Callbacks {
+		cb.OnBatchEnd(t, res)
+	}
+	return res
+}
+
+// clipSampleGradients clips the gradient of the sample which was just backpropagated to
+// the DP-SGD clip norm and moves it into the private sum. Does nothing without DP-SGD.
+func (t *trainer) clipSampleGradients() {
+	dp := t.opts.Privacy
+	if dp == nil {
+		return
+	}
+
+	pgList := trainable(t.net)
+	if t.dpSum == nil || !sameSizes(pgList, t.dpSum) {
+		t.dpSum = nil
+		for _, pg := range pgList {
+			t.dpSum = append(t.dpSum, make([]float64, len(pg.Gradients)))
 		}
+	}
 
-		// perform an update for all sets of weights
-		for i, pg := range pgList {
-			p := pg.Weights
-			g := pg.Gradients
+	var sumSq float64
+	for _, pg := range pgList {
+		for _, g := range pg.Gradients {
+			sumSq += g * g
+		}
+	}
+	scale := 1.0
+	if norm := math.Sqrt(sumSq); norm > dp.ClipNorm {
+		scale = dp.ClipNorm / norm
+	}
 
-			// learning rate for some parameters.
-			l1DecayMul, l2DecayMul := pg.L1DecayMul, pg.L2DecayMul
-			l1Decay := t.opts.L1Decay * l1DecayMul
-			l2Decay := t.opts.L2Decay * l2DecayMul
+	for i, pg := range pgList {
+		for j, g := range pg.Gradients {
+			t.dpSum[i][j] += g * scale
+			pg.Gradients[j] = 0.0
+		}
+	}
+}
 
-			for j := 0; j < len(p); j++ {
-				// accumulate weight decay loss
-				l2DecayLoss += l2Decay * p[j] * p[j] / 2.0
-				l1DecayLoss += l1Decay * math.Abs(p[j])
-				l1Grad, l2Grad := l1Decay, l2Decay*p[j]
-				if p[j] <= 0 {
-					l1Grad *= -1
-				}
+// addPrivacyNoise moves the summed per-sample gradients back into the parameters with
+// calibrated gaussian noise added, and records the update with the accountant.
+func (t *trainer) addPrivacyNoise(pgList []layers.LayerResponse) {
+	dp := t.opts.Privacy
+	if dp == nil || t.dpSum == nil {
+		return
+	}
 
-				// raw batch gradient
-				gij := (l2Grad + l1Grad + g[j]) / float64(t.opts.BatchSize)
+	stdDev := dp.NoiseMultiplier * dp.ClipNorm
+	for i, pg := range pgList {
+		for j := range pg.Gradients {
+			pg.Gradients[j] = t.dpSum[i][j] + t.rng.NormFloat64()*stdDev
+			t.dpSum[i][j] = 0.0
+		}
+	}
 
-				meth := t.opts.Method
-				gsumi, xsumi := t.gsum[i], t.xsum[i]
-				if meth == Adam {
+	if dp.Accountant != nil && dp.DatasetSize > 0 {
+		dp.Accountant.Step(math.Min(1, float64(t.samples)/float64(dp.DatasetSize)))
+	}
+}
 
-					// update biased first moment estimate
-					gsumi[j] = gsumi[j]*t.opts.Beta1 + (1-t.opts.Beta1)*gij
+// initAccumulators allocates the per-parameter accumulators. Will only be done once on first update.
+func (t *trainer) initAccumulators(pgList []layers.LayerResponse) {
+	if !sameSizes(pgList, t.gsum) {
+		// layers were frozen or unfrozen, the accumulated state no longer lines up
+		t.pgOpts, t.grads, t.gsum, t.xsum = nil, nil, nil, nil
+	}
 
-					// update biased second moment estimate
-					xsumi[j] = xsumi[j]*t.opts.Beta2 + (1-t.opts.Beta2)*gij*gij
+	if t.pgOpts == nil {
+		for _, pg := range pgList {
+			t.pgOpts = append(t.pgOpts, t.optionsFor(pg))
+		}
+	}
 
-					// correct bias first moment estimate
-					biasCorr1 := gsumi[j] * (1 - math.Pow(t.opts.Beta1, float64(t.k)))
+	if len(t.gsum) != 0 {
+		return
+	}
+	for i := 0; i < len(pgList); i++ {
+		n := len(pgList[i].Weights)
+		t.grads = append(t.grads, make([]float64, n))
+		t.gsum = append(t.gsum, make([]float64, n))
+		if m := t.pgOpts[i].Method; m == Adam || m == Adadelta {
+			t.xsum = append(t.xsum, make([]float64, n))
+		} else {
+			t.xsum = append(t.xsum, []float64{})
+		}
+	}
+}
 
-					// correct bias second moment estimate
-					biasCorr2 := xsumi[j] * (1 - math.Pow(t.opts.Beta2, float64(t.k)))
+// sameSizes reports whether the accumulated state matches the parameters, or is
+// still empty.
+func sameSizes(pgList []layers.LayerResponse, state [][]float64) bool {
+	if len(state) == 0 {
+		return true
+	} else if len(state) != len(pgList) {
+		return false
+	}
+	for i, pg := range pgList {
+		if len(pg.Weights) != len(state[i]) {
+			return false
+		}
+	}
+	return true
+}
 
-					dx := -t.opts.LearningRate * biasCorr1 / (math.Sqrt(biasCorr2) + t.opts.Eps)
-					p[j] += dx
-				} else if meth == Adagrad {
-					// update biased first moment estimate
-					gsumi[j] = gsumi[j] + gij*gij
+// batchGradients computes the raw batch gradient (including weight decay) for every
+// parameter into t.grads, zeroes the accumulated gradients and returns the decay losses.
+func (t *trainer) batchGradients(pgList []layers.LayerResponse) (l1DecayLoss, l2DecayLoss float64) {
+	for i, pg := range pgList {
+		p := pg.Weights
+		g := pg.Gradients
+
+		// learning rate for some parameters.
+		l1DecayMul, l2DecayMul := pg.L1DecayMul, pg.L2DecayMul
+		o := t.pgOpts[i]
+		l1Decay := o.L1Decay * l1DecayMul
+		l2Decay := o.L2Decay * l2DecayMul
+
+		grads := t.grads[i]
+		for j := 0; j < len(p); j++ {
+			// accumulate weight decay loss
+			l2DecayLoss += l2Decay * p[j] * p[j] / 2.0
+			l1DecayLoss += l1Decay * math.Abs(p[j])
+			l1Grad, l2Grad := l1Decay, l2Decay*p[j]
+			if p[j] <= 0 {
+				l1Grad *= -1
+			}
 
-					dx := -t.opts.LearningRate / (math.Sqrt(gsumi[j]) + t.opts.Eps) * gij
-					p[j] += dx
-				} else if meth == Windowgrad {
-					// this is adagrad but with a moving window weighted average
-					// so the gradient is not accumulated over the entire history of the run.
-					// it's also referred to as Idea #1 in Zeiler paper on Adadelta. Seems reasonable to me!
-					gsumi[j] = t.opts.Ro*gsumi[j] + (1-t.opts.Ro)*gij*gij
-
-					// eps added for better conditioning
-					dx := -t.opts.LearningRate / math.Sqrt(gsumi[j]+t.opts.Eps) * gij
-					p[j] += dx
-				} else if meth == Adadelta {
-					gsumi[j] = t.opts.Ro*gsumi[j] + (1-t.opts.Ro)*gij*gij
-					dx := -math.Sqrt((xsumi[j]+t.opts.Eps)/(gsumi[j]+t.opts.Eps)) * gij
-					xsumi[j] = t.opts.Ro*xsumi[j] + (1-t.opts.Ro)*dx*dx // yes, xsum lags behind gsum by 1.
-					p[j] += dx
-				} else if meth == Netsterov {
-					dx := gsumi[j]
-					gsumi[j] = gsumi[j]*t.opts.Momentum + t.opts.LearningRate*gij
-					dx = t.opts.Momentum*dx - (1.0+t.opts.Momentum)*gsumi[j]
-					p[j] += dx
-				} else {
+			// raw batch gradient
+			grads[j] = (l2Grad + l1Grad + g[j]) / float64(t.samples)
+
+			// zero out gradient so that we can begin accumulating anew
+			g[j] = 0.0
+		}
+	}
+	return l1DecayLoss, l2DecayLoss
+}
 
-					// Assume SGD
-					if t.opts.Momentum > 0.0 {
-						// momentum update
+// centralizeGradients subtracts the mean from each filter's raw batch gradient.
+// Bias responses are left untouched as they are not filters.
+func (t *trainer) centralizeGradients(pgList []layers.LayerResponse) {
+	if !t.opts.GradCentralization {
+		return
+	}
 
-						// step
-						dx := t.opts.Momentum*gsumi[j] - t.opts.LearningRate*gij
+	for i, pg := range pgList {
+		grads := t.grads[i]
+		if pg.Bias || len(grads) < 2 {
+			continue
+		}
 
-						// back this up for next iteration of momentum
-						gsumi[j] = dx
+		var mean float64
+		for _, gij := range grads {
+			mean += gij
+		}
+		mean /= float64(len(grads))
+		for j := range grads {
+			grads[j] -= mean
+		}
+	}
+}
 
-						// apply corrected gradient
-						p[j] += dx
-					} else {
-						// vanilla sgd
-						p[j] += -t.opts.LearningRate * gij
-					}
+// clipGradients clips the raw batch gradients by value and then by global norm.
+func (t *trainer) clipGradients() {
+	if v := t.opts.GradClipValue; v > 0 {
+		for _, grads := range t.grads {
+			for j := range grads {
+				grads[j] = math.Max(-v, math.Min(v, grads[j]))
+			}
+		}
+	}
+
+	if maxNorm := t.opts.GradClipNorm; maxNorm > 0 {
+		var sumSq float64
+		for _, grads := range t.grads {
+			for _, gij := range grads {
+				sumSq += gij * gij
+			}
+		}
+
+		// rescale all gradients together so the update direction is preserved
+		if norm := math.Sqrt(sumSq); norm > maxNorm {
+			scale := maxNorm / norm
+			for _, grads := range t.grads {
+				for j := range grads {
+					grads[j] *= scale
 				}
+			}
+		}
+	}
+}
+
+// addGradientNoise adds annealed gaussian noise to the raw batch gradients, with a
+// variance of eta / (1 + k)^gamma at iteration k.
+func (t *trainer) addGradientNoise() {
+	if t.opts.NoiseEta <= 0 {
+		return
+	}
 
-				// zero out gradient so that we can begin accumulating anew
-				g[j] = 0.0
+	stdDev := math.Sqrt(t.opts.NoiseEta / math.Pow(1+float64(t.k), t.opts.NoiseGamma))
+	for _, grads := range t.grads {
+		for j := range grads {
+			grads[j] += t.rng.NormFloat64() * stdDev
+		}
+	}
+}
+
+// notifyGradients passes the raw batch gradients to the callbacks which inspect them.
+func (t *trainer) notifyGradients(pgList []layers.LayerResponse) {
+	var params []layers.LayerResponse
+	for _, cb := range t.opts.Callbacks {
+		gc, ok := cb.(GradientCallback)
+		if !ok {
+			continue
+		}
+		if params == nil {
+			params = make([]layers.LayerResponse, len(pgList))
+			for i, pg := range pgList {
+				params[i] = pg
+				params[i].Gradients = t.grads[i]
 			}
 		}
+		gc.OnGradients(t, params)
 	}
-	return TrainingResults{
-		ForwardTime:  fwdTime,
-		BackwardTime: bwdTime,
-		L1DecayLoss:  l1DecayLoss,
-		L2DecayLoss:  l2DecayLoss,
-		CostLost:     costLoss,
-		TotalLoss:    costLoss + l1DecayLoss + l2DecayLoss,
+}
+
+// update applies the optimizer step for all sets of weights using the raw batch gradients.
+func (t *trainer) update(pgList []layers.LayerResponse) {
+	for i, pg := range pgList {
+		o := t.pgOpts[i]
+		lr := t.learningRate(o)
+
+		p := pg.Weights
+		for j := 0; j < len(p); j++ {
+			gij := t.grads[i][j]
+
+			meth := o.Method
+			gsumi, xsumi := t.gsum[i], t.xsum[i]
+			if meth == Adam {
+
+				// update biased first moment estimate
+				gsumi[j] = gsumi[j]*o.Beta1 + (1-o.Beta1)*gij
+
+				// update biased second moment estimate
+				xsumi[j] = xsumi[j]*o.Beta2 + (1-o.Beta2)*gij*gij
+
+				// correct bias first moment estimate
+				biasCorr1 := gsumi[j] * (1 - math.Pow(o.Beta1, float64(t.k)))
+
+				// correct bias second moment estimate
+				biasCorr2 := xsumi[j] * (1 - math.Pow(o.Beta2, float64(t.k)))
+
+				dx := -lr * biasCorr1 / (math.Sqrt(biasCorr2) + o.Eps)
+				p[j] += dx
+			} else if meth == Adagrad {
+				// update biased first moment estimate
+				gsumi[j] = gsumi[j] + gij*gij
+
+				dx := -lr / (math.Sqrt(gsumi[j]) + o.Eps) * gij
+				p[j] += dx
+			} else if meth == Windowgrad {
+				// this is adagrad but with a moving window weighted average
+				// so the gradient is not accumulated over the entire history of the run.
+				// it's also referred to as Idea #1 in Zeiler paper on Adadelta. Seems reasonable to me!
+				gsumi[j] = o.Ro*gsumi[j] + (1-o.Ro)*gij*gij
+
+				// eps added for better conditioning
+				dx := -lr / math.Sqrt(gsumi[j]+o.Eps) * gij
+				p[j] += dx
+			} else if meth == Adadelta {
+				gsumi[j] = o.Ro*gsumi[j] + (1-o.Ro)*gij*gij
+				dx := -math.Sqrt((xsumi[j]+o.Eps)/(gsumi[j]+o.Eps)) * gij
+				xsumi[j] = o.Ro*xsumi[j] + (1-o.Ro)*dx*dx // yes, xsum lags behind gsum by 1.
+				p[j] += dx
+			} else if meth == Netsterov {
+				dx := gsumi[j]
+				gsumi[j] = gsumi[j]*o.Momentum + lr*gij
+				dx = o.Momentum*dx - (1.0+o.Momentum)*gsumi[j]
+				p[j] += dx
+			} else {
+
+				// Assume SGD
+				if o.Momentum > 0.0 {
+					// momentum update
+
+					// step
+					dx := o.Momentum*gsumi[j] - lr*gij
+
+					// back this up for next iteration of momentum
+					gsumi[j] = dx
+
+					// apply corrected gradient
+					p[j] += dx
+				} else {
+					// vanilla sgd
+					p[j] += -lr * gij
+				}
+			}
+		}
 	}
 }
 
+// AccumulationState describes the gradients accumulated since the last update.
+type AccumulationState struct {
+	// Steps is the number of Train calls since the last update.
+	Steps int
+
+	// Samples is the number of samples whose gradients have been accumulated.
+	Samples int
+
+	// Target is the number of Train calls after which the next update is applied.
+	Target int
+}
+
 type TrainingResults struct {
+	// Updated is set when the call applied an update to the weights.
+	Updated bool
+
+	// LearningRate is the base learning rate after scheduling at this iteration.
+	LearningRate float64
+
 	ForwardTime  time.Duration
 	BackwardTime time.Duration
 	L1DecayLoss  float64
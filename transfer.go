@@ -0,0 +1,131 @@
+package reticulum
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+
+	"github.com/nathanleary/reticulum/layers"
+)
+
+// ReplaceHead returns a new network made of the layers of net up to and including
+// fromLayer, with their weights, followed by freshly initialized layers built from
+// newDefs. net itself is left unchanged. It requires a network built by this package.
+func ReplaceHead(net Network, fromLayer string, newDefs []layers.LayerDef) (Network, error) {
+	n, ok := net.(*network)
+	if !ok {
+		return nil, errors.New("replacing the head requires a network built by NewNetwork or NewGraph")
+	}
+	return n.replaceHead(fromLayer, newDefs)
+}
+
+func (n *network) replaceHead(fromLayer string, newDefs []layers.LayerDef) (Network, error) {
+	if n.graph != nil {
+		return nil, errors.New("replacing the head of a graph network is not supported")
+	} else if len(newDefs) == 0 {
+		return nil, errors.New("the new head requires at least one layer")
+	}
+	idx := n.layerIndex(fromLayer)
+	if idx < 0 {
+		return nil, fmt.Errorf("unknown layer %q", fromLayer)
+	}
+
+	// the backbone keeps its resolved definitions and names
+	defs := make([]layers.LayerDef, 0, idx+1+len(newDefs))
+	for i := 0; i <= idx; i++ {
+		def := n.defs[i]
+		def.Name = n.names[i]
+		defs = append(defs, def)
+	}
+
+	// the head is expanded on its own, its output dimensions are inferred from the
+	// backbone where not given
+	head := make([]layers.LayerDef, len(newDefs))
+	copy(head, newDefs)
+	prev := defs[idx].Output
+	for i := range head {
+		head[i].Input = prev
+		if head[i].Output.Size() == 0 {
+			out, err := layers.OutputDimensions(head[i])
+			if err != nil {
+				return nil, fmt.Errorf("head layer %d (%s): %w", i, head[i].Type, err)
+			}
+			head[i].Output = out
+		}
+		prev = head[i].Output
+	}
+	defs = append(defs, layers.ActivateLayers(head)...)
+
+	// seeded networks initialize the head from a source derived from their own
+	var rng *rand.Rand
+	if r := defs[0].Rand; r != nil {
+		rng = rand.New(rand.NewSource(r.Int63()))
+	}
+	for i := range defs {
+		defs[i].Rand = nil
+	}
+
+	c, err := newSequential(defs, rng)
+	if err != nil {
+		return nil, err
+	}
+	c.tracer = n.tracer
+	c.profileCtx = n.profileCtx
+	if _, err := CopyWeightsByName(c, n, n.names[:idx+1]...); err != nil {
+		return nil, err
+	}
+	for _, name := range n.names[:idx+1] {
+		if n.frozen[name] {
+			c.Freeze(name)
+		}
+	}
+	return c, nil
+}
+
+// CopyWeightsByName copies the parameters of the named layers from src into dst, or
+// of every layer whose name appears in both networks when no names are given, e.g. to
+// reuse a trained backbone. Returns the names of the layers copied.
+func CopyWeightsByName(dst, src Network, names ...string) ([]string, error) {
+	dstLayers, srcLayers := layersByName(dst), layersByName(src)
+	if len(names) == 0 {
+		for i := range dst.Layers() {
+			if name := LayerName(dst, i); srcLayers[name] != nil {
+				names = append(names, name)
+			}
+		}
+	}
+
+	var copied []string
+	for _, name := range names {
+		dl, sl := dstLayers[name], srcLayers[name]
+		if dl == nil || sl == nil {
+			return copied, fmt.Errorf("layer %q is missing from one of the networks", name)
+		} else if dl.Type() != sl.Type() {
+			return copied, fmt.Errorf("layer %q: type mismatch: %s != %s", name, dl.Type(), sl.Type())
+		}
+
+		dp, sp := dl.GetResponse(), sl.GetResponse()
+		if len(dp) != len(sp) {
+			return copied, fmt.Errorf("layer %q: parameter set count mismatch: %d != %d", name, len(dp), len(sp))
+		}
+		for i := range dp {
+			if len(dp[i].Weights) != len(sp[i].Weights) {
+				return copied, fmt.Errorf("layer %q: parameter set %d: size mismatch: %d != %d", name, i, len(dp[i].Weights), len(sp[i].Weights))
+			}
+		}
+		for i := range dp {
+			copy(dp[i].Weights, sp[i].Weights)
+		}
+		copied = append(copied, name)
+	}
+	return copied, nil
+}
+
+// layersByName indexes the layers of the network by name.
+func layersByName(net Network) map[string]layers.Layer {
+	byName := make(map[string]layers.Layer, net.Size())
+	for i, l := range net.Layers() {
+		byName[LayerName(net, i)] = l
+	}
+	return byName
+}
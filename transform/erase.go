@@ -0,0 +1,88 @@
+package transform
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+
+	"github.com/nathanleary/reticulum/volume"
+)
+
+// Cutout zeros holes squares of size x size at random positions of the input, in all
+// depth channels. The squares are centered anywhere on the input and clipped to it,
+// so parts of the input stay visible even when they fall on the border.
+func Cutout(size, holes int) Transform {
+	if size <= 0 || holes <= 0 {
+		panic(fmt.Errorf("invalid cutout of %d holes of %d", holes, size))
+	}
+	return func(vol *volume.Volume, rng *rand.Rand) *volume.Volume {
+		dim := vol.Dimensions()
+		out := vol.Clone()
+		for h := 0; h < holes; h++ {
+			cx, cy := rng.Intn(dim.X), rng.Intn(dim.Y)
+			fill(out, cx-size/2, cy-size/2, size, size, func() float64 { return 0 })
+		}
+		return out
+	}
+}
+
+// RandomErasing replaces a random rectangle of the input with noise with probability
+// p. The rectangle covers between minArea and maxArea of the input, with an aspect
+// ratio between 1/maxAspect and maxAspect, and the noise is drawn uniformly between
+// the smallest and the largest value of the input so it matches its scale. The input
+// is left unchanged when no rectangle of the drawn shape fits after 10 attempts.
+func RandomErasing(p, minArea, maxArea, maxAspect float64) Transform {
+	if p < 0 || p > 1 {
+		panic(fmt.Errorf("erasing probability must be in [0, 1], got %v", p))
+	} else if minArea <= 0 || maxArea > 1 || minArea > maxArea {
+		panic(fmt.Errorf("invalid erased area range [%v, %v]", minArea, maxArea))
+	} else if maxAspect < 1 {
+		panic(fmt.Errorf("maximum aspect ratio must be at least 1, got %v", maxAspect))
+	}
+	return func(vol *volume.Volume, rng *rand.Rand) *volume.Volume {
+		if rng.Float64() >= p {
+			return vol
+		}
+
+		dim := vol.Dimensions()
+		area := float64(dim.X * dim.Y)
+		for attempt := 0; attempt < 10; attempt++ {
+			target := area * (minArea + rng.Float64()*(maxArea-minArea))
+			aspect := math.Exp((rng.Float64()*2 - 1) * math.Log(maxAspect))
+			w := int(math.Round(math.Sqrt(target * aspect)))
+			h := int(math.Round(math.Sqrt(target / aspect)))
+			if w <= 0 || h <= 0 || w > dim.X || h > dim.Y {
+				continue
+			}
+
+			lo, hi := valueRange(vol.Weights())
+			out := vol.Clone()
+			fill(out, rng.Intn(dim.X-w+1), rng.Intn(dim.Y-h+1), w, h, func() float64 {
+				return lo + rng.Float64()*(hi-lo)
+			})
+			return out
+		}
+		return vol
+	}
+}
+
+// fill sets the w x h rectangle with its top left corner at x, y to values drawn from
+// value, clipping it to the volume.
+func fill(vol *volume.Volume, x, y, w, h int, value func() float64) {
+	dim := vol.Dimensions()
+	for fy := max(y, 0); fy < min(y+h, dim.Y); fy++ {
+		for fx := max(x, 0); fx < min(x+w, dim.X); fx++ {
+			for d := 0; d < dim.Z; d++ {
+				vol.Set(fx, fy, d, value())
+			}
+		}
+	}
+}
+
+func valueRange(w []float64) (float64, float64) {
+	lo, hi := math.Inf(1), math.Inf(-1)
+	for _, v := range w {
+		lo, hi = min(lo, v), max(hi, v)
+	}
+	return lo, hi
+}
@@ -0,0 +1,106 @@
+package transform
+
+import (
+	"math/rand"
+	"slices"
+	"testing"
+)
+
+func TestCutout(t *testing.T) {
+	in := grid(6, 6, 2)
+	rng := rand.New(rand.NewSource(1))
+	for _, test := range []struct {
+		size, holes int
+	}{
+		{2, 1},
+		{3, 2},
+		{12, 1},
+	} {
+		for i := 0; i < 20; i++ {
+			out := Cutout(test.size, test.holes)(in, rng)
+			var zeros int
+			for x := 0; x < 6; x++ {
+				for y := 0; y < 6; y++ {
+					// holes span every channel, and the input has no zeros of its own
+					if out.Get(x, y, 0) == 0 {
+						zeros++
+						if out.Get(x, y, 1) != 0 {
+							t.Fatalf("cutout of %d zeroed %d,%d in one channel only", test.size, x, y)
+						}
+					} else if out.Get(x, y, 0) != in.Get(x, y, 0) {
+						t.Fatalf("cutout of %d changed %d,%d without zeroing it", test.size, x, y)
+					}
+				}
+			}
+			if zeros == 0 || zeros > min(test.holes*test.size*test.size, 36) {
+				t.Fatalf("cutout of %d holes of %d zeroed %d positions", test.holes, test.size, zeros)
+			}
+		}
+	}
+	if !slices.Equal(in.Weights(), grid(6, 6, 2).Weights()) {
+		t.Error("cutout modified its input")
+	}
+}
+
+func TestRandomErasing(t *testing.T) {
+	in := grid(8, 8, 1)
+	rng := rand.New(rand.NewSource(1))
+	for _, test := range []struct {
+		name             string
+		p, minA, maxA    float64
+		erased, unerased bool
+	}{
+		{"always", 1, 0.1, 0.3, true, false},
+		{"never", 0, 0.1, 0.3, false, true},
+		{"sometimes", 0.5, 0.1, 0.3, true, true},
+	} {
+		var erased, unerased bool
+		for i := 0; i < 50; i++ {
+			out := RandomErasing(test.p, test.minA, test.maxA, 2)(in, rng)
+			var changed int
+			for j, v := range out.Weights() {
+				if v != in.Weights()[j] {
+					changed++
+				}
+				if v < 1 || v > 64 {
+					t.Fatalf("%s: erased value %v outside the input range", test.name, v)
+				}
+			}
+			// the rectangle covers at most 30% of the input, give or take the rounding
+			// of its sides
+			if changed == 0 {
+				unerased = true
+			} else if changed > 24 {
+				t.Fatalf("%s: erased %d of 64 values", test.name, changed)
+			} else {
+				erased = true
+			}
+		}
+		if erased != test.erased || unerased != test.unerased {
+			t.Errorf("%s: erased %v, left unchanged %v", test.name, erased, unerased)
+		}
+	}
+}
+
+func TestErase_Panics(t *testing.T) {
+	for _, test := range []struct {
+		name string
+		fn   func()
+	}{
+		{"empty cutout", func() { Cutout(0, 1) }},
+		{"no holes", func() { Cutout(2, 0) }},
+		{"probability above 1", func() { RandomErasing(2, 0.1, 0.2, 2) }},
+		{"empty area", func() { RandomErasing(0.5, 0, 0.2, 2) }},
+		{"area above 1", func() { RandomErasing(0.5, 0.1, 2, 2) }},
+		{"aspect below 1", func() { RandomErasing(0.5, 0.1, 0.2, 0.5) }},
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("%s: expected a panic", test.name)
+				}
+			}()
+			test.fn()
+		}()
+	}
+}
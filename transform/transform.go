@@ -0,0 +1,123 @@
+// Package transform augments training inputs on the fly. Transforms draw a new
+// variation of the input every time they are applied, so each epoch sees different
+// inputs, and are composed into a pipeline run on every sample by the data loader:
+//
+//	aug := transform.Compose(
+//		transform.RandomCrop(24, 4),
+//		transform.RandomFlip(),
+//		transform.Normalize([]float64{0.49, 0.48, 0.45}, []float64{0.25, 0.24, 0.26}),
+//	)
+//	loader, err := datasets.NewDataLoader(src, datasets.WithTransform(aug))
+//
+// They are built on the same primitives as the test-time augmentations of the
+// reticulum package, so a network can be evaluated on the inputs it was trained on.
+package transform
+
+import (
+	"fmt"
+	"math/rand"
+
+	"github.com/nathanleary/reticulum"
+	"github.com/nathanleary/reticulum/volume"
+)
+
+// Transform returns a variation of the input, drawing any randomness from rng. The
+// input volume must not be modified. Invalid inputs, like a crop larger than the
+// input, panic.
+type Transform func(vol *volume.Volume, rng *rand.Rand) *volume.Volume
+
+// Compose applies the transforms in order.
+func Compose(ts ...Transform) Transform {
+	return func(vol *volume.Volume, rng *rand.Rand) *volume.Volume {
+		for _, t := range ts {
+			vol = t(vol, rng)
+		}
+		return vol
+	}
+}
+
+// Apply adapts a deterministic augmentation, e.g. reticulum.CenterCrop, to a
+// transform producing inputs of the given width and height.
+func Apply(aug reticulum.Augmentation, x, y int) Transform {
+	return func(vol *volume.Volume, _ *rand.Rand) *volume.Volume {
+		return aug(vol, volume.NewDimensions(x, y, vol.Dimensions().Z))
+	}
+}
+
+// RandomFlip mirrors the input horizontally half of the time.
+func RandomFlip() Transform {
+	flip := reticulum.FlipX()
+	return func(vol *volume.Volume, rng *rand.Rand) *volume.Volume {
+		if rng.Intn(2) == 0 {
+			return vol
+		}
+		return flip(vol, vol.Dimensions())
+	}
+}
+
+// RandomVerticalFlip mirrors the input vertically half of the time.
+func RandomVerticalFlip() Transform {
+	flip := reticulum.FlipY()
+	return func(vol *volume.Volume, rng *rand.Rand) *volume.Volume {
+		if rng.Intn(2) == 0 {
+			return vol
+		}
+		return flip(vol, vol.Dimensions())
+	}
+}
+
+// RandomCrop cuts a size x size window at a random position of the input padded by
+// pad zeros on every side.
+func RandomCrop(size, pad int) Transform {
+	if size <= 0 || pad < 0 {
+		panic(fmt.Errorf("invalid crop of %d with padding %d", size, pad))
+	}
+	return func(vol *volume.Volume, rng *rand.Rand) *volume.Volume {
+		dim := vol.Dimensions()
+		if dim.X+2*pad < size || dim.Y+2*pad < size {
+			panic(fmt.Errorf("crop of %d does not fit the %dx%d input padded by %d", size, dim.X, dim.Y, pad))
+		}
+		x := rng.Intn(dim.X+2*pad-size+1) - pad
+		y := rng.Intn(dim.Y+2*pad-size+1) - pad
+		return reticulum.Crop(x, y)(vol, volume.NewDimensions(size, size, dim.Z))
+	}
+}
+
+// RandomShift translates the input by up to max positions in each direction, filling
+// the uncovered border with zeros.
+func RandomShift(max int) Transform {
+	return func(vol *volume.Volume, rng *rand.Rand) *volume.Volume {
+		dx, dy := rng.Intn(2*max+1)-max, rng.Intn(2*max+1)-max
+		return reticulum.Shift(dx, dy)(vol, vol.Dimensions())
+	}
+}
+
+// Normalize subtracts the mean and divides by the standard deviation of every depth
+// channel. A single mean and std apply to all channels.
+func Normalize(mean, std []float64) Transform {
+	if len(mean) == 0 || len(mean) != len(std) {
+		panic(fmt.Errorf("normalize needs as many means as stds, got %d and %d", len(mean), len(std)))
+	}
+	for _, s := range std {
+		if s <= 0 {
+			panic(fmt.Errorf("std must be greater than 0, got %v", s))
+		}
+	}
+	return func(vol *volume.Volume, _ *rand.Rand) *volume.Volume {
+		dim := vol.Dimensions()
+		if len(mean) != 1 && len(mean) != dim.Z {
+			panic(fmt.Errorf("normalize has %d channels, the input %d", len(mean), dim.Z))
+		}
+		out := vol.Clone()
+		w := out.Weights()
+		for i := range w {
+			// the depth is the innermost dimension of a volume
+			c := 0
+			if len(mean) > 1 {
+				c = i % dim.Z
+			}
+			w[i] = (w[i] - mean[c]) / std[c]
+		}
+		return out
+	}
+}
@@ -0,0 +1,109 @@
+package transform
+
+import (
+	"math/rand"
+	"slices"
+	"testing"
+
+	"github.com/nathanleary/reticulum"
+	"github.com/nathanleary/reticulum/volume"
+)
+
+// grid returns a volume whose values are 1 plus their index.
+func grid(x, y, z int) *volume.Volume {
+	vol := volume.NewVolume(volume.NewDimensions(x, y, z), volume.WithZeros())
+	for i := range vol.Weights() {
+		vol.SetByIndex(i, float64(i+1))
+	}
+	return vol
+}
+
+func TestTransforms(t *testing.T) {
+	in := grid(4, 3, 2)
+	flipped := reticulum.FlipX()(in, in.Dimensions())
+	vflipped := reticulum.FlipY()(in, in.Dimensions())
+	for _, test := range []struct {
+		name string
+		t    Transform
+		dim  volume.Dimensions
+		// outputs lists every possible output, nil if unchecked
+		outputs []*volume.Volume
+	}{
+		{"flip", RandomFlip(), in.Dimensions(), []*volume.Volume{in, flipped}},
+		{"vertical flip", RandomVerticalFlip(), in.Dimensions(), []*volume.Volume{in, vflipped}},
+		{"full crop", RandomCrop(3, 0), volume.NewDimensions(3, 3, 2), []*volume.Volume{
+			reticulum.Crop(0, 0)(in, volume.NewDimensions(3, 3, 2)), reticulum.Crop(1, 0)(in, volume.NewDimensions(3, 3, 2)),
+		}},
+		{"padded crop", RandomCrop(4, 2), volume.NewDimensions(4, 4, 2), nil},
+		{"shift", RandomShift(1), in.Dimensions(), nil},
+		{"no shift", RandomShift(0), in.Dimensions(), []*volume.Volume{in}},
+		{"center crop", Apply(reticulum.CenterCrop(), 2, 2), volume.NewDimensions(2, 2, 2), []*volume.Volume{reticulum.CenterCrop()(in, volume.NewDimensions(2, 2, 2))}},
+		{"composed", Compose(RandomFlip(), RandomVerticalFlip()), in.Dimensions(), []*volume.Volume{
+			in, flipped, vflipped, reticulum.FlipY()(flipped, in.Dimensions()),
+		}},
+	} {
+		rng := rand.New(rand.NewSource(1))
+		seen := make([]bool, len(test.outputs))
+		for i := 0; i < 50; i++ {
+			out := test.t(in, rng)
+			if out.Dimensions() != test.dim {
+				t.Fatalf("%s: output of %v, want %v", test.name, out.Dimensions(), test.dim)
+			}
+			if test.outputs == nil {
+				continue
+			}
+			k := slices.IndexFunc(test.outputs, func(v *volume.Volume) bool { return slices.Equal(v.Weights(), out.Weights()) })
+			if k < 0 {
+				t.Fatalf("%s: unexpected output %v", test.name, out.Weights())
+			}
+			seen[k] = true
+		}
+		if slices.Contains(seen, false) {
+			t.Errorf("%s: not every output was drawn: %v", test.name, seen)
+		}
+		if !slices.Equal(in.Weights(), grid(4, 3, 2).Weights()) {
+			t.Fatalf("%s: the input was modified", test.name)
+		}
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	in := grid(2, 1, 2) // values 1, 2, 3, 4 with channels alternating
+	for _, test := range []struct {
+		name      string
+		mean, std []float64
+		want      []float64
+	}{
+		{"per channel", []float64{1, 2}, []float64{1, 2}, []float64{0, 0, 2, 1}},
+		{"shared", []float64{2}, []float64{2}, []float64{-0.5, 0, 0.5, 1}},
+	} {
+		if got := Normalize(test.mean, test.std)(in, nil).Weights(); !slices.Equal(got, test.want) {
+			t.Errorf("%s: Normalize() = %v, want %v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestTransforms_Panics(t *testing.T) {
+	in := grid(4, 3, 2)
+	rng := rand.New(rand.NewSource(1))
+	for _, test := range []struct {
+		name string
+		fn   func()
+	}{
+		{"empty crop", func() { RandomCrop(0, 0) }},
+		{"negative padding", func() { RandomCrop(2, -1) }},
+		{"crop too large", func() { RandomCrop(5, 0)(in, rng) }},
+		{"no means", func() { Normalize(nil, nil) }},
+		{"zero std", func() { Normalize([]float64{0}, []float64{0}) }},
+		{"channel mismatch", func() { Normalize([]float64{0, 0, 0}, []float64{1, 1, 1})(in, rng) }},
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("%s: expected a panic", test.name)
+				}
+			}()
+			test.fn()
+		}()
+	}
+}
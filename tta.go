@@ -0,0 +1,162 @@
+package reticulum
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/nathanleary/reticulum/volume"
+)
+
+// Augmentation transforms an input for test-time augmentation. It is given the input
+// dimensions of the network, so crops can cut inputs larger than the network down to
+// size. The input volume must not be modified.
+type Augmentation func(vol *volume.Volume, dims volume.Dimensions) *volume.Volume
+
+// Identity leaves the input unchanged.
+func Identity() Augmentation {
+	return func(vol *volume.Volume, _ volume.Dimensions) *volume.Volume {
+		return vol
+	}
+}
+
+// FlipX mirrors the input horizontally.
+func FlipX() Augmentation {
+	return func(vol *volume.Volume, _ volume.Dimensions) *volume.Volume {
+		dim := vol.Dimensions()
+		return remap(vol, dim, func(x, y int) (int, int) { return dim.X - 1 - x, y })
+	}
+}
+
+// FlipY mirrors the input vertically.
+func FlipY() Augmentation {
+	return func(vol *volume.Volume, _ volume.Dimensions) *volume.Volume {
+		dim := vol.Dimensions()
+		return remap(vol, dim, func(x, y int) (int, int) { return x, dim.Y - 1 - y })
+	}
+}
+
+// Shift translates the input by dx, dy, filling the uncovered border with zeros.
+func Shift(dx, dy int) Augmentation {
+	return func(vol *volume.Volume, _ volume.Dimensions) *volume.Volume {
+		return remap(vol, vol.Dimensions(), func(x, y int) (int, int) { return x - dx, y - dy })
+	}
+}
+
+// Crop cuts a window of the network input size from the input, with its top left
+// corner at x, y. Parts of the window outside the input are zero.
+func Crop(x, y int) Augmentation {
+	return func(vol *volume.Volume, dims volume.Dimensions) *volume.Volume {
+		out := volume.NewDimensions(dims.X, dims.Y, vol.Dimensions().Z)
+		return remap(vol, out, func(ox, oy int) (int, int) { return ox + x, oy + y })
+	}
+}
+
+// CenterCrop cuts a window of the network input size from the center of the input.
+func CenterCrop() Augmentation {
+	return func(vol *volume.Volume, dims volume.Dimensions) *volume.Volume {
+		dim := vol.Dimensions()
+		return Crop((dim.X-dims.X)/2, (dim.Y-dims.Y)/2)(vol, dims)
+	}
+}
+
+// FiveCrops returns the center and the four corner crops of the network input size.
+func FiveCrops() []Augmentation {
+	corner := func(right, bottom bool) Augmentation {
+		return func(vol *volume.Volume, dims volume.Dimensions) *volume.Volume {
+			dim := vol.Dimensions()
+			var x, y int
+			if right {
+				x = dim.X - dims.X
+			}
+			if bottom {
+				y = dim.Y - dims.Y
+			}
+			return Crop(x, y)(vol, dims)
+		}
+	}
+	return []Augmentation{CenterCrop(), corner(false, false), corner(true, false), corner(false, true), corner(true, true)}
+}
+
+// Compose applies the augmentations in order, e.g. Compose(CenterCrop(), FlipX()).
+func Compose(augs ...Augmentation) Augmentation {
+	return func(vol *volume.Volume, dims volume.Dimensions) *volume.Volume {
+		for _, aug := range augs {
+			vol = aug(vol, dims)
+		}
+		return vol
+	}
+}
+
+// remap returns a volume of the given dimensions where every position x, y takes the
+// values of the position src(x, y) of vol, or zeros outside of it.
+func remap(vol *volume.Volume, dims volume.Dimensions, src func(x, y int) (int, int)) *volume.Volume {
+	in := vol.Dimensions()
+	out := volume.NewVolume(dims, volume.WithZeros())
+	for y := 0; y < dims.Y; y++ {
+		for x := 0; x < dims.X; x++ {
+			sx, sy := src(x, y)
+			if sx < 0 || sx >= in.X || sy < 0 || sy >= in.Y {
+				continue
+			}
+			for d := 0; d < dims.Z; d++ {
+				out.Set(x, y, d, vol.Get(sx, sy, d))
+			}
+		}
+	}
+	return out
+}
+
+// TTA runs a network over several augmentations of every input and averages the
+// outputs, which usually gives steadier predictions than the input alone.
+type TTA struct {
+	net  Network
+	augs []Augmentation
+	dims volume.Dimensions
+}
+
+// NewTTA creates test-time augmentation for a single input network. Without
+// augmentations only the input itself is used.
+func NewTTA(net Network, augs ...Augmentation) (*TTA, error) {
+	if net == nil {
+		return nil, errors.New("network cannot be nil")
+	}
+	dims := InputDimensions(net)
+	if len(dims) != 1 {
+		return nil, fmt.Errorf("test-time augmentation requires a single input, network has %d", len(dims))
+	}
+	if len(augs) == 0 {
+		augs = []Augmentation{Identity()}
+	}
+	return &TTA{net: net, augs: augs, dims: dims[0]}, nil
+}
+
+// Forward returns the mean output of the network over the augmentations of vol, which
+// run in parallel as with ForwardBatch.
+func (t *TTA) Forward(vol *volume.Volume) (*volume.Volume, error) {
+	if vol == nil {
+		return nil, errors.New("volume cannot be nil")
+	}
+	vols := make([]*volume.Volume, len(t.augs))
+	for i, aug := range t.augs {
+		vols[i] = aug(vol, t.dims)
+		if dim := vols[i].Dimensions(); dim != t.dims {
+			return nil, fmt.Errorf("augmentation %d: dimensions %v do not match the input %v", i, dim, t.dims)
+		}
+	}
+
+	outs := ForwardBatch(t.net, vols, false)
+	mean := volume.NewVolume(outs[0].Dimensions(), volume.WithZeros())
+	for _, out := range outs {
+		mean.AddFromScaled(out, 1/float64(len(outs)))
+	}
+	return mean, nil
+}
+
+// Predict returns the class with the highest mean output.
+func (t *TTA) Predict(vol *volume.Volume) (int, error) {
+	out, err := t.Forward(vol)
+	if err != nil {
+		return 0, err
+	}
+	return argmax(out.Weights()), nil
+}
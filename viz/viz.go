@@ -0,0 +1,240 @@
+// Package viz renders the filters and activations of a network to PNG images, like
+// the visualizations of ConvNetJS:
+//
+//	if err := viz.SaveFilters(net.Layers()[1], "out/conv1"); err != nil {
+//		return err
+//	}
+//	if err := viz.SaveActivations(net, img, "out/activations"); err != nil {
+//		return err
+//	}
+//
+// Every image is a grid of tiles, one per filter or channel, drawn in grayscale, or in
+// color for filters on RGB inputs, with the values of the whole grid scaled from the
+// lowest to the highest, so the tiles can be compared with each other.
+package viz
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/nathanleary/reticulum"
+	"github.com/nathanleary/reticulum/layers"
+	"github.com/nathanleary/reticulum/volume"
+)
+
+// OptionFunc modifies the Options of a rendering.
+type OptionFunc func(*Options)
+
+// Options configures the rendering of grids.
+type Options struct {
+	// Scale is the size in pixels of every value.
+	Scale int
+
+	// Padding is the number of pixels between the tiles.
+	Padding int
+}
+
+// WithScale sets the size in pixels of every value.
+func WithScale(scale int) OptionFunc {
+	return func(opts *Options) {
+		opts.Scale = scale
+	}
+}
+
+// WithPadding sets the number of pixels between the tiles.
+func WithPadding(pad int) OptionFunc {
+	return func(opts *Options) {
+		opts.Padding = pad
+	}
+}
+
+func newOptions(optFuncs []OptionFunc) *Options {
+	opts := &Options{Scale: 4, Padding: 1}
+	for _, optFn := range optFuncs {
+		optFn(opts)
+	}
+	opts.Scale = max(opts.Scale, 1)
+	opts.Padding = max(opts.Padding, 0)
+	return opts
+}
+
+// FilterGrid renders the filters of a layer with spatial filters, such as conv, as a
+// grid with a row per filter and a tile per input channel. Filters of depth 3 are
+// drawn as a single color tile instead, as the filters of a layer on RGB images.
+func FilterGrid(layer layers.Layer, optFuncs ...OptionFunc) (*image.RGBA, error) {
+	fl, ok := layer.(layers.FilterLayer)
+	if !ok {
+		return nil, fmt.Errorf("%s layer has no spatial filters", layer.Type())
+	}
+	filters := fl.Filters()
+	if len(filters) == 0 {
+		return nil, fmt.Errorf("%s layer has no filters", layer.Type())
+	}
+
+	dim := filters[0].Dimensions()
+	cols := dim.Z
+	if dim.Z == 3 {
+		cols = 1
+	}
+	lo, hi := bounds(filters...)
+	g := newGrid(dim.X, dim.Y, len(filters), cols, newOptions(optFuncs))
+	for row, f := range filters {
+		if dim.Z == 3 {
+			g.drawRGB(row, 0, f, lo, hi)
+			continue
+		}
+		for d := 0; d < dim.Z; d++ {
+			g.drawChannel(row, d, f, d, lo, hi)
+		}
+	}
+	return g.img, nil
+}
+
+// ActivationGrid renders the channels of a volume, e.g. the output of a layer, as a
+// square grid of tiles.
+func ActivationGrid(vol *volume.Volume, optFuncs ...OptionFunc) *image.RGBA {
+	dim := vol.Dimensions()
+	cols := int(math.Ceil(math.Sqrt(float64(dim.Z))))
+	rows := (dim.Z + cols - 1) / cols
+	lo, hi := bounds(vol)
+	g := newGrid(dim.X, dim.Y, rows, cols, newOptions(optFuncs))
+	for d := 0; d < dim.Z; d++ {
+		g.drawChannel(d/cols, d%cols, vol, d, lo, hi)
+	}
+	return g.img
+}
+
+// SaveFilters writes the filter grid of the layer to filters.png in dir, creating dir
+// if needed. See FilterGrid.
+func SaveFilters(layer layers.Layer, dir string, optFuncs ...OptionFunc) error {
+	img, err := FilterGrid(layer, optFuncs...)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	return savePNG(filepath.Join(dir, "filters.png"), img)
+}
+
+// SaveActivations runs the network in inference mode on the volume and writes the
+// activation grid of the output of every layer to dir, creating it if needed. The
+// files are named after the layers, prefixed with their index to keep them in order,
+// such as 01_conv1.png.
+func SaveActivations(net reticulum.Network, vol *volume.Volume, dir string, optFuncs ...OptionFunc) error {
+	names := make([]string, net.Size())
+	for i := range names {
+		names[i] = reticulum.LayerName(net, i)
+	}
+	acts, err := reticulum.ActivationsOf(net, vol, names...)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	width := len(fmt.Sprint(net.Size() - 1))
+	for i, name := range names {
+		act := acts[name]
+		file := fmt.Sprintf("%0*d_%s.png", width, i, sanitize(name))
+		if err := savePNG(filepath.Join(dir, file), ActivationGrid(act, optFuncs...)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func savePNG(path string, img image.Image) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := png.Encode(f, img); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// sanitize replaces the characters of a layer name which are not safe in file names.
+func sanitize(name string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '/' || r == '\\' || r == ':' || r == ' ' {
+			return '_'
+		}
+		return r
+	}, name)
+}
+
+// bounds returns the lowest and highest values of the volumes.
+func bounds(vols ...*volume.Volume) (float64, float64) {
+	lo, hi := math.Inf(1), math.Inf(-1)
+	for _, vol := range vols {
+		for _, v := range vol.Weights() {
+			lo, hi = math.Min(lo, v), math.Max(hi, v)
+		}
+	}
+	return lo, hi
+}
+
+// grid draws tiles of w x h values in rows and columns on a gray background.
+type grid struct {
+	img   *image.RGBA
+	w, h  int
+	scale int
+	pad   int
+}
+
+func newGrid(w, h, rows, cols int, opts *Options) *grid {
+	tw, th := w*opts.Scale, h*opts.Scale
+	width := cols*tw + (cols+1)*opts.Padding
+	height := rows*th + (rows+1)*opts.Padding
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(img, img.Bounds(), image.NewUniform(color.Gray{Y: 128}), image.Point{}, draw.Src)
+	return &grid{img: img, w: w, h: h, scale: opts.Scale, pad: opts.Padding}
+}
+
+// drawChannel draws channel d of the volume in grayscale in the tile at row, col.
+func (g *grid) drawChannel(row, col int, vol *volume.Volume, d int, lo, hi float64) {
+	g.draw(row, col, func(x, y int) color.RGBA {
+		v := level(vol.Get(x, y, d), lo, hi)
+		return color.RGBA{R: v, G: v, B: v, A: 255}
+	})
+}
+
+// drawRGB draws the three channels of the volume in color in the tile at row, col.
+func (g *grid) drawRGB(row, col int, vol *volume.Volume, lo, hi float64) {
+	g.draw(row, col, func(x, y int) color.RGBA {
+		return color.RGBA{R: level(vol.Get(x, y, 0), lo, hi), G: level(vol.Get(x, y, 1), lo, hi), B: level(vol.Get(x, y, 2), lo, hi), A: 255}
+	})
+}
+
+func (g *grid) draw(row, col int, at func(x, y int) color.RGBA) {
+	left := g.pad + col*(g.w*g.scale+g.pad)
+	top := g.pad + row*(g.h*g.scale+g.pad)
+	for y := 0; y < g.h; y++ {
+		for x := 0; x < g.w; x++ {
+			c := at(x, y)
+			for sy := 0; sy < g.scale; sy++ {
+				for sx := 0; sx < g.scale; sx++ {
+					g.img.SetRGBA(left+x*g.scale+sx, top+y*g.scale+sy, c)
+				}
+			}
+		}
+	}
+}
+
+// level maps a value between lo and hi to a gray level.
+func level(v, lo, hi float64) uint8 {
+	if hi == lo {
+		return 0
+	}
+	return uint8(math.Round((v - lo) / (hi - lo) * 255))
+}
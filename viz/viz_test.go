@@ -0,0 +1,115 @@
+package viz
+
+import (
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/nathanleary/reticulum"
+	"github.com/nathanleary/reticulum/layers"
+	"github.com/nathanleary/reticulum/volume"
+)
+
+func convNet(t *testing.T, depth int) reticulum.Network {
+	t.Helper()
+	net, err := reticulum.NewNetwork([]layers.LayerDef{
+		{Type: layers.Input, Output: volume.NewDimensions(5, 5, depth)},
+		{Type: layers.Conv, Name: "conv/1", LayerConfig: layers.NewConvLayerConfig(4, layers.WithSx(3))},
+		{Type: layers.SoftMax, LayerConfig: layers.NewSoftmaxLayerConfig(2)},
+	}, reticulum.WithNetworkSeed(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return net
+}
+
+func TestFilterGrid(t *testing.T) {
+	for _, test := range []struct {
+		name          string
+		depth         int
+		opts          []OptionFunc
+		width, height int
+	}{
+		// 4 rows of 2 tiles of 3x3 values of 4 pixels, 1 pixel apart
+		{"grayscale", 2, nil, 2*12 + 3, 4*12 + 5},
+		{"color", 3, []OptionFunc{WithScale(2), WithPadding(0)}, 6, 4 * 6},
+	} {
+		img, err := FilterGrid(convNet(t, test.depth).Layers()[1], test.opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if b := img.Bounds(); b.Dx() != test.width || b.Dy() != test.height {
+			t.Errorf("%s: grid of %dx%d, want %dx%d", test.name, b.Dx(), b.Dy(), test.width, test.height)
+		}
+	}
+
+	if _, err := FilterGrid(convNet(t, 1).Layers()[0]); err == nil {
+		t.Error("expected an error for a layer without filters")
+	}
+}
+
+func TestActivationGrid(t *testing.T) {
+	// 5 channels of 2x1 values, in a 3x2 grid scaled from 0 to 4
+	vol := volume.NewVolume(volume.NewDimensions(2, 1, 5), volume.WithZeros())
+	for d := 0; d < 5; d++ {
+		vol.Set(1, 0, d, float64(d))
+	}
+	img := ActivationGrid(vol, WithScale(1), WithPadding(1))
+	if b := img.Bounds(); b.Dx() != 3*2+4 || b.Dy() != 2*1+3 {
+		t.Fatalf("grid of %v, want 10x5", b)
+	}
+	for _, test := range []struct {
+		x, y int
+		want color.RGBA
+	}{
+		{0, 0, color.RGBA{128, 128, 128, 255}}, // padding
+		{1, 1, color.RGBA{0, 0, 0, 255}},       // channel 0 at 0, 0
+		{2, 1, color.RGBA{0, 0, 0, 255}},       // channel 0 at 1, 0
+		{8, 1, color.RGBA{128, 128, 128, 255}}, // channel 2 at 1, 0
+		{2, 3, color.RGBA{191, 191, 191, 255}}, // channel 3 at 1, 0
+		{5, 3, color.RGBA{255, 255, 255, 255}}, // channel 4 at 1, 0
+		{8, 3, color.RGBA{128, 128, 128, 255}}, // the missing sixth tile
+	} {
+		if got := img.RGBAAt(test.x, test.y); got != test.want {
+			t.Errorf("pixel %d,%d = %v, want %v", test.x, test.y, got, test.want)
+		}
+	}
+}
+
+func TestSave(t *testing.T) {
+	net := convNet(t, 1)
+	dir := filepath.Join(t.TempDir(), "out")
+	if err := SaveFilters(net.Layers()[1], dir); err != nil {
+		t.Fatal(err)
+	}
+	vol := volume.NewVolume(volume.NewDimensions(5, 5, 1), volume.WithZeros())
+	if err := SaveActivations(net, vol, dir); err != nil {
+		t.Fatal(err)
+	}
+
+	// one image per layer next to the filters, decodable and named after the layer
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != net.Size()+1 {
+		t.Fatalf("%d files written, want %d", len(entries), net.Size()+1)
+	}
+	for _, name := range []string{"filters.png", "1_conv_1.png"} {
+		f, err := os.Open(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = png.Decode(f)
+		f.Close()
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+
+	if err := SaveActivations(net, volume.NewVolume(volume.NewDimensions(4, 4, 1), volume.WithZeros()), dir); err == nil {
+		t.Error("expected an error for an input of the wrong size")
+	}
+}
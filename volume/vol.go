@@ -31,6 +31,9 @@ type Options struct {
 	HasInitialValue bool
 	InitialValue    float64
 	Weights         []float64
+
+	// Rand is the source for the random weight initialization, defaults to the global source.
+	Rand *rand.Rand
 }
 
 // OptionFunc modifies the Options when creating a new Volume.
@@ -59,6 +62,13 @@ func WithWeights(w []float64) OptionFunc {
 	}
 }
 
+// WithRand initializes random weights from the given source, for reproducible weights.
+func WithRand(r *rand.Rand) OptionFunc {
+	return func(opts *Options) {
+		opts.Rand = r
+	}
+}
+
 // NewVolume creates a new Volume of the given size and options.
 func NewVolume(dim Dimensions, optFuncs ...OptionFunc) *Volume {
 	n := dim.Size()
@@ -96,10 +106,14 @@ func NewVolume(dim Dimensions, optFuncs ...OptionFunc) *Volume {
 		// variance of every neuron, otherwise neurons with a lot
 		// of incoming connections have outputs of larger variance
 		desiredStdDev := math.Sqrt(1.0 / float64(n))
+		normFloat64 := rand.NormFloat64
+		if opts.Rand != nil {
+			normFloat64 = opts.Rand.NormFloat64
+		}
 		for i := 0; i < n; i++ {
 
 			// Gaussian distribution with a mean of 0 and the given stdev
-			w[i] = rand.NormFloat64() * desiredStdDev
+			w[i] = normFloat64() * desiredStdDev
 		}
 	}
 
@@ -93,6 +93,20 @@ func TestWithWeights(t *testing.T) {
 	}
 }
 
+func TestWithRand(t *testing.T) {
+	dim := Dimensions{2, 2, 3}
+	vol1 := NewVolume(dim, WithRand(rand.New(rand.NewSource(42))))
+	vol2 := NewVolume(dim, WithRand(rand.New(rand.NewSource(42))))
+	if !reflect.DeepEqual(vol1.w, vol2.w) {
+		t.Errorf("WithRand() = %v, want %v", vol1.w, vol2.w)
+	}
+
+	vol3 := NewVolume(dim, WithRand(rand.New(rand.NewSource(43))))
+	if reflect.DeepEqual(vol1.w, vol3.w) {
+		t.Errorf("WithRand() with different seeds = %v, want different weights", vol3.w)
+	}
+}
+
 func TestWithZeros(t *testing.T) {
 	tests := []struct {
 		name string
@@ -0,0 +1,142 @@
+package reticulum
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// LoadOptions configures LoadWeights.
+type LoadOptions struct {
+	// SkipMismatched skips layers whose parameters differ in count or size instead of
+	// failing.
+	SkipMismatched bool
+
+	// NameMap renames the layers of the saved model to those of the network.
+	NameMap map[string]string
+}
+
+// LoadOptionFunc modifies the LoadOptions.
+type LoadOptionFunc func(*LoadOptions)
+
+// WithSkipMismatched skips layers whose parameters do not match instead of failing.
+func WithSkipMismatched() LoadOptionFunc {
+	return func(opts *LoadOptions) {
+		opts.SkipMismatched = true
+	}
+}
+
+// WithNameMap renames the saved layers, keyed by their saved name, before matching
+// them to the layers of the network.
+func WithNameMap(names map[string]string) LoadOptionFunc {
+	return func(opts *LoadOptions) {
+		opts.NameMap = names
+	}
+}
+
+// LoadReport lists what LoadWeights did with every layer, by name in the network.
+type LoadReport struct {
+	// Loaded holds the layers whose weights were loaded.
+	Loaded []string
+
+	// Mismatched holds the layers whose parameters differ from the saved ones.
+	Mismatched []string
+
+	// Missing holds the layers of the network with parameters which were not saved,
+	// and Unused the saved layers which are not in the network.
+	Missing []string
+	Unused  []string
+}
+
+// LoadWeights loads the weights saved by SaveModel into the layers of net with the
+// same name, ignoring the saved architecture, e.g. to warm start a network whose
+// architecture has drifted slightly. A layer is loaded only when all of its
+// parameters match the saved ones in count and size. Other mismatches are an error
+// unless WithSkipMismatched is given; no weights are written on error.
+func LoadWeights(r io.Reader, net Network, opts ...LoadOptionFunc) (*LoadReport, error) {
+	o := &LoadOptions{}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	m, err := decodeModel(r)
+	if err != nil {
+		return nil, err
+	}
+
+	saved := map[string]map[string][]float64{}
+	for key, w := range m.Weights {
+		layer, param := splitParameter(key)
+		if name, ok := o.NameMap[layer]; ok {
+			layer = name
+		}
+		if saved[layer] == nil {
+			saved[layer] = map[string][]float64{}
+		}
+		saved[layer][param] = w
+	}
+	params := map[string]map[string]int{}
+	for key, vol := range NamedParameters(net) {
+		layer, param := splitParameter(key)
+		if params[layer] == nil {
+			params[layer] = map[string]int{}
+		}
+		params[layer][param] = vol.Size()
+	}
+
+	report := &LoadReport{}
+	load := map[string][]float64{}
+	for layer, sizes := range params {
+		src, ok := saved[layer]
+		if !ok {
+			report.Missing = append(report.Missing, layer)
+		} else if !sameParameters(sizes, src) {
+			report.Mismatched = append(report.Mismatched, layer)
+		} else {
+			report.Loaded = append(report.Loaded, layer)
+			for param, w := range src {
+				load[layer+"/"+param] = w
+			}
+		}
+	}
+	for layer := range saved {
+		if _, ok := params[layer]; !ok {
+			report.Unused = append(report.Unused, layer)
+		}
+	}
+	for _, names := range [][]string{report.Loaded, report.Mismatched, report.Missing, report.Unused} {
+		sort.Strings(names)
+	}
+
+	if len(report.Mismatched) > 0 && !o.SkipMismatched {
+		return report, fmt.Errorf("parameters of layers %v do not match the saved ones", report.Mismatched)
+	}
+	if err := LoadNamedParameters(net, load, false); err != nil {
+		return report, err
+	}
+	return report, nil
+}
+
+// splitParameter splits a parameter name of NamedParameters into the layer name and
+// the parameter within the layer.
+func splitParameter(key string) (string, string) {
+	i := strings.LastIndex(key, "/")
+	if i < 0 {
+		return key, ""
+	}
+	return key[:i], key[i+1:]
+}
+
+// sameParameters reports whether the saved parameters of a layer match its sizes.
+func sameParameters(sizes map[string]int, saved map[string][]float64) bool {
+	if len(sizes) != len(saved) {
+		return false
+	}
+	for param, size := range sizes {
+		if w, ok := saved[param]; !ok || len(w) != size {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,594 @@
+// Package word2vec trains word embeddings on a token stream with the skip-gram or
+// CBOW models of word2vec (Mikolov et al.), using negative sampling:
+//
+//	emb, err := word2vec.Train(strings.Fields(text),
+//		word2vec.WithModel(word2vec.SkipGram), word2vec.WithDimensions(100))
+//	if err != nil {
+//		return err
+//	}
+//	neighbors := emb.Nearest("king", 10)
+//	err = emb.WriteText(f)
+//
+// Each training pair touches only a few rows of the embedding tables: the center
+// word, its context and the negative samples, and only those rows are updated. The
+// trained vectors feed a network through a layers.Embedding layer, built with
+// LayerConfig and loaded with CopyTo, whose backward pass likewise only updates the
+// rows of the tokens it saw.
+package word2vec
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/nathanleary/reticulum/layers"
+	"github.com/nathanleary/reticulum/volume"
+)
+
+// Model is a word2vec training objective.
+type Model string
+
+const (
+	// SkipGram predicts every context word from the center word. It is slower than
+	// CBOW and better for rare words.
+	SkipGram Model = "skipgram"
+
+	// CBOW predicts the center word from the mean of its context words.
+	CBOW Model = "cbow"
+)
+
+// OptionFunc modifies the Options of the training.
+type OptionFunc func(*Options)
+
+// Options configures the training of embeddings.
+type Options struct {
+	Model      Model
+	Dimensions int
+
+	// Window is the largest distance between the center word and a context word.
+	// The window of every center word is drawn uniformly from 1 to Window, which
+	// weights near context words more.
+	Window int
+
+	// Negative is the number of negative samples for every positive pair, drawn from
+	// the unigram distribution raised to the power of 3/4.
+	Negative int
+
+	// MinCount drops the words that occur fewer times from the vocabulary.
+	MinCount int
+
+	// Subsample is the threshold t for discarding frequent words, every occurrence
+	// of a word with frequency f being kept with probability sqrt(t/f) + t/f. Zero
+	// disables subsampling.
+	Subsample float64
+
+	// LearningRate decays linearly to MinLearningRate over the training.
+	LearningRate    float64
+	MinLearningRate float64
+
+	Epochs int
+
+	HasSeed bool
+	Seed    int64
+}
+
+// WithModel sets the training objective.
+func WithModel(m Model) OptionFunc {
+	return func(opts *Options) {
+		opts.Model = m
+	}
+}
+
+// WithDimensions sets the size of the embeddings.
+func WithDimensions(n int) OptionFunc {
+	return func(opts *Options) {
+		opts.Dimensions = n
+	}
+}
+
+// WithWindow sets the largest distance between the center and context words.
+func WithWindow(n int) OptionFunc {
+	return func(opts *Options) {
+		opts.Window = n
+	}
+}
+
+// WithNegative sets the number of negative samples for every positive pair.
+func WithNegative(n int) OptionFunc {
+	return func(opts *Options) {
+		opts.Negative = n
+	}
+}
+
+// WithMinCount sets the minimum number of occurrences of a word in the vocabulary.
+func WithMinCount(n int) OptionFunc {
+	return func(opts *Options) {
+		opts.MinCount = n
+	}
+}
+
+// WithSubsample sets the threshold for discarding frequent words.
+func WithSubsample(t float64) OptionFunc {
+	return func(opts *Options) {
+		opts.Subsample = t
+	}
+}
+
+// WithLearningRate sets the initial and final learning rates.
+func WithLearningRate(start, end float64) OptionFunc {
+	return func(opts *Options) {
+		opts.LearningRate = start
+		opts.MinLearningRate = end
+	}
+}
+
+// WithEpochs sets the number of passes over the tokens.
+func WithEpochs(n int) OptionFunc {
+	return func(opts *Options) {
+		opts.Epochs = n
+	}
+}
+
+// WithSeed makes the training reproducible.
+func WithSeed(seed int64) OptionFunc {
+	return func(opts *Options) {
+		opts.HasSeed = true
+		opts.Seed = seed
+	}
+}
+
+// Embeddings maps every word of a vocabulary to a vector.
+type Embeddings struct {
+	Words   []string
+	Vectors [][]float64
+
+	index map[string]int
+}
+
+// NewEmbeddings creates embeddings from the words and their vectors, which must all
+// have the same size.
+func NewEmbeddings(words []string, vectors [][]float64) (*Embeddings, error) {
+	if len(words) != len(vectors) {
+		return nil, fmt.Errorf("got %d vectors for %d words", len(vectors), len(words))
+	}
+	e := &Embeddings{Words: words, Vectors: vectors, index: make(map[string]int, len(words))}
+	for i, w := range words {
+		if len(vectors[i]) != len(vectors[0]) {
+			return nil, fmt.Errorf("vector of %q has size %d, expected %d", w, len(vectors[i]), len(vectors[0]))
+		} else if _, ok := e.index[w]; ok {
+			return nil, fmt.Errorf("duplicate word: %q", w)
+		}
+		e.index[w] = i
+	}
+	return e, nil
+}
+
+// Dimensions returns the size of the vectors.
+func (e *Embeddings) Dimensions() int {
+	if len(e.Vectors) == 0 {
+		return 0
+	}
+	return len(e.Vectors[0])
+}
+
+// Vector returns the vector of the word, and whether the word is in the vocabulary.
+func (e *Embeddings) Vector(word string) ([]float64, bool) {
+	i, ok := e.index[word]
+	if !ok {
+		return nil, false
+	}
+	return e.Vectors[i], true
+}
+
+// Similarity returns the cosine similarity of the vectors of two words, or 0 when
+// either is not in the vocabulary.
+func (e *Embeddings) Similarity(a, b string) float64 {
+	va, ok := e.Vector(a)
+	if !ok {
+		return 0
+	}
+	vb, ok := e.Vector(b)
+	if !ok {
+		return 0
+	}
+	return cosine(va, vb)
+}
+
+// Neighbor is a word with its cosine similarity to a query.
+type Neighbor struct {
+	Word       string
+	Similarity float64
+}
+
+// Nearest returns the k words most similar to the word, excluding itself, by
+// decreasing cosine similarity. It returns nil when the word is not in the
+// vocabulary.
+func (e *Embeddings) Nearest(word string, k int) []Neighbor {
+	v, ok := e.Vector(word)
+	if !ok {
+		return nil
+	}
+	return e.NearestVector(v, k, word)
+}
+
+// NearestVector returns the k words most similar to the vector, by decreasing cosine
+// similarity, leaving out the excluded words. For analogies, query e.g. the vector
+// of king - man + woman excluding the three words.
+func (e *Embeddings) NearestVector(v []float64, k int, exclude ...string) []Neighbor {
+	skip := map[string]bool{}
+	for _, w := range exclude {
+		skip[w] = true
+	}
+	var neighbors []Neighbor
+	for i, w := range e.Words {
+		if !skip[w] {
+			neighbors = append(neighbors, Neighbor{Word: w, Similarity: cosine(v, e.Vectors[i])})
+		}
+	}
+	sort.SliceStable(neighbors, func(i, j int) bool {
+		return neighbors[i].Similarity > neighbors[j].Similarity
+	})
+	if k < len(neighbors) {
+		neighbors = neighbors[:k]
+	}
+	return neighbors
+}
+
+// WriteText writes the embeddings in the text format of the original word2vec tool:
+// a header line with the number of words and the size of the vectors, then one line
+// per word with the word followed by its vector, separated by spaces.
+func (e *Embeddings) WriteText(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	if _, err := fmt.Fprintf(bw, "%d %d\n", len(e.Words), e.Dimensions()); err != nil {
+		return err
+	}
+	for i, word := range e.Words {
+		bw.WriteString(word)
+		for _, v := range e.Vectors[i] {
+			bw.WriteByte(' ')
+			bw.WriteString(strconv.FormatFloat(v, 'f', 6, 64))
+		}
+		if err := bw.WriteByte('\n'); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+// ReadText reads embeddings in the word2vec text format written by WriteText. The
+// header line is optional, as in the GloVe files.
+func ReadText(r io.Reader) (*Embeddings, error) {
+	var words []string
+	var vectors [][]float64
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		} else if line == 1 && len(fields) == 2 {
+			if _, err := strconv.Atoi(fields[0]); err == nil {
+				continue
+			}
+		}
+		vec := make([]float64, len(fields)-1)
+		for i, f := range fields[1:] {
+			v, err := strconv.ParseFloat(f, 64)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", line, err)
+			}
+			vec[i] = v
+		}
+		words = append(words, fields[0])
+		vectors = append(vectors, vec)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return NewEmbeddings(words, vectors)
+}
+
+// LayerConfig returns the config of an embedding layer holding a vector for every
+// word, to load the embeddings into with CopyTo:
+//
+//	net, err := reticulum.NewNetwork([]layers.LayerDef{
+//		{Type: layers.Input, Output: volume.NewDimensions(1, 1, seqLen)},
+//		{Type: layers.Embedding, LayerConfig: emb.LayerConfig()},
+//		...
+//	})
+//	err = emb.CopyTo(net.Layers()[1])
+func (e *Embeddings) LayerConfig(opts ...layers.LayerOptionFunc) layers.LayerConfig {
+	return layers.NewEmbeddingLayerConfig(len(e.Words), e.Dimensions(), opts...)
+}
+
+// CopyTo copies the vectors into the rows of an embedding layer of the same
+// vocabulary and size, where the index of a word is its position in Words.
+func (e *Embeddings) CopyTo(layer layers.Layer) error {
+	if layer == nil || layer.Type() != layers.Embedding {
+		return errors.New("not an embedding layer")
+	}
+	rows := layer.GetResponse()
+	if len(rows) != len(e.Words) {
+		return fmt.Errorf("layer has %d rows for %d words", len(rows), len(e.Words))
+	}
+	for i, r := range rows {
+		if len(r.Weights) != len(e.Vectors[i]) {
+			return fmt.Errorf("layer has vectors of size %d, expected %d", len(r.Weights), len(e.Vectors[i]))
+		}
+		copy(r.Weights, e.Vectors[i])
+	}
+	return nil
+}
+
+// Indices returns the input of an embedding layer loaded with CopyTo for the tokens:
+// the index of every token, or -1 for the tokens not in the vocabulary, which the
+// layer maps to zero vectors.
+func (e *Embeddings) Indices(tokens []string) *volume.Volume {
+	w := make([]float64, len(tokens))
+	for i, t := range tokens {
+		w[i] = -1
+		if idx, ok := e.index[t]; ok {
+			w[i] = float64(idx)
+		}
+	}
+	return volume.NewVolume(volume.NewDimensions(1, 1, len(tokens)), volume.WithWeights(w))
+}
+
+// Train learns embeddings of the words in tokens, e.g. the words of a corpus in
+// order. The returned embeddings are the input vectors of the model, with the
+// vocabulary sorted by decreasing frequency.
+func Train(tokens []string, optFuncs ...OptionFunc) (*Embeddings, error) {
+	opts := &Options{
+		Model:           SkipGram,
+		Dimensions:      100,
+		Window:          5,
+		Negative:        5,
+		MinCount:        5,
+		Subsample:       1e-3,
+		LearningRate:    0.025,
+		MinLearningRate: 0.0001,
+		Epochs:          5,
+	}
+	for _, optFn := range optFuncs {
+		optFn(opts)
+	}
+	if opts.Model != SkipGram && opts.Model != CBOW {
+		return nil, fmt.Errorf("unknown model: %q", opts.Model)
+	} else if opts.Dimensions <= 0 || opts.Window <= 0 || opts.Negative <= 0 || opts.Epochs <= 0 {
+		return nil, errors.New("dimensions, window, negative samples and epochs must be greater than 0")
+	}
+
+	seed := rand.Int63()
+	if opts.HasSeed {
+		seed = opts.Seed
+	}
+	rng := rand.New(rand.NewSource(seed))
+
+	words, counts := vocabulary(tokens, opts.MinCount)
+	if len(words) < 2 {
+		return nil, fmt.Errorf("vocabulary has %d words of at least %d occurrences, need 2", len(words), opts.MinCount)
+	}
+	index := make(map[string]int, len(words))
+	for i, w := range words {
+		index[w] = i
+	}
+	corpus := make([]int, 0, len(tokens))
+	for _, t := range tokens {
+		if i, ok := index[t]; ok {
+			corpus = append(corpus, i)
+		}
+	}
+
+	// the input vectors start small and random, the output vectors at zero
+	dim := opts.Dimensions
+	in := make([][]float64, len(words))
+	out := make([][]float64, len(words))
+	for i := range in {
+		in[i] = make([]float64, dim)
+		out[i] = make([]float64, dim)
+		for j := range in[i] {
+			in[i][j] = (rng.Float64() - 0.5) / float64(dim)
+		}
+	}
+
+	t := &sgns{
+		opts:  opts,
+		rng:   rng,
+		in:    in,
+		out:   out,
+		noise: noiseDistribution(counts),
+		keep:  keepProbabilities(counts, len(corpus), opts.Subsample),
+		grad:  make([]float64, dim),
+		mean:  make([]float64, dim),
+	}
+	total := float64(opts.Epochs * len(corpus))
+	for epoch := 0; epoch < opts.Epochs; epoch++ {
+		sentence := t.subsample(corpus)
+		for pos := range sentence {
+			progress := float64(epoch*len(corpus)+pos*len(corpus)/max(len(sentence), 1)) / total
+			t.lr = max(opts.LearningRate*(1-progress), opts.MinLearningRate)
+			t.step(sentence, pos)
+		}
+	}
+	return NewEmbeddings(words, in)
+}
+
+// vocabulary returns the words occurring at least minCount times by decreasing count,
+// ties broken alphabetically, with their counts.
+func vocabulary(tokens []string, minCount int) ([]string, []int) {
+	freq := map[string]int{}
+	for _, t := range tokens {
+		freq[t]++
+	}
+	var words []string
+	for w, c := range freq {
+		if c >= minCount {
+			words = append(words, w)
+		}
+	}
+	sort.Slice(words, func(i, j int) bool {
+		if freq[words[i]] != freq[words[j]] {
+			return freq[words[i]] > freq[words[j]]
+		}
+		return words[i] < words[j]
+	})
+	counts := make([]int, len(words))
+	for i, w := range words {
+		counts[i] = freq[w]
+	}
+	return words, counts
+}
+
+// noiseDistribution returns the cumulative unigram distribution raised to 3/4.
+func noiseDistribution(counts []int) []float64 {
+	cdf := make([]float64, len(counts))
+	var sum float64
+	for i, c := range counts {
+		sum += math.Pow(float64(c), 0.75)
+		cdf[i] = sum
+	}
+	for i := range cdf {
+		cdf[i] /= sum
+	}
+	return cdf
+}
+
+// keepProbabilities returns the probability of keeping an occurrence of every word
+// when subsampling with threshold t.
+func keepProbabilities(counts []int, total int, t float64) []float64 {
+	keep := make([]float64, len(counts))
+	for i, c := range counts {
+		keep[i] = 1
+		if t > 0 {
+			r := t / (float64(c) / float64(total))
+			keep[i] = min(math.Sqrt(r)+r, 1)
+		}
+	}
+	return keep
+}
+
+// sgns trains the embedding tables with negative sampling.
+type sgns struct {
+	opts    *Options
+	rng     *rand.Rand
+	lr      float64
+	in, out [][]float64
+	noise   []float64
+	keep    []float64
+
+	// scratch rows for the gradient of the input vectors and the CBOW context mean
+	grad, mean []float64
+}
+
+// subsample returns the corpus without the discarded occurrences of frequent words.
+func (t *sgns) subsample(corpus []int) []int {
+	sentence := make([]int, 0, len(corpus))
+	for _, w := range corpus {
+		if t.keep[w] >= 1 || t.rng.Float64() < t.keep[w] {
+			sentence = append(sentence, w)
+		}
+	}
+	return sentence
+}
+
+// step trains on the center word at pos and its context.
+func (t *sgns) step(sentence []int, pos int) {
+	window := 1 + t.rng.Intn(t.opts.Window)
+	lo, hi := max(pos-window, 0), min(pos+window, len(sentence)-1)
+	center := sentence[pos]
+
+	if t.opts.Model == SkipGram {
+		for c := lo; c <= hi; c++ {
+			if c == pos {
+				continue
+			}
+			// the context word's input vector predicts the center word, as in the
+			// original tool, which trains the same pairs as the other direction
+			v := t.in[sentence[c]]
+			t.train(v, center)
+			for j := range v {
+				v[j] += t.grad[j]
+			}
+		}
+		return
+	}
+
+	clear(t.mean)
+	n := 0
+	for c := lo; c <= hi; c++ {
+		if c != pos {
+			for j, x := range t.in[sentence[c]] {
+				t.mean[j] += x
+			}
+			n++
+		}
+	}
+	if n == 0 {
+		return
+	}
+	for j := range t.mean {
+		t.mean[j] /= float64(n)
+	}
+	t.train(t.mean, center)
+	for c := lo; c <= hi; c++ {
+		if c != pos {
+			v := t.in[sentence[c]]
+			for j := range v {
+				v[j] += t.grad[j] / float64(n)
+			}
+		}
+	}
+}
+
+// train updates the output vectors of the target and of the negative samples to
+// score the input vector h, and leaves the update of h in grad for the caller to
+// apply to the rows h came from.
+func (t *sgns) train(h []float64, target int) {
+	clear(t.grad)
+	for k := 0; k <= t.opts.Negative; k++ {
+		word, label := target, 1.0
+		if k > 0 {
+			word, label = t.sampleNoise(), 0
+			if word == target {
+				continue
+			}
+		}
+		u := t.out[word]
+		var dot float64
+		for j := range h {
+			dot += h[j] * u[j]
+		}
+		g := (label - sigmoid(dot)) * t.lr
+		for j := range h {
+			t.grad[j] += g * u[j]
+			u[j] += g * h[j]
+		}
+	}
+}
+
+func (t *sgns) sampleNoise() int {
+	return min(sort.SearchFloat64s(t.noise, t.rng.Float64()), len(t.noise)-1)
+}
+
+func sigmoid(x float64) float64 {
+	return 1 / (1 + math.Exp(-x))
+}
+
+func cosine(a, b []float64) float64 {
+	var dot, na, nb float64
+	for i := range a {
+		dot += a[i] * b[i]
+		na += a[i] * a[i]
+		nb += b[i] * b[i]
+	}
+	if na == 0 || nb == 0 {
+		return 0
+	}
+	return dot / math.Sqrt(na*nb)
+}
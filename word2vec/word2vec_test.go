@@ -0,0 +1,140 @@
+package word2vec
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/nathanleary/reticulum"
+	"github.com/nathanleary/reticulum/layers"
+	"github.com/nathanleary/reticulum/volume"
+)
+
+// corpus returns sentences drawn from two groups of four words, so that the words of
+// a group share their contexts.
+func corpus() []string {
+	r := rand.New(rand.NewSource(1))
+	var tokens []string
+	for s := 0; s < 300; s++ {
+		group := "ab"[s%2 : s%2+1]
+		for i := 0; i < 8; i++ {
+			tokens = append(tokens, fmt.Sprintf("%s%d", group, r.Intn(4)))
+		}
+	}
+	return tokens
+}
+
+func TestTrain(t *testing.T) {
+	for _, model := range []Model{SkipGram, CBOW} {
+		emb, err := Train(corpus(), WithModel(model), WithDimensions(8), WithWindow(2), WithMinCount(1),
+			WithSubsample(0), WithEpochs(5), WithSeed(1))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(emb.Words) != 8 || emb.Dimensions() != 8 {
+			t.Fatalf("%s: %d words of %d dimensions, want 8 of 8", model, len(emb.Words), emb.Dimensions())
+		}
+		if same, other := emb.Similarity("a0", "a1"), emb.Similarity("a0", "b1"); same <= other {
+			t.Errorf("%s: similarity %v within a group, %v across groups", model, same, other)
+		}
+		for _, n := range emb.Nearest("b2", 3) {
+			if !strings.HasPrefix(n.Word, "b") {
+				t.Errorf("%s: nearest words of b2 are %v", model, emb.Nearest("b2", 3))
+				break
+			}
+		}
+	}
+}
+
+func TestTrain_Errors(t *testing.T) {
+	for _, test := range []struct {
+		name   string
+		tokens []string
+		opts   []OptionFunc
+	}{
+		{"unknown model", corpus(), []OptionFunc{WithModel("glove")}},
+		{"no dimensions", corpus(), []OptionFunc{WithDimensions(0)}},
+		{"no epochs", corpus(), []OptionFunc{WithEpochs(0)}},
+		{"small vocabulary", []string{"a", "a", "b"}, []OptionFunc{WithMinCount(2)}},
+	} {
+		if _, err := Train(test.tokens, test.opts...); err == nil {
+			t.Errorf("%s: expected an error", test.name)
+		}
+	}
+}
+
+func TestText(t *testing.T) {
+	emb, err := NewEmbeddings([]string{"cat", "dog"}, [][]float64{{0.5, -1}, {0.25, 2}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := emb.WriteText(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if want := "2 2\ncat 0.500000 -1.000000\ndog 0.250000 2.000000\n"; buf.String() != want {
+		t.Errorf("wrote %q, want %q", buf.String(), want)
+	}
+	got, err := ReadText(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, ok := got.Vector("dog"); !ok || v[0] != 0.25 || v[1] != 2 {
+		t.Errorf("read dog as %v, want [0.25 2]", v)
+	}
+
+	for _, test := range []struct {
+		name string
+		text string
+	}{
+		{"bad number", "cat 0.5 x\n"},
+		{"uneven vectors", "cat 0.5 1\ndog 0.5\n"},
+		{"duplicate word", "cat 0.5\ncat 1\n"},
+	} {
+		if _, err := ReadText(strings.NewReader(test.text)); err == nil {
+			t.Errorf("%s: expected an error", test.name)
+		}
+	}
+}
+
+func TestEmbeddings_CopyTo(t *testing.T) {
+	emb, err := NewEmbeddings([]string{"cat", "dog", "fish"}, [][]float64{{1, 2}, {3, 4}, {5, 6}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	net, err := reticulum.NewNetwork([]layers.LayerDef{
+		{Type: layers.Input, Output: volume.NewDimensions(1, 1, 3)},
+		{Type: layers.Embedding, LayerConfig: emb.LayerConfig()},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := emb.CopyTo(net.Layers()[1]); err != nil {
+		t.Fatal(err)
+	}
+	out := net.Forward(emb.Indices([]string{"fish", "bird", "cat"}), false)
+	for i, w := range []float64{5, 6, 0, 0, 1, 2} {
+		if out.Weights()[i] != w {
+			t.Fatalf("embedded %v, want the vectors of fish, nothing and cat", out.Weights())
+		}
+	}
+
+	small, err := NewEmbeddings([]string{"cat"}, [][]float64{{1, 2}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []struct {
+		name  string
+		emb   *Embeddings
+		layer layers.Layer
+	}{
+		{"input layer", emb, net.Layers()[0]},
+		{"other vocabulary", small, net.Layers()[1]},
+	} {
+		if err := test.emb.CopyTo(test.layer); err == nil {
+			t.Errorf("%s: expected an error", test.name)
+		}
+	}
+}